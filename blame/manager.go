@@ -22,6 +22,7 @@ type Manager struct {
 	acceptedShares    map[RoundInfo][]string
 	acceptShareLocker *sync.Mutex
 	localPartyID      string
+	rateLimitedPeers  map[peer.ID]bool
 }
 
 func NewBlameManager() *Manager {
@@ -37,6 +38,7 @@ func NewBlameManager() *Manager {
 		lastMsgLocker:     &sync.RWMutex{},
 		acceptedShares:    make(map[RoundInfo][]string),
 		acceptShareLocker: &sync.Mutex{},
+		rateLimitedPeers:  make(map[peer.ID]bool),
 	}
 }
 
@@ -102,7 +104,10 @@ func (m *Manager) SetPartyInfo(partyMap *sync.Map, partyIDMap map[string]*btss.P
 		localParty = value.(btss.Party)
 		return false
 	})
-	m.localPartyID = localParty.PartyID().Id
+	// there is no local party when the signers sign with presignatures
+	if localParty != nil {
+		m.localPartyID = localParty.PartyID().Id
+	}
 }
 
 func (m *Manager) SetLastUnicastPeer(peerID peer.ID, roundInfo string) {
@@ -117,3 +122,10 @@ func (m *Manager) SetLastUnicastPeer(peerID peer.ID, roundInfo string) {
 		m.lastUnicastPeer[roundInfo] = l
 	}
 }
+
+// AddRateLimitEvidence record the peer whose messages keep being dropped over the inbound rate limits
+func (m *Manager) AddRateLimitEvidence(peerID peer.ID) {
+	m.lastMsgLocker.Lock()
+	defer m.lastMsgLocker.Unlock()
+	m.rateLimitedPeers[peerID] = true
+}
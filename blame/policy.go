@@ -3,6 +3,7 @@ package blame
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	btss "github.com/binance-chain/tss-lib/tss"
 	mapset "github.com/deckarep/golang-set"
@@ -114,6 +115,21 @@ func (m *Manager) GetBroadcastBlame(lastMessageType string) ([]Node, error) {
 	return blameNodes, nil
 }
 
+// this blame blames the committee members who cause the timeout in resharing broadcast message, since
+// each message of resharing is only sent by one of the committees, only the given senders are blamed
+func (m *Manager) GetCommitteeBroadcastBlame(lastMessageType string, senders map[string]*btss.PartyID) ([]Node, error) {
+	blamePeers, err := m.tssTimeoutBlame(lastMessageType, senders)
+	if err != nil {
+		m.logger.Error().Err(err).Msg("fail to get the blamed peers")
+		return nil, fmt.Errorf("fail to get the blamed peers %w", ErrTssTimeOut)
+	}
+	var blameNodes []Node
+	for _, el := range blamePeers {
+		blameNodes = append(blameNodes, NewNode(el, nil, nil))
+	}
+	return blameNodes, nil
+}
+
 // this blame blames the node who provide the wrong share
 func (m *Manager) TssWrongShareBlame(wiredMsg *messages.WireMessage) (string, error) {
 	shareOwner := wiredMsg.Routing.From
@@ -196,3 +212,23 @@ func (m *Manager) TssMissingShareBlame(rounds int) ([]Node, bool, error) {
 	}
 	return blameNodes, isUnicast, nil
 }
+
+// this blame blames the nodes that flood the node with more messages than the rate limits allow
+func (m *Manager) GetRateLimitBlame() ([]Node, error) {
+	m.lastMsgLocker.RLock()
+	peers := make([]string, 0, len(m.rateLimitedPeers))
+	for el := range m.rateLimitedPeers {
+		peers = append(peers, el.String())
+	}
+	m.lastMsgLocker.RUnlock()
+	sort.Strings(peers)
+	pubKeys, err := conversion.GetPubKeysFromPeerIDs(peers)
+	if err != nil {
+		return nil, err
+	}
+	var blameNodes []Node
+	for _, el := range pubKeys {
+		blameNodes = append(blameNodes, NewNode(el, nil, nil))
+	}
+	return blameNodes, nil
+}
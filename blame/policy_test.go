@@ -142,3 +142,21 @@ func (p *policyTestSuite) TestTssMissingShareBlame(c *C) {
 	sort.Strings(results)
 	c.Assert(results, DeepEquals, localTestPubKeys[2:])
 }
+
+func (p *policyTestSuite) TestGetRateLimitBlame(c *C) {
+	blames, err := p.blameMgr.GetRateLimitBlame()
+	c.Assert(err, IsNil)
+	c.Assert(blames, HasLen, 0)
+	for _, el := range []string{testPeers[2], testPeers[1], testPeers[2]} {
+		pID, err := peer.Decode(el)
+		c.Assert(err, IsNil)
+		p.blameMgr.AddRateLimitEvidence(pID)
+	}
+	blames, err = p.blameMgr.GetRateLimitBlame()
+	c.Assert(err, IsNil)
+	expected, err := conversion.GetPubKeysFromPeerIDs(testPeers[1:3])
+	c.Assert(err, IsNil)
+	c.Assert(blames, HasLen, 2)
+	c.Assert(blames[0].Pubkey, Equals, expected[0])
+	c.Assert(blames[1].Pubkey, Equals, expected[1])
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cosmos/cosmos-sdk/client/input"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+func usage() {
+	if _, err := fmt.Fprintf(os.Stderr, "usage: tss-migrate -home <tss home folder>\n"); err != nil {
+		panic(err)
+	}
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// tss-migrate import the local states the tss saved as files in the home folder into the leveldb backend, the
+// tss should be stopped while it runs, and started with -state-backend leveldb afterwards
+func main() {
+	home := flag.String("home", "", "home folder the tss stores the keygen state files in")
+	flag.Usage = usage
+	flag.Parse()
+	if len(*home) == 0 {
+		usage()
+	}
+	conversion.SetupBech32Prefix()
+
+	// the local states are encrypted with the key derived from the node secret key, or the state passphrase
+	inBuf := bufio.NewReader(os.Stdin)
+	priKeyBytes, err := input.GetPassword("input node secret key:", inBuf)
+	if err != nil {
+		fmt.Printf("error in get the secret key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	priKey, err := conversion.GetPriKey(priKeyBytes)
+	if err != nil {
+		fmt.Printf("invalid secret key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	passphrase := ""
+	header, err := storage.LoadStateKeyHeader(*home)
+	if err != nil {
+		fmt.Printf("fail to read the state key header: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if header != nil {
+		passphrase, err = input.GetPassword("input state passphrase:", inBuf)
+		if err != nil {
+			fmt.Printf("error in get the state passphrase: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	sk, err := storage.GetLocalStateKey(*home, priKey, passphrase)
+	if err != nil {
+		fmt.Printf("fail to get the local state key: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ldm, err := storage.NewLevelDBStateMgr(*home, sk)
+	if err != nil {
+		fmt.Printf("fail to open the database: %s\n", err.Error())
+		os.Exit(1)
+	}
+	defer func() {
+		if err := ldm.Close(); err != nil {
+			fmt.Printf("fail to close the database: %s\n", err.Error())
+		}
+	}()
+	num, err := storage.MigrateFileState(*home, sk, ldm)
+	if err != nil {
+		fmt.Printf("fail to migrate the local states, nothing is imported: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("%d local states are imported\n", num)
+}
@@ -4,50 +4,101 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
-	"os"
+	"path/filepath"
 
-	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/crypto/vss"
 	"github.com/btcsuite/btcd/btcec"
 	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
-type (
-	KeygenLocalState struct {
-		PubKey          string                    `json:"pub_key"`
-		LocalData       keygen.LocalPartySaveData `json:"local_data"`
-		ParticipantKeys []string                  `json:"participant_keys"` // the paticipant of last key gen
-		LocalPartyKey   string                    `json:"local_party_key"`
+// loadLocalState read the local state file of a node, the file is decrypted with the key derived from the node
+// secret key, or from the state passphrase when the folder of the file has a state key header. The files saved
+// before the local states are encrypted are read without any key
+func loadLocalState(file string, priKey tcrypto.PrivKey, passphrase string) (storage.KeygenLocalState, error) {
+	var sk []byte
+	if priKey != nil {
+		var err error
+		sk, err = storage.GetLocalStateKey(filepath.Dir(file), priKey, passphrase)
+		if err != nil {
+			return storage.KeygenLocalState{}, fmt.Errorf("fail to get the key of file(%s): %w", file, err)
+		}
 	}
-)
+	localState, err := storage.ReadLocalStateFile(file, sk)
+	if err != nil {
+		return storage.KeygenLocalState{}, fmt.Errorf("fail to read file(%s): %w", file, err)
+	}
+	return localState, nil
+}
 
-func getTssSecretFile(file string) (KeygenLocalState, error) {
-	_, err := os.Stat(file)
+// recoverKey reconstruct the private key of the pool from the first num shares, all the shares must be valid
+// shares of the same ECDSA pool, and the key is only returned when it derives the pub key of the pool
+func recoverKey(localStates []storage.KeygenLocalState, num int) (*btcec.PrivateKey, error) {
+	if len(localStates) == 0 {
+		return nil, errors.New("no local state is given")
+	}
+	pubKey := localStates[0].PubKey
+	for _, el := range localStates {
+		if el.PubKey != pubKey {
+			return nil, fmt.Errorf("the local states belong to different pools(%s, %s)", pubKey, el.PubKey)
+		}
+		if el.GetAlgo() != common.ECDSA {
+			return nil, fmt.Errorf("only the keys of ECDSA pools can be recovered, %s is %s", pubKey, el.GetAlgo())
+		}
+		if result := el.Verify(); !result.Valid {
+			return nil, fmt.Errorf("the share of %s is invalid: %v", el.LocalPartyKey, result.Errors)
+		}
+	}
+	threshold, err := localStates[0].GetThreshold()
 	if err != nil {
-		return KeygenLocalState{}, err
+		return nil, fmt.Errorf("fail to get the threshold: %w", err)
 	}
-	buf, err := ioutil.ReadFile(file)
+	if num == 0 {
+		num = threshold + 1
+	}
+	if num < threshold+1 {
+		return nil, fmt.Errorf("%d shares are needed to recover the key, %d are asked for", threshold+1, num)
+	}
+	if num > len(localStates) {
+		return nil, fmt.Errorf("%d shares are asked for, only %d are given", num, len(localStates))
+	}
+	vssShares := make(vss.Shares, num)
+	for i, el := range localStates[:num] {
+		vssShares[i] = &vss.Share{
+			Threshold: threshold,
+			ID:        el.LocalData.ShareID,
+			Share:     el.LocalData.Xi,
+		}
+	}
+	secret, err := vssShares.ReConstruct()
 	if err != nil {
-		return KeygenLocalState{}, fmt.Errorf("file to read from file(%s): %w", file, err)
+		return nil, fmt.Errorf("fail to reconstruct the key: %w", err)
 	}
-	var localState KeygenLocalState
-	if err := json.Unmarshal(buf, &localState); nil != err {
-		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), padTo32(secret.Bytes()))
+	recovered, _, err := getTssPubKey(privKey.PubKey().X, privKey.PubKey().Y)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get the pub key of the recovered key: %w", err)
 	}
-	return localState, nil
+	if recovered != pubKey {
+		return nil, fmt.Errorf("the recovered key derives %s, not %s", recovered, pubKey)
+	}
+	return privKey, nil
 }
 
-func setupBech32Prefix() {
-	config := sdk.GetConfig()
-	// thorchain will import go-tss as a library , thus this is not needed, we copy the prefix here to avoid go-tss to import thorchain
-	config.SetBech32PrefixForAccount("thor", "thorpub")
-	config.SetBech32PrefixForValidator("thorv", "thorvpub")
-	config.SetBech32PrefixForConsensusNode("thorc", "thorcpub")
+func padTo32(buf []byte) []byte {
+	if len(buf) >= 32 {
+		return buf
+	}
+	padded := make([]byte, 32)
+	copy(padded[32-len(buf):], buf)
+	return padded
 }
 
 func getTssPubKey(x, y *big.Int) (string, sdk.AccAddress, error) {
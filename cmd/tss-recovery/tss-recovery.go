@@ -1,72 +1,221 @@
 package main
 
 import (
+	"bufio"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
-	"github.com/binance-chain/tss-lib/crypto/vss"
-	. "github.com/decred/dcrd/dcrec/secp256k1"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/cosmos/cosmos-sdk/client/input"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+const (
+	outputKeystore = "keystore"
+	outputWIF      = "wif"
+	outputHex      = "hex"
 )
 
+func usage() {
+	if _, err := fmt.Fprintf(os.Stderr, "usage: tss-recovery [flags] <localstate file>...\n"); err != nil {
+		panic(err)
+	}
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
 func main() {
-	n := *(flag.Int("n", 3, "signing party size"))
-	threshold := n - 1
+	n := flag.Int("n", 0, "number of shares combined to recover the key, 0 uses the threshold of the pool")
+	shares := flag.String("shares", "", "comma separated indexes of the local state files to combine, all of them by default")
+	keyFiles := flag.String("key-files", "", "comma separated files with the node secret key of each selected local state file, prompted when empty")
+	output := flag.String("output", outputKeystore, "how the recovered key is written out, keystore, wif or hex")
+	danger := flag.Bool("danger-print-secret", false, "allow the wif and hex outputs, which print the private key to stdout")
 	export := flag.String("export", "", "path to export keyfile")
-	password := flag.String("password", "", "encryption password for keyfile")
+	password := flag.String("password", "", "encryption password for keyfile, prompted when empty")
+	flag.Usage = usage
 	flag.Parse()
-	files := flag.Args()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	switch *output {
+	case outputKeystore:
+		if len(*export) == 0 {
+			fail("-export is required by the keystore output")
+		}
+	case outputWIF, outputHex:
+		if !*danger {
+			fail("the %s output prints the private key to stdout, add -danger-print-secret to allow it", *output)
+		}
+	default:
+		fail("unknown output(%s)", *output)
+	}
+	conversion.SetupBech32Prefix()
 
-	setupBech32Prefix()
-	allSecret := make([]KeygenLocalState, len(files))
+	files, err := selectFiles(flag.Args(), *shares)
+	if err != nil {
+		fail("%s", err)
+	}
+	var keys []string
+	if len(*keyFiles) > 0 {
+		keys = strings.Split(*keyFiles, ",")
+		if len(keys) != len(files) {
+			fail("%d key files are given for %d local state files", len(keys), len(files))
+		}
+	}
+	inBuf := bufio.NewReader(os.Stdin)
+	localStates := make([]storage.KeygenLocalState, len(files))
 	for i, f := range files {
-		tssSecret, err := getTssSecretFile(f)
+		keyFile := ""
+		if len(keys) > 0 {
+			keyFile = keys[i]
+		}
+		priKey, passphrase, err := getNodeKey(f, keyFile, inBuf)
 		if err != nil {
-			fmt.Printf("---%v\n", err)
+			fail("%s", err)
+		}
+		localStates[i], err = loadLocalState(f, priKey, passphrase)
+		if err != nil {
+			fail("%s", err)
 		}
-		allSecret[i] = tssSecret
 	}
 
-	vssShares := make(vss.Shares, len(allSecret))
+	privKey, err := recoverKey(localStates, *n)
+	if err != nil {
+		fail("fail to recover the key: %s", err)
+	}
+	_, address, err := getTssPubKey(privKey.PubKey().X, privKey.PubKey().Y)
+	if err != nil {
+		fail("fail to get the address: %s", err)
+	}
+	fmt.Printf("recovered the key of %s\n", localStates[0].PubKey)
+	fmt.Printf("address: %s\n", address)
+
+	if err := writeKey(privKey, *output, *export, *password, inBuf); err != nil {
+		fail("%s", err)
+	}
+}
 
-	for i, el := range allSecret {
-		share := vss.Share{
-			Threshold: threshold,
-			ID:        el.LocalData.ShareID,
-			Share:     el.LocalData.Xi,
+// selectFiles return the local state files picked by the comma separated indexes, all of them when no index is
+// given
+func selectFiles(files []string, shares string) ([]string, error) {
+	if len(shares) == 0 {
+		return files, nil
+	}
+	var selected []string
+	picked := make(map[int]bool)
+	for _, el := range strings.Split(shares, ",") {
+		idx, err := strconv.Atoi(strings.TrimSpace(el))
+		if err != nil || idx < 0 || idx >= len(files) {
+			return nil, fmt.Errorf("invalid share index(%s) of %d local state files", el, len(files))
+		}
+		if picked[idx] {
+			return nil, fmt.Errorf("share index %d is given more than once", idx)
 		}
-		vssShares[i] = &share
+		picked[idx] = true
+		selected = append(selected, files[idx])
 	}
+	return selected, nil
+}
 
-	tssPrivateKey, err := vssShares[:n].ReConstruct()
+// getNodeKey return the node secret key the local state file is encrypted with, read from the key file or the
+// stdin, and the state passphrase when the folder of the file has a state key header. An empty secret key read
+// from the stdin is only good for the files saved before the local states are encrypted
+func getNodeKey(file, keyFile string, inBuf *bufio.Reader) (tcrypto.PrivKey, string, error) {
+	var priKeyBytes string
+	var err error
+	if len(keyFile) > 0 {
+		buf, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("fail to read the key file(%s): %w", keyFile, err)
+		}
+		priKeyBytes = strings.TrimSpace(string(buf))
+	} else {
+		priKeyBytes, err = input.GetPassword(fmt.Sprintf("input node secret key of %s:", file), inBuf)
+		if err != nil {
+			return nil, "", fmt.Errorf("error in get the secret key: %w", err)
+		}
+	}
+	if len(priKeyBytes) == 0 {
+		return nil, "", nil
+	}
+	priKey, err := conversion.GetPriKey(priKeyBytes)
 	if err != nil {
-		fmt.Printf("error in tss verify")
+		return nil, "", fmt.Errorf("invalid secret key of %s: %w", file, err)
 	}
-
-	privKey := NewPrivateKey(tssPrivateKey)
-
-	pk := privKey.PubKey()
-	thorchainpk, address, err := getTssPubKey(pk.X, pk.Y)
+	header, err := storage.LoadStateKeyHeader(filepath.Dir(file))
+	if err != nil {
+		return nil, "", err
+	}
+	if header == nil {
+		return priKey, "", nil
+	}
+	passphrase, err := input.GetPassword(fmt.Sprintf("input state passphrase of %s:", file), inBuf)
 	if err != nil {
-		fmt.Printf("--->%v", err)
+		return nil, "", fmt.Errorf("error in get the state passphrase: %w", err)
 	}
-	fmt.Printf("---recoverd sk:%v\n", privKey)
-	fmt.Printf("---recoverd pk:%v\n", thorchainpk)
-	fmt.Printf("-------%v\n", address)
+	return priKey, passphrase, nil
+}
 
-	if len(*export) > 0 && len(*password) >= 8 {
-		keyfile, err := exportKeyStore(privKey.Serialize(), *password)
+// writeKey write the recovered key out the given way, only the wif and hex outputs print it to stdout
+func writeKey(privKey *btcec.PrivateKey, output, export, password string, inBuf *bufio.Reader) error {
+	switch output {
+	case outputWIF:
+		wif, err := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true)
 		if err != nil {
-			fmt.Printf("--->%v", err)
+			return fmt.Errorf("fail to encode the key as wif: %w", err)
 		}
-
-		jsonString, _ := json.Marshal(keyfile)
-		err = ioutil.WriteFile(*export, jsonString, os.ModePerm)
+		fmt.Printf("private key(wif): %s\n", wif.String())
+		return nil
+	case outputHex:
+		fmt.Printf("private key(hex): %s\n", hex.EncodeToString(privKey.Serialize()))
+		return nil
+	}
+	if len(password) == 0 {
+		var err error
+		password, err = input.GetPassword("input keystore password:", inBuf)
 		if err != nil {
-			fmt.Printf("--->%v", err)
+			return fmt.Errorf("error in get the keystore password: %w", err)
+		}
+		confirmed, err := input.GetPassword("repeat keystore password:", inBuf)
+		if err != nil {
+			return fmt.Errorf("error in get the keystore password: %w", err)
+		}
+		if confirmed != password {
+			return errors.New("the keystore passwords do not match")
 		}
-		fmt.Printf("---wrote to: %+v\n", *export)
 	}
+	if len(password) < 8 {
+		return errors.New("the keystore password must have at least 8 characters")
+	}
+	keyfile, err := exportKeyStore(privKey.Serialize(), password)
+	if err != nil {
+		return fmt.Errorf("fail to create the keystore: %w", err)
+	}
+	buf, err := json.Marshal(keyfile)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the keystore: %w", err)
+	}
+	if err := ioutil.WriteFile(export, buf, 0o600); err != nil {
+		return fmt.Errorf("fail to write the keystore: %w", err)
+	}
+	fmt.Printf("wrote the keystore to: %s\n", export)
+	return nil
 }
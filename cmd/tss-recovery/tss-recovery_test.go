@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+const testPriKey = "ZThiMDAxOTk2MDc4ODk3YWE0YThlMjdkMWY0NjA1MTAwZDgyNDkyYzdhNmMwZWQ3MDBhMWIyMjNmNGMzYjVhYg=="
+
+func TestPackage(t *testing.T) { TestingT(t) }
+
+type RecoveryTestSuite struct {
+	localStates []storage.KeygenLocalState
+}
+
+var _ = Suite(&RecoveryTestSuite{})
+
+func (s *RecoveryTestSuite) SetUpSuite(c *C) {
+	conversion.SetupBech32Prefix()
+	for i := 0; i < 4; i++ {
+		// the test data is saved before the local states are encrypted
+		localState, err := loadLocalState(fmt.Sprintf("../../test_data/keysign_data/%d.json", i), nil, "")
+		c.Assert(err, IsNil)
+		s.localStates = append(s.localStates, localState)
+	}
+}
+
+func (s *RecoveryTestSuite) TestLoadEncryptedLocalState(c *C) {
+	priKey, err := conversion.GetPriKey(testPriKey)
+	c.Assert(err, IsNil)
+	folder := c.MkDir()
+	fsm, err := storage.NewFileStateMgr(folder, storage.GetStateKey(priKey))
+	c.Assert(err, IsNil)
+	c.Assert(fsm.SaveLocalState(s.localStates[0]), IsNil)
+	file := filepath.Join(folder, "localstate-"+s.localStates[0].PubKey+".json")
+
+	localState, err := loadLocalState(file, priKey, "")
+	c.Assert(err, IsNil)
+	c.Assert(localState.LocalData.Xi, DeepEquals, s.localStates[0].LocalData.Xi)
+	_, err = loadLocalState(file, nil, "")
+	c.Assert(err, NotNil)
+}
+
+func (s *RecoveryTestSuite) TestRecoverKey(c *C) {
+	// any threshold+1 of the 4 shares recover the key of the pool
+	for skip := 0; skip < 4; skip++ {
+		var localStates []storage.KeygenLocalState
+		for i, el := range s.localStates {
+			if i != skip {
+				localStates = append(localStates, el)
+			}
+		}
+		privKey, err := recoverKey(localStates, 0)
+		c.Assert(err, IsNil)
+		c.Assert(privKey, NotNil)
+	}
+	_, err := recoverKey(s.localStates, 4)
+	c.Assert(err, IsNil)
+	_, err = recoverKey(s.localStates, 2)
+	c.Assert(err, ErrorMatches, "3 shares are needed to recover the key, 2 are asked for")
+	_, err = recoverKey(s.localStates[:2], 0)
+	c.Assert(err, ErrorMatches, "3 shares are asked for, only 2 are given")
+	_, err = recoverKey(nil, 0)
+	c.Assert(err, NotNil)
+
+	other := s.localStates[1]
+	other.PubKey = "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	_, err = recoverKey([]storage.KeygenLocalState{s.localStates[0], other, s.localStates[2]}, 0)
+	c.Assert(err, ErrorMatches, "the local states belong to different pools.*")
+}
+
+func (s *RecoveryTestSuite) TestSelectFiles(c *C) {
+	files := []string{"a", "b", "c", "d"}
+	selected, err := selectFiles(files, "")
+	c.Assert(err, IsNil)
+	c.Assert(selected, DeepEquals, files)
+	selected, err = selectFiles(files, "3, 0,2")
+	c.Assert(err, IsNil)
+	c.Assert(selected, DeepEquals, []string{"d", "a", "c"})
+	_, err = selectFiles(files, "1,4")
+	c.Assert(err, NotNil)
+	_, err = selectFiles(files, "1,1")
+	c.Assert(err, NotNil)
+}
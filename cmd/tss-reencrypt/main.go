@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cosmos/cosmos-sdk/client/input"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+func usage() {
+	if _, err := fmt.Fprintf(os.Stderr, "usage: tss-reencrypt -home <tss home folder>\n"); err != nil {
+		panic(err)
+	}
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// tss-reencrypt encrypt the local state files and the local state database in the home folder with the key derived
+// from the state passphrase instead of the key derived from the node secret key, the tss should be stopped while it
+// runs, and it asks for the passphrase when it starts afterwards. It can be run again if it is interrupted
+func main() {
+	home := flag.String("home", "", "home folder the tss stores the keygen state files in")
+	flag.Usage = usage
+	flag.Parse()
+	if len(*home) == 0 {
+		usage()
+	}
+	conversion.SetupBech32Prefix()
+
+	inBuf := bufio.NewReader(os.Stdin)
+	priKeyBytes, err := input.GetPassword("input node secret key:", inBuf)
+	if err != nil {
+		fmt.Printf("error in get the secret key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	priKey, err := conversion.GetPriKey(priKeyBytes)
+	if err != nil {
+		fmt.Printf("invalid secret key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	passphrase, err := input.GetPassword("input state passphrase:", inBuf)
+	if err != nil {
+		fmt.Printf("error in get the state passphrase: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	header, err := storage.LoadStateKeyHeader(*home)
+	if err != nil {
+		fmt.Printf("fail to read the state key header: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if header == nil {
+		confirmed, err := input.GetPassword("repeat state passphrase:", inBuf)
+		if err != nil {
+			fmt.Printf("error in get the state passphrase: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if confirmed != passphrase {
+			fmt.Println("the passphrases do not match")
+			os.Exit(1)
+		}
+		newHeader, err := storage.NewStateKeyHeader()
+		if err != nil {
+			fmt.Printf("fail to create the state key header: %s\n", err.Error())
+			os.Exit(1)
+		}
+		header = &newHeader
+	}
+	newKey, err := header.DeriveKey(passphrase)
+	if err != nil {
+		fmt.Printf("fail to derive the state key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	// the header is saved first, so the files already re-encrypted can be read when it is interrupted
+	if err := storage.SaveStateKeyHeader(*home, *header); err != nil {
+		fmt.Printf("fail to save the state key header: %s\n", err.Error())
+		os.Exit(1)
+	}
+	num, err := storage.ReEncryptLocalStates(*home, storage.GetStateKey(priKey), newKey)
+	if err != nil {
+		fmt.Printf("fail to re-encrypt the local states after %d files, run it again: %s\n", num, err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("%d files and records are re-encrypted\n", num)
+}
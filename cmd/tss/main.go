@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -13,20 +15,26 @@ import (
 	"github.com/cosmos/cosmos-sdk/client/input"
 	golog "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-peerstore/addr"
+	tcrypto "github.com/tendermint/tendermint/crypto"
 	"gitlab.com/thorchain/binance-sdk/common/types"
 
 	"gitlab.com/thorchain/tss/go-tss/common"
 	"gitlab.com/thorchain/tss/go-tss/conversion"
 	"gitlab.com/thorchain/tss/go-tss/p2p"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 	"gitlab.com/thorchain/tss/go-tss/tss"
 )
 
 var (
-	help       bool
-	logLevel   string
-	pretty     bool
-	baseFolder string
-	tssAddr    string
+	help         bool
+	logLevel     string
+	pretty       bool
+	baseFolder   string
+	tssAddr      string
+	genPreParams int
+	exportShare  string
+	importShare  string
+	shareFile    string
 )
 
 func main() {
@@ -58,6 +66,31 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// the local states are encrypted with the key derived from the passphrase once they are re-encrypted
+	header, err := storage.LoadStateKeyHeader(baseFolder)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if header != nil {
+		tssConf.StatePassphrase, err = input.GetPassword("input state passphrase:", inBuf)
+		if err != nil {
+			fmt.Printf("error in get the state passphrase: %s\n", err.Error())
+			return
+		}
+	}
+	if genPreParams > 0 {
+		if err := tss.GeneratePreParams(baseFolder, priKey, tssConf, genPreParams); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d pre-parameters are saved\n", genPreParams)
+		return
+	}
+	if len(exportShare) > 0 || len(importShare) > 0 {
+		if err := backupShare(priKey, tssConf, inBuf); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	// init tss module
 	tss, err := tss.NewTss(
 		addr.AddrList(p2pConf.BootstrapPeers),
@@ -68,10 +101,16 @@ func main() {
 		tssConf,
 		nil,
 		p2pConf.ExternalIP,
+		p2pConf.BroadcastMode,
 	)
 	if nil != err {
 		log.Fatal(err)
 	}
+	if p2pConf.GatePeers {
+		if err := tss.SetAllowedPeers(p2pConf.AllowedPubKeys); err != nil {
+			log.Fatal(err)
+		}
+	}
 	s := NewTssHttpServer(tssAddr, tss)
 	go func() {
 		if err := s.Start(); err != nil {
@@ -85,6 +124,46 @@ func main() {
 	fmt.Println(s.Stop())
 }
 
+// backupShare export the share of the pool given by export-share to share-file, or import the share backup given
+// by import-share, the backup is encrypted with its own passphrase
+func backupShare(priKey tcrypto.PrivKey, tssConf common.TssConfig, inBuf *bufio.Reader) error {
+	passphrase, err := input.GetPassword("input share backup passphrase:", inBuf)
+	if err != nil {
+		return fmt.Errorf("fail to get the share backup passphrase: %w", err)
+	}
+	if len(importShare) > 0 {
+		buf, err := ioutil.ReadFile(importShare)
+		if err != nil {
+			return fmt.Errorf("fail to read the share backup: %w", err)
+		}
+		pubKey, err := tss.ImportShare(baseFolder, priKey, tssConf, buf, passphrase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("the share of %s is imported\n", pubKey)
+		return nil
+	}
+	if len(shareFile) == 0 {
+		return errors.New("share-file is required to export the share")
+	}
+	confirmed, err := input.GetPassword("repeat share backup passphrase:", inBuf)
+	if err != nil {
+		return fmt.Errorf("fail to get the share backup passphrase: %w", err)
+	}
+	if confirmed != passphrase {
+		return errors.New("the share backup passphrases do not match")
+	}
+	buf, err := tss.ExportShare(baseFolder, priKey, tssConf, exportShare, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(shareFile, buf, 0o600); err != nil {
+		return fmt.Errorf("fail to write the share backup: %w", err)
+	}
+	fmt.Printf("the share of %s is exported to %s\n", exportShare, shareFile)
+	return nil
+}
+
 // parseFlags - Parses the cli flags
 func parseFlags() (tssConf common.TssConfig, p2pConf p2p.Config) {
 	// we setup the configure for the general configuration
@@ -93,12 +172,21 @@ func parseFlags() (tssConf common.TssConfig, p2pConf p2p.Config) {
 	flag.StringVar(&logLevel, "loglevel", "info", "Log Level")
 	flag.BoolVar(&pretty, "pretty-log", false, "Enables unstructured prettified logging. This is useful for local debugging")
 	flag.StringVar(&baseFolder, "home", "", "home folder to store the keygen state file")
+	flag.IntVar(&genPreParams, "gen-preparams", 0, "generate the given number of pre-parameters into the home folder and exit")
+	flag.StringVar(&exportShare, "export-share", "", "export the share of the given pool to share-file and exit")
+	flag.StringVar(&importShare, "import-share", "", "import the share in the given backup file and exit")
+	flag.StringVar(&shareFile, "share-file", "", "file the exported share is written to")
 
 	// we setup the Tss parameter configuration
 	flag.DurationVar(&tssConf.KeyGenTimeout, "gentimeout", 30*time.Second, "keygen timeout")
 	flag.DurationVar(&tssConf.KeySignTimeout, "signtimeout", 30*time.Second, "keysign timeout")
 	flag.DurationVar(&tssConf.PreParamTimeout, "preparamtimeout", 5*time.Minute, "pre-parameter generation timeout")
+	flag.IntVar(&tssConf.PreParamsPoolSize, "preparams-pool", 3, "number of pre-parameters generated ahead of the keygens")
+	flag.IntVar(&tssConf.PreParamsConcurrency, "preparams-concurrency", 1, "number of pre-parameters generated at the same time")
 	flag.BoolVar(&tssConf.EnableMonitor, "enablemonitor", true, "enable the tss monitor")
+	flag.StringVar(&tssConf.StateBackend, "state-backend", "file", "where the local states are saved, file or leveldb")
+	flag.IntVar(&tssConf.StateBackups, "state-backups", storage.DefaultLocalStateBackups, "number of the older generations kept in the history of the local states, -1 keeps only the pinned one")
+	flag.DurationVar(&tssConf.ShareVerifyInterval, "share-verify-interval", 0, "how often the shares of all the pools are verified, 0 disables it")
 
 	// we setup the p2p network configuration
 	flag.StringVar(&p2pConf.RendezvousString, "rendezvous", "Asgard",
@@ -106,6 +194,10 @@ func parseFlags() (tssConf common.TssConfig, p2pConf p2p.Config) {
 	flag.IntVar(&p2pConf.Port, "p2p-port", 6668, "listening port local")
 	flag.StringVar(&p2pConf.ExternalIP, "external-ip", "", "external IP of this node")
 	flag.Var(&p2pConf.BootstrapPeers, "peer", "Adds a peer multiaddress to the bootstrap list")
+	p2pConf.BroadcastMode = p2p.BroadcastUnicast
+	flag.Var(&p2pConf.BroadcastMode, "broadcast-mode", "how the messages to all the parties are sent, unicast or gossip")
+	flag.BoolVar(&p2pConf.GatePeers, "gate-peers", false, "admit only the allowed peers and the participants of the known pools to connect")
+	flag.Var(&p2pConf.AllowedPubKeys, "allowed-peer", "Adds a node pub key to the allowlist of the connection gating")
 	flag.Parse()
 	return
 }
@@ -2,18 +2,28 @@ package main
 
 import (
 	"errors"
+	"strconv"
 
 	"gitlab.com/thorchain/tss/go-tss/blame"
 	"gitlab.com/thorchain/tss/go-tss/common"
 	"gitlab.com/thorchain/tss/go-tss/conversion"
 	"gitlab.com/thorchain/tss/go-tss/keygen"
 	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
 type MockTssServer struct {
-	failToStart   bool
-	failToKeyGen  bool
-	failToKeySign bool
+	failToStart    bool
+	failToKeyGen   bool
+	failToKeySign  bool
+	failToReshare  bool
+	failToRefresh  bool
+	failToPreSign  bool
+	localStates    []storage.LocalStateInfo
+	versions       []storage.LocalStateVersion
+	verifications  []storage.ShareVerification
+	allowedPubKeys []string
 }
 
 func (mts *MockTssServer) Start() error {
@@ -44,3 +54,105 @@ func (mts *MockTssServer) KeySign(req keysign.Request) (keysign.Response, error)
 	newSig := keysign.NewSignature("", "", "", "")
 	return keysign.NewResponse([]keysign.Signature{newSig}, common.Success, blame.Blame{}), nil
 }
+
+func (mts *MockTssServer) Reshare(req resharing.Request) (resharing.Response, error) {
+	if mts.failToReshare {
+		return resharing.Response{}, errors.New("you ask for it")
+	}
+	return resharing.NewResponse(req.PoolPubKey, "whatever", common.Success, blame.Blame{}), nil
+}
+
+func (mts *MockTssServer) Refresh(req resharing.RefreshRequest) (resharing.Response, error) {
+	if mts.failToRefresh {
+		return resharing.Response{}, errors.New("you ask for it")
+	}
+	return resharing.NewResponse(req.PoolPubKey, "whatever", common.Success, blame.Blame{}), nil
+}
+
+func (mts *MockTssServer) PreSign(req keysign.PreSignRequest) (keysign.PreSignResponse, error) {
+	if mts.failToPreSign {
+		return keysign.PreSignResponse{}, errors.New("you ask for it")
+	}
+	ids := make([]string, req.Number)
+	for idx := range ids {
+		ids[idx] = strconv.Itoa(idx)
+	}
+	return keysign.NewPreSignResponse(ids, common.Success, blame.Blame{}), nil
+}
+
+func (mts *MockTssServer) GetPreParamsBuffered() int {
+	return 2
+}
+
+func (mts *MockTssServer) ListLocalStates() ([]storage.LocalStateInfo, error) {
+	return mts.localStates, nil
+}
+
+func (mts *MockTssServer) GetLocalStateInfo(pubKey string) (storage.LocalStateInfo, error) {
+	for _, el := range mts.localStates {
+		if el.PubKey == pubKey {
+			return el, nil
+		}
+	}
+	return storage.LocalStateInfo{}, storage.ErrLocalStateNotFound
+}
+
+func (mts *MockTssServer) RetireLocalState(pubKey string) error {
+	for i, el := range mts.localStates {
+		if el.PubKey == pubKey {
+			mts.localStates = append(mts.localStates[:i], mts.localStates[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrLocalStateNotFound
+}
+
+func (mts *MockTssServer) GetLocalStateHistory(pubKey string) ([]storage.LocalStateVersion, error) {
+	if len(mts.versions) == 0 {
+		return nil, storage.ErrLocalStateNotFound
+	}
+	return mts.versions, nil
+}
+
+func (mts *MockTssServer) PinLocalState(pubKey string, generation int64) error {
+	for i := range mts.versions {
+		mts.versions[i].Pinned = mts.versions[i].Generation == generation
+	}
+	for _, el := range mts.versions {
+		if el.Pinned {
+			return nil
+		}
+	}
+	return storage.ErrLocalStateNotFound
+}
+
+func (mts *MockTssServer) RollbackLocalState(pubKey string) (storage.LocalStateVersion, error) {
+	for i, el := range mts.versions {
+		if el.Pinned && i > 0 {
+			err := mts.PinLocalState(pubKey, mts.versions[i-1].Generation)
+			return mts.versions[i-1], err
+		}
+	}
+	return storage.LocalStateVersion{}, errors.New("you ask for it")
+}
+
+func (mts *MockTssServer) VerifyLocalState(pubKey string) (storage.ShareVerification, error) {
+	for _, el := range mts.verifications {
+		if el.PubKey == pubKey {
+			return el, nil
+		}
+	}
+	return storage.ShareVerification{}, storage.ErrLocalStateNotFound
+}
+
+func (mts *MockTssServer) VerifyLocalStates() ([]storage.ShareVerification, error) {
+	return mts.verifications, nil
+}
+
+func (mts *MockTssServer) SetAllowedPeers(pubKeys []string) error {
+	if _, err := conversion.GetPeerIDsFromPubKeys(pubKeys); err != nil {
+		return err
+	}
+	mts.allowedPubKeys = pubKeys
+	return nil
+}
@@ -15,6 +15,8 @@ import (
 
 	"gitlab.com/thorchain/tss/go-tss/keygen"
 	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 	"gitlab.com/thorchain/tss/go-tss/tss"
 )
 
@@ -25,6 +27,22 @@ type TssHttpServer struct {
 	s         *http.Server
 }
 
+// ReadyResponse is the readiness of the tss server
+type ReadyResponse struct {
+	PreParams int `json:"pre_params"` // number of pre-parameters buffered for the coming keygens
+}
+
+// PinRequest is the request to pin a generation of the share of a pool
+type PinRequest struct {
+	Generation int64 `json:"generation"`
+}
+
+// AllowlistRequest is the request to admit only the given nodes and the participants of the known pools to connect,
+// the participants of a keygen or resharing are admitted while it is in progress without being listed here
+type AllowlistRequest struct {
+	PubKeys []string `json:"pub_keys"`
+}
+
 // NewTssHttpServer should only listen to the loopback
 func NewTssHttpServer(tssAddr string, t tss.Server) *TssHttpServer {
 	hs := &TssHttpServer{
@@ -44,7 +62,20 @@ func (t *TssHttpServer) tssNewHandler() http.Handler {
 	router := mux.NewRouter()
 	router.Handle("/keygen", http.HandlerFunc(t.keygenHandler)).Methods(http.MethodPost)
 	router.Handle("/keysign", http.HandlerFunc(t.keySignHandler)).Methods(http.MethodPost)
+	router.Handle("/reshare", http.HandlerFunc(t.reshareHandler)).Methods(http.MethodPost)
+	router.Handle("/refresh", http.HandlerFunc(t.refreshHandler)).Methods(http.MethodPost)
+	router.Handle("/presign", http.HandlerFunc(t.preSignHandler)).Methods(http.MethodPost)
+	router.Handle("/shares", http.HandlerFunc(t.listSharesHandler)).Methods(http.MethodGet)
+	router.Handle("/shares/verify", http.HandlerFunc(t.verifySharesHandler)).Methods(http.MethodGet)
+	router.Handle("/shares/{pubkey}", http.HandlerFunc(t.getShareHandler)).Methods(http.MethodGet)
+	router.Handle("/shares/{pubkey}", http.HandlerFunc(t.retireShareHandler)).Methods(http.MethodDelete)
+	router.Handle("/shares/{pubkey}/history", http.HandlerFunc(t.shareHistoryHandler)).Methods(http.MethodGet)
+	router.Handle("/shares/{pubkey}/pin", http.HandlerFunc(t.pinShareHandler)).Methods(http.MethodPost)
+	router.Handle("/shares/{pubkey}/rollback", http.HandlerFunc(t.rollbackShareHandler)).Methods(http.MethodPost)
+	router.Handle("/shares/{pubkey}/verify", http.HandlerFunc(t.verifyShareHandler)).Methods(http.MethodGet)
+	router.Handle("/allowlist", http.HandlerFunc(t.allowlistHandler)).Methods(http.MethodPost)
 	router.Handle("/ping", http.HandlerFunc(t.pingHandler)).Methods(http.MethodGet)
+	router.Handle("/ready", http.HandlerFunc(t.readyHandler)).Methods(http.MethodGet)
 	router.Handle("/p2pid", http.HandlerFunc(t.getP2pIDHandler)).Methods(http.MethodGet)
 	router.Handle("/metrics", promhttp.Handler())
 	router.Use(logMiddleware())
@@ -87,6 +118,114 @@ func (t *TssHttpServer) keygenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (t *TssHttpServer) reshareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); nil != err {
+			t.logger.Error().Err(err).Msg("fail to close request body")
+		}
+	}()
+	t.logger.Info().Msg("receive resharing request")
+	decoder := json.NewDecoder(r.Body)
+	var reshareReq resharing.Request
+	if err := decoder.Decode(&reshareReq); nil != err {
+		t.logger.Error().Err(err).Msg("fail to decode resharing request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := t.tssServer.Reshare(reshareReq)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to reshare")
+	}
+	t.logger.Debug().Msgf("resp:%+v", resp)
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to marshal response to json")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+func (t *TssHttpServer) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); nil != err {
+			t.logger.Error().Err(err).Msg("fail to close request body")
+		}
+	}()
+	t.logger.Info().Msg("receive refresh request")
+	decoder := json.NewDecoder(r.Body)
+	var refreshReq resharing.RefreshRequest
+	if err := decoder.Decode(&refreshReq); nil != err {
+		t.logger.Error().Err(err).Msg("fail to decode refresh request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := t.tssServer.Refresh(refreshReq)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to refresh")
+	}
+	t.logger.Debug().Msgf("resp:%+v", resp)
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to marshal response to json")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+func (t *TssHttpServer) preSignHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); nil != err {
+			t.logger.Error().Err(err).Msg("fail to close request body")
+		}
+	}()
+	t.logger.Info().Msg("receive presign request")
+	decoder := json.NewDecoder(r.Body)
+	var preSignReq keysign.PreSignRequest
+	if err := decoder.Decode(&preSignReq); nil != err {
+		t.logger.Error().Err(err).Msg("fail to decode presign request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := t.tssServer.PreSign(preSignReq)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to presign")
+	}
+	t.logger.Debug().Msgf("resp:%+v", resp)
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to marshal response to json")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
 func (t *TssHttpServer) keySignHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -171,6 +310,162 @@ func (t *TssHttpServer) pingHandler(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (t *TssHttpServer) readyHandler(w http.ResponseWriter, _ *http.Request) {
+	buf, err := json.Marshal(ReadyResponse{
+		PreParams: t.tssServer.GetPreParamsBuffered(),
+	})
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to marshal response to json")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+func (t *TssHttpServer) writeJSON(w http.ResponseWriter, value interface{}) {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to marshal response to json")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(buf)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+func (t *TssHttpServer) listSharesHandler(w http.ResponseWriter, _ *http.Request) {
+	infos, err := t.tssServer.ListLocalStates()
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to list the local states")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	t.writeJSON(w, infos)
+}
+
+func (t *TssHttpServer) getShareHandler(w http.ResponseWriter, r *http.Request) {
+	pubKey := mux.Vars(r)["pubkey"]
+	info, err := t.tssServer.GetLocalStateInfo(pubKey)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to get the local state")
+		t.writeShareError(w, err)
+		return
+	}
+	t.writeJSON(w, info)
+}
+
+func (t *TssHttpServer) retireShareHandler(w http.ResponseWriter, r *http.Request) {
+	pubKey := mux.Vars(r)["pubkey"]
+	t.logger.Info().Msgf("receive request to retire the local state of %s", pubKey)
+	if err := t.tssServer.RetireLocalState(pubKey); err != nil {
+		t.logger.Error().Err(err).Msg("fail to retire the local state")
+		t.writeShareError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *TssHttpServer) writeShareError(w http.ResponseWriter, err error) {
+	if storage.IsLocalStateNotFound(err) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func (t *TssHttpServer) shareHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	pubKey := mux.Vars(r)["pubkey"]
+	versions, err := t.tssServer.GetLocalStateHistory(pubKey)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to get the local state history")
+		t.writeShareError(w, err)
+		return
+	}
+	t.writeJSON(w, versions)
+}
+
+func (t *TssHttpServer) pinShareHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); nil != err {
+			t.logger.Error().Err(err).Msg("fail to close request body")
+		}
+	}()
+	pubKey := mux.Vars(r)["pubkey"]
+	var pinReq PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&pinReq); nil != err {
+		t.logger.Error().Err(err).Msg("fail to decode pin request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.logger.Info().Msgf("receive request to pin the generation %d of the local state of %s", pinReq.Generation, pubKey)
+	if err := t.tssServer.PinLocalState(pubKey, pinReq.Generation); err != nil {
+		t.logger.Error().Err(err).Msg("fail to pin the local state")
+		t.writeShareError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *TssHttpServer) rollbackShareHandler(w http.ResponseWriter, r *http.Request) {
+	pubKey := mux.Vars(r)["pubkey"]
+	t.logger.Info().Msgf("receive request to roll back the local state of %s", pubKey)
+	version, err := t.tssServer.RollbackLocalState(pubKey)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to roll back the local state")
+		t.writeShareError(w, err)
+		return
+	}
+	t.writeJSON(w, version)
+}
+
+func (t *TssHttpServer) verifySharesHandler(w http.ResponseWriter, _ *http.Request) {
+	results, err := t.tssServer.VerifyLocalStates()
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to verify the local states")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	t.writeJSON(w, results)
+}
+
+func (t *TssHttpServer) verifyShareHandler(w http.ResponseWriter, r *http.Request) {
+	pubKey := mux.Vars(r)["pubkey"]
+	result, err := t.tssServer.VerifyLocalState(pubKey)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to verify the local state")
+		t.writeShareError(w, err)
+		return
+	}
+	t.writeJSON(w, result)
+}
+
+func (t *TssHttpServer) allowlistHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); nil != err {
+			t.logger.Error().Err(err).Msg("fail to close request body")
+		}
+	}()
+	var allowlistReq AllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&allowlistReq); nil != err {
+		t.logger.Error().Err(err).Msg("fail to decode allowlist request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.logger.Info().Msgf("receive request to allow %d peers", len(allowlistReq.PubKeys))
+	if err := t.tssServer.SetAllowedPeers(allowlistReq.PubKeys); err != nil {
+		t.logger.Error().Err(err).Msg("fail to set the allowed peers")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (t *TssHttpServer) getP2pIDHandler(w http.ResponseWriter, _ *http.Request) {
 	localPeerID := t.tssServer.GetLocalPeerID()
 	_, err := w.Write([]byte(localPeerID))
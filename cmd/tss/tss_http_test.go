@@ -11,7 +11,12 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
 	"gitlab.com/thorchain/tss/go-tss/keygen"
+	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
 func TestPackage(t *testing.T) { TestingT(t) }
@@ -48,6 +53,19 @@ func (TssHttpServerTestSuite) TestPingHandler(c *C) {
 	c.Assert(res.Code, Equals, http.StatusOK)
 }
 
+func (TssHttpServerTestSuite) TestReadyHandler(c *C) {
+	tssServer := &MockTssServer{}
+	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+	c.Assert(s, NotNil)
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	res := httptest.NewRecorder()
+	s.readyHandler(res, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var resp ReadyResponse
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &resp), IsNil)
+	c.Assert(resp.PreParams, Equals, 2)
+}
+
 func (TssHttpServerTestSuite) TestGetP2pIDHandler(c *C) {
 	tssServer := &MockTssServer{}
 	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
@@ -58,6 +76,125 @@ func (TssHttpServerTestSuite) TestGetP2pIDHandler(c *C) {
 	c.Assert(res.Code, Equals, http.StatusOK)
 }
 
+func (TssHttpServerTestSuite) TestSharesHandler(c *C) {
+	pubKey := "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	tssServer := &MockTssServer{
+		localStates: []storage.LocalStateInfo{
+			{PubKey: pubKey, ParticipantKeys: []string{"A", "B", "C"}, LocalPartyKey: "A", Threshold: 2, KeyHeight: 10},
+		},
+	}
+	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+	c.Assert(s, NotNil)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		s.s.Handler.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+		return res
+	}
+
+	res := serve(http.MethodGet, "/shares")
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var infos []storage.LocalStateInfo
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &infos), IsNil)
+	c.Assert(infos, DeepEquals, tssServer.localStates)
+
+	res = serve(http.MethodGet, "/shares/"+pubKey)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var info storage.LocalStateInfo
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &info), IsNil)
+	c.Assert(info.KeyHeight, Equals, int64(10))
+	c.Assert(serve(http.MethodGet, "/shares/whatever").Code, Equals, http.StatusNotFound)
+
+	c.Assert(serve(http.MethodPost, "/shares/"+pubKey).Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(serve(http.MethodDelete, "/shares/"+pubKey).Code, Equals, http.StatusOK)
+	c.Assert(serve(http.MethodDelete, "/shares/"+pubKey).Code, Equals, http.StatusNotFound)
+	c.Assert(serve(http.MethodGet, "/shares/"+pubKey).Code, Equals, http.StatusNotFound)
+}
+
+func (TssHttpServerTestSuite) TestShareHistoryHandler(c *C) {
+	pubKey := "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	tssServer := &MockTssServer{}
+	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+	c.Assert(s, NotNil)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		s.s.Handler.ServeHTTP(res, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return res
+	}
+	c.Assert(serve(http.MethodGet, "/shares/"+pubKey+"/history", "").Code, Equals, http.StatusNotFound)
+
+	tssServer.versions = []storage.LocalStateVersion{
+		{Generation: 1, BlockHeight: 10, MsgID: "keygen"},
+		{Generation: 2, BlockHeight: 20, MsgID: "refresh", Pinned: true},
+	}
+	res := serve(http.MethodGet, "/shares/"+pubKey+"/history", "")
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var versions []storage.LocalStateVersion
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &versions), IsNil)
+	c.Assert(versions, HasLen, 2)
+	c.Assert(versions[1].Pinned, Equals, true)
+
+	res = serve(http.MethodPost, "/shares/"+pubKey+"/rollback", "")
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var version storage.LocalStateVersion
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &version), IsNil)
+	c.Assert(version.Generation, Equals, int64(1))
+	c.Assert(tssServer.versions[0].Pinned, Equals, true)
+	c.Assert(serve(http.MethodPost, "/shares/"+pubKey+"/rollback", "").Code, Equals, http.StatusInternalServerError)
+
+	c.Assert(serve(http.MethodPost, "/shares/"+pubKey+"/pin", "").Code, Equals, http.StatusBadRequest)
+	c.Assert(serve(http.MethodPost, "/shares/"+pubKey+"/pin", `{"generation":3}`).Code, Equals, http.StatusNotFound)
+	c.Assert(serve(http.MethodPost, "/shares/"+pubKey+"/pin", `{"generation":2}`).Code, Equals, http.StatusOK)
+	c.Assert(tssServer.versions[1].Pinned, Equals, true)
+}
+
+func (TssHttpServerTestSuite) TestVerifySharesHandler(c *C) {
+	pubKey := "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	tssServer := &MockTssServer{}
+	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+	c.Assert(s, NotNil)
+	serve := func(target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		s.s.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		return res
+	}
+	c.Assert(serve("/shares/"+pubKey+"/verify").Code, Equals, http.StatusNotFound)
+
+	tssServer.verifications = []storage.ShareVerification{
+		{PubKey: pubKey, Algo: common.ECDSA, Valid: true},
+		{PubKey: "whatever", Algo: common.ECDSA, Errors: []string{"the public shares do not interpolate to the pool public key"}},
+	}
+	res := serve("/shares/verify")
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var results []storage.ShareVerification
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &results), IsNil)
+	c.Assert(results, DeepEquals, tssServer.verifications)
+
+	res = serve("/shares/" + pubKey + "/verify")
+	c.Assert(res.Code, Equals, http.StatusOK)
+	var result storage.ShareVerification
+	c.Assert(json.Unmarshal(res.Body.Bytes(), &result), IsNil)
+	c.Assert(result.Valid, Equals, true)
+}
+
+func (TssHttpServerTestSuite) TestAllowlistHandler(c *C) {
+	conversion.SetupBech32Prefix()
+	pubKey := "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	tssServer := &MockTssServer{}
+	s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+	c.Assert(s, NotNil)
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		s.s.Handler.ServeHTTP(res, httptest.NewRequest(method, "/allowlist", bytes.NewBufferString(body)))
+		return res
+	}
+	c.Assert(serve(http.MethodGet, "").Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(serve(http.MethodPost, "").Code, Equals, http.StatusBadRequest)
+	c.Assert(serve(http.MethodPost, `{"pub_keys":["whatever"]}`).Code, Equals, http.StatusBadRequest)
+	c.Assert(tssServer.allowedPubKeys, HasLen, 0)
+	c.Assert(serve(http.MethodPost, `{"pub_keys":["`+pubKey+`"]}`).Code, Equals, http.StatusOK)
+	c.Assert(tssServer.allowedPubKeys, DeepEquals, []string{pubKey})
+}
+
 func (TssHttpServerTestSuite) TestKeygenHandler(c *C) {
 	normalKeygenRequest := `{"keys":["thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3", "thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09", "thorpub1addwnpepq2ryyje5zr09lq7gqptjwnxqsy2vcdngvwd6z7yt5yjcnyj8c8cn559xe69", "thorpub1addwnpepqfjcw5l4ay5t00c32mmlky7qrppepxzdlkcwfs2fd5u73qrwna0vzag3y4j"]}`
 	testCases := []struct {
@@ -126,6 +263,211 @@ func (TssHttpServerTestSuite) TestKeygenHandler(c *C) {
 	}
 }
 
+func (TssHttpServerTestSuite) TestReshareHandler(c *C) {
+	normalReshareRequest := `{"pool_pub_key":"thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3","old_party_keys":["thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09", "thorpub1addwnpepq2ryyje5zr09lq7gqptjwnxqsy2vcdngvwd6z7yt5yjcnyj8c8cn559xe69"],"new_party_keys":["thorpub1addwnpepq2ryyje5zr09lq7gqptjwnxqsy2vcdngvwd6z7yt5yjcnyj8c8cn559xe69", "thorpub1addwnpepqfjcw5l4ay5t00c32mmlky7qrppepxzdlkcwfs2fd5u73qrwna0vzag3y4j"],"block_height":10}`
+	testCases := []struct {
+		name          string
+		reqProvider   func() *http.Request
+		setter        func(s *MockTssServer)
+		resultChecker func(c *C, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "method get should return status method not allowed",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/reshare", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusMethodNotAllowed)
+			},
+		},
+		{
+			name: "nil request body should return status bad request",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/reshare", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusBadRequest)
+			},
+		},
+		{
+			name: "fail to reshare should still return the response",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/reshare",
+					bytes.NewBufferString(normalReshareRequest))
+			},
+			setter: func(s *MockTssServer) {
+				s.failToReshare = true
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+			},
+		},
+		{
+			name: "normal",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/reshare",
+					bytes.NewBufferString(normalReshareRequest))
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+				var resp resharing.Response
+				c.Assert(json.Unmarshal(w.Body.Bytes(), &resp), IsNil)
+				c.Assert(resp.PubKey, Equals, "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3")
+			},
+		},
+	}
+	for _, tc := range testCases {
+		c.Log(tc.name)
+		tssServer := &MockTssServer{}
+		s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+		c.Assert(s, NotNil)
+		if tc.setter != nil {
+			tc.setter(tssServer)
+		}
+		req := tc.reqProvider()
+		res := httptest.NewRecorder()
+		s.reshareHandler(res, req)
+		tc.resultChecker(c, res)
+	}
+}
+
+func (TssHttpServerTestSuite) TestRefreshHandler(c *C) {
+	normalRefreshRequest := `{"pool_pub_key":"thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3","block_height":10}`
+	testCases := []struct {
+		name          string
+		reqProvider   func() *http.Request
+		setter        func(s *MockTssServer)
+		resultChecker func(c *C, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "method get should return status method not allowed",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/refresh", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusMethodNotAllowed)
+			},
+		},
+		{
+			name: "nil request body should return status bad request",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/refresh", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusBadRequest)
+			},
+		},
+		{
+			name: "fail to refresh should still return the response",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/refresh",
+					bytes.NewBufferString(normalRefreshRequest))
+			},
+			setter: func(s *MockTssServer) {
+				s.failToRefresh = true
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+			},
+		},
+		{
+			name: "normal",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/refresh",
+					bytes.NewBufferString(normalRefreshRequest))
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+				var resp resharing.Response
+				c.Assert(json.Unmarshal(w.Body.Bytes(), &resp), IsNil)
+				c.Assert(resp.PubKey, Equals, "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3")
+			},
+		},
+	}
+	for _, tc := range testCases {
+		c.Log(tc.name)
+		tssServer := &MockTssServer{}
+		s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+		c.Assert(s, NotNil)
+		if tc.setter != nil {
+			tc.setter(tssServer)
+		}
+		req := tc.reqProvider()
+		res := httptest.NewRecorder()
+		s.refreshHandler(res, req)
+		tc.resultChecker(c, res)
+	}
+}
+
+func (TssHttpServerTestSuite) TestPreSignHandler(c *C) {
+	normalPreSignRequest := `{"pool_pub_key":"thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3","signer_pub_keys":["thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09", "thorpub1addwnpepq2ryyje5zr09lq7gqptjwnxqsy2vcdngvwd6z7yt5yjcnyj8c8cn559xe69"],"number":5,"block_height":10}`
+	testCases := []struct {
+		name          string
+		reqProvider   func() *http.Request
+		setter        func(s *MockTssServer)
+		resultChecker func(c *C, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "method get should return status method not allowed",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/presign", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusMethodNotAllowed)
+			},
+		},
+		{
+			name: "nil request body should return status bad request",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/presign", nil)
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusBadRequest)
+			},
+		},
+		{
+			name: "fail to presign should still return the response",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/presign",
+					bytes.NewBufferString(normalPreSignRequest))
+			},
+			setter: func(s *MockTssServer) {
+				s.failToPreSign = true
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+			},
+		},
+		{
+			name: "normal",
+			reqProvider: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/presign",
+					bytes.NewBufferString(normalPreSignRequest))
+			},
+			resultChecker: func(c *C, w *httptest.ResponseRecorder) {
+				c.Assert(w.Code, Equals, http.StatusOK)
+				var resp keysign.PreSignResponse
+				c.Assert(json.Unmarshal(w.Body.Bytes(), &resp), IsNil)
+				c.Assert(resp.Number, Equals, 5)
+				c.Assert(resp.IDs, HasLen, 5)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		c.Log(tc.name)
+		tssServer := &MockTssServer{}
+		s := NewTssHttpServer("127.0.0.1:8080", tssServer)
+		c.Assert(s, NotNil)
+		if tc.setter != nil {
+			tc.setter(tssServer)
+		}
+		req := tc.reqProvider()
+		res := httptest.NewRecorder()
+		s.preSignHandler(res, req)
+		tc.resultChecker(c, res)
+	}
+}
+
 func (TssHttpServerTestSuite) TestKeysignHandler(c *C) {
 	var normalKeySignRequest string = `{
     "pool_pub_key": "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3",
@@ -0,0 +1,168 @@
+package common
+
+import (
+	"crypto/elliptic"
+	"sync"
+	"time"
+
+	btss "github.com/binance-chain/tss-lib/tss"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+)
+
+// Algo is the signature scheme a pool key is generated and used with
+type Algo string
+
+const (
+	// ECDSA over secp256k1, this is the default when no algorithm is given
+	ECDSA Algo = "ecdsa"
+	// EdDSA over ed25519
+	EdDSA Algo = "eddsa"
+)
+
+// GetAlgo return the algorithm the given value represents, empty value is treated as ECDSA
+func GetAlgo(algo Algo) (Algo, bool) {
+	switch algo {
+	case "", ECDSA:
+		return ECDSA, true
+	case EdDSA:
+		return EdDSA, true
+	default:
+		return algo, false
+	}
+}
+
+// Curve return the elliptic curve tss-lib should use for the given algorithm
+func (a Algo) Curve() elliptic.Curve {
+	if a == EdDSA {
+		return edwards.Edwards()
+	}
+	return btcec.S256()
+}
+
+// tss-lib keeps the curve it works on in a process wide variable, so ceremonies of different algorithms
+// can not run at the same time. curveLocker allows any number of ceremonies of the same algorithm to run
+// concurrently, and make the ceremony of the other algorithm wait until all of them are done. While a ceremony of
+// the other algorithm waits, the new ceremonies of the current algorithm wait as well, so neither algorithm can
+// keep the curve forever.
+type curveLocker struct {
+	lock    *sync.Mutex
+	cond    *sync.Cond
+	algo    Algo
+	holders int
+	// waiting is the number of the ceremonies waiting for the curve of each algorithm
+	waiting map[Algo]int
+	// turn is increased every time the curve is handed to the waiting ceremonies
+	turn int
+}
+
+var tssCurveLocker = newCurveLocker()
+
+func newCurveLocker() *curveLocker {
+	l := &sync.Mutex{}
+	return &curveLocker{
+		lock:    l,
+		cond:    sync.NewCond(l),
+		algo:    ECDSA,
+		waiting: make(map[Algo]int),
+	}
+}
+
+// otherWaiting return an algorithm other than the given one that has ceremonies waiting for its curve
+func (c *curveLocker) otherWaiting(algo Algo) (Algo, bool) {
+	for el, num := range c.waiting {
+		if el != algo && num > 0 {
+			return el, true
+		}
+	}
+	return "", false
+}
+
+// handOver switch to the curve of the algorithm and count all the ceremonies waiting for it as the holders
+func (c *curveLocker) handOver(algo Algo) {
+	if c.algo != algo {
+		btss.SetCurve(algo.Curve())
+		c.algo = algo
+	}
+	c.holders += c.waiting[algo]
+	c.waiting[algo] = 0
+	c.turn++
+	c.cond.Broadcast()
+}
+
+// acquire hold the curve of the algorithm, it waits at most the timeout for the ceremonies of the other algorithm,
+// 0 waits till they are done. It returns false when the curve is not acquired in time
+func (c *curveLocker) acquire(algo Algo, timeout time.Duration) bool {
+	algo, _ = GetAlgo(algo)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.holders == 0 {
+		if c.algo != algo {
+			btss.SetCurve(algo.Curve())
+			c.algo = algo
+		}
+		c.holders++
+		return true
+	}
+	if _, ok := c.otherWaiting(algo); c.algo == algo && !ok {
+		c.holders++
+		return true
+	}
+	timedOut := false
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			timedOut = true
+			c.cond.Broadcast()
+		})
+		defer timer.Stop()
+	}
+	turn := c.turn
+	c.waiting[algo]++
+	// the ceremony is counted in the holders when the curve is handed over to its algorithm
+	for c.turn == turn || c.algo != algo {
+		if timedOut {
+			c.waiting[algo]--
+			// the ceremonies of the current algorithm only waited for this one
+			if _, ok := c.otherWaiting(c.algo); !ok && c.waiting[c.algo] > 0 {
+				c.handOver(c.algo)
+			}
+			return false
+		}
+		c.cond.Wait()
+	}
+	return true
+}
+
+func (c *curveLocker) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.holders--
+	if c.holders > 0 {
+		return
+	}
+	// the ceremonies of the other algorithm go first
+	if algo, ok := c.otherWaiting(c.algo); ok {
+		c.handOver(algo)
+		return
+	}
+	if c.waiting[c.algo] > 0 {
+		c.handOver(c.algo)
+	}
+}
+
+// AcquireCurve switch tss-lib to the curve of the given algorithm, and hold it until ReleaseCurve is called
+func AcquireCurve(algo Algo) {
+	tssCurveLocker.acquire(algo, 0)
+}
+
+// TryAcquireCurve is AcquireCurve that gives up after the timeout, it returns false when the curve is not acquired
+func TryAcquireCurve(algo Algo, timeout time.Duration) bool {
+	return tssCurveLocker.acquire(algo, timeout)
+}
+
+// ReleaseCurve release the curve acquired by AcquireCurve
+func ReleaseCurve() {
+	tssCurveLocker.release()
+}
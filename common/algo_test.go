@@ -0,0 +1,80 @@
+package common
+
+import (
+	"time"
+
+	btss "github.com/binance-chain/tss-lib/tss"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+	. "gopkg.in/check.v1"
+)
+
+type AlgoTestSuite struct{}
+
+var _ = Suite(&AlgoTestSuite{})
+
+// waitForWaiting wait till the given number of ceremonies wait for the curve of the algorithm
+func waitForWaiting(c *C, l *curveLocker, algo Algo, num int) {
+	for i := 0; i < 100; i++ {
+		l.lock.Lock()
+		waiting := l.waiting[algo]
+		l.lock.Unlock()
+		if waiting == num {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("%d ceremonies do not wait for the %s curve", num, algo)
+}
+
+func (s *AlgoTestSuite) TestCurveLocker(c *C) {
+	l := newCurveLocker()
+	// tss-lib is left on the default curve
+	defer btss.SetCurve(btcec.S256())
+	c.Assert(l.acquire(ECDSA, 0), Equals, true)
+	// the ceremonies of the same algorithm run together
+	c.Assert(l.acquire("", 0), Equals, true)
+	l.release()
+
+	eddsaAcquired := make(chan struct{})
+	go func() {
+		l.acquire(EdDSA, 0)
+		close(eddsaAcquired)
+	}()
+	waitForWaiting(c, l, EdDSA, 1)
+	// the new ecdsa ceremonies wait behind the waiting eddsa one
+	c.Assert(l.acquire(ECDSA, 50*time.Millisecond), Equals, false)
+	ecdsaAcquired := make(chan struct{})
+	go func() {
+		l.acquire(ECDSA, 0)
+		close(ecdsaAcquired)
+	}()
+	waitForWaiting(c, l, ECDSA, 1)
+
+	l.release()
+	select {
+	case <-eddsaAcquired:
+	case <-time.After(time.Second):
+		c.Fatal("the eddsa curve is not handed over")
+	}
+	c.Assert(btss.EC().Params(), DeepEquals, edwards.Edwards().Params())
+	select {
+	case <-ecdsaAcquired:
+		c.Fatal("the ecdsa curve is acquired while the eddsa ceremony runs")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.release()
+	select {
+	case <-ecdsaAcquired:
+	case <-time.After(time.Second):
+		c.Fatal("the ecdsa curve is not handed over")
+	}
+	c.Assert(btss.EC().Params(), DeepEquals, btcec.S256().Params())
+
+	// the ceremony that gives up no longer holds up the others
+	c.Assert(l.acquire(EdDSA, 50*time.Millisecond), Equals, false)
+	waitForWaiting(c, l, EdDSA, 0)
+	c.Assert(l.acquire(ECDSA, 0), Equals, true)
+	l.release()
+	l.release()
+}
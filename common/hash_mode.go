@@ -0,0 +1,76 @@
+package common
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// HashMode is how a message is hashed before it is signed
+type HashMode string
+
+const (
+	// HashRaw signs the message as it is, the message is a 32 bytes digest, this is the default when no mode is given
+	HashRaw HashMode = "raw"
+	// HashSHA256 signs the SHA-256 of the message
+	HashSHA256 HashMode = "sha256"
+	// HashSHA256d signs the double SHA-256 of the message, as Bitcoin does
+	HashSHA256d HashMode = "sha256d"
+	// HashKeccak256 signs the legacy Keccak-256 of the message, as Ethereum does
+	HashKeccak256 HashMode = "keccak256"
+)
+
+// GetHashMode return the hash mode the given value represents, empty value is treated as HashRaw
+func GetHashMode(mode HashMode) (HashMode, bool) {
+	switch mode {
+	case "", HashRaw:
+		return HashRaw, true
+	case HashSHA256, HashSHA256d, HashKeccak256:
+		return mode, true
+	default:
+		return mode, false
+	}
+}
+
+// HashMessage return the digest of the message that is signed with the given hash mode
+func HashMessage(msg []byte, mode HashMode) ([]byte, error) {
+	hashMode, ok := GetHashMode(mode)
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash mode: %s", mode)
+	}
+	if len(msg) == 0 {
+		return nil, errors.New("empty message")
+	}
+	switch hashMode {
+	case HashSHA256:
+		h := sha256.Sum256(msg)
+		return h[:], nil
+	case HashSHA256d:
+		h := sha256.Sum256(msg)
+		h = sha256.Sum256(h[:])
+		return h[:], nil
+	case HashKeccak256:
+		h := sha3.NewLegacyKeccak256()
+		if _, err := h.Write(msg); err != nil {
+			return nil, fmt.Errorf("fail to calculate keccak256 hash: %w", err)
+		}
+		return h.Sum(nil), nil
+	default:
+		return msg, nil
+	}
+}
+
+// HashMessages return the digests of the messages that are signed with the given hash mode
+func HashMessages(msgs [][]byte, mode HashMode) ([][]byte, error) {
+	digests := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		digest, err := HashMessage(msg, mode)
+		if err != nil {
+			return nil, err
+		}
+		digests[i] = digest
+	}
+	return digests, nil
+}
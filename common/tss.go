@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -25,6 +26,7 @@ import (
 type PartyInfo struct {
 	PartyMap   *sync.Map
 	PartyIDMap map[string]*btss.PartyID
+	Algo       Algo
 }
 
 type TssCommon struct {
@@ -50,6 +52,10 @@ type TssCommon struct {
 	cachedWireBroadcastMsgLists *sync.Map
 	cachedWireUnicastMsgLists   *sync.Map
 	msgNum                      int
+	onlineSignLock              *sync.Mutex
+	onlineSignMsgs              map[string]*messages.WireMessage
+	onlineSignNotify            chan struct{}
+	curveHeld                   bool
 }
 
 func NewTssCommon(peerID string, broadcastChannel chan *messages.BroadcastMsgChan, conf TssConfig, msgID string, privKey tcrypto.PrivKey, msgNum int) *TssCommon {
@@ -75,6 +81,9 @@ func NewTssCommon(peerID string, broadcastChannel chan *messages.BroadcastMsgCha
 		cachedWireBroadcastMsgLists: &sync.Map{},
 		cachedWireUnicastMsgLists:   &sync.Map{},
 		msgNum:                      msgNum,
+		onlineSignLock:              &sync.Mutex{},
+		onlineSignMsgs:              make(map[string]*messages.WireMessage),
+		onlineSignNotify:            make(chan struct{}, 1),
 	}
 }
 
@@ -122,14 +131,20 @@ func (t *TssCommon) doTssJob(tssJobChan chan *tssJob, jobWg *sync.WaitGroup) {
 		partyID := tssjob.partyID
 		isBroadcast := tssjob.isBroadcast
 
-		round, err := GetMsgRound(wireBytes, partyID, isBroadcast)
+		algo := t.getPartyInfo().Algo
+		round, err := GetMsgRound(wireBytes, partyID, isBroadcast, algo)
 		if err != nil {
 			t.logger.Error().Err(err).Msg("broken tss share")
 			continue
 		}
 		round.MsgIdentifier = tssjob.msgIdentifier
 
-		_, errUp := party.UpdateFromBytes(wireBytes, partyID, isBroadcast)
+		parsedMsg, err := ParseWireMessage(wireBytes, partyID, isBroadcast, algo)
+		if err != nil {
+			t.logger.Error().Err(err).Msg("broken tss share")
+			continue
+		}
+		_, errUp := party.Update(parsedMsg)
 		if errUp != nil {
 			err := t.processInvalidMsgBlame(round.RoundMsg, round, errUp)
 			t.logger.Error().Err(err).Msgf("fail to apply the share to tss")
@@ -153,6 +168,10 @@ func (t *TssCommon) GetConf() TssConfig {
 	return t.conf
 }
 
+func (t *TssCommon) GetMsgID() string {
+	return t.msgID
+}
+
 func (t *TssCommon) GetTaskDone() chan struct{} {
 	return t.taskDone
 }
@@ -161,6 +180,29 @@ func (t *TssCommon) GetBlameMgr() *blame.Manager {
 	return t.blameMgr
 }
 
+// AcquireCurve hold the tss-lib curve of the algorithm for the ceremony, it waits at most the timeout for the
+// ceremonies of the other algorithm, 0 waits till they are done. It does nothing when the ceremony holds the curve
+// already, so the curve can be acquired before the party forms
+func (t *TssCommon) AcquireCurve(algo Algo, timeout time.Duration) error {
+	if t.curveHeld {
+		return nil
+	}
+	if !TryAcquireCurve(algo, timeout) {
+		return fmt.Errorf("fail to acquire the %s curve in %s, the ceremonies of the other algorithm are running", algo, timeout)
+	}
+	t.curveHeld = true
+	return nil
+}
+
+// ReleaseCurve release the curve held by the ceremony, it does nothing when the curve is not held
+func (t *TssCommon) ReleaseCurve() {
+	if !t.curveHeld {
+		return
+	}
+	t.curveHeld = false
+	ReleaseCurve()
+}
+
 func (t *TssCommon) SetPartyInfo(partyInfo *PartyInfo) {
 	t.partyLock.Lock()
 	defer t.partyLock.Unlock()
@@ -221,8 +263,50 @@ func (t *TssCommon) processInvalidMsgBlame(roundInfo string, round blame.RoundIn
 	return fmt.Errorf("fail to set bytes to local party: %w", err)
 }
 
-// updateLocal will apply the wireMsg to local keygen/keysign party
-func (t *TssCommon) updateLocal(wireMsg *messages.WireMessage) error {
+// isReSharingMsg return whether the given message type belongs to resharing
+func isReSharingMsg(msgType messages.THORChainTSSMessageType) bool {
+	return msgType == messages.TSSReSharingMsg || msgType == messages.TSSReSharingVerMsg
+}
+
+// getLocalParties return the local parties the given message should be applied to. In keygen and keysign
+// the local parties are indexed by the moniker of the message, while in resharing a node may run a party in
+// both committees, the local parties are indexed by their party id and we apply the message to the ones it is sent to
+func getLocalParties(partyInfo *PartyInfo, msg *BulkWireMsg, isReSharing bool) []btss.Party {
+	var parties []btss.Party
+	if !isReSharing {
+		data, ok := partyInfo.PartyMap.Load(msg.MsgIdentifier)
+		if ok {
+			parties = append(parties, data.(btss.Party))
+		}
+		return parties
+	}
+	for _, each := range msg.Routing.To {
+		if each.Id == msg.Routing.From.Id {
+			continue
+		}
+		data, ok := partyInfo.PartyMap.Load(each.Id)
+		if ok {
+			parties = append(parties, data.(btss.Party))
+		}
+	}
+	return parties
+}
+
+// getNodeNum return the number of nodes that join the ceremony, in resharing a node may run more than one party
+func (t *TssCommon) getNodeNum(partyIDMap map[string]*btss.PartyID) int {
+	nodes := make(map[peer.ID]bool)
+	for id := range partyIDMap {
+		peerID, ok := t.PartyIDtoP2PID[id]
+		if !ok {
+			return len(partyIDMap)
+		}
+		nodes[peerID] = true
+	}
+	return len(nodes)
+}
+
+// updateLocal will apply the wireMsg to local keygen/keysign/resharing party
+func (t *TssCommon) updateLocal(wireMsg *messages.WireMessage, msgType messages.THORChainTSSMessageType) error {
 	if wireMsg == nil || wireMsg.Routing == nil || wireMsg.Routing.From == nil {
 		t.logger.Warn().Msg("wire msg is nil")
 		return errors.New("invalid wireMsg")
@@ -242,7 +326,7 @@ func (t *TssCommon) updateLocal(wireMsg *messages.WireMessage) error {
 		return errors.New("fail to find the peer")
 	}
 	// here we log down this peer as the latest unicast peer
-	if !wireMsg.Routing.IsBroadcast {
+	if !wireMsg.Routing.IsBroadcast && dataOwnerPeerID.String() != t.localPeerID {
 		t.blameMgr.SetLastUnicastPeer(dataOwnerPeerID, wireMsg.RoundInfo)
 	}
 
@@ -260,55 +344,73 @@ func (t *TssCommon) updateLocal(wireMsg *messages.WireMessage) error {
 		jobWg.Add(1)
 		go t.doTssJob(tssJobChan, &jobWg)
 	}
+	isReSharing := isReSharingMsg(msgType)
+	var errUpdate error
 	for _, msg := range bulkMsg {
-		data, ok := partyInfo.PartyMap.Load(msg.MsgIdentifier)
-		if !ok {
+		msg := msg
+		localMsgParties := getLocalParties(partyInfo, &msg, isReSharing)
+		if len(localMsgParties) == 0 {
+			// in resharing, the broadcast message is confirmed by all the nodes, but only applied by the receivers
+			if isReSharing {
+				continue
+			}
 			t.logger.Error().Msg("cannot find the party to this wired msg")
-			return errors.New("cannot find the party")
+			errUpdate = errors.New("cannot find the party")
+			break
 		}
-		localMsgParty := data.(btss.Party)
 		partyID, ok := partyInfo.PartyIDMap[msg.Routing.From.Id]
 		if !ok {
 			t.logger.Error().Msg("error in find the partyID")
-			return errors.New("cannot find the party to handle the message")
+			errUpdate = errors.New("cannot find the party to handle the message")
+			break
 		}
 
-		round, err := GetMsgRound(msg.WiredBulkMsgs, partyID, msg.Routing.IsBroadcast)
+		round, err := GetMsgRound(msg.WiredBulkMsgs, partyID, msg.Routing.IsBroadcast, partyInfo.Algo)
 		if err != nil {
 			t.logger.Error().Err(err).Msg("broken tss share")
-			return err
+			errUpdate = err
+			break
 		}
 
-		// we only allow a message be updated only once.
-		// here we use round + msgIdentifier as the key for the acceptedShares
-		round.MsgIdentifier = msg.MsgIdentifier
-		// if this share is duplicated, we skip this share
-		if t.blameMgr.CheckMsgDuplication(round, partyID.Id) {
-			t.logger.Debug().Msgf("we received the duplicated message from party %s", partyID.Id)
-			continue
-		}
+		for _, localMsgParty := range localMsgParties {
+			// we only allow a message be updated only once.
+			// here we use round + msgIdentifier as the key for the acceptedShares
+			round.MsgIdentifier = msg.MsgIdentifier
+			if isReSharing {
+				round.MsgIdentifier = localMsgParty.PartyID().Id
+			}
+			// if this share is duplicated, we skip this share
+			if t.blameMgr.CheckMsgDuplication(round, partyID.Id) {
+				t.logger.Debug().Msgf("we received the duplicated message from party %s", partyID.Id)
+				continue
+			}
 
-		partyInlist := func(el *btss.PartyID, l []*btss.PartyID) bool {
-			for _, each := range l {
-				if el == each {
-					return true
+			partyInlist := func(el *btss.PartyID, l []*btss.PartyID) bool {
+				for _, each := range l {
+					if el == each {
+						return true
+					}
 				}
+				return false
+			}
+			t.culpritsLock.RLock()
+			if len(t.culprits) != 0 && partyInlist(partyID, t.culprits) {
+				t.logger.Error().Msgf("the malicious party (party ID:%s) try to send incorrect message to me (party ID:%s)", partyID.Id, localMsgParty.PartyID().Id)
+				t.culpritsLock.RUnlock()
+				errUpdate = errors.New(blame.TssBrokenMsg)
+				break
 			}
-			return false
-		}
-		t.culpritsLock.RLock()
-		if len(t.culprits) != 0 && partyInlist(partyID, t.culprits) {
-			t.logger.Error().Msgf("the malicious party (party ID:%s) try to send incorrect message to me (party ID:%s)", partyID.Id, localMsgParty.PartyID().Id)
 			t.culpritsLock.RUnlock()
-			return errors.New(blame.TssBrokenMsg)
+			job := newJob(localMsgParty, msg.WiredBulkMsgs, round.MsgIdentifier, partyID, msg.Routing.IsBroadcast)
+			tssJobChan <- job
+		}
+		if errUpdate != nil {
+			break
 		}
-		t.culpritsLock.RUnlock()
-		job := newJob(localMsgParty, msg.WiredBulkMsgs, round.MsgIdentifier, partyID, msg.Routing.IsBroadcast)
-		tssJobChan <- job
 	}
 	close(tssJobChan)
 	jobWg.Wait()
-	return nil
+	return errUpdate
 }
 
 func (t *TssCommon) checkDupAndUpdateVerMsg(bMsg *messages.BroadcastConfirmMessage, peerID string) bool {
@@ -336,13 +438,13 @@ func (t *TssCommon) ProcessOneMessage(wrappedMsg *messages.WrappedMessage, peerI
 	}
 
 	switch wrappedMsg.MessageType {
-	case messages.TSSKeyGenMsg, messages.TSSKeySignMsg:
+	case messages.TSSKeyGenMsg, messages.TSSKeySignMsg, messages.TSSReSharingMsg:
 		var wireMsg messages.WireMessage
 		if err := json.Unmarshal(wrappedMsg.Payload, &wireMsg); nil != err {
 			return fmt.Errorf("fail to unmarshal wire message: %w", err)
 		}
 		return t.processTSSMsg(&wireMsg, wrappedMsg.MessageType, false)
-	case messages.TSSKeyGenVerMsg, messages.TSSKeySignVerMsg:
+	case messages.TSSKeyGenVerMsg, messages.TSSKeySignVerMsg, messages.TSSReSharingVerMsg:
 		var bMsg messages.BroadcastConfirmMessage
 		if err := json.Unmarshal(wrappedMsg.Payload, &bMsg); nil != err {
 			return errors.New("fail to unmarshal broadcast confirm message")
@@ -352,6 +454,12 @@ func (t *TssCommon) ProcessOneMessage(wrappedMsg *messages.WrappedMessage, peerI
 		if ret {
 			return t.processVerMsg(&bMsg, wrappedMsg.MessageType)
 		}
+	case messages.TSSOnlineSignMsg:
+		var wireMsg messages.WireMessage
+		if err := json.Unmarshal(wrappedMsg.Payload, &wireMsg); nil != err {
+			return fmt.Errorf("fail to unmarshal wire message: %w", err)
+		}
+		return t.processOnlineSignMsg(&wireMsg)
 	case messages.TSSTaskDone:
 		var wireMsg messages.TssTaskNotifier
 		err := json.Unmarshal(wrappedMsg.Payload, &wireMsg)
@@ -365,7 +473,7 @@ func (t *TssCommon) ProcessOneMessage(wrappedMsg *messages.WrappedMessage, peerI
 				return fmt.Errorf("duplicated notification from peer %s ignored", peerID)
 			}
 			t.finishedPeers[peerID] = true
-			if len(t.finishedPeers) == len(t.partyInfo.PartyIDMap)-1 {
+			if len(t.finishedPeers) == t.getNodeNum(t.partyInfo.PartyIDMap)-1 {
 				t.logger.Debug().Msg("we get the confirm of the nodes that generate the signature")
 				close(t.taskDone)
 			}
@@ -476,7 +584,9 @@ func (t *TssCommon) sendBulkMsg(wiredMsgType string, tssMsgType messages.THORCha
 	}
 
 	peerIDs := make([]peer.ID, 0)
-	if len(r.To) == 0 {
+	// the broadcast message of resharing is sent to all the nodes, so that all of them can confirm it
+	// even if only one of the committees applies it
+	if len(r.To) == 0 || (r.IsBroadcast && isReSharingMsg(tssMsgType)) {
 		t.P2PPeersLock.RLock()
 		peerIDs = t.P2PPeers
 		t.P2PPeersLock.RUnlock()
@@ -487,6 +597,9 @@ func (t *TssCommon) sendBulkMsg(wiredMsgType string, tssMsgType messages.THORCha
 				t.logger.Error().Msg("error in find the P2P ID")
 				continue
 			}
+			if peerID.String() == t.localPeerID {
+				continue
+			}
 			peerIDs = append(peerIDs, peerID)
 		}
 	}
@@ -494,10 +607,40 @@ func (t *TssCommon) sendBulkMsg(wiredMsgType string, tssMsgType messages.THORCha
 		WrappedMessage: wrappedMsg,
 		PeersID:        peerIDs,
 	})
-
+	if isReSharingMsg(tssMsgType) {
+		t.deliverToLocalParties(&wireMsg, tssMsgType)
+	}
 	return nil
 }
 
+// deliverToLocalParties apply the message sent by one of our local parties to the other local parties, this
+// happens in resharing when a node runs a party in both the old and the new committee
+func (t *TssCommon) deliverToLocalParties(wireMsg *messages.WireMessage, msgType messages.THORChainTSSMessageType) {
+	partyInfo := t.getPartyInfo()
+	if partyInfo == nil {
+		return
+	}
+	found := false
+	for _, each := range wireMsg.Routing.To {
+		if each.Id == wireMsg.Routing.From.Id {
+			continue
+		}
+		if _, ok := partyInfo.PartyMap.Load(each.Id); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	// the local party is still holding its lock while sending the message out, so we apply it asynchronously
+	go func() {
+		if err := t.updateLocal(wireMsg, msgType); err != nil {
+			t.logger.Error().Err(err).Msg("fail to apply the message to the local party")
+		}
+	}()
+}
+
 func (t *TssCommon) ProcessOutCh(msg btss.Message, msgType messages.THORChainTSSMessageType) error {
 	msgData, r, err := msg.WireBytes()
 	// if we cannot get the wire share, the tss will fail, we just quit.
@@ -588,7 +731,7 @@ func (t *TssCommon) applyShare(localCacheItem *LocalCacheItem, threshold int, ke
 	}
 
 	t.blameMgr.GetRoundMgr().Set(key, localCacheItem.Msg)
-	if err := t.updateLocal(localCacheItem.Msg); nil != err {
+	if err := t.updateLocal(localCacheItem.Msg, msgType); nil != err {
 		return fmt.Errorf("fail to update the message to local party: %w", err)
 	}
 	t.logger.Debug().Msgf("remove key: %s", key)
@@ -629,7 +772,10 @@ func (t *TssCommon) requestShareFromPeer(localCacheItem *LocalCacheItem, thresho
 	case messages.TSSKeySignVerMsg:
 		msg.RequestType = messages.TSSKeySignMsg
 		return t.processRequestMsgFromPeer(peersIDs, msg, true)
-	case messages.TSSKeySignMsg, messages.TSSKeyGenMsg:
+	case messages.TSSReSharingVerMsg:
+		msg.RequestType = messages.TSSReSharingMsg
+		return t.processRequestMsgFromPeer(peersIDs, msg, true)
+	case messages.TSSKeySignMsg, messages.TSSKeyGenMsg, messages.TSSReSharingMsg:
 		msg.RequestType = msgType
 		return t.processRequestMsgFromPeer(peersIDs, msg, true)
 	default:
@@ -659,7 +805,7 @@ func (t *TssCommon) processVerMsg(broadcastConfirmMsg *messages.BroadcastConfirm
 	localCacheItem.UpdateConfirmList(broadcastConfirmMsg.P2PID, broadcastConfirmMsg.Hash)
 	t.logger.Debug().Msgf("total confirmed parties:%+v", localCacheItem.ConfirmedList)
 
-	threshold, err := conversion.GetThreshold(len(partyInfo.PartyIDMap))
+	threshold, err := conversion.GetThreshold(t.getNodeNum(partyInfo.PartyIDMap))
 	if err != nil {
 		return err
 	}
@@ -730,34 +876,42 @@ func (t *TssCommon) receiverBroadcastHashToPeers(wireMsg *messages.WireMessage,
 	return nil
 }
 
-// processTSSMsg
-func (t *TssCommon) processTSSMsg(wireMsg *messages.WireMessage, msgType messages.THORChainTSSMessageType, forward bool) error {
-	t.logger.Debug().Msg("process wire message")
-	defer t.logger.Debug().Msg("finish process wire message")
-
-	if wireMsg == nil || wireMsg.Routing == nil || wireMsg.Routing.From == nil {
-		t.logger.Warn().Msg("received msg invalid")
-		return errors.New("invalid wireMsg")
-	}
+// checkWireMsgOwner return the party sent the wire message once the signature of the message is verified
+func (t *TssCommon) checkWireMsgOwner(wireMsg *messages.WireMessage) (*btss.PartyID, error) {
 	partyIDMap := t.getPartyInfo().PartyIDMap
 	dataOwner, ok := partyIDMap[wireMsg.Routing.From.Id]
 	if !ok {
 		t.logger.Error().Msg("error in find the data owner")
-		return errors.New("error in find the data owner")
+		return nil, errors.New("error in find the data owner")
 	}
-	keyBytes := dataOwner.GetKey()
+	keyBytes := conversion.GetPartyPubKeyBytes(dataOwner)
 	var pk secp256k1.PubKey
 	pk = keyBytes
 	ok = verifySignature(pk, wireMsg.Message, wireMsg.Sig, t.msgID)
 	if !ok {
 		t.logger.Error().Msg("fail to verify the signature")
-		return errors.New("signature verify failed")
+		return nil, errors.New("signature verify failed")
+	}
+	return dataOwner, nil
+}
+
+// processTSSMsg
+func (t *TssCommon) processTSSMsg(wireMsg *messages.WireMessage, msgType messages.THORChainTSSMessageType, forward bool) error {
+	t.logger.Debug().Msg("process wire message")
+	defer t.logger.Debug().Msg("finish process wire message")
+
+	if wireMsg == nil || wireMsg.Routing == nil || wireMsg.Routing.From == nil {
+		t.logger.Warn().Msg("received msg invalid")
+		return errors.New("invalid wireMsg")
+	}
+	if _, err := t.checkWireMsgOwner(wireMsg); err != nil {
+		return err
 	}
 
 	// for the unicast message, we only update it local party
 	if !wireMsg.Routing.IsBroadcast {
 		t.logger.Debug().Msgf("msg from %s to %+v", wireMsg.Routing.From, wireMsg.Routing.To)
-		return t.updateLocal(wireMsg)
+		return t.updateLocal(wireMsg, msgType)
 	}
 
 	// if not received the broadcast message , we save a copy locally , and then tell all others what we got
@@ -790,19 +944,101 @@ func (t *TssCommon) processTSSMsg(wireMsg *messages.WireMessage, msgType message
 	}
 	localCacheItem.UpdateConfirmList(t.localPeerID, msgHash)
 
-	threshold, err := conversion.GetThreshold(len(partyInfo.PartyIDMap))
+	threshold, err := conversion.GetThreshold(t.getNodeNum(partyInfo.PartyIDMap))
 	if err != nil {
 		return err
 	}
 	return t.applyShare(localCacheItem, threshold, key, msgType)
 }
 
+// processOnlineSignMsg keep the signature shares a peer sent in the online round of the keysign with presignatures,
+// only the first message of each party is kept
+func (t *TssCommon) processOnlineSignMsg(wireMsg *messages.WireMessage) error {
+	if wireMsg == nil || wireMsg.Routing == nil || wireMsg.Routing.From == nil {
+		t.logger.Warn().Msg("received msg invalid")
+		return errors.New("invalid wireMsg")
+	}
+	if t.getPartyInfo() == nil {
+		return errors.New("can't process online sign msg, local party is not ready")
+	}
+	dataOwner, err := t.checkWireMsgOwner(wireMsg)
+	if err != nil {
+		return err
+	}
+	t.onlineSignLock.Lock()
+	defer t.onlineSignLock.Unlock()
+	if _, ok := t.onlineSignMsgs[dataOwner.Id]; ok {
+		return fmt.Errorf("duplicated online sign message from party %s ignored", dataOwner.Id)
+	}
+	t.onlineSignMsgs[dataOwner.Id] = wireMsg
+	select {
+	case t.onlineSignNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// GetOnlineSignNotify return the channel notified when we receive the signature shares from a peer
+func (t *TssCommon) GetOnlineSignNotify() <-chan struct{} {
+	return t.onlineSignNotify
+}
+
+// GetOnlineSignMsgs return the online sign messages received so far, indexed by the party id of the sender
+func (t *TssCommon) GetOnlineSignMsgs() map[string]*messages.WireMessage {
+	t.onlineSignLock.Lock()
+	defer t.onlineSignLock.Unlock()
+	msgs := make(map[string]*messages.WireMessage, len(t.onlineSignMsgs))
+	for k, v := range t.onlineSignMsgs {
+		msgs[k] = v
+	}
+	return msgs
+}
+
+// BroadcastOnlineSignShares send the signature shares of the local party to all the other signers
+func (t *TssCommon) BroadcastOnlineSignShares(localPartyID *btss.PartyID, shares []messages.OnlineSignShare) error {
+	buf, err := json.Marshal(shares)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the signature shares: %w", err)
+	}
+	sig, err := generateSignature(buf, t.msgID, t.privateKey)
+	if err != nil {
+		return fmt.Errorf("fail to generate the signature of the shares: %w", err)
+	}
+	wireMsg := messages.WireMessage{
+		Routing: &btss.MessageRouting{
+			From:        localPartyID,
+			IsBroadcast: true,
+		},
+		RoundInfo: messages.ONLINESIGN,
+		Message:   buf,
+		Sig:       sig,
+	}
+	wireMsgBytes, err := json.Marshal(wireMsg)
+	if err != nil {
+		return fmt.Errorf("fail to convert online sign msg to wire bytes: %w", err)
+	}
+	t.P2PPeersLock.RLock()
+	peerIDs := t.P2PPeers
+	t.P2PPeersLock.RUnlock()
+	t.renderToP2P(&messages.BroadcastMsgChan{
+		WrappedMessage: messages.WrappedMessage{
+			MsgID:       t.msgID,
+			MessageType: messages.TSSOnlineSignMsg,
+			Payload:     wireMsgBytes,
+		},
+		PeersID: peerIDs,
+	})
+	return nil
+}
+
 func getBroadcastMessageType(msgType messages.THORChainTSSMessageType) messages.THORChainTSSMessageType {
 	switch msgType {
 	case messages.TSSKeyGenMsg:
 		return messages.TSSKeyGenVerMsg
 	case messages.TSSKeySignMsg:
 		return messages.TSSKeySignVerMsg
+	case messages.TSSReSharingMsg:
+		return messages.TSSReSharingVerMsg
 	default:
 		return messages.Unknown // this should not happen
 	}
@@ -14,7 +14,10 @@ import (
 	"strings"
 
 	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/resharing"
 	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	eddsakeygen "github.com/binance-chain/tss-lib/eddsa/keygen"
+	eddsasigning "github.com/binance-chain/tss-lib/eddsa/signing"
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -42,6 +45,18 @@ func MsgToHashInt(msg []byte) (*big.Int, error) {
 	return hashToInt(msg, btcec.S256()), nil
 }
 
+// MsgToSignInt return the integer tss-lib signs for the given message, ECDSA signs the message truncated to
+// the curve order, while EdDSA signs the message bytes as they are (leading zero bytes are dropped by tss-lib)
+func MsgToSignInt(msg []byte, algo Algo) (*big.Int, error) {
+	if algo == EdDSA {
+		if len(msg) == 0 {
+			return nil, errors.New("empty message")
+		}
+		return new(big.Int).SetBytes(msg), nil
+	}
+	return MsgToHashInt(msg)
+}
+
 func MsgToHashString(msg []byte) (string, error) {
 	if len(msg) == 0 {
 		return "", errors.New("empty message")
@@ -119,6 +134,14 @@ func getHighestFreq(confirmedList map[string]string) (string, int, error) {
 // when we get the shares from the peers in the current round. So, when we identify
 // an error in this round, we check whether the previous round is the unicast
 func checkUnicast(round blame.RoundInfo) bool {
+	// EdDSA only has one unicast round in keygen
+	if strings.HasPrefix(round.RoundMsg, "EDDSA") {
+		return round.RoundMsg == messages.EDDSAKEYGEN2aUnicast
+	}
+	// the shares of resharing are sent in unicast in round 3, and verified along with the round 3 broadcast
+	if strings.HasPrefix(round.RoundMsg, "DGR") {
+		return round.RoundMsg == messages.RESHARING3aUnicast || round.RoundMsg == messages.RESHARING3b
+	}
 	index := round.Index
 	isKeyGen := strings.Contains(round.RoundMsg, "KGR")
 	// keygen unicast blame
@@ -135,8 +158,8 @@ func checkUnicast(round blame.RoundInfo) bool {
 	return false
 }
 
-func GetMsgRound(msg []byte, partyID *btss.PartyID, isBroadcast bool) (blame.RoundInfo, error) {
-	parsedMsg, err := btss.ParseWireMessage(msg, partyID, isBroadcast)
+func GetMsgRound(msg []byte, partyID *btss.PartyID, isBroadcast bool, algo Algo) (blame.RoundInfo, error) {
+	parsedMsg, err := ParseWireMessage(msg, partyID, isBroadcast, algo)
 	if err != nil {
 		return blame.RoundInfo{}, err
 	}
@@ -213,6 +236,78 @@ func GetMsgRound(msg []byte, partyID *btss.PartyID, isBroadcast bool) (blame.Rou
 			RoundMsg: messages.KEYSIGN7,
 		}, nil
 
+	case *resharing.DGRound1Message:
+		return blame.RoundInfo{
+			Index:    0,
+			RoundMsg: messages.RESHARING1,
+		}, nil
+
+	case *resharing.DGRound2Message1:
+		return blame.RoundInfo{
+			Index:    1,
+			RoundMsg: messages.RESHARING2a,
+		}, nil
+
+	case *resharing.DGRound2Message2:
+		return blame.RoundInfo{
+			Index:    2,
+			RoundMsg: messages.RESHARING2b,
+		}, nil
+
+	case *resharing.DGRound3Message1:
+		return blame.RoundInfo{
+			Index:    3,
+			RoundMsg: messages.RESHARING3aUnicast,
+		}, nil
+
+	case *resharing.DGRound3Message2:
+		return blame.RoundInfo{
+			Index:    4,
+			RoundMsg: messages.RESHARING3b,
+		}, nil
+
+	case *resharing.DGRound4Message:
+		return blame.RoundInfo{
+			Index:    5,
+			RoundMsg: messages.RESHARING4,
+		}, nil
+
+	case *eddsakeygen.KGRound1Message:
+		return blame.RoundInfo{
+			Index:    0,
+			RoundMsg: messages.EDDSAKEYGEN1,
+		}, nil
+
+	case *eddsakeygen.KGRound2Message1:
+		return blame.RoundInfo{
+			Index:    1,
+			RoundMsg: messages.EDDSAKEYGEN2aUnicast,
+		}, nil
+
+	case *eddsakeygen.KGRound2Message2:
+		return blame.RoundInfo{
+			Index:    2,
+			RoundMsg: messages.EDDSAKEYGEN2b,
+		}, nil
+
+	case *eddsasigning.SignRound1Message:
+		return blame.RoundInfo{
+			Index:    0,
+			RoundMsg: messages.EDDSAKEYSIGN1,
+		}, nil
+
+	case *eddsasigning.SignRound2Message:
+		return blame.RoundInfo{
+			Index:    1,
+			RoundMsg: messages.EDDSAKEYSIGN2,
+		}, nil
+
+	case *eddsasigning.SignRound3Message:
+		return blame.RoundInfo{
+			Index:    2,
+			RoundMsg: messages.EDDSAKEYSIGN3,
+		}, nil
+
 	default:
 		return blame.RoundInfo{}, errors.New("unknown round")
 	}
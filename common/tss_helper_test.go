@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
@@ -60,6 +61,26 @@ func (t *tssHelpSuite) TestMsgToHashString(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (t *tssHelpSuite) TestHashMessage(c *C) {
+	msg := []byte("hello")
+	out, err := HashMessage(msg, "")
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, msg)
+	out, err = HashMessage(msg, HashSHA256)
+	c.Assert(err, IsNil)
+	c.Assert(hex.EncodeToString(out), Equals, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	out, err = HashMessage(msg, HashSHA256d)
+	c.Assert(err, IsNil)
+	c.Assert(hex.EncodeToString(out), Equals, "9595c9df90075148eb06860365df33584b75bff782a510c6cd4883a419833d50")
+	out, err = HashMessage(msg, HashKeccak256)
+	c.Assert(err, IsNil)
+	c.Assert(hex.EncodeToString(out), Equals, "1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8")
+	_, err = HashMessage(msg, "md5")
+	c.Assert(err, NotNil)
+	_, err = HashMessage(nil, HashSHA256)
+	c.Assert(err, NotNil)
+}
+
 func (t *tssHelpSuite) TestTssCommon_NotifyTaskDone(c *C) {
 	conversion.SetupBech32Prefix()
 	pk, err := sdk.GetPubKeyFromBech32(sdk.Bech32PubKeyTypeAccPub, "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3")
@@ -140,7 +161,7 @@ func (t *tssHelpSuite) TestGetMsgRound(c *C) {
 	mockParty := btss.NewPartyID("12", "22", big.NewInt(2))
 	j := 0
 	for i := 0; i < len(messagesKeygen); i++ {
-		ret, err := GetMsgRound(sharesKeyGen[j].Message, mockParty, sharesKeyGen[j].Routing.IsBroadcast)
+		ret, err := GetMsgRound(sharesKeyGen[j].Message, mockParty, sharesKeyGen[j].Routing.IsBroadcast, ECDSA)
 		c.Assert(err, IsNil)
 		expectedRound := blame.RoundInfo{
 			Index:    i,
@@ -156,7 +177,7 @@ func (t *tssHelpSuite) TestGetMsgRound(c *C) {
 	}
 	j = 0
 	for i := 0; i < len(messagesKeysign); i++ {
-		ret, err := GetMsgRound(sharesKeySign[j].Message, mockParty, sharesKeySign[1].Routing.IsBroadcast)
+		ret, err := GetMsgRound(sharesKeySign[j].Message, mockParty, sharesKeySign[1].Routing.IsBroadcast, ECDSA)
 		c.Assert(err, IsNil)
 		expectedRound := blame.RoundInfo{
 			Index:    i,
@@ -171,7 +192,7 @@ func (t *tssHelpSuite) TestGetMsgRound(c *C) {
 		}
 	}
 
-	ret, err := GetMsgRound(sharesKeyGen[1].Message, mockParty, sharesKeyGen[1].Routing.IsBroadcast)
+	ret, err := GetMsgRound(sharesKeyGen[1].Message, mockParty, sharesKeyGen[1].Routing.IsBroadcast, ECDSA)
 	c.Assert(ret, Equals, blame.RoundInfo{Index: 1, RoundMsg: messages.KEYGEN2aUnicast})
 	c.Assert(err, IsNil)
 }
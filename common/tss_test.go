@@ -70,6 +70,24 @@ func (t *TssTestSuite) TestGetThreshold(c *C) {
 	c.Assert(output, Equals, 65)
 }
 
+func (t *TssTestSuite) TestGetThresholdWithSigners(c *C) {
+	output, err := conversion.GetThresholdWithSigners(4, 0)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, 2)
+	output, err = conversion.GetThresholdWithSigners(5, 3)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, 2)
+	output, err = conversion.GetThresholdWithSigners(9, 7)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, 6)
+	_, err = conversion.GetThresholdWithSigners(5, 6)
+	c.Assert(err, NotNil)
+	_, err = conversion.GetThresholdWithSigners(5, -1)
+	c.Assert(err, NotNil)
+	_, err = conversion.GetThresholdWithSigners(5, 1)
+	c.Assert(err, NotNil)
+}
+
 func (t *TssTestSuite) TestMsgToHashInt(c *C) {
 	input := []byte("whatever")
 	result, err := MsgToHashInt(input)
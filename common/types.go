@@ -13,6 +13,21 @@ type TssConfig struct {
 	KeySignTimeout time.Duration
 	// Pre-parameter define the pre-parameter generations timeout
 	PreParamTimeout time.Duration
+	// PreParamsPoolSize defines how many sets of pre-parameters are generated ahead of the keygens
+	PreParamsPoolSize int
+	// PreParamsConcurrency defines how many sets of pre-parameters are generated at the same time
+	PreParamsConcurrency int
 	// enable the tss monitor
 	EnableMonitor bool
+	// StateBackend defines where the local states are saved, file (default) or leveldb
+	StateBackend string
+	// StateBackups is the number of the older generations kept in the history of the local states besides the pinned
+	// one, a corrupted local state file falls back to them, 0 uses the default, a negative value keeps only the pinned
+	// one
+	StateBackups int
+	// StatePassphrase is the operator passphrase the key of the local states is derived from, when the home folder
+	// has a state key header, it is never saved
+	StatePassphrase string `json:"-"`
+	// ShareVerifyInterval is how often the shares of all the pools are verified, 0 disables the verification
+	ShareVerifyInterval time.Duration
 }
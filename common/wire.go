@@ -0,0 +1,83 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/resharing"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	eddsakeygen "github.com/binance-chain/tss-lib/eddsa/keygen"
+	eddsasigning "github.com/binance-chain/tss-lib/eddsa/signing"
+	btss "github.com/binance-chain/tss-lib/tss"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+type msgContentFactory func() btss.MessageContent
+
+// tss-lib declares the protobuf messages of ECDSA and EdDSA without a package, so both of them register
+// the same message names (e.g. KGRound1Message) in the global protobuf registry, and the registry can not
+// tell which one a wire message is. We keep our own lookup table for each algorithm instead.
+var wireMsgTypes = map[Algo]map[string]msgContentFactory{
+	ECDSA: {
+		"KGRound1Message":    func() btss.MessageContent { return new(keygen.KGRound1Message) },
+		"KGRound2Message1":   func() btss.MessageContent { return new(keygen.KGRound2Message1) },
+		"KGRound2Message2":   func() btss.MessageContent { return new(keygen.KGRound2Message2) },
+		"KGRound3Message":    func() btss.MessageContent { return new(keygen.KGRound3Message) },
+		"SignRound1Message1": func() btss.MessageContent { return new(signing.SignRound1Message1) },
+		"SignRound1Message2": func() btss.MessageContent { return new(signing.SignRound1Message2) },
+		"SignRound2Message":  func() btss.MessageContent { return new(signing.SignRound2Message) },
+		"SignRound3Message":  func() btss.MessageContent { return new(signing.SignRound3Message) },
+		"SignRound4Message":  func() btss.MessageContent { return new(signing.SignRound4Message) },
+		"SignRound5Message":  func() btss.MessageContent { return new(signing.SignRound5Message) },
+		"SignRound6Message":  func() btss.MessageContent { return new(signing.SignRound6Message) },
+		"SignRound7Message":  func() btss.MessageContent { return new(signing.SignRound7Message) },
+		"DGRound1Message":    func() btss.MessageContent { return new(resharing.DGRound1Message) },
+		"DGRound2Message1":   func() btss.MessageContent { return new(resharing.DGRound2Message1) },
+		"DGRound2Message2":   func() btss.MessageContent { return new(resharing.DGRound2Message2) },
+		"DGRound3Message1":   func() btss.MessageContent { return new(resharing.DGRound3Message1) },
+		"DGRound3Message2":   func() btss.MessageContent { return new(resharing.DGRound3Message2) },
+		"DGRound4Message":    func() btss.MessageContent { return new(resharing.DGRound4Message) },
+	},
+	EdDSA: {
+		"KGRound1Message":   func() btss.MessageContent { return new(eddsakeygen.KGRound1Message) },
+		"KGRound2Message1":  func() btss.MessageContent { return new(eddsakeygen.KGRound2Message1) },
+		"KGRound2Message2":  func() btss.MessageContent { return new(eddsakeygen.KGRound2Message2) },
+		"SignRound1Message": func() btss.MessageContent { return new(eddsasigning.SignRound1Message) },
+		"SignRound2Message": func() btss.MessageContent { return new(eddsasigning.SignRound2Message) },
+		"SignRound3Message": func() btss.MessageContent { return new(eddsasigning.SignRound3Message) },
+	},
+}
+
+// ParseWireMessage parse the wire bytes of a tss message of the given algorithm, it replaces
+// btss.ParseWireMessage which relies on the global protobuf registry
+func ParseWireMessage(wireBytes []byte, from *btss.PartyID, isBroadcast bool, algo Algo) (btss.ParsedMessage, error) {
+	if from == nil {
+		return nil, errors.New("nil party id")
+	}
+	wire := new(btss.MessageWrapper)
+	wire.Message = new(any.Any)
+	wire.From = from.MessageWrapper_PartyID
+	wire.IsBroadcast = isBroadcast
+	if err := proto.Unmarshal(wireBytes, wire.Message); err != nil {
+		return nil, err
+	}
+	typeURL := wire.Message.GetTypeUrl()
+	msgName := typeURL[strings.LastIndex(typeURL, "/")+1:]
+	algo, _ = GetAlgo(algo)
+	newContent, ok := wireMsgTypes[algo][msgName]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s message type: %s", algo, msgName)
+	}
+	content := newContent()
+	if err := proto.Unmarshal(wire.Message.GetValue(), content); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal %s: %w", msgName, err)
+	}
+	meta := btss.MessageRouting{
+		From:        from,
+		IsBroadcast: isBroadcast,
+	}
+	return btss.NewMessage(meta, content, wire), nil
+}
@@ -14,8 +14,10 @@ import (
 	"github.com/binance-chain/tss-lib/crypto"
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
 	crypto2 "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"gitlab.com/thorchain/binance-sdk/common/types"
@@ -35,11 +37,43 @@ func GetPeerIDFromSecp256PubKey(pk []byte) (peer.ID, error) {
 	return peer.IDFromPublicKey(ppk)
 }
 
+// the key of a party is the big.Int of its compressed secp256k1 public key, resharing gives every party
+// of the new committee a new key by putting the block height of the resharing above the public key bytes
+const partyKeyHeightShift = coskey.PubKeySize * 8
+
+// GetPartyKey return the party key of the given public key at the given block height
+func GetPartyKey(pk []byte, height int64) *big.Int {
+	key := new(big.Int).SetBytes(pk)
+	if height == 0 {
+		return key
+	}
+	h := new(big.Int).Lsh(big.NewInt(height), partyKeyHeightShift)
+	return key.Or(key, h)
+}
+
+// GetPartyKeyHeight return the block height the given party key is created at, keys of the parties that
+// never joined a resharing are at height 0
+func GetPartyKeyHeight(key *big.Int) int64 {
+	if key == nil {
+		return 0
+	}
+	return new(big.Int).Rsh(key, partyKeyHeightShift).Int64()
+}
+
+// GetPartyPubKeyBytes return the compressed secp256k1 public key bytes of the given party
+func GetPartyPubKeyBytes(partyID *btss.PartyID) []byte {
+	pkBytes := partyID.KeyInt().Bytes()
+	if len(pkBytes) > coskey.PubKeySize {
+		pkBytes = pkBytes[len(pkBytes)-coskey.PubKeySize:]
+	}
+	return pkBytes
+}
+
 func GetPeerIDFromPartyID(partyID *btss.PartyID) (peer.ID, error) {
 	if partyID == nil || !partyID.ValidateBasic() {
 		return "", errors.New("invalid partyID")
 	}
-	pkBytes := partyID.KeyInt().Bytes()
+	pkBytes := GetPartyPubKeyBytes(partyID)
 	return GetPeerIDFromSecp256PubKey(pkBytes)
 }
 
@@ -47,7 +81,7 @@ func PartyIDtoPubKey(party *btss.PartyID) (string, error) {
 	if party == nil || !party.ValidateBasic() {
 		return "", errors.New("invalid party")
 	}
-	partyKeyBytes := party.GetKey()
+	partyKeyBytes := GetPartyPubKeyBytes(party)
 	pk := coskey.PubKey{
 		Key: partyKeyBytes,
 	}
@@ -87,10 +121,13 @@ func GetPeersID(partyIDtoP2PID map[string]peer.ID, localPeerID string) []peer.ID
 		return nil
 	}
 	peerIDs := make([]peer.ID, 0, len(partyIDtoP2PID)-1)
+	// a peer may run more than one party, e.g. both the old and the new one in resharing
+	seen := make(map[peer.ID]bool)
 	for _, value := range partyIDtoP2PID {
-		if value.String() == localPeerID {
+		if value.String() == localPeerID || seen[value] {
 			continue
 		}
+		seen[value] = true
 		peerIDs = append(peerIDs, value)
 	}
 	return peerIDs
@@ -108,6 +145,20 @@ func SetupIDMaps(parties map[string]*btss.PartyID, partyIDtoP2PID map[string]pee
 }
 
 func GetParties(keys []string, localPartyKey string) ([]*btss.PartyID, *btss.PartyID, error) {
+	partiesID, localPartyID, err := GetPartiesAtHeight(keys, localPartyKey, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if localPartyID == nil {
+		return nil, nil, errors.New("local party is not in the list")
+	}
+	return partiesID, localPartyID, nil
+}
+
+// GetPartiesAtHeight return the parties of the given keys with their party keys created at the given block height,
+// the party ids start from idOffset so that the two committees of a resharing never share an id.
+// The local party id is nil if the local party is not in the list
+func GetPartiesAtHeight(keys []string, localPartyKey string, height int64, idOffset int) ([]*btss.PartyID, *btss.PartyID, error) {
 	var localPartyID *btss.PartyID
 	var unSortedPartiesID []*btss.PartyID
 	sort.Strings(keys)
@@ -116,20 +167,17 @@ func GetParties(keys []string, localPartyKey string) ([]*btss.PartyID, *btss.Par
 		if err != nil {
 			return nil, nil, fmt.Errorf("fail to get account pub key address(%s): %w", item, err)
 		}
-		key := new(big.Int).SetBytes(pk.Bytes())
+		key := GetPartyKey(pk.Bytes(), height)
 		// Set up the parameters
 		// Note: The `id` and `moniker` fields are for convenience to allow you to easily track participants.
 		// The `id` should be a unique string representing this party in the network and `moniker` can be anything (even left blank).
 		// The `uniqueKey` is a unique identifying key for this peer (such as its p2p public key) as a big.Int.
-		partyID := btss.NewPartyID(strconv.Itoa(idx), "", key)
+		partyID := btss.NewPartyID(strconv.Itoa(idOffset+idx), "", key)
 		if item == localPartyKey {
 			localPartyID = partyID
 		}
 		unSortedPartiesID = append(unSortedPartiesID, partyID)
 	}
-	if localPartyID == nil {
-		return nil, nil, errors.New("local party is not in the list")
-	}
 
 	partiesID := btss.SortPartyIDs(unSortedPartiesID)
 	return partiesID, localPartyID, nil
@@ -167,6 +215,24 @@ func GetTssPubKey(pubKeyPoint *crypto.ECPoint) (string, types.AccAddress, error)
 	return pubKey, addr, err
 }
 
+// GetTssPubKeyEDDSA return the bech32 ed25519 pub key and the address of the given EdDSA pub key point
+func GetTssPubKeyEDDSA(pubKeyPoint *crypto.ECPoint) (string, types.AccAddress, error) {
+	if pubKeyPoint == nil || !edwards.Edwards().IsOnCurve(pubKeyPoint.X(), pubKeyPoint.Y()) {
+		return "", types.AccAddress{}, errors.New("invalid points")
+	}
+	tssPubKey := edwards.PublicKey{
+		Curve: edwards.Edwards(),
+		X:     pubKeyPoint.X(),
+		Y:     pubKeyPoint.Y(),
+	}
+	pk := ed25519.PubKey{
+		Key: tssPubKey.Serialize(),
+	}
+	pubKey, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &pk)
+	addr := types.AccAddress(pk.Address().Bytes())
+	return pubKey, addr, err
+}
+
 func BytesToHashString(msg []byte) (string, error) {
 	h := sha256.New()
 	_, err := h.Write(msg)
@@ -176,6 +242,19 @@ func BytesToHashString(msg []byte) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// GetThresholdWithSigners return the threshold tss-lib works with for a pool of the given number of parties
+// that needs the given number of signers, the default threshold is returned if the number of signers is 0. At
+// least 2 signers are needed, as tss-lib does not take a threshold below 1
+func GetThresholdWithSigners(parties, signers int) (int, error) {
+	if signers == 0 {
+		return GetThreshold(parties)
+	}
+	if signers < 2 || signers > parties {
+		return 0, fmt.Errorf("invalid threshold %d of %d parties", signers, parties)
+	}
+	return signers - 1, nil
+}
+
 func GetThreshold(value int) (int, error) {
 	if value < 0 {
 		return 0, errors.New("negative input")
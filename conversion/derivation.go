@@ -0,0 +1,113 @@
+package conversion
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/binance-chain/tss-lib/crypto"
+	"github.com/btcsuite/btcd/btcec"
+	"gitlab.com/thorchain/binance-sdk/common/types"
+)
+
+// hardenedKeyStart is the index of the first hardened child key, which can not be derived from a public key
+const hardenedKeyStart = uint32(0x80000000)
+
+// ParseDerivationPath parse a BIP32 derivation path of non-hardened indexes, such as m/0/1
+func ParseDerivationPath(path string) ([]uint32, error) {
+	items := strings.Split(strings.TrimSpace(path), "/")
+	if len(items) == 0 || items[0] != "m" {
+		return nil, fmt.Errorf("derivation path(%s) does not start with m", path)
+	}
+	indexes := make([]uint32, 0, len(items)-1)
+	for _, el := range items[1:] {
+		if strings.HasSuffix(el, "'") || strings.HasSuffix(el, "h") {
+			return nil, fmt.Errorf("hardened index(%s) can not be derived from the pool pub key", el)
+		}
+		index, err := strconv.ParseUint(el, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index(%s) in derivation path: %w", el, err)
+		}
+		if uint32(index) >= hardenedKeyStart {
+			return nil, fmt.Errorf("hardened index(%s) can not be derived from the pool pub key", el)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// DeriveChildKey derive the non-hardened BIP32 child of the given secp256k1 pub key along the path, it returns
+// the tweak to add to the private key, the child pub key and the child chain code
+func DeriveChildKey(pubKey *crypto.ECPoint, chainCode []byte, path []uint32) (*big.Int, *crypto.ECPoint, []byte, error) {
+	if pubKey == nil || !isOnCurve(pubKey.X(), pubKey.Y()) {
+		return nil, nil, nil, errors.New("invalid points")
+	}
+	if len(chainCode) != 32 {
+		return nil, nil, nil, fmt.Errorf("invalid chain code length %d", len(chainCode))
+	}
+	curve := btcec.S256()
+	tweak := big.NewInt(0)
+	childKey := pubKey
+	childChainCode := chainCode
+	for _, index := range path {
+		if index >= hardenedKeyStart {
+			return nil, nil, nil, fmt.Errorf("hardened index(%d) can not be derived from the pool pub key", index)
+		}
+		key := btcec.PublicKey{
+			Curve: curve,
+			X:     childKey.X(),
+			Y:     childKey.Y(),
+		}
+		data := make([]byte, 37)
+		copy(data, key.SerializeCompressed())
+		binary.BigEndian.PutUint32(data[33:], index)
+		mac := hmac.New(sha512.New, childChainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(curve.N) >= 0 {
+			return nil, nil, nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		ilX, ilY := curve.ScalarBaseMult(sum[:32])
+		x, y := curve.Add(childKey.X(), childKey.Y(), ilX, ilY)
+		if x.Sign() == 0 && y.Sign() == 0 {
+			return nil, nil, nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		next, err := crypto.NewECPoint(curve, x, y)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("fail to create the child key: %w", err)
+		}
+		childKey = next
+		childChainCode = sum[32:]
+		tweak.Add(tweak, il)
+		tweak.Mod(tweak, curve.N)
+	}
+	return tweak, childKey, childChainCode, nil
+}
+
+// GetDerivedPubKey return the bech32 pub key and the address of the non-hardened BIP32 child of the given pool
+// pub key at the given derivation path
+func GetDerivedPubKey(poolPubKey string, chainCode []byte, path string) (string, types.AccAddress, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return "", types.AccAddress{}, err
+	}
+	bPk, err := GetECDSAPubKey(poolPubKey)
+	if err != nil {
+		return "", types.AccAddress{}, err
+	}
+	point, err := crypto.NewECPoint(btcec.S256(), bPk.X, bPk.Y)
+	if err != nil {
+		return "", types.AccAddress{}, fmt.Errorf("fail to parse pub key(%s): %w", poolPubKey, err)
+	}
+	_, childKey, _, err := DeriveChildKey(point, chainCode, indexes)
+	if err != nil {
+		return "", types.AccAddress{}, err
+	}
+	return GetTssPubKey(childKey)
+}
@@ -0,0 +1,91 @@
+package conversion
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/binance-chain/tss-lib/crypto"
+	"github.com/btcsuite/btcd/btcec"
+	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	. "gopkg.in/check.v1"
+)
+
+func (p *ConversionTestSuite) TestParseDerivationPath(c *C) {
+	path, err := ParseDerivationPath("m/0/1/2147483647")
+	c.Assert(err, IsNil)
+	c.Assert(path, DeepEquals, []uint32{0, 1, 2147483647})
+	path, err = ParseDerivationPath("m")
+	c.Assert(err, IsNil)
+	c.Assert(path, HasLen, 0)
+	_, err = ParseDerivationPath("")
+	c.Assert(err, NotNil)
+	_, err = ParseDerivationPath("0/1")
+	c.Assert(err, NotNil)
+	_, err = ParseDerivationPath("m/0'/1")
+	c.Assert(err, NotNil)
+	_, err = ParseDerivationPath("m/2147483648")
+	c.Assert(err, NotNil)
+	_, err = ParseDerivationPath("m/a")
+	c.Assert(err, NotNil)
+}
+
+func (p *ConversionTestSuite) TestDeriveChildKey(c *C) {
+	// test vector 2 of BIP32, from m to m/0
+	chainCode, err := hex.DecodeString("60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689")
+	c.Assert(err, IsNil)
+	pubKeyBytes, err := hex.DecodeString("03cbcaa9c98c877a26977d00825c956a238e8dddfbd322cce4f74b0b5bd6ace4a7")
+	c.Assert(err, IsNil)
+	priKey, ok := new(big.Int).SetString("4b03d6fc340455b363f51020ad3ecca4f0850280cf436c70c727923f6db46c3e", 16)
+	c.Assert(ok, Equals, true)
+	pk, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	c.Assert(err, IsNil)
+	point, err := crypto.NewECPoint(btcec.S256(), pk.X, pk.Y)
+	c.Assert(err, IsNil)
+
+	tweak, childKey, childChainCode, err := DeriveChildKey(point, chainCode, []uint32{0})
+	c.Assert(err, IsNil)
+	c.Assert(hex.EncodeToString(childChainCode), Equals, "f0909affaa7ee7abe5dd4e100598d4dc53cd709d5a5c2cac40e7412f232f7c9c")
+	childPk := btcec.PublicKey{Curve: btcec.S256(), X: childKey.X(), Y: childKey.Y()}
+	c.Assert(hex.EncodeToString(childPk.SerializeCompressed()), Equals, "02fc9e5af0ac8d9b3cecfe2a888e2117ba3d089d8585886c9c826b6b22a98d12ea")
+	childPriKey := new(big.Int).Add(priKey, tweak)
+	childPriKey.Mod(childPriKey, btcec.S256().N)
+	c.Assert(hex.EncodeToString(childPriKey.Bytes()), Equals, "abe74a98f6c7eabee0428f53798f0ab8aa1bd37873999041703c742f15ac7e1e")
+
+	// the empty path is the key itself
+	tweak, childKey, _, err = DeriveChildKey(point, chainCode, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tweak.Sign(), Equals, 0)
+	c.Assert(childKey.Equals(point), Equals, true)
+
+	_, _, _, err = DeriveChildKey(point, chainCode[:31], []uint32{0})
+	c.Assert(err, NotNil)
+	_, _, _, err = DeriveChildKey(point, chainCode, []uint32{0x80000000})
+	c.Assert(err, NotNil)
+	_, _, _, err = DeriveChildKey(nil, chainCode, []uint32{0})
+	c.Assert(err, NotNil)
+}
+
+func (p *ConversionTestSuite) TestGetDerivedPubKey(c *C) {
+	chainCode, err := hex.DecodeString("60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689")
+	c.Assert(err, IsNil)
+	pubKeyBytes, err := hex.DecodeString("03cbcaa9c98c877a26977d00825c956a238e8dddfbd322cce4f74b0b5bd6ace4a7")
+	c.Assert(err, IsNil)
+	poolPubKey, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &coskey.PubKey{Key: pubKeyBytes})
+	c.Assert(err, IsNil)
+	childPubKeyBytes, err := hex.DecodeString("02fc9e5af0ac8d9b3cecfe2a888e2117ba3d089d8585886c9c826b6b22a98d12ea")
+	c.Assert(err, IsNil)
+	childPk := coskey.PubKey{Key: childPubKeyBytes}
+	expected, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &childPk)
+	c.Assert(err, IsNil)
+
+	childPubKey, addr, err := GetDerivedPubKey(poolPubKey, chainCode, "m/0")
+	c.Assert(err, IsNil)
+	c.Assert(childPubKey, Equals, expected)
+	c.Assert(addr.Bytes(), DeepEquals, childPk.Address().Bytes())
+
+	_, _, err = GetDerivedPubKey(poolPubKey, chainCode, "m/0'")
+	c.Assert(err, NotNil)
+	_, _, err = GetDerivedPubKey("invalid", chainCode, "m/0")
+	c.Assert(err, NotNil)
+}
@@ -7,8 +7,10 @@ import (
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	tcrypto "github.com/tendermint/tendermint/crypto"
@@ -116,9 +118,32 @@ func CheckKeyOnCurve(pk string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("fail to parse pub key(%s): %w", pk, err)
 	}
+	if _, ok := pubKey.(*ed25519.PubKey); ok {
+		ePk, err := edwards.ParsePubKey(pubKey.Bytes())
+		if err != nil {
+			return false, err
+		}
+		return edwards.Edwards().IsOnCurve(ePk.X, ePk.Y), nil
+	}
 	bPk, err := btcec.ParsePubKey(pubKey.Bytes(), btcec.S256())
 	if err != nil {
 		return false, err
 	}
 	return isOnCurve(bPk.X, bPk.Y), nil
 }
+
+// GetECDSAPubKey return the secp256k1 pub key of the given bech32 pub key
+func GetECDSAPubKey(pk string) (*btcec.PublicKey, error) {
+	pubKey, err := sdk.GetPubKeyFromBech32(sdk.Bech32PubKeyTypeAccPub, pk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse pub key(%s): %w", pk, err)
+	}
+	if _, ok := pubKey.(*coskey.PubKey); !ok {
+		return nil, fmt.Errorf("pub key(%s) is not a secp256k1 key", pk)
+	}
+	bPk, err := btcec.ParsePubKey(pubKey.Bytes(), btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse pub key(%s): %w", pk, err)
+	}
+	return bPk, nil
+}
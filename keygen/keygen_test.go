@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"golang.org/x/crypto/sha3"
 	"io/ioutil"
 	"os"
@@ -221,6 +222,217 @@ func (s *TssKeygenTestSuite) TestGenerateNewKey(c *C) {
 	}
 }
 
+func (s *TssKeygenTestSuite) TestGenerateNewKeyWithThreshold(c *C) {
+	sort.Strings(testPubKeys)
+	req := NewRequest(testPubKeys, 10, "")
+	req.Threshold = 2
+	messageID, err := common.MsgToHashString([]byte(strings.Join(req.Keys, "")))
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   120 * time.Second,
+		KeySignTimeout:  120 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int]*crypto.ECPoint)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			stopChan := make(chan struct{})
+			localPubKey := testPubKeys[idx]
+			keygenInstance := NewTssKeyGen(
+				comm.GetLocalPeerID(),
+				conf,
+				localPubKey,
+				comm.BroadcastMsgChan,
+				stopChan,
+				s.preParams[idx],
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			c.Assert(keygenInstance, NotNil)
+			keygenMsgChannel := keygenInstance.GetTssKeyGenChannels()
+			comm.SetSubscribe(messages.TSSKeyGenMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSKeyGenVerMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, keygenMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSKeyGenMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSKeyGenVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			resp, err := keygenInstance.GenerateNewKey(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	ans := keygenResult[0]
+	poolPubKey, _, err := conversion.GetTssPubKey(ans)
+	c.Assert(err, IsNil)
+	for i := 0; i < s.partyNum; i++ {
+		c.Assert(keygenResult[i].Equals(ans), Equals, true)
+		state, err := s.stateMgrs[i].GetLocalState(poolPubKey)
+		c.Assert(err, IsNil)
+		c.Assert(state.Threshold, Equals, 2)
+		threshold, err := state.GetThreshold()
+		c.Assert(err, IsNil)
+		c.Assert(threshold, Equals, 1)
+	}
+}
+
+func (s *TssKeygenTestSuite) TestGenerateNewKeyWithInvalidThreshold(c *C) {
+	sort.Strings(testPubKeys)
+	req := NewRequest(testPubKeys, 10, "")
+	req.Threshold = 5
+	keygenInstance := NewTssKeyGen("", common.TssConfig{}, testPubKeys[0], nil, nil, s.preParams[0], "test", s.stateMgrs[0], s.nodePrivKeys[0], s.comms[0])
+	_, err := keygenInstance.GenerateNewKey(req)
+	c.Assert(err, NotNil)
+}
+
+func (s *TssKeygenTestSuite) TestGenerateNewKeyEdDSA(c *C) {
+	sort.Strings(testPubKeys)
+	req := NewRequest(testPubKeys, 10, "")
+	req.Algo = common.EdDSA
+	messageID, err := common.MsgToHashString([]byte(string(common.EdDSA) + strings.Join(req.Keys, "")))
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   60 * time.Second,
+		KeySignTimeout:  60 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int]*crypto.ECPoint)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			stopChan := make(chan struct{})
+			localPubKey := testPubKeys[idx]
+			// EdDSA keygen does not need the pre-parameters
+			keygenInstance := NewTssKeyGen(
+				comm.GetLocalPeerID(),
+				conf,
+				localPubKey,
+				comm.BroadcastMsgChan,
+				stopChan,
+				nil,
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			c.Assert(keygenInstance, NotNil)
+			keygenMsgChannel := keygenInstance.GetTssKeyGenChannels()
+			comm.SetSubscribe(messages.TSSKeyGenMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSKeyGenVerMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, keygenMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSKeyGenMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSKeyGenVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			resp, err := keygenInstance.GenerateNewKey(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	ans := keygenResult[0]
+	for _, el := range keygenResult {
+		c.Assert(el.Equals(ans), Equals, true)
+	}
+	poolPubKey, _, err := conversion.GetTssPubKeyEDDSA(ans)
+	c.Assert(err, IsNil)
+	for i := 0; i < s.partyNum; i++ {
+		state, err := s.stateMgrs[i].GetLocalState(poolPubKey)
+		c.Assert(err, IsNil)
+		c.Assert(state.GetAlgo(), Equals, common.EdDSA)
+		c.Assert(state.EdDSALocalData, NotNil)
+		c.Assert(state.EdDSALocalData.EDDSAPub.Equals(ans), Equals, true)
+		c.Assert(state.Threshold, Equals, 3)
+	}
+}
+
+func (s *TssKeygenTestSuite) TestGenerateNewKeys(c *C) {
+	sort.Strings(testPubKeys)
+	req := NewRequest(testPubKeys, 10, "")
+	req.KeyCount = 2
+	messageID, err := common.MsgToHashString([]byte("keys2" + strings.Join(req.Keys, "")))
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   120 * time.Second,
+		KeySignTimeout:  120 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int][]*crypto.ECPoint)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			stopChan := make(chan struct{})
+			localPubKey := testPubKeys[idx]
+			// the test only has a set of pre-parameters for each node, both keys use it
+			preParams := []*btsskeygen.LocalPreParams{s.preParams[idx], s.preParams[idx]}
+			keygenInstance := NewTssBatchKeyGen(
+				comm.GetLocalPeerID(),
+				conf,
+				localPubKey,
+				comm.BroadcastMsgChan,
+				stopChan,
+				preParams,
+				2,
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			c.Assert(keygenInstance, NotNil)
+			keygenMsgChannel := keygenInstance.GetTssKeyGenChannels()
+			comm.SetSubscribe(messages.TSSKeyGenMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSKeyGenVerMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, keygenMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, keygenMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSKeyGenMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSKeyGenVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			resp, err := keygenInstance.GenerateNewKeys(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	ans := keygenResult[0]
+	c.Assert(ans, HasLen, 2)
+	c.Assert(ans[0].Equals(ans[1]), Equals, false)
+	for _, el := range keygenResult {
+		c.Assert(el, HasLen, 2)
+		c.Assert(el[0].Equals(ans[0]), Equals, true)
+		c.Assert(el[1].Equals(ans[1]), Equals, true)
+	}
+	for _, key := range ans {
+		poolPubKey, _, err := conversion.GetTssPubKey(key)
+		c.Assert(err, IsNil)
+		for i := 0; i < s.partyNum; i++ {
+			state, err := s.stateMgrs[i].GetLocalState(poolPubKey)
+			c.Assert(err, IsNil)
+			c.Assert(state.LocalData.ECDSAPub.Equals(key), Equals, true)
+		}
+	}
+
+	// the keygen is created for the number of keys of the request
+	keygenInstance := NewTssKeyGen("", conf, testPubKeys[0], nil, nil, s.preParams[0], "test", s.stateMgrs[0], s.nodePrivKeys[0], s.comms[0])
+	_, err = keygenInstance.GenerateNewKeys(req)
+	c.Assert(err, NotNil)
+}
+
 func (s *TssKeygenTestSuite) TestGenerateNewKeyWithStop(c *C) {
 	conf := common.TssConfig{
 		KeyGenTimeout:   20 * time.Second,
@@ -322,3 +534,57 @@ func (s *TssKeygenTestSuite) TestCloseKeyGenNotifyChannel(c *C) {
 	err = keyGenInstance.tssCommonStruct.ProcessOneMessage(msg, "node1")
 	c.Assert(err, ErrorMatches, "duplicated notification from peer node1 ignored")
 }
+
+// failingStateMgr fail to save the local state of the given pool
+type failingStateMgr struct {
+	storage.LocalStateManager
+	failPubKey string
+}
+
+func (f *failingStateMgr) SaveLocalState(state storage.KeygenLocalState) error {
+	if state.PubKey == f.failPubKey {
+		return errors.New("you ask for it")
+	}
+	return f.LocalStateManager.SaveLocalState(state)
+}
+
+func (s *TssKeygenTestSuite) TestSaveLocalStates(c *C) {
+	fsm, err := storage.NewFileStateMgr(c.MkDir(), []byte("12345678901234567890123456789012"))
+	c.Assert(err, IsNil)
+	stateManager := &failingStateMgr{LocalStateManager: fsm, failPubKey: testPubKeys[2]}
+	keyGenInstance := NewTssKeyGen("", common.TssConfig{}, "", nil, nil, nil, "test", stateManager, s.nodePrivKeys[0], nil)
+	var localStates []storage.KeygenLocalState
+	for _, el := range testPubKeys[:2] {
+		localStates = append(localStates, storage.KeygenLocalState{
+			PubKey:          el,
+			LocalData:       btsskeygen.NewLocalPartySaveData(4),
+			ParticipantKeys: testPubKeys,
+			LocalPartyKey:   testPubKeys[0],
+		})
+	}
+	c.Assert(keyGenInstance.saveLocalStates(localStates), IsNil)
+	for _, el := range testPubKeys[:2] {
+		_, err := fsm.GetLocalState(el)
+		c.Assert(err, IsNil)
+	}
+
+	// the batch is rolled back when any of the local states fails to save
+	localStates[0].PubKey = testPubKeys[3]
+	localStates[1].PubKey = testPubKeys[2]
+	c.Assert(keyGenInstance.saveLocalStates(localStates), NotNil)
+	_, err = fsm.GetLocalState(testPubKeys[3])
+	c.Assert(storage.IsLocalStateNotFound(err), Equals, true)
+	_, err = fsm.GetLocalState(testPubKeys[2])
+	c.Assert(storage.IsLocalStateNotFound(err), Equals, true)
+}
+
+func (s *TssKeygenTestSuite) TestValidateKeyCount(c *C) {
+	req := NewRequest(testPubKeys, 10, "0.14.0")
+	c.Assert(req.ValidateKeyCount(), IsNil)
+	req.KeyCount = MaxKeyCount
+	c.Assert(req.ValidateKeyCount(), IsNil)
+	req.KeyCount = MaxKeyCount + 1
+	c.Assert(req.ValidateKeyCount(), NotNil)
+	req.KeyCount = -1
+	c.Assert(req.ValidateKeyCount(), NotNil)
+}
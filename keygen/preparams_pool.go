@@ -0,0 +1,245 @@
+package keygen
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	bkg "github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// generateRetryDelay is the wait after the first failed generation, it doubles with every failure in a row
+	generateRetryDelay = time.Second
+	// maxGenerateRetryDelay is the longest wait before the generation is retried
+	maxGenerateRetryDelay = time.Minute
+)
+
+// PreParamsStore keeps the pre-parameters across restarts
+type PreParamsStore interface {
+	SavePreParams(preParams []*bkg.LocalPreParams) error
+	GetPreParams() ([]*bkg.LocalPreParams, error)
+}
+
+// PreParamsPool generates the Paillier pre-parameters in the background and keeps them encrypted in the store, so
+// that every keygen uses a fresh set of them without waiting for the generation
+type PreParamsPool struct {
+	logger      zerolog.Logger
+	store       PreParamsStore
+	size        int
+	concurrency int
+	timeout     time.Duration
+	lock        *sync.Mutex
+	preParams   []*bkg.LocalPreParams
+	generating  int
+	wakeUp      chan struct{}
+	stopChan    chan struct{}
+	stopOnce    *sync.Once
+}
+
+// NewPreParamsPool create a pool that keeps size sets of pre-parameters buffered, generating concurrency of them
+// at the same time, the pre-parameters saved in the store by a previous run are validated and loaded back
+func NewPreParamsPool(store PreParamsStore, size, concurrency int, timeout time.Duration) (*PreParamsPool, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	pool := &PreParamsPool{
+		logger:      log.With().Str("module", "preparams").Logger(),
+		store:       store,
+		size:        size,
+		concurrency: concurrency,
+		timeout:     timeout,
+		lock:        &sync.Mutex{},
+		wakeUp:      make(chan struct{}, 1),
+		stopChan:    make(chan struct{}),
+		stopOnce:    &sync.Once{},
+	}
+	if err := pool.load(); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+func (p *PreParamsPool) load() error {
+	saved, err := p.store.GetPreParams()
+	if err != nil {
+		return fmt.Errorf("fail to load the pre-parameters: %w", err)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, el := range saved {
+		if el == nil || !el.Validate() {
+			p.logger.Warn().Msg("drop the invalid pre-parameters")
+			continue
+		}
+		p.preParams = append(p.preParams, el)
+	}
+	if len(p.preParams) > 0 {
+		p.logger.Info().Msgf("%d pre-parameters loaded", len(p.preParams))
+	}
+	if len(p.preParams) != len(saved) {
+		return p.save(p.preParams)
+	}
+	return nil
+}
+
+// save write the given pre-parameters over the ones in the store, it must be called with the lock held
+func (p *PreParamsPool) save(preParams []*bkg.LocalPreParams) error {
+	if err := p.store.SavePreParams(preParams); err != nil {
+		return fmt.Errorf("fail to save the pre-parameters: %w", err)
+	}
+	return nil
+}
+
+// Start the background generation of the pre-parameters
+func (p *PreParamsPool) Start() {
+	for i := 0; i < p.concurrency; i++ {
+		go p.generate()
+	}
+}
+
+// Stop the background generation, the pre-parameters being generated are still saved once they are done
+func (p *PreParamsPool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+}
+
+func (p *PreParamsPool) notify() {
+	select {
+	case p.wakeUp <- struct{}{}:
+	default:
+	}
+}
+
+// generateOne generate a set of pre-parameters when less than target of them are buffered or being generated, it
+// returns false when no more of them is needed
+func (p *PreParamsPool) generateOne(target int) (bool, error) {
+	p.lock.Lock()
+	if len(p.preParams)+p.generating >= target {
+		p.lock.Unlock()
+		return false, nil
+	}
+	p.generating++
+	p.lock.Unlock()
+
+	preParams, err := bkg.GeneratePreParams(p.timeout)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.generating--
+	if err != nil {
+		return true, fmt.Errorf("fail to generate the pre-parameters: %w", err)
+	}
+	p.preParams = append(p.preParams, preParams)
+	if err := p.save(p.preParams); err != nil {
+		return true, err
+	}
+	p.logger.Info().Msgf("pre-parameters generated, %d of %d buffered", len(p.preParams), target)
+	return true, nil
+}
+
+func (p *PreParamsPool) generate() {
+	delay := generateRetryDelay
+	for {
+		generated, err := p.generateOne(p.size)
+		if err != nil {
+			p.logger.Error().Err(err).Msgf("fail to generate the pre-parameters, retry in %s", delay)
+			select {
+			case <-p.stopChan:
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > maxGenerateRetryDelay {
+				delay = maxGenerateRetryDelay
+			}
+			continue
+		}
+		delay = generateRetryDelay
+		if !generated {
+			select {
+			case <-p.stopChan:
+				return
+			case <-p.wakeUp:
+				continue
+			}
+		}
+		select {
+		case <-p.stopChan:
+			return
+		default:
+		}
+	}
+}
+
+// Fill generate the pre-parameters in the foreground till num of them are buffered, it is used to generate them
+// before the tss starts
+func (p *PreParamsPool) Fill(num int) error {
+	errChan := make(chan error, p.concurrency)
+	wg := sync.WaitGroup{}
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				generated, err := p.generateOne(num)
+				if err != nil {
+					errChan <- err
+					return
+				}
+				if !generated {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errChan)
+	return <-errChan
+}
+
+// Take remove a set of pre-parameters from the pool and return it, the removal is saved before the pre-parameters
+// are returned so that they are never used twice, the pool is left as it is when the removal can not be saved. The
+// pre-parameters are generated on the spot if the pool is empty
+func (p *PreParamsPool) Take() (*bkg.LocalPreParams, error) {
+	p.lock.Lock()
+	if len(p.preParams) > 0 {
+		preParams := p.preParams[0]
+		if err := p.save(p.preParams[1:]); err != nil {
+			p.lock.Unlock()
+			return nil, err
+		}
+		p.preParams = p.preParams[1:]
+		p.lock.Unlock()
+		p.notify()
+		return preParams, nil
+	}
+	p.lock.Unlock()
+	p.notify()
+	p.logger.Warn().Msg("no pre-parameters buffered, generate them now")
+	preParams, err := bkg.GeneratePreParams(p.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("fail to generate pre parameters: %w", err)
+	}
+	return preParams, nil
+}
+
+// Return put the pre-parameters taken but never used back to the pool, so the next keygen uses them
+func (p *PreParamsPool) Return(preParams []*bkg.LocalPreParams) error {
+	if len(preParams) == 0 {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.preParams = append(append([]*bkg.LocalPreParams{}, preParams...), p.preParams...)
+	return p.save(p.preParams)
+}
+
+// Size return the number of pre-parameters buffered
+func (p *PreParamsPool) Size() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.preParams)
+}
@@ -0,0 +1,112 @@
+package keygen
+
+import (
+	"errors"
+	"time"
+
+	btsskeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+type PreParamsPoolTestSuite struct{}
+
+var _ = Suite(&PreParamsPoolTestSuite{})
+
+// failingPreParamsStore fail to save the pre-parameters while fail is set
+type failingPreParamsStore struct {
+	PreParamsStore
+	fail bool
+}
+
+func (s *failingPreParamsStore) SavePreParams(preParams []*btsskeygen.LocalPreParams) error {
+	if s.fail {
+		return errors.New("fail to write")
+	}
+	return s.PreParamsStore.SavePreParams(preParams)
+}
+
+func (s *PreParamsPoolTestSuite) TestPreParamsPool(c *C) {
+	folder := c.MkDir()
+	sk := []byte("12345678901234567890123456789012")
+	store, err := storage.NewFileStateMgr(folder, sk)
+	c.Assert(err, IsNil)
+	pool, err := NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 0)
+
+	preParams := getPreparams(c)
+	pool.lock.Lock()
+	pool.preParams = append(pool.preParams, preParams[0], preParams[1])
+	c.Assert(pool.save(pool.preParams), IsNil)
+	pool.lock.Unlock()
+
+	// the pool is left as it is when the removal can not be saved
+	failingStore := &failingPreParamsStore{PreParamsStore: store}
+	pool, err = NewPreParamsPool(failingStore, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	failingStore.fail = true
+	_, err = pool.Take()
+	c.Assert(err, NotNil)
+	c.Assert(pool.Size(), Equals, 2)
+
+	// the pre-parameters are loaded back by a new pool
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 2)
+	taken, err := pool.Take()
+	c.Assert(err, IsNil)
+	c.Assert(taken.NTildei.Cmp(preParams[0].NTildei), Equals, 0)
+	c.Assert(pool.Size(), Equals, 1)
+
+	// the pre-parameters taken are gone from the disk
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 1)
+	taken, err = pool.Take()
+	c.Assert(err, IsNil)
+	c.Assert(taken.NTildei.Cmp(preParams[1].NTildei), Equals, 0)
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 0)
+
+	// the pre-parameters returned unused are taken first, they are saved as well
+	c.Assert(pool.Return([]*btsskeygen.LocalPreParams{taken}), IsNil)
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 1)
+	taken, err = pool.Take()
+	c.Assert(err, IsNil)
+	c.Assert(taken.NTildei.Cmp(preParams[1].NTildei), Equals, 0)
+
+	// the pool can be stopped more than once
+	pool.Start()
+	pool.Stop()
+	pool.Stop()
+
+	// the pre-parameters can not be read with another key
+	pool.lock.Lock()
+	pool.preParams = append(pool.preParams, preParams[2])
+	c.Assert(pool.save(pool.preParams), IsNil)
+	pool.lock.Unlock()
+	otherStore, err := storage.NewFileStateMgr(folder, []byte("02345678901234567890123456789012"))
+	c.Assert(err, IsNil)
+	_, err = NewPreParamsPool(otherStore, 0, 1, time.Second)
+	c.Assert(err, NotNil)
+
+	// nothing is generated when enough pre-parameters are buffered
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Fill(1), IsNil)
+	c.Assert(pool.Size(), Equals, 1)
+
+	// the invalid pre-parameters are dropped when they are loaded
+	c.Assert(store.SavePreParams([]*btsskeygen.LocalPreParams{preParams[2], {}}), IsNil)
+	pool, err = NewPreParamsPool(store, 0, 1, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Size(), Equals, 1)
+	saved, err := store.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(saved, HasLen, 1)
+}
@@ -1,10 +1,23 @@
 package keygen
 
+import (
+	"fmt"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+// MaxKeyCount is the most pool keys a keygen ceremony creates, every key runs a full keygen and uses a set of
+// pre-parameters
+const MaxKeyCount = 32
+
 // Request request to do keygen
 type Request struct {
-	Keys        []string `json:"keys"`
-	BlockHeight int64    `json:"block_height"`
-	Version     string   `json:"tss_version"`
+	Keys        []string    `json:"keys"`
+	BlockHeight int64       `json:"block_height"`
+	Version     string      `json:"tss_version"`
+	Algo        common.Algo `json:"algo,omitempty"`      // signature scheme of the new pool, default to ECDSA
+	Threshold   int         `json:"threshold,omitempty"` // number of parties needed to sign, default to 2/3 of the parties
+	KeyCount    int         `json:"key_count,omitempty"` // number of pool keys created in the ceremony, default to 1
 }
 
 // NewRequest creeate a new instance of keygen.Request
@@ -15,3 +28,19 @@ func NewRequest(keys []string, blockHeight int64, version string) Request {
 		Version:     version,
 	}
 }
+
+// ValidateKeyCount check the number of pool keys the request asks for
+func (r Request) ValidateKeyCount() error {
+	if r.KeyCount < 0 || r.KeyCount > MaxKeyCount {
+		return fmt.Errorf("invalid key count %d, it must be between 0 and %d", r.KeyCount, MaxKeyCount)
+	}
+	return nil
+}
+
+// GetKeyCount return the number of pool keys the request asks for
+func (r Request) GetKeyCount() int {
+	if r.KeyCount <= 0 {
+		return 1
+	}
+	return r.KeyCount
+}
@@ -7,10 +7,12 @@ import (
 
 // Response keygen response
 type Response struct {
-	PubKey      string        `json:"pub_key"`
-	PoolAddress string        `json:"pool_address"`
-	Status      common.Status `json:"status"`
-	Blame       blame.Blame   `json:"blame"`
+	PubKey        string        `json:"pub_key"`
+	PoolAddress   string        `json:"pool_address"`
+	Status        common.Status `json:"status"`
+	Blame         blame.Blame   `json:"blame"`
+	PubKeys       []string      `json:"pub_keys,omitempty"`       // all the keys created in the ceremony, the first one is PubKey
+	PoolAddresses []string      `json:"pool_addresses,omitempty"` // the addresses of PubKeys
 }
 
 // NewResponse create a new instance of keygen.Response
@@ -3,15 +3,19 @@ package keygen
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	bcrypto "github.com/binance-chain/tss-lib/crypto"
 	bkg "github.com/binance-chain/tss-lib/ecdsa/keygen"
+	eddsakeygen "github.com/binance-chain/tss-lib/eddsa/keygen"
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	tcrypto "github.com/tendermint/tendermint/crypto"
+	"go.uber.org/atomic"
 
 	"gitlab.com/thorchain/tss/go-tss/blame"
 	"gitlab.com/thorchain/tss/go-tss/common"
@@ -24,13 +28,14 @@ import (
 type TssKeyGen struct {
 	logger          zerolog.Logger
 	localNodePubKey string
-	preParams       *bkg.LocalPreParams
+	preParams       []*bkg.LocalPreParams
+	keyNum          int
 	tssCommonStruct *common.TssCommon
 	stopChan        chan struct{} // channel to indicate whether we should stop
 	localParty      *btss.PartyID
 	stateManager    storage.LocalStateManager
 	commStopChan    chan struct{}
-	p2pComm         *p2p.Communication
+	p2pComm         p2p.Transport
 }
 
 func NewTssKeyGen(localP2PID string,
@@ -42,14 +47,35 @@ func NewTssKeyGen(localP2PID string,
 	msgID string,
 	stateManager storage.LocalStateManager,
 	privateKey tcrypto.PrivKey,
-	p2pComm *p2p.Communication) *TssKeyGen {
+	p2pComm p2p.Transport) *TssKeyGen {
+	var preParams []*bkg.LocalPreParams
+	if preParam != nil {
+		preParams = []*bkg.LocalPreParams{preParam}
+	}
+	return NewTssBatchKeyGen(localP2PID, conf, localNodePubKey, broadcastChan, stopChan, preParams, 1, msgID, stateManager, privateKey, p2pComm)
+}
+
+// NewTssBatchKeyGen create a keygen that creates keyNum pool keys in one ceremony, every ECDSA key needs its own
+// pre-parameters
+func NewTssBatchKeyGen(localP2PID string,
+	conf common.TssConfig,
+	localNodePubKey string,
+	broadcastChan chan *messages.BroadcastMsgChan,
+	stopChan chan struct{},
+	preParams []*bkg.LocalPreParams,
+	keyNum int,
+	msgID string,
+	stateManager storage.LocalStateManager,
+	privateKey tcrypto.PrivKey,
+	p2pComm p2p.Transport) *TssKeyGen {
 	return &TssKeyGen{
 		logger: log.With().
 			Str("module", "keygen").
 			Str("msgID", msgID).Logger(),
 		localNodePubKey: localNodePubKey,
-		preParams:       preParam,
-		tssCommonStruct: common.NewTssCommon(localP2PID, broadcastChan, conf, msgID, privateKey, 1),
+		preParams:       preParams,
+		keyNum:          keyNum,
+		tssCommonStruct: common.NewTssCommon(localP2PID, broadcastChan, conf, msgID, privateKey, keyNum),
 		stopChan:        stopChan,
 		localParty:      nil,
 		stateManager:    stateManager,
@@ -66,45 +92,119 @@ func (tKeyGen *TssKeyGen) GetTssCommonStruct() *common.TssCommon {
 	return tKeyGen.tssCommonStruct
 }
 
+// getMoniker return the moniker of the keygen party of the key at the given index, a single keygen keeps the
+// default empty moniker so that it works with the nodes that never run multi keygen
+func getMoniker(idx, keyNum int) string {
+	if keyNum == 1 {
+		return ""
+	}
+	return "keygen:" + strconv.Itoa(idx)
+}
+
+func (tKeyGen *TssKeyGen) startBatchKeyGen(keyGenPartyMap *sync.Map) bool {
+	var keyGenWg sync.WaitGroup
+	ret := atomic.NewBool(true)
+	keyGenWg.Add(tKeyGen.keyNum)
+	keyGenPartyMap.Range(func(key, value interface{}) bool {
+		eachParty := value.(btss.Party)
+		go func(eachParty btss.Party) {
+			defer keyGenWg.Done()
+			if err := eachParty.Start(); err != nil {
+				tKeyGen.logger.Error().Err(err).Msg("fail to start keygen party")
+				ret.Store(false)
+			}
+			tKeyGen.logger.Info().Msgf("local party(%s) %s is ready", eachParty.PartyID().Id, eachParty.PartyID().Moniker)
+		}(eachParty)
+		return true
+	})
+	keyGenWg.Wait()
+	return ret.Load()
+}
+
 func (tKeyGen *TssKeyGen) GenerateNewKey(keygenReq Request) (*bcrypto.ECPoint, error) {
-	partiesID, localPartyID, err := conversion.GetParties(keygenReq.Keys, tKeyGen.localNodePubKey)
+	keys, err := tKeyGen.GenerateNewKeys(keygenReq)
+	if err != nil {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// GenerateNewKeys create all the pool keys of the request in one ceremony, the keys are sorted by their pub keys
+func (tKeyGen *TssKeyGen) GenerateNewKeys(keygenReq Request) ([]*bcrypto.ECPoint, error) {
+	algo, ok := common.GetAlgo(keygenReq.Algo)
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %s", keygenReq.Algo)
+	}
+	if keygenReq.GetKeyCount() != tKeyGen.keyNum {
+		return nil, fmt.Errorf("the request asks for %d keys while the keygen is created for %d", keygenReq.GetKeyCount(), tKeyGen.keyNum)
+	}
+	partiesID, _, err := conversion.GetParties(keygenReq.Keys, tKeyGen.localNodePubKey)
 	if err != nil {
 		return nil, fmt.Errorf("fail to get keygen parties: %w", err)
 	}
 
+	threshold, err := conversion.GetThresholdWithSigners(len(partiesID), keygenReq.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	if algo != common.EdDSA && len(tKeyGen.preParams) != tKeyGen.keyNum {
+		tKeyGen.logger.Error().Msgf("%d pre-parameters for %d keys", len(tKeyGen.preParams), tKeyGen.keyNum)
+		return nil, errors.New("error, empty pre-parameters")
+	}
 	keyGenLocalStateItem := storage.KeygenLocalState{
 		ParticipantKeys: keygenReq.Keys,
 		LocalPartyKey:   tKeyGen.localNodePubKey,
+		Algo:            algo,
+		Threshold:       threshold + 1,
+		BlockHeight:     keygenReq.BlockHeight,
+		MsgID:           tKeyGen.tssCommonStruct.GetMsgID(),
 	}
 
-	threshold, err := conversion.GetThreshold(len(partiesID))
-	if err != nil {
-		return nil, err
-	}
 	keyGenPartyMap := new(sync.Map)
-	ctx := btss.NewPeerContext(partiesID)
-	params := btss.NewParameters(ctx, localPartyID, len(partiesID), threshold)
-	outCh := make(chan btss.Message, len(partiesID))
-	endCh := make(chan bkg.LocalPartySaveData, len(partiesID))
+	outCh := make(chan btss.Message, len(partiesID)*tKeyGen.keyNum)
+	// only the end channel of the algorithm in use is created, a nil channel is never selected
+	var endCh chan bkg.LocalPartySaveData
+	var eddsaEndCh chan eddsakeygen.LocalPartySaveData
+	if algo == common.EdDSA {
+		eddsaEndCh = make(chan eddsakeygen.LocalPartySaveData, len(partiesID)*tKeyGen.keyNum)
+	} else {
+		endCh = make(chan bkg.LocalPartySaveData, len(partiesID)*tKeyGen.keyNum)
+	}
 	errChan := make(chan struct{})
-	if tKeyGen.preParams == nil {
-		tKeyGen.logger.Error().Err(err).Msg("error, empty pre-parameters")
-		return nil, errors.New("error, empty pre-parameters")
+	// tss-lib works on a process wide curve, hold it till the local parties are done
+	if err := tKeyGen.tssCommonStruct.AcquireCurve(algo, 0); err != nil {
+		return nil, err
+	}
+	defer tKeyGen.tssCommonStruct.ReleaseCurve()
+	for i := 0; i < tKeyGen.keyNum; i++ {
+		moniker := getMoniker(i, tKeyGen.keyNum)
+		eachPartiesID, eachLocalPartyID, err := conversion.GetParties(keygenReq.Keys, tKeyGen.localNodePubKey)
+		if err != nil {
+			return nil, fmt.Errorf("error to create parties in batch keygen: %w", err)
+		}
+		eachLocalPartyID.Moniker = moniker
+		ctx := btss.NewPeerContext(eachPartiesID)
+		params := btss.NewParameters(ctx, eachLocalPartyID, len(eachPartiesID), threshold)
+		var keyGenParty btss.Party
+		if algo == common.EdDSA {
+			keyGenParty = eddsakeygen.NewLocalParty(params, outCh, eddsaEndCh)
+		} else {
+			keyGenParty = bkg.NewLocalParty(params, outCh, endCh, *tKeyGen.preParams[i])
+		}
+		keyGenPartyMap.Store(moniker, keyGenParty)
 	}
 	blameMgr := tKeyGen.tssCommonStruct.GetBlameMgr()
-	keyGenParty := bkg.NewLocalParty(params, outCh, endCh, *tKeyGen.preParams)
 	partyIDMap := conversion.SetupPartyIDMap(partiesID)
 	err1 := conversion.SetupIDMaps(partyIDMap, tKeyGen.tssCommonStruct.PartyIDtoP2PID)
 	err2 := conversion.SetupIDMaps(partyIDMap, blameMgr.PartyIDtoP2PID)
 	if err1 != nil || err2 != nil {
 		tKeyGen.logger.Error().Msgf("error in creating mapping between partyID and P2P ID")
-		return nil, err
+		return nil, errors.New("fail to create mapping between partyID and P2P ID")
 	}
-	// we never run multi keygen, so the moniker is set to default empty value
-	keyGenPartyMap.Store("", keyGenParty)
 	partyInfo := &common.PartyInfo{
 		PartyMap:   keyGenPartyMap,
 		PartyIDMap: partyIDMap,
+		Algo:       algo,
 	}
 
 	tKeyGen.tssCommonStruct.SetPartyInfo(partyInfo)
@@ -118,14 +218,13 @@ func (tKeyGen *TssKeyGen) GenerateNewKey(keygenReq Request) (*bcrypto.ECPoint, e
 	go func() {
 		defer keyGenWg.Done()
 		defer tKeyGen.logger.Debug().Msg(">>>>>>>>>>>>>.keyGenParty started")
-		if err := keyGenParty.Start(); nil != err {
-			tKeyGen.logger.Error().Err(err).Msg("fail to start keygen party")
+		if !tKeyGen.startBatchKeyGen(keyGenPartyMap) {
 			close(errChan)
 		}
 	}()
 	go tKeyGen.tssCommonStruct.ProcessInboundMessages(tKeyGen.commStopChan, &keyGenWg)
 
-	r, err := tKeyGen.processKeyGen(errChan, outCh, endCh, keyGenLocalStateItem)
+	r, err := tKeyGen.processKeyGen(errChan, outCh, endCh, eddsaEndCh, keyGenLocalStateItem)
 	if err != nil {
 		close(tKeyGen.commStopChan)
 		return nil, fmt.Errorf("fail to process key sign: %w", err)
@@ -145,9 +244,14 @@ func (tKeyGen *TssKeyGen) GenerateNewKey(keygenReq Request) (*bcrypto.ECPoint, e
 func (tKeyGen *TssKeyGen) processKeyGen(errChan chan struct{},
 	outCh <-chan btss.Message,
 	endCh <-chan bkg.LocalPartySaveData,
-	keyGenLocalStateItem storage.KeygenLocalState) (*bcrypto.ECPoint, error) {
+	eddsaEndCh <-chan eddsakeygen.LocalPartySaveData,
+	keyGenLocalStateItem storage.KeygenLocalState) ([]*bcrypto.ECPoint, error) {
 	defer tKeyGen.logger.Debug().Msg("finished keygen process")
 	tKeyGen.logger.Debug().Msg("start to read messages from local party")
+	var keys []*bcrypto.ECPoint
+	var pubKeys []string
+	// the local states are only saved once all the keys of the batch are created
+	var localStates []storage.KeygenLocalState
 	tssConf := tKeyGen.tssCommonStruct.GetConf()
 	blameMgr := tKeyGen.tssCommonStruct.GetBlameMgr()
 	for {
@@ -171,6 +275,7 @@ func (tKeyGen *TssKeyGen) processKeyGen(errChan chan struct{},
 				tKeyGen.logger.Error().Msg("fail to start the keygen, the last produced message of this node is none")
 				return nil, errors.New("timeout before shared message is generated")
 			}
+			// EdDSA keygen names its unicast message the same as ECDSA does
 			blameNodesUnicast, err := blameMgr.GetUnicastBlame(messages.KEYGEN2aUnicast)
 			if err != nil {
 				tKeyGen.logger.Error().Err(err).Msg("error in get unicast blame")
@@ -191,9 +296,22 @@ func (tKeyGen *TssKeyGen) processKeyGen(errChan chan struct{},
 			}
 			blameMgr.GetBlame().AddBlameNodes(blameNodesBroadcast...)
 
+			// the nodes flooding us are blamed, their messages may have been dropped
+			blameNodesRateLimit, err := blameMgr.GetRateLimitBlame()
+			if err != nil {
+				tKeyGen.logger.Error().Err(err).Msg("error in get rate limit blame")
+			}
+			if len(blameNodesRateLimit) > 0 && len(blameNodesRateLimit) <= threshold {
+				blameMgr.GetBlame().AddBlameNodes(blameNodesRateLimit...)
+			}
+
 			// if we cannot find the blame node, we check whether everyone send me the share
 			if len(blameMgr.GetBlame().BlameNodes) == 0 {
-				blameNodesMisingShare, isUnicast, err := blameMgr.TssMissingShareBlame(messages.TSSKEYGENROUNDS)
+				rounds := messages.TSSKEYGENROUNDS
+				if keyGenLocalStateItem.Algo == common.EdDSA {
+					rounds = messages.EDDSAKEYGENROUNDS
+				}
+				blameNodesMisingShare, isUnicast, err := blameMgr.TssMissingShareBlame(rounds)
 				if err != nil {
 					tKeyGen.logger.Error().Err(err).Msg("fail to get the node of missing share ")
 				}
@@ -215,24 +333,89 @@ func (tKeyGen *TssKeyGen) processKeyGen(errChan chan struct{},
 
 		case msg := <-endCh:
 			tKeyGen.logger.Debug().Msgf("keygen finished successfully: %s", msg.ECDSAPub.Y().String())
-			err := tKeyGen.tssCommonStruct.NotifyTaskDone()
-			if err != nil {
-				tKeyGen.logger.Error().Err(err).Msg("fail to broadcast the keysign done")
-			}
 			pubKey, _, err := conversion.GetTssPubKey(msg.ECDSAPub)
 			if err != nil {
 				return nil, fmt.Errorf("fail to get thorchain pubkey: %w", err)
 			}
 			keyGenLocalStateItem.LocalData = msg
 			keyGenLocalStateItem.PubKey = pubKey
-			if err := tKeyGen.stateManager.SaveLocalState(keyGenLocalStateItem); err != nil {
-				return nil, fmt.Errorf("fail to save keygen result to storage: %w", err)
+			localStates = append(localStates, keyGenLocalStateItem)
+			keys = append(keys, msg.ECDSAPub)
+			pubKeys = append(pubKeys, pubKey)
+			if len(keys) == tKeyGen.keyNum {
+				if err := tKeyGen.saveLocalStates(localStates); err != nil {
+					return nil, err
+				}
+				return tKeyGen.keyGenDone(keys, pubKeys), nil
 			}
-			address := tKeyGen.p2pComm.ExportPeerAddress()
-			if err := tKeyGen.stateManager.SaveAddressBook(address); err != nil {
-				tKeyGen.logger.Error().Err(err).Msg("fail to save the peer addresses")
+
+		case msg := <-eddsaEndCh:
+			tKeyGen.logger.Debug().Msgf("eddsa keygen finished successfully: %s", msg.EDDSAPub.Y().String())
+			pubKey, _, err := conversion.GetTssPubKeyEDDSA(msg.EDDSAPub)
+			if err != nil {
+				return nil, fmt.Errorf("fail to get thorchain pubkey: %w", err)
+			}
+			keyGenLocalStateItem.EdDSALocalData = &msg
+			keyGenLocalStateItem.PubKey = pubKey
+			localStates = append(localStates, keyGenLocalStateItem)
+			keys = append(keys, msg.EDDSAPub)
+			pubKeys = append(pubKeys, pubKey)
+			if len(keys) == tKeyGen.keyNum {
+				if err := tKeyGen.saveLocalStates(localStates); err != nil {
+					return nil, err
+				}
+				return tKeyGen.keyGenDone(keys, pubKeys), nil
 			}
-			return msg.ECDSAPub, nil
 		}
 	}
 }
+
+// saveLocalStates save the local states of all the keys of the batch, in one go when the state manager can, or else
+// the ones already saved are retired when any of them fails to save, so the node never keeps part of a batch
+func (tKeyGen *TssKeyGen) saveLocalStates(localStates []storage.KeygenLocalState) error {
+	if batchStateManager, ok := tKeyGen.stateManager.(storage.BatchLocalStateManager); ok {
+		if err := batchStateManager.SaveLocalStates(localStates...); err != nil {
+			return fmt.Errorf("fail to save keygen result to storage: %w", err)
+		}
+		return nil
+	}
+	for i, el := range localStates {
+		err := tKeyGen.stateManager.SaveLocalState(el)
+		if err == nil {
+			continue
+		}
+		for _, saved := range localStates[:i] {
+			if errRetire := tKeyGen.stateManager.RetireLocalState(saved.PubKey); errRetire != nil {
+				tKeyGen.logger.Error().Err(errRetire).Msgf("fail to roll back the local state of pool(%s)", saved.PubKey)
+			}
+		}
+		return fmt.Errorf("fail to save keygen result to storage: %w", err)
+	}
+	return nil
+}
+
+// keyGenDone notify the peers once all the keys are created and sort the keys by their pub keys, so that every
+// node returns them in the same order
+func (tKeyGen *TssKeyGen) keyGenDone(keys []*bcrypto.ECPoint, pubKeys []string) []*bcrypto.ECPoint {
+	err := tKeyGen.tssCommonStruct.NotifyTaskDone()
+	if err != nil {
+		tKeyGen.logger.Error().Err(err).Msg("fail to broadcast the keygen done")
+	}
+	if err := tKeyGen.stateManager.SaveAddressBook(tKeyGen.p2pComm.ExportAddressBook()); err != nil {
+		tKeyGen.logger.Error().Err(err).Msg("fail to save the peer addresses")
+	}
+	sort.Sort(sortedKeys{keys: keys, pubKeys: pubKeys})
+	return keys
+}
+
+type sortedKeys struct {
+	keys    []*bcrypto.ECPoint
+	pubKeys []string
+}
+
+func (s sortedKeys) Len() int           { return len(s.keys) }
+func (s sortedKeys) Less(i, j int) bool { return s.pubKeys[i] < s.pubKeys[j] }
+func (s sortedKeys) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.pubKeys[i], s.pubKeys[j] = s.pubKeys[j], s.pubKeys[i]
+}
@@ -0,0 +1,45 @@
+package keysign
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/binance-chain/tss-lib/crypto"
+	"github.com/btcsuite/btcd/btcec"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+// SetDerivation make the key sign sign with the non-hardened BIP32 child key of the pool at the given path
+func (tKeySign *TssKeySign) SetDerivation(chainCode []byte, path []uint32) {
+	tKeySign.chainCode = chainCode
+	tKeySign.derivationPath = path
+}
+
+// deriveLocalState return a copy of the local state whose share is tweaked to the child key at the given path,
+// every party adds the same tweak to its share, so the shares stay on a polynomial whose secret is the child key
+func deriveLocalState(localStateItem storage.KeygenLocalState, chainCode []byte, path []uint32) (storage.KeygenLocalState, error) {
+	tweak, childKey, _, err := conversion.DeriveChildKey(localStateItem.LocalData.ECDSAPub, chainCode, path)
+	if err != nil {
+		return storage.KeygenLocalState{}, fmt.Errorf("fail to derive the child key: %w", err)
+	}
+	if tweak.Sign() == 0 {
+		return localStateItem, nil
+	}
+	curve := btcec.S256()
+	localData := localStateItem.LocalData
+	localData.Xi = new(big.Int).Add(localData.Xi, tweak)
+	localData.Xi.Mod(localData.Xi, curve.N)
+	tweakPoint := crypto.ScalarBaseMult(curve, tweak)
+	localData.BigXj = make([]*crypto.ECPoint, len(localStateItem.LocalData.BigXj))
+	for j, el := range localStateItem.LocalData.BigXj {
+		localData.BigXj[j], err = el.Add(tweakPoint)
+		if err != nil {
+			return storage.KeygenLocalState{}, fmt.Errorf("fail to tweak the share of party %d: %w", j, err)
+		}
+	}
+	localData.ECDSAPub = childKey
+	localStateItem.LocalData = localData
+	return localStateItem, nil
+}
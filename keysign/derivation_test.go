@@ -0,0 +1,83 @@
+package keysign
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	"github.com/btcsuite/btcd/btcec"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+func (s *TssKeysignTestSuite) TestSignMessageWithDerivation(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	sort.Strings(testPubKeys)
+	poolPubKey := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	chainCode, err := hex.DecodeString("873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508")
+	c.Assert(err, IsNil)
+	path, err := conversion.ParseDerivationPath("m/0/7")
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   90 * time.Second,
+		KeySignTimeout:  90 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	msgsToSign := [][]byte{[]byte("helloworld-derived")}
+	messageID, err := common.MsgToHashString([]byte("helloworld-derived"))
+	c.Assert(err, IsNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keysignResult := make(map[int][]*tsslibcommon.ECSignature)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			keysignIns, cancel := s.newKeySignInstance(idx, conf, messageID, 1)
+			defer cancel()
+			keysignIns.SetDerivation(chainCode, path)
+			localState, err := s.stateMgrs[idx].GetLocalState(poolPubKey)
+			c.Assert(err, IsNil)
+			sigs, err := keysignIns.SignMessage(msgsToSign, localState, testPubKeys, common.HashRaw)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx] = sigs
+		}(i)
+	}
+	wg.Wait()
+
+	localState, err := s.stateMgrs[0].GetLocalState(poolPubKey)
+	c.Assert(err, IsNil)
+	_, childKey, _, err := conversion.DeriveChildKey(localState.LocalData.ECDSAPub, chainCode, path)
+	c.Assert(err, IsNil)
+	childPubKey := &ecdsa.PublicKey{
+		Curve: btcec.S256(),
+		X:     childKey.X(),
+		Y:     childKey.Y(),
+	}
+	poolKey := &ecdsa.PublicKey{
+		Curve: btcec.S256(),
+		X:     localState.LocalData.ECDSAPub.X(),
+		Y:     localState.LocalData.ECDSAPub.Y(),
+	}
+	for i := 0; i < s.partyNum; i++ {
+		c.Assert(keysignResult[i], HasLen, 1)
+		sig := keysignResult[i][0]
+		c.Assert(sig.GetSignature(), DeepEquals, keysignResult[0][0].GetSignature())
+		r := new(big.Int).SetBytes(sig.R)
+		sigS := new(big.Int).SetBytes(sig.S)
+		c.Assert(ecdsa.Verify(childPubKey, sig.M, r, sigS), Equals, true)
+		c.Assert(ecdsa.Verify(poolKey, sig.M, r, sigS), Equals, false)
+	}
+}
@@ -16,9 +16,9 @@ import (
 	"time"
 
 	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	btsskeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/ipfs/go-log"
-	"github.com/libp2p/go-libp2p-peerstore/addr"
 	zlog "github.com/rs/zerolog/log"
 
 	"gitlab.com/thorchain/tss/go-tss/conversion"
@@ -66,7 +66,9 @@ func TestPackage(t *testing.T) {
 }
 
 type MockLocalStateManager struct {
-	file string
+	file          string
+	preSignLock   sync.Mutex
+	preSignatures []storage.PreSignature
 }
 
 func (m *MockLocalStateManager) SaveLocalState(state storage.KeygenLocalState) error {
@@ -85,12 +87,51 @@ func (m *MockLocalStateManager) GetLocalState(pubKey string) (storage.KeygenLoca
 	return state, nil
 }
 
-func (s *MockLocalStateManager) SaveAddressBook(address map[peer.ID]addr.AddrList) error {
+func (s *MockLocalStateManager) SaveAddressBook(addressBook *p2p.AddressBook) error {
 	return nil
 }
 
-func (s *MockLocalStateManager) RetrieveP2PAddresses() (addr.AddrList, error) {
-	return nil, os.ErrNotExist
+func (s *MockLocalStateManager) GetAddressBook() (*p2p.AddressBook, error) {
+	return p2p.NewAddressBook(), nil
+}
+
+func (s *MockLocalStateManager) SavePreSignatures(pubKey string, preSignatures []storage.PreSignature) error {
+	s.preSignLock.Lock()
+	defer s.preSignLock.Unlock()
+	s.preSignatures = append(s.preSignatures, preSignatures...)
+	return nil
+}
+
+func (s *MockLocalStateManager) TakePreSignatures(pubKey string, signers []string, ids []string) ([]storage.PreSignature, error) {
+	s.preSignLock.Lock()
+	defer s.preSignLock.Unlock()
+	taken, left := storage.PickPreSignatures(s.preSignatures, signers, ids)
+	s.preSignatures = left
+	return taken, nil
+}
+
+func (s *MockLocalStateManager) ListLocalStates() ([]string, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) SavePreParams(preParams []*btsskeygen.LocalPreParams) error {
+	return nil
+}
+
+func (s *MockLocalStateManager) GetPreParams() ([]*btsskeygen.LocalPreParams, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) GetLocalStateHistory(pubKey string) ([]storage.LocalStateVersion, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) PinLocalState(pubKey string, generation int64) error {
+	return nil
+}
+
+func (s *MockLocalStateManager) RetireLocalState(pubKey string) error {
+	return nil
 }
 
 type TssKeysignTestSuite struct {
@@ -209,7 +250,7 @@ func (s *TssKeysignTestSuite) TestSignMessage(c *C) {
 
 			localState, err := s.stateMgrs[idx].GetLocalState(req.PoolPubKey)
 			c.Assert(err, IsNil)
-			sig, err := keysignIns.SignMessage(msgForSign, localState, req.SignerPubKeys)
+			sig, err := keysignIns.SignMessage(msgForSign, localState, req.SignerPubKeys, common.HashRaw)
 
 			c.Assert(err, IsNil)
 			lock.Lock()
@@ -237,6 +278,61 @@ func (s *TssKeysignTestSuite) TestSignMessage(c *C) {
 	}
 }
 
+func (s *TssKeysignTestSuite) TestSignMessageWithHashMode(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	sort.Strings(testPubKeys)
+	poolPubKey := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	conf := common.TssConfig{
+		KeyGenTimeout:   90 * time.Second,
+		KeySignTimeout:  90 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	msg := []byte("helloworld-keccak")
+	messageID, err := common.MsgToHashString(append(msg, []byte(common.HashKeccak256)...))
+	c.Assert(err, IsNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keysignResult := make(map[int][]*tsslibcommon.ECSignature)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			keysignIns, cancel := s.newKeySignInstance(idx, conf, messageID, 1)
+			defer cancel()
+			localState, err := s.stateMgrs[idx].GetLocalState(poolPubKey)
+			c.Assert(err, IsNil)
+			sigs, err := keysignIns.SignMessage([][]byte{msg}, localState, testPubKeys, common.HashKeccak256)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx] = sigs
+		}(i)
+	}
+	wg.Wait()
+
+	digest, err := common.HashMessage(msg, common.HashKeccak256)
+	c.Assert(err, IsNil)
+	for i := 0; i < s.partyNum; i++ {
+		c.Assert(keysignResult[i], HasLen, 1)
+		c.Assert(keysignResult[i][0].M, DeepEquals, digest)
+		c.Assert(keysignResult[i][0].GetSignature(), DeepEquals, keysignResult[0][0].GetSignature())
+	}
+	// the notifier verifies the signature against the message hashed with the same mode
+	n, err := NewNotifier(messageID, [][]byte{msg}, poolPubKey, common.HashSHA256)
+	c.Assert(err, IsNil)
+	verified, err := n.ProcessSignature(keysignResult[0])
+	c.Assert(err, NotNil)
+	c.Assert(verified, Equals, false)
+	n, err = NewNotifier(messageID, [][]byte{msg}, poolPubKey, common.HashKeccak256)
+	c.Assert(err, IsNil)
+	verified, err = n.ProcessSignature(keysignResult[0])
+	c.Assert(err, IsNil)
+	c.Assert(verified, Equals, true)
+}
+
 func observeAndStop(c *C, tssKeySign *TssKeySign, stopChan chan struct{}) {
 	for {
 		select {
@@ -312,7 +408,7 @@ func (s *TssKeysignTestSuite) TestSignMessageWithStop(c *C) {
 			msgsToSign = append(msgsToSign, []byte(req.Messages[0]))
 			msgsToSign = append(msgsToSign, []byte(req.Messages[1]))
 
-			_, err = keysignIns.SignMessage(msgsToSign, localState, req.SignerPubKeys)
+			_, err = keysignIns.SignMessage(msgsToSign, localState, req.SignerPubKeys, common.HashRaw)
 			c.Assert(err, NotNil)
 			lastMsg := keysignIns.tssCommonStruct.GetBlameMgr().GetLastMsg()
 			zlog.Info().Msgf("%s------->last message %v, broadcast? %v", keysignIns.tssCommonStruct.GetLocalPeerID(), lastMsg.Type(), lastMsg.IsBroadcast())
@@ -403,7 +499,7 @@ func (s *TssKeysignTestSuite) TestSignMessageRejectOnePeer(c *C) {
 			var msgsToSign [][]byte
 			msgsToSign = append(msgsToSign, []byte(req.Messages[0]))
 			msgsToSign = append(msgsToSign, []byte(req.Messages[1]))
-			_, err = keysignIns.SignMessage(msgsToSign, localState, req.SignerPubKeys)
+			_, err = keysignIns.SignMessage(msgsToSign, localState, req.SignerPubKeys, common.HashRaw)
 			lastMsg := keysignIns.tssCommonStruct.GetBlameMgr().GetLastMsg()
 			zlog.Info().Msgf("%s------->last message %v, broadcast? %v", keysignIns.tssCommonStruct.GetLocalPeerID(), lastMsg.Type(), lastMsg.IsBroadcast())
 			c.Assert(err, IsNil)
@@ -2,13 +2,17 @@ package keysign
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/binance-chain/tss-lib/common"
+	cosed25519 "github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/tendermint/btcd/btcec"
+
+	tsscommon "gitlab.com/thorchain/tss/go-tss/common"
 )
 
 // Notifier is design to receive keysign signature, success or failure
@@ -16,11 +20,12 @@ type Notifier struct {
 	MessageID  string
 	messages   [][]byte // the message
 	poolPubKey string
+	hashMode   tsscommon.HashMode // how the messages are hashed before they are signed
 	resp       chan []*common.ECSignature
 }
 
 // NewNotifier create a new instance of Notifier
-func NewNotifier(messageID string, messages [][]byte, poolPubKey string) (*Notifier, error) {
+func NewNotifier(messageID string, messages [][]byte, poolPubKey string, hashMode tsscommon.HashMode) (*Notifier, error) {
 	if len(messageID) == 0 {
 		return nil, errors.New("messageID is empty")
 	}
@@ -34,6 +39,7 @@ func NewNotifier(messageID string, messages [][]byte, poolPubKey string) (*Notif
 		MessageID:  messageID,
 		messages:   messages,
 		poolPubKey: poolPubKey,
+		hashMode:   hashMode,
 		resp:       make(chan []*common.ECSignature, 1),
 	}, nil
 }
@@ -48,11 +54,22 @@ func (n *Notifier) verifySignature(data *common.ECSignature, msg []byte) (bool,
 	if err != nil {
 		return false, fmt.Errorf("fail to get pubkey from bech32 pubkey string(%s):%w", n.poolPubKey, err)
 	}
+	// EdDSA pool, tss-lib signs the message as a big integer, so the leading zero bytes are not signed
+	if _, ok := pubKey.(*cosed25519.PubKey); ok {
+		if hashMode, _ := tsscommon.GetHashMode(n.hashMode); hashMode != tsscommon.HashRaw {
+			return false, fmt.Errorf("hash mode %s is not supported by eddsa", n.hashMode)
+		}
+		return ed25519.Verify(pubKey.Bytes(), new(big.Int).SetBytes(msg).Bytes(), data.GetSignature()), nil
+	}
 	pub, err := btcec.ParsePubKey(pubKey.Bytes(), btcec.S256())
 	if err != nil {
 		return false, err
 	}
-	return ecdsa.Verify(pub.ToECDSA(), msg, new(big.Int).SetBytes(data.R), new(big.Int).SetBytes(data.S)), nil
+	digest, err := tsscommon.HashMessage(msg, n.hashMode)
+	if err != nil {
+		return false, fmt.Errorf("fail to hash the message: %w", err)
+	}
+	return ecdsa.Verify(pub.ToECDSA(), digest, new(big.Int).SetBytes(data.R), new(big.Int).SetBytes(data.S)), nil
 }
 
 // ProcessSignature is to verify whether the signature is valid
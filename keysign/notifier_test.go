@@ -24,18 +24,18 @@ func (*NotifierTestSuite) SetUpSuite(c *C) {
 func (NotifierTestSuite) TestNewNotifier(c *C) {
 	testMSg := [][]byte{[]byte("hello"), []byte("world")}
 	poolPubKey := conversion.GetRandomPubKey()
-	n, err := NewNotifier("", testMSg, poolPubKey)
+	n, err := NewNotifier("", testMSg, poolPubKey, common.HashRaw)
 	c.Assert(err, NotNil)
 	c.Assert(n, IsNil)
-	n, err = NewNotifier("aasfdasdf", nil, poolPubKey)
+	n, err = NewNotifier("aasfdasdf", nil, poolPubKey, common.HashRaw)
 	c.Assert(err, NotNil)
 	c.Assert(n, IsNil)
 
-	n, err = NewNotifier("hello", testMSg, "")
+	n, err = NewNotifier("hello", testMSg, "", common.HashRaw)
 	c.Assert(err, NotNil)
 	c.Assert(n, IsNil)
 
-	n, err = NewNotifier("hello", testMSg, poolPubKey)
+	n, err = NewNotifier("hello", testMSg, poolPubKey, common.HashRaw)
 	c.Assert(err, IsNil)
 	c.Assert(n, NotNil)
 	ch := n.GetResponseChannel()
@@ -49,7 +49,7 @@ func (NotifierTestSuite) TestNotifierHappyPath(c *C) {
 	messageID, err := common.MsgToHashString(buf)
 	c.Assert(err, IsNil)
 	poolPubKey := `thorpub1addwnpepq0ul3xt882a6nm6m7uhxj4tk2n82zyu647dyevcs5yumuadn4uamqx7neak`
-	n, err := NewNotifier(messageID, [][]byte{buf}, poolPubKey)
+	n, err := NewNotifier(messageID, [][]byte{buf}, poolPubKey, common.HashRaw)
 	c.Assert(err, IsNil)
 	c.Assert(n, NotNil)
 	sigFile := "../test_data/signature_notify/sig1.json"
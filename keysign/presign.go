@@ -0,0 +1,324 @@
+package keysign
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	btss "github.com/binance-chain/tss-lib/tss"
+
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+// preSignRounds is the number of rounds of the key sign that do not depend on the message
+const preSignRounds = messages.TSSKEYSIGNROUNDS - 1
+
+// SetPreSignatures make the key sign sign the messages with the presignatures of the given ids, one for each message
+// in the order of the messages
+func (tKeySign *TssKeySign) SetPreSignatures(ids []string) {
+	tKeySign.preSignIDs = ids
+}
+
+// PreSign run the message independent rounds of the ECDSA key sign with the given signers for num times, the
+// presignatures are saved through the state manager, so that the same signers can later sign with a single round
+func (tKeySign *TssKeySign) PreSign(num int, localStateItem storage.KeygenLocalState, parties []string) ([]storage.PreSignature, error) {
+	if localStateItem.GetAlgo() != common.ECDSA {
+		return nil, fmt.Errorf("presignature is not supported by %s", localStateItem.GetAlgo())
+	}
+	if num <= 0 {
+		return nil, errors.New("invalid number of presignatures")
+	}
+	partiesID, localPartyID, err := getSignParties(parties, localStateItem)
+	if err != nil {
+		return nil, fmt.Errorf("fail to form presign party: %w", err)
+	}
+	if !common.Contains(partiesID, localPartyID) {
+		return nil, errors.New("we are not in this rounds presign")
+	}
+	threshold, err := localStateItem.GetThreshold()
+	if err != nil {
+		return nil, fmt.Errorf("fail to get threshold: %w", err)
+	}
+	if len(partiesID) <= threshold {
+		return nil, fmt.Errorf("not enough signers, threshold=%d and signers=%d", threshold, len(partiesID))
+	}
+
+	outCh := make(chan btss.Message, 2*len(partiesID)*num)
+	endCh := make(chan *signing.SignatureData, len(partiesID)*num)
+	errCh := make(chan struct{})
+
+	if err := tKeySign.tssCommonStruct.AcquireCurve(common.ECDSA, 0); err != nil {
+		return nil, err
+	}
+	defer tKeySign.tssCommonStruct.ReleaseCurve()
+	preSignPartyMap := new(sync.Map)
+	for i := 0; i < num; i++ {
+		moniker := "presign:" + strconv.Itoa(i)
+		partiesID, eachLocalPartyID, err := getSignParties(parties, localStateItem)
+		if err != nil {
+			return nil, fmt.Errorf("error to create parties in batch presign %w", err)
+		}
+		eachLocalPartyID.Moniker = moniker
+		ctx := btss.NewPeerContext(partiesID)
+		params := btss.NewParameters(ctx, eachLocalPartyID, len(partiesID), threshold)
+		// without the message, the party stops once the message independent rounds are done
+		preSignPartyMap.Store(moniker, signing.NewLocalPartyWithOneRoundSign(params, localStateItem.LocalData, outCh, endCh))
+	}
+	if err := tKeySign.setupParties(preSignPartyMap, partiesID, common.ECDSA); err != nil {
+		return nil, err
+	}
+
+	var preSignWg sync.WaitGroup
+	preSignWg.Add(2)
+	go func() {
+		defer preSignWg.Done()
+		ret := tKeySign.startBatchSigning(preSignPartyMap, num)
+		if !ret {
+			close(errCh)
+		}
+	}()
+	go tKeySign.tssCommonStruct.ProcessInboundMessages(tKeySign.commStopChan, &preSignWg)
+	results, err := tKeySign.processPreSign(num, errCh, outCh, endCh)
+	if err != nil {
+		close(tKeySign.commStopChan)
+		return nil, fmt.Errorf("fail to process presign: %w", err)
+	}
+
+	signers := append([]string{}, parties...)
+	sort.Strings(signers)
+	var preSignatures []storage.PreSignature
+	for _, el := range results {
+		data := el.GetOneRoundData()
+		if data == nil || data.GetBigR() == nil {
+			close(tKeySign.commStopChan)
+			return nil, errors.New("presign ends without the one round data")
+		}
+		id, err := conversion.BytesToHashString(append(append([]byte{}, data.GetBigR().GetX()...), data.GetBigR().GetY()...))
+		if err != nil {
+			close(tKeySign.commStopChan)
+			return nil, fmt.Errorf("fail to get the presignature id: %w", err)
+		}
+		preSignatures = append(preSignatures, storage.PreSignature{
+			ID:      id,
+			Signers: signers,
+			Data:    data,
+		})
+	}
+	if err := tKeySign.stateManager.SavePreSignatures(localStateItem.PubKey, preSignatures); err != nil {
+		close(tKeySign.commStopChan)
+		return nil, fmt.Errorf("fail to save the presignatures: %w", err)
+	}
+	if _, err := tKeySign.keySignDone(nil); err != nil {
+		tKeySign.logger.Error().Err(err).Msg("fail to finish the presign")
+	}
+
+	select {
+	case <-time.After(time.Second * 5):
+		close(tKeySign.commStopChan)
+	case <-tKeySign.tssCommonStruct.GetTaskDone():
+		close(tKeySign.commStopChan)
+	}
+	preSignWg.Wait()
+	tKeySign.logger.Info().Msgf("%s successfully generate %d presignatures", tKeySign.p2pComm.GetLocalPeerID(), len(preSignatures))
+	return preSignatures, nil
+}
+
+func (tKeySign *TssKeySign) processPreSign(reqNum int, errChan chan struct{}, outCh <-chan btss.Message, endCh <-chan *signing.SignatureData) ([]*signing.SignatureData, error) {
+	defer tKeySign.logger.Debug().Msg("presign finished")
+	var results []*signing.SignatureData
+	tssConf := tKeySign.tssCommonStruct.GetConf()
+	for {
+		select {
+		case <-errChan:
+			tKeySign.logger.Error().Msg("presign failed")
+			return nil, errors.New("error channel closed fail to start local party")
+		case <-tKeySign.stopChan:
+			return nil, errors.New("received exit signal")
+		case <-time.After(tssConf.KeySignTimeout):
+			tKeySign.logger.Error().Msgf("fail to presign with %s", tssConf.KeySignTimeout.String())
+			tKeySign.setTimeoutBlame(common.ECDSA, preSignRounds)
+			return nil, blame.ErrTssTimeOut
+		case msg := <-outCh:
+			tKeySign.logger.Debug().Msgf(">>>>>>>>>>presign msg: %s", msg.String())
+			tKeySign.tssCommonStruct.GetBlameMgr().SetLastMsg(msg)
+			err := tKeySign.tssCommonStruct.ProcessOutCh(msg, messages.TSSKeySignMsg)
+			if err != nil {
+				return nil, err
+			}
+		case msg := <-endCh:
+			results = append(results, msg)
+			if len(results) == reqNum {
+				return results, nil
+			}
+		}
+	}
+}
+
+// signWithPreSignatures sign the messages with the presignatures in a single round, each signer broadcasts its shares
+// of the signatures and combines them with the shares of the others
+func (tKeySign *TssKeySign) signWithPreSignatures(msgsToSign [][]byte, localStateItem storage.KeygenLocalState, preSignatures []storage.PreSignature, partiesID []*btss.PartyID, localPartyID *btss.PartyID) ([]*tsslibcommon.ECSignature, error) {
+	if err := tKeySign.tssCommonStruct.AcquireCurve(common.ECDSA, 0); err != nil {
+		return nil, err
+	}
+	defer tKeySign.tssCommonStruct.ReleaseCurve()
+
+	msgInts := make([]*big.Int, len(msgsToSign))
+	ourShares := make([]*big.Int, len(msgsToSign))
+	shares := make([]messages.OnlineSignShare, len(msgsToSign))
+	for i, val := range msgsToSign {
+		m, err := common.MsgToHashInt(val)
+		if err != nil {
+			return nil, fmt.Errorf("fail to convert msg to hash int: %w", err)
+		}
+		msgInts[i] = m
+		ourShares[i] = signing.FinalizeGetOurSigShare(&signing.SignatureData{OneRoundData: preSignatures[i].Data}, m)
+		shares[i] = messages.OnlineSignShare{
+			PreSignID: preSignatures[i].ID,
+			SigShare:  ourShares[i].Bytes(),
+		}
+	}
+	if err := tKeySign.setupParties(new(sync.Map), partiesID, common.ECDSA); err != nil {
+		return nil, err
+	}
+
+	var keySignWg sync.WaitGroup
+	keySignWg.Add(1)
+	go tKeySign.tssCommonStruct.ProcessInboundMessages(tKeySign.commStopChan, &keySignWg)
+	if err := tKeySign.tssCommonStruct.BroadcastOnlineSignShares(localPartyID, shares); err != nil {
+		close(tKeySign.commStopChan)
+		return nil, fmt.Errorf("fail to broadcast the signature shares: %w", err)
+	}
+	results, err := tKeySign.processOnlineSign(localStateItem, preSignatures, partiesID, localPartyID, msgInts, ourShares)
+	if err != nil {
+		close(tKeySign.commStopChan)
+		return nil, fmt.Errorf("fail to process online sign: %w", err)
+	}
+
+	select {
+	case <-time.After(time.Second * 5):
+		close(tKeySign.commStopChan)
+	case <-tKeySign.tssCommonStruct.GetTaskDone():
+		close(tKeySign.commStopChan)
+	}
+	keySignWg.Wait()
+
+	tKeySign.logger.Info().Msgf("%s successfully sign the message with presignatures", tKeySign.p2pComm.GetLocalPeerID())
+	sortSignatures(results)
+	return results, nil
+}
+
+func (tKeySign *TssKeySign) processOnlineSign(localStateItem storage.KeygenLocalState, preSignatures []storage.PreSignature, partiesID []*btss.PartyID, localPartyID *btss.PartyID, msgInts, ourShares []*big.Int) ([]*tsslibcommon.ECSignature, error) {
+	tssConf := tKeySign.tssCommonStruct.GetConf()
+	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
+	for {
+		select {
+		case <-tKeySign.stopChan:
+			return nil, errors.New("received exit signal")
+		case <-time.After(tssConf.KeySignTimeout):
+			tKeySign.logger.Error().Msgf("fail to sign message with presignatures with %s", tssConf.KeySignTimeout.String())
+			received := tKeySign.tssCommonStruct.GetOnlineSignMsgs()
+			var missing []string
+			for _, el := range partiesID {
+				if _, ok := received[el.Id]; !ok && el.Id != localPartyID.Id {
+					missing = append(missing, el.Id)
+				}
+			}
+			blameMgr.GetBlame().SetBlame(blame.TssTimeout, tKeySign.getBlameNodes(partiesID, missing, nil), false)
+			return nil, blame.ErrTssTimeOut
+		case <-tKeySign.tssCommonStruct.GetOnlineSignNotify():
+			received := tKeySign.tssCommonStruct.GetOnlineSignMsgs()
+			if len(received) < len(partiesID)-1 {
+				continue
+			}
+			signatures, err := tKeySign.combineSigShares(localStateItem, preSignatures, partiesID, localPartyID, msgInts, ourShares, received)
+			if err != nil {
+				return nil, err
+			}
+			return tKeySign.keySignDone(signatures)
+		}
+	}
+}
+
+// combineSigShares verify the signature shares of all the signers and combine them into the signatures
+func (tKeySign *TssKeySign) combineSigShares(localStateItem storage.KeygenLocalState, preSignatures []storage.PreSignature, partiesID []*btss.PartyID, localPartyID *btss.PartyID, msgInts, ourShares []*big.Int, received map[string]*messages.WireMessage) ([]*tsslibcommon.ECSignature, error) {
+	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
+	otherShares := make([]map[*btss.PartyID]*big.Int, len(msgInts))
+	for i := range otherShares {
+		otherShares[i] = make(map[*btss.PartyID]*big.Int)
+	}
+	var culprits []string
+	for _, party := range partiesID {
+		if party.Id == localPartyID.Id {
+			continue
+		}
+		var shares []messages.OnlineSignShare
+		if err := json.Unmarshal(received[party.Id].Message, &shares); err != nil || len(shares) != len(msgInts) {
+			culprits = append(culprits, party.Id)
+			continue
+		}
+		for i, el := range shares {
+			// all the signers must use the same presignature for the message
+			if el.PreSignID != preSignatures[i].ID {
+				culprits = append(culprits, party.Id)
+				break
+			}
+			otherShares[i][party] = new(big.Int).SetBytes(el.SigShare)
+		}
+	}
+	if len(culprits) > 0 {
+		blameMgr.GetBlame().SetBlame(blame.TssBrokenMsg, tKeySign.getBlameNodes(partiesID, culprits, received), false)
+		return nil, errors.New("invalid signature shares")
+	}
+
+	pk := &ecdsa.PublicKey{
+		Curve: btss.EC(),
+		X:     localStateItem.LocalData.ECDSAPub.X(),
+		Y:     localStateItem.LocalData.ECDSAPub.Y(),
+	}
+	var signatures []*tsslibcommon.ECSignature
+	for i, m := range msgInts {
+		state := &signing.SignatureData{OneRoundData: preSignatures[i].Data}
+		data, _, errFinalize := signing.FinalizeGetAndVerifyFinalSig(state, pk, m, localPartyID, new(big.Int).Set(ourShares[i]), otherShares[i])
+		if errFinalize != nil {
+			for _, el := range errFinalize.Culprits() {
+				culprits = append(culprits, el.Id)
+			}
+			blameMgr.GetBlame().SetBlame(blame.TssBrokenMsg, tKeySign.getBlameNodes(partiesID, culprits, received), false)
+			return nil, fmt.Errorf("fail to combine the signature shares: %w", errFinalize.Cause())
+		}
+		signatures = append(signatures, data.GetSignature())
+	}
+	return signatures, nil
+}
+
+// getBlameNodes return the nodes of the given parties, along with the online sign message they sent if any
+func (tKeySign *TssKeySign) getBlameNodes(partiesID []*btss.PartyID, partyIDs []string, received map[string]*messages.WireMessage) []blame.Node {
+	partyIDMap := conversion.SetupPartyIDMap(partiesID)
+	var nodes []blame.Node
+	for _, el := range partyIDs {
+		pubKeys, err := conversion.AccPubKeysFromPartyIDs([]string{el}, partyIDMap)
+		if err != nil || len(pubKeys) == 0 {
+			tKeySign.logger.Error().Err(err).Msgf("fail to get the pub key of party %s", el)
+			continue
+		}
+		var msgBody, sig []byte
+		if wireMsg, ok := received[el]; ok {
+			msgBody = wireMsg.Message
+			sig = wireMsg.Sig
+		}
+		nodes = append(nodes, blame.NewNode(pubKeys[0], msgBody, sig))
+	}
+	return nodes
+}
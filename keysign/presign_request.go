@@ -0,0 +1,43 @@
+package keysign
+
+import (
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+// PreSignRequest request to generate presignatures of a pool ahead of time, a key sign of the pool finishes with a
+// single round when its signers are the same as the signers of the presignatures
+type PreSignRequest struct {
+	PoolPubKey    string   `json:"pool_pub_key"`
+	SignerPubKeys []string `json:"signer_pub_keys"`
+	Number        int      `json:"number"` // number of presignatures to generate, each of them signs one message
+	BlockHeight   int64    `json:"block_height"`
+	Version       string   `json:"tss_version"`
+}
+
+// PreSignResponse presign response
+type PreSignResponse struct {
+	Number int           `json:"number"`        // number of presignatures generated
+	IDs    []string      `json:"ids,omitempty"` // ids of the presignatures, to sign with in the key sign requests
+	Status common.Status `json:"status"`
+	Blame  blame.Blame   `json:"blame"`
+}
+
+func NewPreSignRequest(pk string, signers []string, number int, blockHeight int64, version string) PreSignRequest {
+	return PreSignRequest{
+		PoolPubKey:    pk,
+		SignerPubKeys: signers,
+		Number:        number,
+		BlockHeight:   blockHeight,
+		Version:       version,
+	}
+}
+
+func NewPreSignResponse(ids []string, status common.Status, blame blame.Blame) PreSignResponse {
+	return PreSignResponse{
+		Number: len(ids),
+		IDs:    ids,
+		Status: status,
+		Blame:  blame,
+	}
+}
@@ -0,0 +1,143 @@
+package keysign
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	"github.com/btcsuite/btcd/btcec"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+func (s *TssKeysignTestSuite) newKeySignInstance(idx int, conf common.TssConfig, messageID string, msgNum int) (*TssKeySign, func()) {
+	comm := s.comms[idx]
+	keysignIns := NewTssKeySign(comm.GetLocalPeerID(),
+		conf,
+		comm.BroadcastMsgChan,
+		make(chan struct{}), messageID,
+		s.nodePrivKeys[idx], s.comms[idx], s.stateMgrs[idx], msgNum)
+	keysignMsgChannel := keysignIns.GetTssKeySignChannels()
+	msgTypes := []messages.THORChainTSSMessageType{
+		messages.TSSKeySignMsg, messages.TSSKeySignVerMsg, messages.TSSControlMsg, messages.TSSTaskDone, messages.TSSOnlineSignMsg,
+	}
+	for _, el := range msgTypes {
+		comm.SetSubscribe(el, messageID, keysignMsgChannel)
+	}
+	return keysignIns, func() {
+		for _, el := range msgTypes {
+			comm.CancelSubscribe(el, messageID)
+		}
+	}
+}
+
+func (s *TssKeysignTestSuite) TestPreSignAndSignMessage(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	sort.Strings(testPubKeys)
+	poolPubKey := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	conf := common.TssConfig{
+		KeyGenTimeout:   90 * time.Second,
+		KeySignTimeout:  90 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	preSignID, err := common.MsgToHashString([]byte("presign"))
+	c.Assert(err, IsNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	preSignResult := make(map[int][]storage.PreSignature)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			keysignIns, cancel := s.newKeySignInstance(idx, conf, preSignID, 2)
+			defer cancel()
+			localState, err := s.stateMgrs[idx].GetLocalState(poolPubKey)
+			c.Assert(err, IsNil)
+			preSignatures, err := keysignIns.PreSign(2, localState, testPubKeys)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			preSignResult[idx] = preSignatures
+		}(i)
+	}
+	wg.Wait()
+	var ids []string
+	for _, el := range preSignResult[0] {
+		ids = append(ids, el.ID)
+	}
+	sort.Strings(ids)
+	c.Assert(ids, HasLen, 2)
+	for i := 1; i < s.partyNum; i++ {
+		var nodeIDs []string
+		for _, el := range preSignResult[i] {
+			nodeIDs = append(nodeIDs, el.ID)
+		}
+		sort.Strings(nodeIDs)
+		c.Assert(nodeIDs, DeepEquals, ids)
+	}
+
+	msgsToSign := [][]byte{[]byte("helloworld-test"), []byte("t")}
+	messageID, err := common.MsgToHashString([]byte("helloworld-test,t"))
+	c.Assert(err, IsNil)
+	keysignResult := make(map[int][]*tsslibcommon.ECSignature)
+	var pubKey *ecdsa.PublicKey
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			keysignIns, cancel := s.newKeySignInstance(idx, conf, messageID, 2)
+			defer cancel()
+			keysignIns.SetPreSignatures(ids)
+			localState, err := s.stateMgrs[idx].GetLocalState(poolPubKey)
+			c.Assert(err, IsNil)
+			sigs, err := keysignIns.SignMessage(msgsToSign, localState, testPubKeys, common.HashRaw)
+			c.Assert(err, IsNil)
+			// the signing is done with the presignatures, there is no tss-lib message at all
+			c.Assert(keysignIns.GetTssCommonStruct().GetBlameMgr().GetLastMsg(), IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx] = sigs
+			pubKey = &ecdsa.PublicKey{
+				Curve: btcec.S256(),
+				X:     localState.LocalData.ECDSAPub.X(),
+				Y:     localState.LocalData.ECDSAPub.Y(),
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < s.partyNum; i++ {
+		c.Assert(keysignResult[i], HasLen, 2)
+		for j, el := range keysignResult[i] {
+			c.Assert(el.GetSignature(), DeepEquals, keysignResult[0][j].GetSignature())
+			r := new(big.Int).SetBytes(el.R)
+			sig := new(big.Int).SetBytes(el.S)
+			c.Assert(ecdsa.Verify(pubKey, el.M, r, sig), Equals, true)
+		}
+		// the presignatures are used up
+		taken, err := s.stateMgrs[i].TakePreSignatures(poolPubKey, testPubKeys, ids[:1])
+		c.Assert(err, IsNil)
+		c.Assert(taken, IsNil)
+	}
+
+	// a signer without the presignatures fails instead of signing with all the rounds on its own
+	keysignIns, cancel := s.newKeySignInstance(0, conf, messageID, 2)
+	defer cancel()
+	keysignIns.SetPreSignatures(ids)
+	localState, err := s.stateMgrs[0].GetLocalState(poolPubKey)
+	c.Assert(err, IsNil)
+	_, err = keysignIns.SignMessage(msgsToSign, localState, testPubKeys, common.HashRaw)
+	c.Assert(errors.Is(err, ErrPreSignaturesNotFound), Equals, true)
+	c.Assert(keysignIns.GetTssCommonStruct().GetBlameMgr().GetLastMsg(), IsNil)
+}
@@ -1,12 +1,29 @@
 package keysign
 
+import "gitlab.com/thorchain/tss/go-tss/common"
+
 // Request request to sign a message
 type Request struct {
-	PoolPubKey    string   `json:"pool_pub_key"` // pub key of the pool that we would like to send this message from
-	Messages      []string `json:"messages"`     // base64 encoded message to be signed
-	SignerPubKeys []string `json:"signer_pub_keys"`
-	BlockHeight   int64    `json:"block_height"`
-	Version       string   `json:"tss_version"`
+	PoolPubKey    string      `json:"pool_pub_key"` // pub key of the pool that we would like to send this message from
+	Messages      []string    `json:"messages"`     // base64 encoded message to be signed
+	SignerPubKeys []string    `json:"signer_pub_keys"`
+	BlockHeight   int64       `json:"block_height"`
+	Version       string      `json:"tss_version"`
+	Algo          common.Algo `json:"algo,omitempty"` // signature scheme of the pool, default to ECDSA
+	// DerivationPath is the non-hardened BIP32 path of the child key of the pool to sign with, such as m/0/1, the
+	// pool key itself signs if it is empty
+	DerivationPath string `json:"derivation_path,omitempty"`
+	ChainCode      string `json:"chain_code,omitempty"` // hex encoded chain code of the pool key, used with the derivation path
+	// Formats are the chain specific forms of the ECDSA signatures to return along with R and S
+	Formats []SignatureFormat `json:"formats,omitempty"`
+	ChainID int64             `json:"chain_id,omitempty"` // EIP-155 chain id of the Ethereum form
+	// HashMode is how the messages are hashed before they are signed, default to raw, which signs the messages as they are
+	HashMode common.HashMode `json:"hash_mode,omitempty"`
+	// PreSignIDs are the ids of the presignatures of the signers to sign the messages with in a single round, one
+	// for each message, all the signers must have them. The messages are signed with all the rounds if it is empty.
+	// The keysign fails with ErrPreSignaturesNotFound when a signer misses any of them, or they are generated by
+	// other signers, it never falls back to all the rounds, retry the keysign without the PreSignIDs instead
+	PreSignIDs []string `json:"presign_ids,omitempty"`
 }
 
 func NewRequest(pk string, msgs []string, blockHeight int64, signers []string, version string) Request {
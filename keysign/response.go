@@ -11,6 +11,11 @@ type Signature struct {
 	R          string `json:"r"`
 	S          string `json:"s"`
 	RecoveryID string `json:"recovery_id"`
+	// the chain specific forms below are base64 encoded, they are only set when they are requested
+	DER      string `json:"der,omitempty"`
+	Compact  string `json:"compact,omitempty"`
+	Ethereum string `json:"ethereum,omitempty"`
+	Bitcoin  string `json:"bitcoin,omitempty"`
 }
 
 // Response key sign response
@@ -0,0 +1,185 @@
+package keysign
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+// SignatureFormat is a chain specific encoding of an ECDSA signature
+type SignatureFormat string
+
+const (
+	// FormatDER is the DER encoding of the signature as it is generated
+	FormatDER SignatureFormat = "der"
+	// FormatCompact is the 65 bytes compact signature, the recovery header byte followed by R and the low S
+	FormatCompact SignatureFormat = "compact"
+	// FormatEthereum is R, the low S and the EIP-155 v, which is 27 or 28 when no chain id is given
+	FormatEthereum SignatureFormat = "ethereum"
+	// FormatBitcoin is the DER encoding of the signature with the low S, without the sighash type
+	FormatBitcoin SignatureFormat = "bitcoin"
+)
+
+// ValidateFormats check the given signature formats are all supported
+func ValidateFormats(formats []SignatureFormat) error {
+	for _, el := range formats {
+		switch el {
+		case FormatDER, FormatCompact, FormatEthereum, FormatBitcoin:
+		default:
+			return fmt.Errorf("unknown signature format(%s)", el)
+		}
+	}
+	return nil
+}
+
+type derSignature struct {
+	R, S *big.Int
+}
+
+// encodeRS return R and S as 32 bytes big endian each
+func encodeRS(r, s *big.Int) []byte {
+	buf := make([]byte, 64)
+	rBytes := r.Bytes()
+	sBytes := s.Bytes()
+	copy(buf[32-len(rBytes):32], rBytes)
+	copy(buf[64-len(sBytes):], sBytes)
+	return buf
+}
+
+// getRecoveryID return the recovery id that recovers the pub key from the signature of the hash
+func getRecoveryID(pubKey *btcec.PublicKey, hash []byte, r, s *big.Int) (byte, error) {
+	compact := append([]byte{0}, encodeRS(r, s)...)
+	for i := byte(0); i < 4; i++ {
+		compact[0] = 27 + i
+		recovered, _, err := btcec.RecoverCompact(btcec.S256(), compact, hash)
+		if err == nil && recovered.IsEqual(pubKey) {
+			return i, nil
+		}
+	}
+	return 0, errors.New("fail to find the recovery id of the signature")
+}
+
+func verifyRecoverable(pubKey *btcec.PublicKey, hash, compact []byte) error {
+	recovered, _, err := btcec.RecoverCompact(btcec.S256(), compact, hash)
+	if err != nil {
+		return fmt.Errorf("fail to recover the pub key: %w", err)
+	}
+	if !recovered.IsEqual(pubKey) {
+		return errors.New("the recovered pub key does not match")
+	}
+	return nil
+}
+
+// AddFormats set the given chain specific forms of the signature, every form is decoded and verified against the
+// pub key and the message hashed with the hash mode before it is set, the chain id is used by the Ethereum form
+func (s *Signature) AddFormats(pubKey *btcec.PublicKey, formats []SignatureFormat, chainID int64, hashMode common.HashMode) error {
+	if len(formats) == 0 {
+		return nil
+	}
+	if err := ValidateFormats(formats); err != nil {
+		return err
+	}
+	if chainID < 0 {
+		return fmt.Errorf("invalid chain id %d", chainID)
+	}
+	msg, err := base64.StdEncoding.DecodeString(s.Msg)
+	if err != nil {
+		return fmt.Errorf("fail to decode the message: %w", err)
+	}
+	hash, err := common.HashMessage(msg, hashMode)
+	if err != nil {
+		return fmt.Errorf("fail to hash the message: %w", err)
+	}
+	rBytes, err := base64.StdEncoding.DecodeString(s.R)
+	if err != nil {
+		return fmt.Errorf("fail to decode R: %w", err)
+	}
+	sBytes, err := base64.StdEncoding.DecodeString(s.S)
+	if err != nil {
+		return fmt.Errorf("fail to decode S: %w", err)
+	}
+	curve := btcec.S256()
+	r := new(big.Int).SetBytes(rBytes)
+	sigS := new(big.Int).SetBytes(sBytes)
+	if !ecdsa.Verify(pubKey.ToECDSA(), hash, r, sigS) {
+		return errors.New("the signature does not verify against the pub key")
+	}
+	lowS := sigS
+	halfOrder := new(big.Int).Rsh(curve.N, 1)
+	if sigS.Cmp(halfOrder) > 0 {
+		lowS = new(big.Int).Sub(curve.N, sigS)
+	}
+	recoveryID, err := getRecoveryID(pubKey, hash, r, lowS)
+	if err != nil {
+		return err
+	}
+
+	for _, format := range formats {
+		switch format {
+		case FormatDER:
+			der, err := asn1.Marshal(derSignature{R: r, S: sigS})
+			if err != nil {
+				return fmt.Errorf("fail to encode the DER signature: %w", err)
+			}
+			var decoded derSignature
+			if _, err := asn1.Unmarshal(der, &decoded); err != nil {
+				return fmt.Errorf("fail to decode the DER signature: %w", err)
+			}
+			if !ecdsa.Verify(pubKey.ToECDSA(), hash, decoded.R, decoded.S) {
+				return errors.New("the DER signature does not verify against the pub key")
+			}
+			s.DER = base64.StdEncoding.EncodeToString(der)
+
+		case FormatCompact:
+			// the header flags the compressed pub key, which the pool keys are
+			compact := append([]byte{27 + 4 + recoveryID}, encodeRS(r, lowS)...)
+			if err := verifyRecoverable(pubKey, hash, compact); err != nil {
+				return fmt.Errorf("invalid compact signature: %w", err)
+			}
+			s.Compact = base64.StdEncoding.EncodeToString(compact)
+
+		case FormatEthereum:
+			v := big.NewInt(27 + int64(recoveryID))
+			if chainID > 0 {
+				v = new(big.Int).Mul(big.NewInt(chainID), big.NewInt(2))
+				v.Add(v, big.NewInt(35+int64(recoveryID)))
+			}
+			// v takes more than a byte for the large chain ids
+			eth := append(encodeRS(r, lowS), v.Bytes()...)
+			recID := new(big.Int).SetBytes(eth[64:])
+			if chainID > 0 {
+				recID.Sub(recID, big.NewInt(35+2*chainID))
+			} else {
+				recID.Sub(recID, big.NewInt(27))
+			}
+			if !recID.IsInt64() || recID.Int64() < 0 || recID.Int64() > 3 {
+				return errors.New("invalid ethereum v")
+			}
+			compact := append([]byte{27 + byte(recID.Int64())}, eth[:64]...)
+			if err := verifyRecoverable(pubKey, hash, compact); err != nil {
+				return fmt.Errorf("invalid ethereum signature: %w", err)
+			}
+			s.Ethereum = base64.StdEncoding.EncodeToString(eth)
+
+		case FormatBitcoin:
+			sig := btcec.Signature{R: r, S: lowS}
+			der := sig.Serialize()
+			decoded, err := btcec.ParseDERSignature(der, curve)
+			if err != nil {
+				return fmt.Errorf("fail to decode the bitcoin signature: %w", err)
+			}
+			if decoded.S.Cmp(halfOrder) > 0 || !decoded.Verify(hash, pubKey) {
+				return errors.New("the bitcoin signature does not verify against the pub key")
+			}
+			s.Bitcoin = base64.StdEncoding.EncodeToString(der)
+		}
+	}
+	return nil
+}
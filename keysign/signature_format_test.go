@@ -0,0 +1,104 @@
+package keysign
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+type SignatureFormatTestSuite struct{}
+
+var _ = Suite(&SignatureFormatTestSuite{})
+
+// highSSignature return a signature of the hash whose S is in the upper half of the order
+func highSSignature(c *C, priKey *btcec.PrivateKey, hash []byte) Signature {
+	r, s, err := ecdsa.Sign(rand.Reader, priKey.ToECDSA(), hash)
+	c.Assert(err, IsNil)
+	n := btcec.S256().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) <= 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+	return NewSignature(base64.StdEncoding.EncodeToString(hash),
+		base64.StdEncoding.EncodeToString(r.Bytes()),
+		base64.StdEncoding.EncodeToString(s.Bytes()), "")
+}
+
+func (SignatureFormatTestSuite) TestAddFormats(c *C) {
+	priKey, err := btcec.NewPrivateKey(btcec.S256())
+	c.Assert(err, IsNil)
+	pubKey := priKey.PubKey()
+	hash := sha256.Sum256([]byte("helloworld"))
+	halfOrder := new(big.Int).Rsh(btcec.S256().N, 1)
+
+	sig := highSSignature(c, priKey, hash[:])
+	c.Assert(sig.AddFormats(pubKey, nil, 0, common.HashRaw), IsNil)
+	c.Assert(sig.DER, Equals, "")
+
+	formats := []SignatureFormat{FormatDER, FormatCompact, FormatEthereum, FormatBitcoin}
+	c.Assert(sig.AddFormats(pubKey, formats, 1, common.HashRaw), IsNil)
+
+	der, err := base64.StdEncoding.DecodeString(sig.DER)
+	c.Assert(err, IsNil)
+	var decoded derSignature
+	_, err = asn1.Unmarshal(der, &decoded)
+	c.Assert(err, IsNil)
+	c.Assert(decoded.S.Cmp(halfOrder) > 0, Equals, true)
+	c.Assert(ecdsa.Verify(pubKey.ToECDSA(), hash[:], decoded.R, decoded.S), Equals, true)
+
+	compact, err := base64.StdEncoding.DecodeString(sig.Compact)
+	c.Assert(err, IsNil)
+	c.Assert(compact, HasLen, 65)
+	recovered, compressed, err := btcec.RecoverCompact(btcec.S256(), compact, hash[:])
+	c.Assert(err, IsNil)
+	c.Assert(compressed, Equals, true)
+	c.Assert(recovered.IsEqual(pubKey), Equals, true)
+
+	eth, err := base64.StdEncoding.DecodeString(sig.Ethereum)
+	c.Assert(err, IsNil)
+	c.Assert(eth, HasLen, 65)
+	c.Assert(eth[64] == 37 || eth[64] == 38, Equals, true)
+	c.Assert(new(big.Int).SetBytes(eth[32:64]).Cmp(halfOrder) <= 0, Equals, true)
+	recovered, _, err = btcec.RecoverCompact(btcec.S256(), append([]byte{eth[64] - 37 + 27}, eth[:64]...), hash[:])
+	c.Assert(err, IsNil)
+	c.Assert(recovered.IsEqual(pubKey), Equals, true)
+
+	bitcoin, err := base64.StdEncoding.DecodeString(sig.Bitcoin)
+	c.Assert(err, IsNil)
+	bitcoinSig, err := btcec.ParseDERSignature(bitcoin, btcec.S256())
+	c.Assert(err, IsNil)
+	c.Assert(bitcoinSig.S.Cmp(halfOrder) <= 0, Equals, true)
+	c.Assert(bitcoinSig.Verify(hash[:], pubKey), Equals, true)
+
+	// without the chain id, v of the Ethereum form is 27 or 28
+	sig = highSSignature(c, priKey, hash[:])
+	c.Assert(sig.AddFormats(pubKey, []SignatureFormat{FormatEthereum}, 0, common.HashRaw), IsNil)
+	eth, err = base64.StdEncoding.DecodeString(sig.Ethereum)
+	c.Assert(err, IsNil)
+	c.Assert(eth[64] == 27 || eth[64] == 28, Equals, true)
+	c.Assert(sig.DER, Equals, "")
+
+	// the forms are not set if the signature does not verify against the pub key
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	c.Assert(err, IsNil)
+	sig = highSSignature(c, priKey, hash[:])
+	c.Assert(sig.AddFormats(otherKey.PubKey(), formats, 1, common.HashRaw), NotNil)
+	c.Assert(sig.AddFormats(pubKey, []SignatureFormat{"unknown"}, 1, common.HashRaw), NotNil)
+	c.Assert(sig.AddFormats(pubKey, formats, -1, common.HashRaw), NotNil)
+	c.Assert(sig.DER, Equals, "")
+
+	// the message is hashed with the hash mode the signature is made with
+	sig = highSSignature(c, priKey, hash[:])
+	sig.Msg = base64.StdEncoding.EncodeToString([]byte("helloworld"))
+	c.Assert(sig.AddFormats(pubKey, []SignatureFormat{FormatDER}, 0, common.HashKeccak256), NotNil)
+	c.Assert(sig.DER, Equals, "")
+	c.Assert(sig.AddFormats(pubKey, []SignatureFormat{FormatDER}, 0, common.HashSHA256), IsNil)
+	c.Assert(sig.DER, Not(Equals), "")
+}
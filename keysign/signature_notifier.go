@@ -9,13 +9,13 @@ import (
 	"github.com/binance-chain/tss-lib/common"
 	tsslibcommon "github.com/binance-chain/tss-lib/common"
 	"github.com/golang/protobuf/proto"
-	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	tsscommon "gitlab.com/thorchain/tss/go-tss/common"
 	"gitlab.com/thorchain/tss/go-tss/messages"
 	"gitlab.com/thorchain/tss/go-tss/p2p"
 )
@@ -31,7 +31,7 @@ type signatureItem struct {
 // SignatureNotifier is design to notify the
 type SignatureNotifier struct {
 	logger       zerolog.Logger
-	host         host.Host
+	host         p2p.StreamHost
 	notifierLock *sync.Mutex
 	notifiers    map[string]*Notifier
 	messages     chan *signatureItem
@@ -39,7 +39,7 @@ type SignatureNotifier struct {
 }
 
 // NewSignatureNotifier create a new instance of SignatureNotifier
-func NewSignatureNotifier(host host.Host) *SignatureNotifier {
+func NewSignatureNotifier(host p2p.StreamHost) *SignatureNotifier {
 	s := &SignatureNotifier{
 		logger:       log.With().Str("module", "signature_notifier").Logger(),
 		host:         host,
@@ -196,8 +196,8 @@ func (s *SignatureNotifier) removeNotifier(n *Notifier) {
 }
 
 // WaitForSignature wait until keysign finished and signature is available
-func (s *SignatureNotifier) WaitForSignature(messageID string, message [][]byte, poolPubKey string, timeout time.Duration, sigChan chan string) ([]*common.ECSignature, error) {
-	n, err := NewNotifier(messageID, message, poolPubKey)
+func (s *SignatureNotifier) WaitForSignature(messageID string, message [][]byte, poolPubKey string, hashMode tsscommon.HashMode, timeout time.Duration, sigChan chan string) ([]*common.ECSignature, error) {
+	n, err := NewNotifier(messageID, message, poolPubKey, hashMode)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create notifier")
 	}
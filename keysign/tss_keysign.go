@@ -11,6 +11,7 @@ import (
 
 	tsslibcommon "github.com/binance-chain/tss-lib/common"
 	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	eddsasigning "github.com/binance-chain/tss-lib/eddsa/signing"
 	btss "github.com/binance-chain/tss-lib/tss"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -25,20 +26,28 @@ import (
 	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
+// ErrPreSignaturesNotFound is returned when the signer misses any of the presignatures the request names, or they
+// are generated by other signers. The signers do not fall back to all the rounds on their own, as the others sign in
+// a single round, the keysign can be retried without the presignatures
+var ErrPreSignaturesNotFound = errors.New("the presignatures of the signers are not found")
+
 type TssKeySign struct {
 	logger          zerolog.Logger
 	tssCommonStruct *common.TssCommon
 	stopChan        chan struct{} // channel to indicate whether we should stop
 	localParties    []*btss.PartyID
 	commStopChan    chan struct{}
-	p2pComm         *p2p.Communication
+	p2pComm         p2p.Transport
 	stateManager    storage.LocalStateManager
+	chainCode       []byte
+	derivationPath  []uint32
+	preSignIDs      []string
 }
 
 func NewTssKeySign(localP2PID string,
 	conf common.TssConfig,
 	broadcastChan chan *messages.BroadcastMsgChan,
-	stopChan chan struct{}, msgID string, privKey tcrypto.PrivKey, p2pComm *p2p.Communication, stateManager storage.LocalStateManager, msgNum int) *TssKeySign {
+	stopChan chan struct{}, msgID string, privKey tcrypto.PrivKey, p2pComm p2p.Transport, stateManager storage.LocalStateManager, msgNum int) *TssKeySign {
 	logItems := []string{"keySign", msgID}
 	return &TssKeySign{
 		logger:          log.With().Strs("module", logItems).Logger(),
@@ -80,9 +89,41 @@ func (tKeySign *TssKeySign) startBatchSigning(keySignPartyMap *sync.Map, msgNum
 	return ret.Load()
 }
 
-// signMessage
-func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem storage.KeygenLocalState, parties []string) ([]*tsslibcommon.ECSignature, error) {
-	partiesID, localPartyID, err := conversion.GetParties(parties, localStateItem.LocalPartyKey)
+// getSignParties return the parties of the key sign, the keys of the parties must be the ones the shares are
+// generated with, which carry the block height of the last resharing of the pool
+func getSignParties(parties []string, localStateItem storage.KeygenLocalState) ([]*btss.PartyID, *btss.PartyID, error) {
+	var height int64
+	if len(localStateItem.LocalData.Ks) > 0 {
+		height = conversion.GetPartyKeyHeight(localStateItem.LocalData.Ks[0])
+	}
+	partiesID, localPartyID, err := conversion.GetPartiesAtHeight(parties, localStateItem.LocalPartyKey, height, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if localPartyID == nil {
+		return nil, nil, errors.New("local party is not in the list")
+	}
+	return partiesID, localPartyID, nil
+}
+
+// SignMessage sign the messages hashed with the given hash mode, the signatures carry the digests they sign
+func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem storage.KeygenLocalState, parties []string, hashMode common.HashMode) ([]*tsslibcommon.ECSignature, error) {
+	algo, ok := common.GetAlgo(localStateItem.GetAlgo())
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %s", localStateItem.Algo)
+	}
+	if algo == common.EdDSA && localStateItem.EdDSALocalData == nil {
+		return nil, errors.New("no eddsa local data in the local state")
+	}
+	// ed25519 hashes the message itself
+	if mode, _ := common.GetHashMode(hashMode); algo == common.EdDSA && mode != common.HashRaw {
+		return nil, fmt.Errorf("hash mode %s is not supported by eddsa", hashMode)
+	}
+	msgsToSign, err := common.HashMessages(msgsToSign, hashMode)
+	if err != nil {
+		return nil, fmt.Errorf("fail to hash the messages: %w", err)
+	}
+	partiesID, localPartyID, err := getSignParties(parties, localStateItem)
 	if err != nil {
 		return nil, fmt.Errorf("fail to form key sign party: %w", err)
 	}
@@ -90,24 +131,66 @@ func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem stor
 		tKeySign.logger.Info().Msgf("we are not in this rounds key sign")
 		return nil, nil
 	}
-	threshold, err := conversion.GetThreshold(len(localStateItem.ParticipantKeys))
+	threshold, err := localStateItem.GetThreshold()
 	if err != nil {
-		return nil, errors.New("fail to get threshold")
+		return nil, fmt.Errorf("fail to get threshold: %w", err)
+	}
+	derived := len(tKeySign.derivationPath) > 0
+	if derived {
+		if algo != common.ECDSA {
+			return nil, fmt.Errorf("child key derivation is not supported for %s keys", algo)
+		}
+		localStateItem, err = deriveLocalState(localStateItem, tKeySign.chainCode, tKeySign.derivationPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the signers finish with a single round with the presignatures named by the request, so all of them take the
+	// same ones, the presignatures are taken out of the store before they are used, so that they are never used
+	// twice. A signer missing any of them fails with ErrPreSignaturesNotFound rather than falls back to all the rounds
+	// on its own
+	if len(tKeySign.preSignIDs) > 0 {
+		if algo != common.ECDSA || derived {
+			return nil, errors.New("presignatures can only sign with the ecdsa pool key")
+		}
+		if len(tKeySign.preSignIDs) != len(msgsToSign) {
+			return nil, fmt.Errorf("%d presignatures are given to sign %d messages", len(tKeySign.preSignIDs), len(msgsToSign))
+		}
+		preSignatures, err := tKeySign.stateManager.TakePreSignatures(localStateItem.PubKey, parties, tKeySign.preSignIDs)
+		if err != nil {
+			return nil, fmt.Errorf("fail to take the presignatures: %w", err)
+		}
+		if len(preSignatures) != len(msgsToSign) {
+			return nil, ErrPreSignaturesNotFound
+		}
+		return tKeySign.signWithPreSignatures(msgsToSign, localStateItem, preSignatures, partiesID, localPartyID)
 	}
 
 	// tKeySign.logger.Debug().Msgf("local party: %+v", localPartyID)
 	outCh := make(chan btss.Message, 2*len(partiesID)*len(msgsToSign))
-	endCh := make(chan *signing.SignatureData, len(partiesID)*len(msgsToSign))
+	// only the end channel of the algorithm in use is created, a nil channel is never selected
+	var endCh chan *signing.SignatureData
+	var eddsaEndCh chan *eddsasigning.SignatureData
+	if algo == common.EdDSA {
+		eddsaEndCh = make(chan *eddsasigning.SignatureData, len(partiesID)*len(msgsToSign))
+	} else {
+		endCh = make(chan *signing.SignatureData, len(partiesID)*len(msgsToSign))
+	}
 	errCh := make(chan struct{})
 
+	// tss-lib works on a process wide curve, hold it till the local parties are done
+	if err := tKeySign.tssCommonStruct.AcquireCurve(algo, 0); err != nil {
+		return nil, err
+	}
+	defer tKeySign.tssCommonStruct.ReleaseCurve()
 	keySignPartyMap := new(sync.Map)
 	for i, val := range msgsToSign {
-		m, err := common.MsgToHashInt(val)
+		m, err := common.MsgToSignInt(val, algo)
 		if err != nil {
 			return nil, fmt.Errorf("fail to convert msg to hash int: %w", err)
 		}
 		moniker := m.String() + ":" + strconv.Itoa(i)
-		partiesID, eachLocalPartyID, err := conversion.GetParties(parties, localStateItem.LocalPartyKey)
+		partiesID, eachLocalPartyID, err := getSignParties(parties, localStateItem)
 		ctx := btss.NewPeerContext(partiesID)
 		if err != nil {
 			return nil, fmt.Errorf("error to create parties in batch signging %w\n", err)
@@ -115,29 +198,18 @@ func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem stor
 		eachLocalPartyID.Moniker = moniker
 		tKeySign.localParties = nil
 		params := btss.NewParameters(ctx, eachLocalPartyID, len(partiesID), threshold)
-		keySignParty := signing.NewLocalParty(m, params, localStateItem.LocalData, outCh, endCh)
+		var keySignParty btss.Party
+		if algo == common.EdDSA {
+			keySignParty = eddsasigning.NewLocalParty(m, params, *localStateItem.EdDSALocalData, outCh, eddsaEndCh)
+		} else {
+			keySignParty = signing.NewLocalParty(m, params, localStateItem.LocalData, outCh, endCh)
+		}
 		keySignPartyMap.Store(moniker, keySignParty)
 	}
 
-	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
-	partyIDMap := conversion.SetupPartyIDMap(partiesID)
-	err1 := conversion.SetupIDMaps(partyIDMap, tKeySign.tssCommonStruct.PartyIDtoP2PID)
-	err2 := conversion.SetupIDMaps(partyIDMap, blameMgr.PartyIDtoP2PID)
-	if err1 != nil || err2 != nil {
-		tKeySign.logger.Error().Err(err).Msgf("error in creating mapping between partyID and P2P ID")
+	if err := tKeySign.setupParties(keySignPartyMap, partiesID, algo); err != nil {
 		return nil, err
 	}
-
-	tKeySign.tssCommonStruct.SetPartyInfo(&common.PartyInfo{
-		PartyMap:   keySignPartyMap,
-		PartyIDMap: partyIDMap,
-	})
-
-	blameMgr.SetPartyInfo(keySignPartyMap, partyIDMap)
-
-	tKeySign.tssCommonStruct.P2PPeersLock.Lock()
-	tKeySign.tssCommonStruct.P2PPeers = conversion.GetPeersID(tKeySign.tssCommonStruct.PartyIDtoP2PID, tKeySign.tssCommonStruct.GetLocalPeerID())
-	tKeySign.tssCommonStruct.P2PPeersLock.Unlock()
 	var keySignWg sync.WaitGroup
 	keySignWg.Add(2)
 	// start the key sign
@@ -149,7 +221,7 @@ func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem stor
 		}
 	}()
 	go tKeySign.tssCommonStruct.ProcessInboundMessages(tKeySign.commStopChan, &keySignWg)
-	results, err := tKeySign.processKeySign(len(msgsToSign), errCh, outCh, endCh)
+	results, err := tKeySign.processKeySign(algo, len(msgsToSign), errCh, outCh, endCh, eddsaEndCh)
 	if err != nil {
 		close(tKeySign.commStopChan)
 		return nil, fmt.Errorf("fail to process key sign: %w", err)
@@ -163,7 +235,12 @@ func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem stor
 	}
 	keySignWg.Wait()
 
-	tKeySign.logger.Info().Msgf("%s successfully sign the message", tKeySign.p2pComm.GetHost().ID().String())
+	tKeySign.logger.Info().Msgf("%s successfully sign the message", tKeySign.p2pComm.GetLocalPeerID())
+	sortSignatures(results)
+	return results, nil
+}
+
+func sortSignatures(results []*tsslibcommon.ECSignature) {
 	sort.SliceStable(results, func(i, j int) bool {
 		a := new(big.Int).SetBytes(results[i].M)
 		b := new(big.Int).SetBytes(results[j].M)
@@ -173,17 +250,43 @@ func (tKeySign *TssKeySign) SignMessage(msgsToSign [][]byte, localStateItem stor
 		}
 		return true
 	})
+}
 
-	return results, nil
+// setupParties set up the party information of the key sign with the given local parties
+func (tKeySign *TssKeySign) setupParties(keySignPartyMap *sync.Map, partiesID []*btss.PartyID, algo common.Algo) error {
+	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
+	partyIDMap := conversion.SetupPartyIDMap(partiesID)
+	err1 := conversion.SetupIDMaps(partyIDMap, tKeySign.tssCommonStruct.PartyIDtoP2PID)
+	err2 := conversion.SetupIDMaps(partyIDMap, blameMgr.PartyIDtoP2PID)
+	if err1 != nil || err2 != nil {
+		tKeySign.logger.Error().Msgf("error in creating mapping between partyID and P2P ID")
+		return errors.New("fail to create mapping between partyID and P2P ID")
+	}
+
+	tKeySign.tssCommonStruct.SetPartyInfo(&common.PartyInfo{
+		PartyMap:   keySignPartyMap,
+		PartyIDMap: partyIDMap,
+		Algo:       algo,
+	})
+
+	blameMgr.SetPartyInfo(keySignPartyMap, partyIDMap)
+
+	tKeySign.tssCommonStruct.P2PPeersLock.Lock()
+	tKeySign.tssCommonStruct.P2PPeers = conversion.GetPeersID(tKeySign.tssCommonStruct.PartyIDtoP2PID, tKeySign.tssCommonStruct.GetLocalPeerID())
+	tKeySign.tssCommonStruct.P2PPeersLock.Unlock()
+	return nil
 }
 
-func (tKeySign *TssKeySign) processKeySign(reqNum int, errChan chan struct{}, outCh <-chan btss.Message, endCh <-chan *signing.SignatureData) ([]*tsslibcommon.ECSignature, error) {
+func (tKeySign *TssKeySign) processKeySign(algo common.Algo, reqNum int, errChan chan struct{}, outCh <-chan btss.Message, endCh <-chan *signing.SignatureData, eddsaEndCh <-chan *eddsasigning.SignatureData) ([]*tsslibcommon.ECSignature, error) {
 	defer tKeySign.logger.Debug().Msg("key sign finished")
 	tKeySign.logger.Debug().Msg("start to read messages from local party")
 	var signatures []*tsslibcommon.ECSignature
 
 	tssConf := tKeySign.tssCommonStruct.GetConf()
-	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
+	rounds := messages.TSSKEYSIGNROUNDS
+	if algo == common.EdDSA {
+		rounds = messages.EDDSAKEYSIGNROUNDS
+	}
 
 	for {
 		select {
@@ -195,55 +298,7 @@ func (tKeySign *TssKeySign) processKeySign(reqNum int, errChan chan struct{}, ou
 		case <-time.After(tssConf.KeySignTimeout):
 			// we bail out after KeySignTimeoutSeconds
 			tKeySign.logger.Error().Msgf("fail to sign message with %s", tssConf.KeySignTimeout.String())
-			lastMsg := blameMgr.GetLastMsg()
-			failReason := blameMgr.GetBlame().FailReason
-			if failReason == "" {
-				failReason = blame.TssTimeout
-			}
-
-			tKeySign.tssCommonStruct.P2PPeersLock.RLock()
-			threshold, err := conversion.GetThreshold(len(tKeySign.tssCommonStruct.P2PPeers) + 1)
-			tKeySign.tssCommonStruct.P2PPeersLock.RUnlock()
-			if err != nil {
-				tKeySign.logger.Error().Err(err).Msg("error in get the threshold for generate blame")
-			}
-			if !lastMsg.IsBroadcast() {
-				blameNodesUnicast, err := blameMgr.GetUnicastBlame(lastMsg.Type())
-				if err != nil {
-					tKeySign.logger.Error().Err(err).Msg("error in get unicast blame")
-				}
-				if len(blameNodesUnicast) > 0 && len(blameNodesUnicast) <= threshold {
-					blameMgr.GetBlame().SetBlame(failReason, blameNodesUnicast, true)
-				}
-			} else {
-				blameNodesUnicast, err := blameMgr.GetUnicastBlame(conversion.GetPreviousKeySignUicast(lastMsg.Type()))
-				if err != nil {
-					tKeySign.logger.Error().Err(err).Msg("error in get unicast blame")
-				}
-				if len(blameNodesUnicast) > 0 && len(blameNodesUnicast) <= threshold {
-					blameMgr.GetBlame().SetBlame(failReason, blameNodesUnicast, true)
-				}
-			}
-
-			blameNodesBroadcast, err := blameMgr.GetBroadcastBlame(lastMsg.Type())
-			if err != nil {
-				tKeySign.logger.Error().Err(err).Msg("error in get broadcast blame")
-			}
-			blameMgr.GetBlame().AddBlameNodes(blameNodesBroadcast...)
-
-			// if we cannot find the blame node, we check whether everyone send me the share
-			if len(blameMgr.GetBlame().BlameNodes) == 0 {
-				blameNodesMisingShare, isUnicast, err := blameMgr.TssMissingShareBlame(messages.TSSKEYSIGNROUNDS)
-				if err != nil {
-					tKeySign.logger.Error().Err(err).Msg("fail to get the node of missing share ")
-				}
-
-				if len(blameNodesMisingShare) > 0 && len(blameNodesMisingShare) <= threshold {
-					blameMgr.GetBlame().AddBlameNodes(blameNodesMisingShare...)
-					blameMgr.GetBlame().IsUnicast = isUnicast
-				}
-			}
-
+			tKeySign.setTimeoutBlame(algo, rounds)
 			return nil, blame.ErrTssTimeOut
 		case msg := <-outCh:
 			tKeySign.logger.Debug().Msgf(">>>>>>>>>>key sign msg: %s", msg.String())
@@ -256,18 +311,92 @@ func (tKeySign *TssKeySign) processKeySign(reqNum int, errChan chan struct{}, ou
 		case msg := <-endCh:
 			signatures = append(signatures, msg.GetSignature())
 			if len(signatures) == reqNum {
-				tKeySign.logger.Debug().Msg("we have done the key sign")
-				err := tKeySign.tssCommonStruct.NotifyTaskDone()
-				if err != nil {
-					tKeySign.logger.Error().Err(err).Msg("fail to broadcast the keysign done")
-				}
-				//export the address book
-				address := tKeySign.p2pComm.ExportPeerAddress()
-				if err := tKeySign.stateManager.SaveAddressBook(address); err != nil {
-					tKeySign.logger.Error().Err(err).Msg("fail to save the peer addresses")
-				}
-				return signatures, nil
+				return tKeySign.keySignDone(signatures)
+			}
+
+		case msg := <-eddsaEndCh:
+			signatures = append(signatures, msg.GetSignature())
+			if len(signatures) == reqNum {
+				return tKeySign.keySignDone(signatures)
 			}
 		}
 	}
 }
+
+// setTimeoutBlame find the nodes to blame when the key sign of the given rounds times out
+func (tKeySign *TssKeySign) setTimeoutBlame(algo common.Algo, rounds int) {
+	blameMgr := tKeySign.tssCommonStruct.GetBlameMgr()
+	lastMsg := blameMgr.GetLastMsg()
+	failReason := blameMgr.GetBlame().FailReason
+	if failReason == "" {
+		failReason = blame.TssTimeout
+	}
+
+	tKeySign.tssCommonStruct.P2PPeersLock.RLock()
+	threshold, err := conversion.GetThreshold(len(tKeySign.tssCommonStruct.P2PPeers) + 1)
+	tKeySign.tssCommonStruct.P2PPeersLock.RUnlock()
+	if err != nil {
+		tKeySign.logger.Error().Err(err).Msg("error in get the threshold for generate blame")
+	}
+	// all the EdDSA key sign messages are broadcast
+	if algo == common.EdDSA {
+		tKeySign.logger.Debug().Msg("no unicast message in eddsa key sign")
+	} else if !lastMsg.IsBroadcast() {
+		blameNodesUnicast, err := blameMgr.GetUnicastBlame(lastMsg.Type())
+		if err != nil {
+			tKeySign.logger.Error().Err(err).Msg("error in get unicast blame")
+		}
+		if len(blameNodesUnicast) > 0 && len(blameNodesUnicast) <= threshold {
+			blameMgr.GetBlame().SetBlame(failReason, blameNodesUnicast, true)
+		}
+	} else {
+		blameNodesUnicast, err := blameMgr.GetUnicastBlame(conversion.GetPreviousKeySignUicast(lastMsg.Type()))
+		if err != nil {
+			tKeySign.logger.Error().Err(err).Msg("error in get unicast blame")
+		}
+		if len(blameNodesUnicast) > 0 && len(blameNodesUnicast) <= threshold {
+			blameMgr.GetBlame().SetBlame(failReason, blameNodesUnicast, true)
+		}
+	}
+
+	blameNodesBroadcast, err := blameMgr.GetBroadcastBlame(lastMsg.Type())
+	if err != nil {
+		tKeySign.logger.Error().Err(err).Msg("error in get broadcast blame")
+	}
+	blameMgr.GetBlame().AddBlameNodes(blameNodesBroadcast...)
+
+	// the nodes flooding us are blamed, their messages may have been dropped
+	blameNodesRateLimit, err := blameMgr.GetRateLimitBlame()
+	if err != nil {
+		tKeySign.logger.Error().Err(err).Msg("error in get rate limit blame")
+	}
+	if len(blameNodesRateLimit) > 0 && len(blameNodesRateLimit) <= threshold {
+		blameMgr.GetBlame().AddBlameNodes(blameNodesRateLimit...)
+	}
+
+	// if we cannot find the blame node, we check whether everyone send me the share
+	if len(blameMgr.GetBlame().BlameNodes) == 0 {
+		blameNodesMisingShare, isUnicast, err := blameMgr.TssMissingShareBlame(rounds)
+		if err != nil {
+			tKeySign.logger.Error().Err(err).Msg("fail to get the node of missing share ")
+		}
+
+		if len(blameNodesMisingShare) > 0 && len(blameNodesMisingShare) <= threshold {
+			blameMgr.GetBlame().AddBlameNodes(blameNodesMisingShare...)
+			blameMgr.GetBlame().IsUnicast = isUnicast
+		}
+	}
+}
+
+func (tKeySign *TssKeySign) keySignDone(signatures []*tsslibcommon.ECSignature) ([]*tsslibcommon.ECSignature, error) {
+	tKeySign.logger.Debug().Msg("we have done the key sign")
+	err := tKeySign.tssCommonStruct.NotifyTaskDone()
+	if err != nil {
+		tKeySign.logger.Error().Err(err).Msg("fail to broadcast the keysign done")
+	}
+	//export the address book
+	if err := tKeySign.stateManager.SaveAddressBook(tKeySign.p2pComm.ExportAddressBook()); err != nil {
+		tKeySign.logger.Error().Err(err).Msg("fail to save the peer addresses")
+	}
+	return signatures, nil
+}
@@ -15,4 +15,22 @@ const (
 	KEYSIGN7         = "SignRound7Message"
 	TSSKEYGENROUNDS  = 4
 	TSSKEYSIGNROUNDS = 8
+	ONLINESIGN       = "OnlineSignMessage"
+
+	EDDSAKEYGEN1         = "EDDSAKGRound1Message"
+	EDDSAKEYGEN2aUnicast = "EDDSAKGRound2Message1"
+	EDDSAKEYGEN2b        = "EDDSAKGRound2Message2"
+	EDDSAKEYSIGN1        = "EDDSASignRound1Message"
+	EDDSAKEYSIGN2        = "EDDSASignRound2Message"
+	EDDSAKEYSIGN3        = "EDDSASignRound3Message"
+	EDDSAKEYGENROUNDS    = 3
+	EDDSAKEYSIGNROUNDS   = 3
+
+	RESHARING1         = "DGRound1Message"
+	RESHARING2a        = "DGRound2Message1"
+	RESHARING2b        = "DGRound2Message2"
+	RESHARING3aUnicast = "DGRound3Message1"
+	RESHARING3b        = "DGRound3Message2"
+	RESHARING4         = "DGRound4Message"
+	TSSRESHARINGROUNDS = 6
 )
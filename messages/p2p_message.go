@@ -23,6 +23,12 @@ const (
 	TSSControlMsg
 	// TSSTaskDone is the message of Tss process notification
 	TSSTaskDone
+	// TSSReSharingMsg is the message directly generated by tss lib for resharing
+	TSSReSharingMsg
+	// TSSReSharingVerMsg is the message we create to make sure every party receive the same resharing broadcast message
+	TSSReSharingVerMsg
+	// TSSOnlineSignMsg is the message carries the signature shares of the keysign with presignatures
+	TSSOnlineSignMsg
 	// Unknown is the message indicates the undefined message type
 	Unknown
 )
@@ -38,6 +44,12 @@ func (msgType THORChainTSSMessageType) String() string {
 		return "TSSKeyGenVerMsg"
 	case TSSKeySignVerMsg:
 		return "TSSKeySignVerMsg"
+	case TSSReSharingMsg:
+		return "TSSReSharingMsg"
+	case TSSReSharingVerMsg:
+		return "TSSReSharingVerMsg"
+	case TSSOnlineSignMsg:
+		return "TSSOnlineSignMsg"
 	default:
 		return "Unknown"
 	}
@@ -83,6 +95,12 @@ type TssControl struct {
 	Msg         *WireMessage            `json:"message_body"`
 }
 
+// OnlineSignShare is the share of the signature a party generates with a presignature in the online round
+type OnlineSignShare struct {
+	PreSignID string `json:"pre_sign_id"`
+	SigShare  []byte `json:"sig_share"`
+}
+
 type TssTaskNotifier struct {
 	TaskDone bool `json:"task_done"`
 }
@@ -20,6 +20,7 @@ func (THORChainTSSMessageTypeSuite) TestTHORChainTSSMessageType_String(c *C) {
 		TSSKeySignMsg:    "TSSKeySignMsg",
 		TSSKeyGenVerMsg:  "TSSKeyGenVerMsg",
 		TSSKeySignVerMsg: "TSSKeySignVerMsg",
+		TSSOnlineSignMsg: "TSSOnlineSignMsg",
 	}
 	for k, v := range m {
 		c.Assert(k.String(), Equals, v)
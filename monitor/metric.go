@@ -8,6 +8,25 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// StreamOpened count the long-lived streams opened to a peer for the first time
+	StreamOpened = "opened"
+	// StreamReused count the messages sent over a stream already open
+	StreamReused = "reused"
+	// StreamReconnected count the streams opened again after the one to the peer is broken
+	StreamReconnected = "reconnected"
+	// StreamFailed count the streams that fail to open
+	StreamFailed = "failed"
+	// RejectedInbound count the connections from the peers not on the allowlist
+	RejectedInbound = "inbound"
+	// RejectedOutbound count the dials to the peers not on the allowlist
+	RejectedOutbound = "outbound"
+	// DroppedPeerLimit count the inbound messages dropped over the rate limit of their peer
+	DroppedPeerLimit = "peer"
+	// DroppedMsgIDLimit count the inbound messages dropped over the rate limit of their peer in their msg id
+	DroppedMsgIDLimit = "msg_id"
+)
+
 type Metric struct {
 	keygenCounter    *prometheus.CounterVec
 	keysignCounter   *prometheus.CounterVec
@@ -15,6 +34,9 @@ type Metric struct {
 	keySignTime      prometheus.Gauge
 	keyGenTime       prometheus.Gauge
 	joinPartyTime    *prometheus.GaugeVec
+	p2pStreamCounter *prometheus.CounterVec
+	p2pRejectCounter *prometheus.CounterVec
+	p2pDropCounter   *prometheus.CounterVec
 	logger           zerolog.Logger
 }
 
@@ -54,6 +76,22 @@ func (m *Metric) KeysignJoinParty(joinpartyTime time.Duration, success bool) {
 	}
 }
 
+// UpdateP2PStream count the event of the long-lived p2p streams, see StreamOpened, StreamReused,
+// StreamReconnected and StreamFailed
+func (m *Metric) UpdateP2PStream(event string) {
+	m.p2pStreamCounter.WithLabelValues(event).Inc()
+}
+
+// UpdateP2PRejected count the connection of a peer not on the allowlist, see RejectedInbound and RejectedOutbound
+func (m *Metric) UpdateP2PRejected(direction string) {
+	m.p2pRejectCounter.WithLabelValues(direction).Inc()
+}
+
+// UpdateP2PDropped count the inbound message dropped over a rate limit, see DroppedPeerLimit and DroppedMsgIDLimit
+func (m *Metric) UpdateP2PDropped(limit string) {
+	m.p2pDropCounter.WithLabelValues(limit).Inc()
+}
+
 func (m *Metric) Enable() {
 	prometheus.MustRegister(m.keygenCounter)
 	prometheus.MustRegister(m.keysignCounter)
@@ -61,6 +99,9 @@ func (m *Metric) Enable() {
 	prometheus.MustRegister(m.keyGenTime)
 	prometheus.MustRegister(m.keySignTime)
 	prometheus.MustRegister(m.joinPartyTime)
+	prometheus.MustRegister(m.p2pStreamCounter)
+	prometheus.MustRegister(m.p2pRejectCounter)
+	prometheus.MustRegister(m.p2pDropCounter)
 }
 
 func NewMetric() *Metric {
@@ -122,6 +163,27 @@ func NewMetric() *Metric {
 				Help:      "the time spend for the latest keysign/keygen join party",
 			}, []string{"type"}),
 
+		p2pStreamCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "Tss",
+			Subsystem: "P2P",
+			Name:      "stream",
+			Help:      "Tss p2p stream opened, reused, reconnected and failed counter",
+		}, []string{"event"}),
+
+		p2pRejectCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "Tss",
+			Subsystem: "P2P",
+			Name:      "rejected_peer",
+			Help:      "Tss p2p inbound and outbound connections rejected by the allowlist counter",
+		}, []string{"direction"}),
+
+		p2pDropCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "Tss",
+			Subsystem: "P2P",
+			Name:      "dropped_message",
+			Help:      "Tss p2p inbound messages dropped over the rate limits counter",
+		}, []string{"limit"}),
+
 		logger: log.With().Str("module", "tssMonitor").Logger(),
 	}
 	return &metrics
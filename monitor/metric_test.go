@@ -64,3 +64,52 @@ func TestMetric_UpdateKeyGen(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, float64(5), val)
 }
+
+func TestMetric_UpdateP2PStream(t *testing.T) {
+	metrics := NewMetric()
+	metrics.UpdateP2PStream(StreamOpened)
+	metrics.UpdateP2PStream(StreamReused)
+	metrics.UpdateP2PStream(StreamReused)
+	metrics.UpdateP2PStream(StreamReconnected)
+
+	val, err := getCounterValue(metrics.p2pStreamCounter, StreamOpened)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	val, err = getCounterValue(metrics.p2pStreamCounter, StreamReused)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), val)
+	val, err = getCounterValue(metrics.p2pStreamCounter, StreamReconnected)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	val, err = getCounterValue(metrics.p2pStreamCounter, StreamFailed)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), val)
+}
+
+func TestMetric_UpdateP2PRejected(t *testing.T) {
+	metrics := NewMetric()
+	metrics.UpdateP2PRejected(RejectedInbound)
+	metrics.UpdateP2PRejected(RejectedInbound)
+	metrics.UpdateP2PRejected(RejectedOutbound)
+
+	val, err := getCounterValue(metrics.p2pRejectCounter, RejectedInbound)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), val)
+	val, err = getCounterValue(metrics.p2pRejectCounter, RejectedOutbound)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+}
+
+func TestMetric_UpdateP2PDropped(t *testing.T) {
+	metrics := NewMetric()
+	metrics.UpdateP2PDropped(DroppedPeerLimit)
+	metrics.UpdateP2PDropped(DroppedMsgIDLimit)
+	metrics.UpdateP2PDropped(DroppedMsgIDLimit)
+
+	val, err := getCounterValue(metrics.p2pDropCounter, DroppedPeerLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	val, err = getCounterValue(metrics.p2pDropCounter, DroppedMsgIDLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), val)
+}
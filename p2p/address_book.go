@@ -0,0 +1,235 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	maddr "github.com/multiformats/go-multiaddr"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+const (
+	// AddressBookVersion is the version of the saved address book
+	AddressBookVersion = 1
+	// DefaultAddressBookMaxAge is how long a peer that is not seen again stays in the address book
+	DefaultAddressBookMaxAge = 7 * 24 * time.Hour
+)
+
+// PeerRecord is what the address book knows about one peer
+type PeerRecord struct {
+	PeerID peer.ID `json:"peer_id"`
+	// PubKey is the bech32 pub key of the node, it is empty if it can not be derived from the peer id
+	PubKey   string    `json:"pub_key,omitempty"`
+	Addrs    []string  `json:"addrs"`
+	LastSeen time.Time `json:"last_seen"`
+	// Successes and Failures count the dials to the peer
+	Successes int `json:"successes"`
+	Failures  int `json:"failures"`
+}
+
+// Score rank the peer for the bootstrap dialing, it is the success rate of the dials to the peer that fades the
+// longer the peer is not seen, a peer without any record has the score of a peer just seen but never dialed
+func (r PeerRecord) Score(now time.Time) float64 {
+	rate := float64(r.Successes+1) / float64(r.Successes+r.Failures+2)
+	if r.LastSeen.IsZero() {
+		return rate
+	}
+	days := now.Sub(r.LastSeen).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return rate / (1 + days)
+}
+
+// AddressBook keep the addresses of the peers the node has seen, with the outcome of the dials to them
+type AddressBook struct {
+	lock  *sync.RWMutex
+	peers map[peer.ID]*PeerRecord
+}
+
+// NewAddressBook create an empty address book
+func NewAddressBook() *AddressBook {
+	return &AddressBook{
+		lock:  &sync.RWMutex{},
+		peers: make(map[peer.ID]*PeerRecord),
+	}
+}
+
+type addressBookJSON struct {
+	Version int          `json:"version"`
+	Peers   []PeerRecord `json:"peers"`
+}
+
+// MarshalJSON encode the records of the address book ordered by the peer id
+func (b *AddressBook) MarshalJSON() ([]byte, error) {
+	return json.Marshal(addressBookJSON{
+		Version: AddressBookVersion,
+		Peers:   b.Records(),
+	})
+}
+
+// UnmarshalJSON decode the records of the address book
+func (b *AddressBook) UnmarshalJSON(buf []byte) error {
+	var content addressBookJSON
+	if err := json.Unmarshal(buf, &content); err != nil {
+		return err
+	}
+	if content.Version != AddressBookVersion {
+		return fmt.Errorf("unsupported address book version(%d)", content.Version)
+	}
+	peers := make(map[peer.ID]*PeerRecord, len(content.Peers))
+	for i := range content.Peers {
+		record := content.Peers[i]
+		peers[record.PeerID] = &record
+	}
+	if b.lock == nil {
+		b.lock = &sync.RWMutex{}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.peers = peers
+	return nil
+}
+
+// Len return the number of the peers in the address book
+func (b *AddressBook) Len() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.peers)
+}
+
+// Get return the record of the given peer
+func (b *AddressBook) Get(pID peer.ID) (PeerRecord, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	record, ok := b.peers[pID]
+	if !ok {
+		return PeerRecord{}, false
+	}
+	return copyRecord(record), true
+}
+
+// Records return a copy of the records ordered by the peer id
+func (b *AddressBook) Records() []PeerRecord {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	records := make([]PeerRecord, 0, len(b.peers))
+	for _, el := range b.peers {
+		records = append(records, copyRecord(el))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].PeerID < records[j].PeerID
+	})
+	return records
+}
+
+func copyRecord(record *PeerRecord) PeerRecord {
+	result := *record
+	result.Addrs = append([]string{}, record.Addrs...)
+	return result
+}
+
+// Seen record the peer is seen at the given addresses, the loopback addresses are not kept, and a peer without
+// any other address is ignored
+func (b *AddressBook) Seen(pID peer.ID, addrs []maddr.Multiaddr, now time.Time) {
+	var kept []string
+	for _, el := range addrs {
+		// we do not save the loopback addr
+		if strings.Contains(el.String(), "127.0.0.1") {
+			continue
+		}
+		kept = append(kept, el.String())
+	}
+	if len(kept) == 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	record, ok := b.peers[pID]
+	if !ok {
+		record = &PeerRecord{PeerID: pID}
+		if pubKey, err := conversion.GetPubKeyFromPeerID(pID.String()); err == nil {
+			record.PubKey = pubKey
+		}
+		b.peers[pID] = record
+	}
+	record.Addrs = kept
+	record.LastSeen = now
+}
+
+// RecordDial record the outcome of a dial to the peer, a successful dial also marks the peer seen
+func (b *AddressBook) RecordDial(pID peer.ID, ok bool, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	record, found := b.peers[pID]
+	if !found {
+		return
+	}
+	if ok {
+		record.Successes++
+		record.LastSeen = now
+		return
+	}
+	record.Failures++
+}
+
+// Prune remove the peers not seen for longer than maxAge, it returns the number of the peers removed
+func (b *AddressBook) Prune(now time.Time, maxAge time.Duration) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	removed := 0
+	for pID, el := range b.peers {
+		if now.Sub(el.LastSeen) > maxAge {
+			delete(b.peers, pID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Score return the score of the given peer, see PeerRecord.Score
+func (b *AddressBook) Score(pID peer.ID, now time.Time) float64 {
+	record, ok := b.Get(pID)
+	if !ok {
+		record = PeerRecord{LastSeen: now}
+	}
+	return record.Score(now)
+}
+
+// AddrInfos return the peers in the address book ordered by their score, the best first
+func (b *AddressBook) AddrInfos(now time.Time) []peer.AddrInfo {
+	records := b.Records()
+	infos := make([]peer.AddrInfo, 0, len(records))
+	for _, el := range records {
+		info := peer.AddrInfo{ID: el.PeerID}
+		for _, a := range el.Addrs {
+			addr, err := maddr.NewMultiaddr(a)
+			if err != nil {
+				continue
+			}
+			info.Addrs = append(info.Addrs, addr)
+		}
+		if len(info.Addrs) > 0 {
+			infos = append(infos, info)
+		}
+	}
+	b.SortByScore(infos, now)
+	return infos
+}
+
+// SortByScore order the peers by their score, the best first, the peers with the same score keep their order
+func (b *AddressBook) SortByScore(infos []peer.AddrInfo, now time.Time) {
+	scores := make(map[peer.ID]float64, len(infos))
+	for _, el := range infos {
+		scores[el.ID] = b.Score(el.ID, now)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return scores[infos[i].ID] > scores[infos[j].ID]
+	})
+}
@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	maddr "github.com/multiformats/go-multiaddr"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+type AddressBookTestSuite struct{}
+
+var _ = Suite(&AddressBookTestSuite{})
+
+func (s *AddressBookTestSuite) SetUpSuite(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func (s *AddressBookTestSuite) TestAddressBook(c *C) {
+	now := time.Now().UTC().Round(0)
+	peers := generateRandomPeers(c, 3)
+	book := NewAddressBook()
+	loopback, err := maddr.NewMultiaddr("/ip4/127.0.0.1/tcp/6668")
+	c.Assert(err, IsNil)
+	remote, err := maddr.NewMultiaddr("/ip4/192.168.0.1/tcp/6668")
+	c.Assert(err, IsNil)
+
+	// a peer only seen at the loopback address is not kept
+	book.Seen(peers[0], []maddr.Multiaddr{loopback}, now)
+	c.Assert(book.Len(), Equals, 0)
+	for _, el := range peers {
+		book.Seen(el, []maddr.Multiaddr{loopback, remote}, now.Add(-time.Hour))
+	}
+	c.Assert(book.Len(), Equals, 3)
+	record, ok := book.Get(peers[0])
+	c.Assert(ok, Equals, true)
+	c.Assert(record.Addrs, DeepEquals, []string{remote.String()})
+	pubKey, err := conversion.GetPubKeyFromPeerID(peers[0].String())
+	c.Assert(err, IsNil)
+	c.Assert(record.PubKey, Equals, pubKey)
+
+	// the peer that keeps failing ranks below the others, the one that always answers ranks first
+	book.RecordDial(peers[1], true, now)
+	book.RecordDial(peers[1], true, now)
+	book.RecordDial(peers[2], false, now)
+	book.RecordDial(peers[2], false, now)
+	infos := book.AddrInfos(now)
+	c.Assert(infos, HasLen, 3)
+	c.Assert(infos[0].ID, Equals, peers[1])
+	c.Assert(infos[1].ID, Equals, peers[0])
+	c.Assert(infos[2].ID, Equals, peers[2])
+	record, _ = book.Get(peers[1])
+	c.Assert(record.Successes, Equals, 2)
+	c.Assert(record.LastSeen.Equal(now), Equals, true)
+	record, _ = book.Get(peers[2])
+	c.Assert(record.Failures, Equals, 2)
+
+	// a peer not in the book ranks as a peer just seen but never dialed
+	unknown := generateRandomPeers(c, 1)[0]
+	infos = []peer.AddrInfo{{ID: peers[2]}, {ID: unknown}, {ID: peers[1]}}
+	book.SortByScore(infos, now)
+	c.Assert(infos[0].ID, Equals, peers[1])
+	c.Assert(infos[1].ID, Equals, unknown)
+	c.Assert(infos[2].ID, Equals, peers[2])
+
+	buf, err := json.Marshal(book)
+	c.Assert(err, IsNil)
+	restored := NewAddressBook()
+	c.Assert(json.Unmarshal(buf, restored), IsNil)
+	c.Assert(restored.Records(), DeepEquals, book.Records())
+	c.Assert(json.Unmarshal([]byte(`{"version":2}`), restored), NotNil)
+
+	// the peers not seen within the max age are pruned, a failed dial does not count as seen
+	c.Assert(book.Prune(now.Add(DefaultAddressBookMaxAge), DefaultAddressBookMaxAge), Equals, 2)
+	c.Assert(book.Len(), Equals, 1)
+	_, ok = book.Get(peers[1])
+	c.Assert(ok, Equals, true)
+}
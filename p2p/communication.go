@@ -23,6 +23,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/monitor"
 )
 
 var (
@@ -36,6 +37,8 @@ var TSSProtocolID protocol.ID = "/p2p/tss"
 const (
 	// TimeoutConnecting maximum time for wait for peers to connect
 	TimeoutConnecting = time.Second * 20
+	// maxConcurrentBootstrapDials is the number of the bootstrap peers dialed at the same time
+	maxConcurrentBootstrapDials = 16
 )
 
 // Message that get transfer across the wire
@@ -53,12 +56,20 @@ type Communication struct {
 	host             host.Host
 	wg               *sync.WaitGroup
 	stopChan         chan struct{} // channel to indicate whether we should stop
-	subscribers      map[messages.THORChainTSSMessageType]*MessageIDSubscriber
-	subscriberLocker *sync.Mutex
+	subscribers      *topicSubscribers
 	streamCount      int64
 	BroadcastMsgChan chan *messages.BroadcastMsgChan
 	externalAddr     maddr.Multiaddr
 	streamMgr        *StreamMgr
+	addressBook      *AddressBook
+	peerStreams      map[peer.ID]*peerStream
+	peerStreamsLock  *sync.Mutex
+	metrics          *monitor.Metric
+	broadcastMode    BroadcastMode
+	gossip           *gossip
+	gater            *peerGater
+	limiter          *rateLimiter
+	queues           *msgQueues
 }
 
 // NewCommunication create a new instance of Communication
@@ -74,27 +85,102 @@ func NewCommunication(rendezvous string, bootstrapPeers []maddr.Multiaddr, port
 			return nil, fmt.Errorf("fail to create listen with given external IP: %w", err)
 		}
 	}
+	logger := log.With().Str("module", "communication").Logger()
+	stopChan := make(chan struct{})
 	return &Communication{
 		rendezvous:       rendezvous,
 		bootstrapPeers:   bootstrapPeers,
-		logger:           log.With().Str("module", "communication").Logger(),
+		logger:           logger,
 		listenAddr:       addr,
 		wg:               &sync.WaitGroup{},
-		stopChan:         make(chan struct{}),
-		subscribers:      make(map[messages.THORChainTSSMessageType]*MessageIDSubscriber),
-		subscriberLocker: &sync.Mutex{},
+		stopChan:         stopChan,
+		subscribers:      newTopicSubscribers(logger),
 		streamCount:      0,
 		BroadcastMsgChan: make(chan *messages.BroadcastMsgChan, 1024),
 		externalAddr:     externalAddr,
 		streamMgr:        NewStreamMgr(),
+		addressBook:      NewAddressBook(),
+		peerStreams:      make(map[peer.ID]*peerStream),
+		peerStreamsLock:  &sync.Mutex{},
+		metrics:          monitor.NewMetric(),
+		broadcastMode:    BroadcastUnicast,
+		gater:            newPeerGater(logger),
+		limiter:          newRateLimiter(DefaultRateLimits, logger),
+		queues:           newMsgQueues(stopChan),
 	}, nil
 }
 
+// SetMetric set the metric the stream events are counted in, it must be called before Start
+func (c *Communication) SetMetric(metrics *monitor.Metric) {
+	c.metrics = metrics
+	c.gater.metrics = metrics
+	c.limiter.metrics = metrics
+}
+
+// SetRateLimits set the limits of the inbound messages of every peer and of every peer in every msg id, it must be
+// called before Start
+func (c *Communication) SetRateLimits(limits RateLimits) {
+	c.limiter.limits = limits
+}
+
+// SetRateLimitHandler report the peers whose messages of the msg id keep being dropped over the rate limits to the
+// handler, till the stream of the msg id is released
+func (c *Communication) SetRateLimitHandler(msgID string, handler func(peer.ID)) {
+	c.limiter.setHandler(msgID, handler)
+}
+
+// SetAllowedPeers admit only the given peers and the bootstrap peers to connect to the node, the connections to
+// the other peers are closed. An empty list admits every peer
+func (c *Communication) SetAllowedPeers(peers []peer.ID) {
+	if len(peers) == 0 {
+		c.gater.setAllowed(nil)
+		return
+	}
+	allowed := append([]peer.ID{}, peers...)
+	for _, el := range c.bootstrapPeers {
+		pi, err := peer.AddrInfoFromP2pAddr(el)
+		if err != nil {
+			continue
+		}
+		allowed = append(allowed, pi.ID)
+	}
+	c.gater.setAllowed(allowed)
+	if c.host == nil {
+		return
+	}
+	for _, el := range c.host.Network().Peers() {
+		if c.gater.isAllowed(el) {
+			continue
+		}
+		c.logger.Info().Msgf("close the connection to peer(%s) that is not on the allowlist", el)
+		if err := c.host.Network().ClosePeer(el); err != nil {
+			c.logger.Error().Err(err).Msgf("fail to close the connection to peer(%s)", el)
+		}
+	}
+}
+
+// SetBroadcastMode set how the broadcast messages are sent, it must be called before Start. In the gossip mode the
+// messages go over the gossip topic of their msg id once all their recipients have joined it, and over the streams
+// till then, so the parties in the unicast mode still get them
+func (c *Communication) SetBroadcastMode(mode BroadcastMode) error {
+	return c.broadcastMode.Set(string(mode))
+}
+
 // GetHost return the host
 func (c *Communication) GetHost() host.Host {
 	return c.host
 }
 
+// GetStreamHost return the libp2p host
+func (c *Communication) GetStreamHost() StreamHost {
+	return c.host
+}
+
+// GetBroadcastChannel return the channel the tss messages to broadcast are queued in
+func (c *Communication) GetBroadcastChannel() chan *messages.BroadcastMsgChan {
+	return c.BroadcastMsgChan
+}
+
 // GetLocalPeerID from p2p host
 func (c *Communication) GetLocalPeerID() string {
 	return c.host.ID().String()
@@ -133,20 +219,8 @@ func (c *Communication) writeToStream(pID peer.ID, msg []byte, msgID string) err
 	if pID == c.host.ID() {
 		return nil
 	}
-	stream, err := c.connectToOnePeer(pID)
-	if err != nil {
-		return fmt.Errorf("fail to open stream to peer(%s): %w", pID, err)
-	}
-	if nil == stream {
-		return nil
-	}
-
-	defer func() {
-		c.streamMgr.AddStream(msgID, stream)
-	}()
 	c.logger.Debug().Msgf(">>>writing messages to peer(%s)", pID)
-
-	return WriteStreamWithBuffer(msg, stream)
+	return c.writeToPeerStream(pID, msg, msgID)
 }
 
 func (c *Communication) readFromStream(stream network.Stream) {
@@ -157,7 +231,16 @@ func (c *Communication) readFromStream(stream network.Stream) {
 	case <-c.stopChan:
 		return
 	default:
-		dataBuf, err := ReadStreamWithBuffer(stream)
+		// the messages over the rate limit of the peer are dropped before the payload is read, and their streams are
+		// reset instead of kept for reuse
+		remotePeer := stream.Conn().RemotePeer()
+		dataBuf, err := readStreamWithCheck(stream, func(length int) bool {
+			return c.limiter.allowPeer(remotePeer, length)
+		})
+		if errors.Is(err, errMessageDropped) {
+			c.resetStream(stream)
+			return
+		}
 		if err != nil {
 			c.logger.Error().Err(err).Msgf("fail to read from stream,peerID: %s", peerID)
 			c.streamMgr.AddStream("UNKNOWN", stream)
@@ -169,6 +252,10 @@ func (c *Communication) readFromStream(stream network.Stream) {
 			c.streamMgr.AddStream("UNKNOWN", stream)
 			return
 		}
+		if !c.limiter.allowMsgID(remotePeer, wrappedMsg.MsgID, len(dataBuf)) {
+			c.resetStream(stream)
+			return
+		}
 		c.logger.Debug().Msgf(">>>>>>>[%s] %s", wrappedMsg.MessageType, string(wrappedMsg.Payload))
 		c.streamMgr.AddStream(wrappedMsg.MsgID, stream)
 		channel := c.getSubscriber(wrappedMsg.MessageType, wrappedMsg.MsgID)
@@ -178,13 +265,56 @@ func (c *Communication) readFromStream(stream network.Stream) {
 			return
 		}
 		channel <- &Message{
-			PeerID:  stream.Conn().RemotePeer(),
+			PeerID:  remotePeer,
 			Payload: dataBuf,
 		}
 
 	}
 }
 
+func (c *Communication) resetStream(stream network.Stream) {
+	if err := stream.Reset(); err != nil {
+		c.logger.Debug().Err(err).Msg("fail to reset the stream")
+	}
+}
+
+// routeGossipMessage route the message relayed through gossip, which is read in full before it is handed over
+func (c *Communication) routeGossipMessage(remotePeer peer.ID, msgID string, dataBuf []byte) {
+	if !c.limiter.allowPeer(remotePeer, len(dataBuf)) {
+		return
+	}
+	c.routeMessage(remotePeer, msgID, dataBuf)
+}
+
+// routeMessage hand the message read from the long-lived stream to the subscriber of its type and msg id, the message
+// has passed the rate limit of its peer already
+func (c *Communication) routeMessage(remotePeer peer.ID, msgID string, dataBuf []byte) {
+	// the messages over the rate limit of the msg id are dropped before they are parsed
+	if !c.limiter.allowMsgID(remotePeer, msgID, len(dataBuf)) {
+		return
+	}
+	var wrappedMsg messages.WrappedMessage
+	if err := json.Unmarshal(dataBuf, &wrappedMsg); nil != err {
+		c.logger.Error().Err(err).Msg("fail to unmarshal wrapped message bytes")
+		return
+	}
+	if wrappedMsg.MsgID != msgID {
+		c.logger.Error().Msgf("the msg id of the frame(%s) does not match the message(%s)", msgID, wrappedMsg.MsgID)
+		return
+	}
+	c.logger.Debug().Msgf(">>>>>>>[%s] %s", wrappedMsg.MessageType, string(wrappedMsg.Payload))
+	channel := c.getSubscriber(wrappedMsg.MessageType, wrappedMsg.MsgID)
+	if nil == channel {
+		c.logger.Debug().Msgf("no MsgID %s found for this message", wrappedMsg.MsgID)
+		return
+	}
+	// a slow subscriber must not hold up the messages of the other msg ids on the same stream, once its queue is full
+	// the stream waits for it rather than drops the message
+	if !c.queues.push(msgID, channel, &Message{PeerID: remotePeer, Payload: dataBuf}) {
+		c.logger.Debug().Msgf("the message(%s) is released, drop the message of peer(%s)", msgID, remotePeer)
+	}
+}
+
 func (c *Communication) handleStream(stream network.Stream) {
 	peerID := stream.Conn().RemotePeer().String()
 	c.logger.Debug().Msgf("handle stream from peer: %s", peerID)
@@ -193,19 +323,19 @@ func (c *Communication) handleStream(stream network.Stream) {
 }
 
 func (c *Communication) bootStrapConnectivityCheck() error {
-	if len(c.bootstrapPeers) == 0 {
+	bootstrapPeers, err := c.getBootstrapPeers()
+	if err != nil {
+		return err
+	}
+	if len(bootstrapPeers) == 0 {
 		c.logger.Error().Msg("we do not have the bootstrap node set, quit the connectivity check")
 		return nil
 	}
 
 	var onlineNodes uint32
 	var wg sync.WaitGroup
-	for _, el := range c.bootstrapPeers {
-		peer, err := peer.AddrInfoFromP2pAddr(el)
-		if err != nil {
-			c.logger.Error().Err(err).Msg("error in decode the bootstrap node, skip it")
-			continue
-		}
+	for _, el := range bootstrapPeers {
+		peer := el
 		wg.Add(1)
 		go func() {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
@@ -255,13 +385,21 @@ func (c *Communication) startChannel(privKeyBytes []byte) error {
 		libp2p.ListenAddrs([]maddr.Multiaddr{c.listenAddr}...),
 		libp2p.Identity(p2pPriKey),
 		libp2p.AddrsFactory(addressFactory),
+		libp2p.ConnectionGater(c.gater),
 	)
 	if err != nil {
 		return fmt.Errorf("fail to create p2p host: %w", err)
 	}
 	c.host = h
 	c.logger.Info().Msgf("Host created, we are: %s, at: %s", h.ID(), h.Addrs())
+	h.SetStreamHandler(TSSMuxProtocolID, c.handleMuxStream)
 	h.SetStreamHandler(TSSProtocolID, c.handleStream)
+	if c.broadcastMode == BroadcastGossip {
+		c.gossip, err = newGossip(h, c.routeGossipMessage, c.logger)
+		if err != nil {
+			return err
+		}
+	}
 	// Start a DHT, for use in peer discovery. We can't just make a new DHT
 	// client because we want each peer to maintain its own local copy of the
 	// DHT, so that the bootstrapping node of the DHT can go down without
@@ -310,7 +448,8 @@ func (c *Communication) connectToOnePeer(pID peer.ID) (network.Stream, error) {
 	c.logger.Debug().Msgf("connect to peer : %s", pID.String())
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutConnecting)
 	defer cancel()
-	stream, err := c.host.NewStream(ctx, pID, TSSProtocolID)
+	// the peers that do not serve the long-lived stream yet get a stream per message
+	stream, err := c.host.NewStream(ctx, pID, TSSMuxProtocolID, TSSProtocolID)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create new stream to peer: %s, %w", pID, err)
 	}
@@ -320,33 +459,43 @@ func (c *Communication) connectToOnePeer(pID peer.ID) (network.Stream, error) {
 func (c *Communication) connectToBootstrapPeers() error {
 	// Let's connect to the bootstrap nodes first. They will tell us about the
 	// other nodes in the network.
-	if len(c.bootstrapPeers) == 0 {
+	bootstrapPeers, err := c.getBootstrapPeers()
+	if err != nil {
+		return err
+	}
+	if len(bootstrapPeers) == 0 {
 		c.logger.Info().Msg("no bootstrap node set, we skip the connection")
 		return nil
 	}
+	// the peers are dialed in the order of their score, so the peers that answered before are dialed first
 	var wg sync.WaitGroup
-	connRet := make(chan bool, len(c.bootstrapPeers))
-	for _, peerAddr := range c.bootstrapPeers {
-		pi, err := peer.AddrInfoFromP2pAddr(peerAddr)
-		if err != nil {
-			return fmt.Errorf("fail to add peer: %w", err)
-		}
+	connRet := make(chan bool, len(bootstrapPeers))
+	dialSlots := make(chan struct{}, maxConcurrentBootstrapDials)
+	for _, el := range bootstrapPeers {
+		pi := el
+		dialSlots <- struct{}{}
 		wg.Add(1)
-		go func(connRet chan bool) {
+		go func() {
 			defer wg.Done()
+			defer func() {
+				<-dialSlots
+			}()
 			ctx, cancel := context.WithTimeout(context.Background(), TimeoutConnecting)
 			defer cancel()
-			if err := c.host.Connect(ctx, *pi); err != nil {
+			if err := c.host.Connect(ctx, pi); err != nil {
 				c.logger.Error().Err(err).Msgf("fail to connect to %s", pi.String())
+				c.addressBook.RecordDial(pi.ID, false, time.Now())
 				connRet <- false
 				return
 			}
+			c.addressBook.Seen(pi.ID, pi.Addrs, time.Now())
+			c.addressBook.RecordDial(pi.ID, true, time.Now())
 			connRet <- true
-			c.logger.Info().Msgf("Connection established with bootstrap node: %s", *pi)
-		}(connRet)
+			c.logger.Info().Msgf("Connection established with bootstrap node: %s", pi)
+		}()
 	}
 	wg.Wait()
-	for i := 0; i < len(c.bootstrapPeers); i++ {
+	for i := 0; i < len(bootstrapPeers); i++ {
 		if <-connRet {
 			return nil
 		}
@@ -370,50 +519,32 @@ func (c *Communication) Stop() error {
 	if err := c.host.Close(); err != nil {
 		c.logger.Err(err).Msg("fail to close host network")
 	}
+	if c.gossip != nil {
+		c.gossip.stop()
+	}
 
 	close(c.stopChan)
 	c.wg.Wait()
 	return nil
 }
 
+// SetSubscribe deliver the messages of the given type and msg id to the channel
 func (c *Communication) SetSubscribe(topic messages.THORChainTSSMessageType, msgID string, channel chan *Message) {
-	c.subscriberLocker.Lock()
-	defer c.subscriberLocker.Unlock()
-
-	messageIDSubscribers, ok := c.subscribers[topic]
-	if !ok {
-		messageIDSubscribers = NewMessageIDSubscriber()
-		c.subscribers[topic] = messageIDSubscribers
+	c.subscribers.subscribe(topic, msgID, channel)
+	if c.gossip != nil {
+		c.gossip.join(msgID)
 	}
-	messageIDSubscribers.Subscribe(msgID, channel)
 }
 
 func (c *Communication) getSubscriber(topic messages.THORChainTSSMessageType, msgID string) chan *Message {
-	c.subscriberLocker.Lock()
-	defer c.subscriberLocker.Unlock()
-	messageIDSubscribers, ok := c.subscribers[topic]
-	if !ok {
-		c.logger.Debug().Msgf("fail to find subscribers for %s", topic)
-		return nil
-	}
-	return messageIDSubscribers.GetSubscriber(msgID)
+	return c.subscribers.get(topic, msgID)
 }
 
+// CancelSubscribe stop delivering the messages of the given type and msg id
 func (c *Communication) CancelSubscribe(topic messages.THORChainTSSMessageType, msgID string) {
-	c.subscriberLocker.Lock()
-	defer c.subscriberLocker.Unlock()
-
-	messageIDSubscribers, ok := c.subscribers[topic]
-	if !ok {
-		c.logger.Debug().Msgf("cannot find the given channels %s", topic.String())
-		return
-	}
-	if nil == messageIDSubscribers {
-		return
-	}
-	messageIDSubscribers.UnSubscribe(msgID)
-	if messageIDSubscribers.IsEmpty() {
-		delete(c.subscribers, topic)
+	c.subscribers.unsubscribe(topic, msgID)
+	if c.gossip != nil {
+		c.gossip.leave(msgID)
 	}
 }
 
@@ -430,6 +561,9 @@ func (c *Communication) ProcessBroadcast() {
 				continue
 			}
 			c.logger.Debug().Msgf("broadcast message %s to %+v", msg.WrappedMessage, msg.PeersID)
+			if c.gossip != nil && c.gossip.publish(msg.PeersID, wrappedMsgBytes, msg.WrappedMessage.MsgID) {
+				continue
+			}
 			c.Broadcast(msg.PeersID, wrappedMsgBytes, msg.WrappedMessage.MsgID)
 
 		case <-c.stopChan:
@@ -440,4 +574,6 @@ func (c *Communication) ProcessBroadcast() {
 
 func (c *Communication) ReleaseStream(msgID string) {
 	c.streamMgr.ReleaseStream(msgID)
+	c.limiter.release(msgID)
+	c.queues.release(msgID)
 }
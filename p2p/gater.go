@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/control"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	maddr "github.com/multiformats/go-multiaddr"
+	"github.com/rs/zerolog"
+
+	"gitlab.com/thorchain/tss/go-tss/monitor"
+)
+
+// peerGater admit only the peers on the allowlist to connect to the node, every peer is admitted till the
+// allowlist is set. It is the connection gater of the libp2p host
+type peerGater struct {
+	lock    *sync.RWMutex
+	allowed map[peer.ID]bool
+	logger  zerolog.Logger
+	metrics *monitor.Metric
+}
+
+func newPeerGater(logger zerolog.Logger) *peerGater {
+	return &peerGater{
+		lock:    &sync.RWMutex{},
+		logger:  logger,
+		metrics: monitor.NewMetric(),
+	}
+}
+
+// setAllowed replace the allowlist with the given peers, an empty list admits every peer
+func (g *peerGater) setAllowed(peers []peer.ID) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(peers) == 0 {
+		g.allowed = nil
+		return
+	}
+	g.allowed = make(map[peer.ID]bool, len(peers))
+	for _, el := range peers {
+		g.allowed[el] = true
+	}
+}
+
+func (g *peerGater) isAllowed(p peer.ID) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.allowed == nil || g.allowed[p]
+}
+
+// admit check the peer against the allowlist, the rejected ones are logged and counted by their direction
+func (g *peerGater) admit(p peer.ID, direction string) bool {
+	if g.isAllowed(p) {
+		return true
+	}
+	g.metrics.UpdateP2PRejected(direction)
+	if direction == monitor.RejectedInbound {
+		g.logger.Warn().Msgf("reject the connection from peer(%s) that is not on the allowlist", p)
+	} else {
+		g.logger.Debug().Msgf("reject the dial to peer(%s) that is not on the allowlist", p)
+	}
+	return false
+}
+
+// InterceptPeerDial implement connmgr.ConnectionGater, the peers not on the allowlist are not dialed
+func (g *peerGater) InterceptPeerDial(p peer.ID) bool {
+	return g.admit(p, monitor.RejectedOutbound)
+}
+
+// InterceptAddrDial implement connmgr.ConnectionGater, the peer is checked before its addresses are dialed
+func (g *peerGater) InterceptAddrDial(peer.ID, maddr.Multiaddr) bool {
+	return true
+}
+
+// InterceptAccept implement connmgr.ConnectionGater, the remote peer is only known once the connection is secured
+func (g *peerGater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured implement connmgr.ConnectionGater, the inbound connections of the peers not on the allowlist
+// are closed before any stream is opened over them
+func (g *peerGater) InterceptSecured(direction network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	if direction != network.DirInbound {
+		return true
+	}
+	return g.admit(p, monitor.RejectedInbound)
+}
+
+// InterceptUpgraded implement connmgr.ConnectionGater
+func (g *peerGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+type GaterTestSuite struct{}
+
+var _ = Suite(&GaterTestSuite{})
+
+func (s *GaterTestSuite) TestPeerGater(c *C) {
+	var comms []*Communication
+	for i := 0; i < 2; i++ {
+		sk, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		c.Assert(err, IsNil)
+		skRaw, err := sk.Raw()
+		c.Assert(err, IsNil)
+		comm, err := NewCommunication("commTest", nil, 2250+i, "")
+		c.Assert(err, IsNil)
+		c.Assert(comm.Start(skRaw), IsNil)
+		defer comm.Stop()
+		comms = append(comms, comm)
+	}
+	connect := func(from, to *Communication) error {
+		return from.host.Connect(context.Background(), peer.AddrInfo{ID: to.host.ID(), Addrs: to.host.Addrs()})
+	}
+	// the peers not on the allowlist can neither connect nor be dialed
+	comms[0].SetAllowedPeers([]peer.ID{conversion.GetRandomPeerID()})
+	c.Assert(connect(comms[1], comms[0]), NotNil)
+	c.Assert(connect(comms[0], comms[1]), NotNil)
+
+	// the rejected dial of the peer is backed off on its side, so the node dials it
+	comms[0].SetAllowedPeers([]peer.ID{comms[1].host.ID()})
+	c.Assert(connect(comms[0], comms[1]), IsNil)
+	c.Assert(comms[0].host.Network().Connectedness(comms[1].host.ID()), Equals, network.Connected)
+
+	// the connections to the peers taken off the allowlist are closed
+	comms[0].SetAllowedPeers([]peer.ID{conversion.GetRandomPeerID()})
+	c.Assert(comms[0].host.Network().Connectedness(comms[1].host.ID()), Not(Equals), network.Connected)
+
+	// an empty allowlist admits every peer
+	comms[0].SetAllowedPeers(nil)
+	c.Assert(connect(comms[0], comms[1]), IsNil)
+	c.Assert(comms[0].host.Network().Connectedness(comms[1].host.ID()), Equals, network.Connected)
+}
+
+func (s *GaterTestSuite) TestMemoryTransportGater(c *C) {
+	_, transports, _ := startMemoryTransports(c, 3)
+	defer func() {
+		for _, el := range transports {
+			c.Assert(el.Stop(), IsNil)
+		}
+	}()
+	var peers []peer.ID
+	var channels []chan *Message
+	for _, el := range transports {
+		peers = append(peers, el.ID())
+		channel := make(chan *Message, 10)
+		el.SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+		channels = append(channels, channel)
+	}
+	// the party only gets the messages of the peers on its allowlist, and only sends to them
+	transports[1].SetAllowedPeers([]peer.ID{peers[2]})
+	transports[0].Broadcast(peers, wrapTestMessage(c, "msg1", "a"), "msg1")
+	transports[1].Broadcast(peers, wrapTestMessage(c, "msg1", "b"), "msg1")
+	select {
+	case msg := <-channels[2]:
+		c.Assert(msg.PeerID, Equals, peers[0])
+	case <-time.After(time.Second * 2):
+		c.Fatal("the message is not delivered")
+	}
+	select {
+	case msg := <-channels[2]:
+		c.Assert(msg.PeerID, Equals, peers[1])
+	case <-time.After(time.Second * 2):
+		c.Fatal("the message is not delivered")
+	}
+	time.Sleep(time.Millisecond * 100)
+	c.Assert(channels[0], HasLen, 0)
+	c.Assert(channels[1], HasLen, 0)
+	_, err := transports[0].NewStream(context.Background(), peers[1], testMemoryProtocol)
+	c.Assert(err, NotNil)
+
+	// an empty allowlist admits every peer
+	transports[1].SetAllowedPeers(nil)
+	transports[0].Broadcast(peers[:2], wrapTestMessage(c, "msg1", "c"), "msg1")
+	select {
+	case msg := <-channels[1]:
+		c.Assert(msg.PeerID, Equals, peers[0])
+	case <-time.After(time.Second * 2):
+		c.Fatal("the message is not delivered")
+	}
+}
@@ -0,0 +1,252 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/rs/zerolog"
+)
+
+// BroadcastMode is how the messages sent to many parties at once leave the node
+type BroadcastMode string
+
+const (
+	// BroadcastUnicast write the message to every party on its own stream
+	BroadcastUnicast BroadcastMode = "unicast"
+	// BroadcastGossip publish the message once on the gossip topic of its msg id, the parties relay it to each other
+	BroadcastGossip BroadcastMode = "gossip"
+)
+
+const (
+	// gossipTopicPrefix is prepended to the msg id to name the gossip topic of a tss ceremony
+	gossipTopicPrefix = "/p2p/tss-gossip/"
+	// gossipSettleTime is how long a peer has to be on the topic before the messages are published to it, the
+	// peers are only grafted into the mesh of the topic on the heartbeats, and what is published before that
+	// reaches them late, if at all. It is twice the heartbeat interval of the gossip sub
+	gossipSettleTime = time.Second * 2
+)
+
+// String implement fmt.Stringer
+func (m *BroadcastMode) String() string {
+	return string(*m)
+}
+
+// Set parse the given value into the broadcast mode
+func (m *BroadcastMode) Set(value string) error {
+	switch BroadcastMode(value) {
+	case BroadcastUnicast, BroadcastGossip:
+		*m = BroadcastMode(value)
+		return nil
+	case "":
+		*m = BroadcastUnicast
+		return nil
+	}
+	return fmt.Errorf("invalid broadcast mode:%s, it should be %s or %s", value, BroadcastUnicast, BroadcastGossip)
+}
+
+// gossipMessage is what is published on the gossip topic, the parties that are not in the recipients drop it
+type gossipMessage struct {
+	Recipients []peer.ID `json:"recipients"`
+	Payload    []byte    `json:"payload"`
+}
+
+// gossipTopic is the topic of one msg id, it is left once all the subscriptions of the msg id are cancelled
+type gossipTopic struct {
+	topic        *pubsub.Topic
+	subscription *pubsub.Subscription
+	refs         int
+	joined       time.Time
+	// seen is when the peers were first seen on the topic
+	seen map[peer.ID]time.Time
+}
+
+// gossip publish the broadcast messages on a topic per msg id, the messages read from the topics are routed the
+// same way as the ones read from the streams
+type gossip struct {
+	lock    *sync.Mutex
+	ps      *pubsub.PubSub
+	self    peer.ID
+	topics  map[string]*gossipTopic
+	route   func(remotePeer peer.ID, msgID string, dataBuf []byte)
+	logger  zerolog.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	readers *sync.WaitGroup
+}
+
+func newGossip(h host.Host, route func(peer.ID, string, []byte), logger zerolog.Logger) (*gossip, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// the publisher only sends the message to its mesh peers, which is what keeps its uplink from being the
+	// bottleneck, the messages are signed by their author, so the relayed ones are still attributed to it
+	ps, err := pubsub.NewGossipSub(ctx, h,
+		pubsub.WithFloodPublish(false),
+		pubsub.WithMaxMessageSize(MaxPayload),
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictSign),
+	)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("fail to create gossip sub: %w", err)
+	}
+	return &gossip{
+		lock:    &sync.Mutex{},
+		ps:      ps,
+		self:    h.ID(),
+		topics:  make(map[string]*gossipTopic),
+		route:   route,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		readers: &sync.WaitGroup{},
+	}, nil
+}
+
+// join subscribe the topic of the msg id, it is joined only once however many times it is called
+func (g *gossip) join(msgID string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if t, ok := g.topics[msgID]; ok {
+		t.refs++
+		return
+	}
+	topic, err := g.ps.Join(gossipTopicPrefix + msgID)
+	if err != nil {
+		g.logger.Error().Err(err).Msgf("fail to join the gossip topic of msg id(%s)", msgID)
+		return
+	}
+	subscription, err := topic.Subscribe()
+	if err != nil {
+		g.logger.Error().Err(err).Msgf("fail to subscribe the gossip topic of msg id(%s)", msgID)
+		if err := topic.Close(); err != nil {
+			g.logger.Error().Err(err).Msg("fail to close the gossip topic")
+		}
+		return
+	}
+	g.topics[msgID] = &gossipTopic{
+		topic:        topic,
+		subscription: subscription,
+		refs:         1,
+		joined:       time.Now(),
+		seen:         make(map[peer.ID]time.Time),
+	}
+	g.readers.Add(1)
+	go g.read(msgID, subscription)
+}
+
+// leave drop one reference to the topic of the msg id, the topic is closed with the last one
+func (g *gossip) leave(msgID string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	t, ok := g.topics[msgID]
+	if !ok {
+		return
+	}
+	t.refs--
+	if t.refs > 0 {
+		return
+	}
+	delete(g.topics, msgID)
+	t.subscription.Cancel()
+	if err := t.topic.Close(); err != nil {
+		g.logger.Error().Err(err).Msgf("fail to close the gossip topic of msg id(%s)", msgID)
+	}
+}
+
+// publish send the message on the topic of the msg id, it returns false when the message is not published, in
+// which case it should be sent on the streams. A message is only published once all its recipients have been on
+// the topic for gossipSettleTime, the ones that have not joined the mesh yet would not get it in time otherwise
+func (g *gossip) publish(peers []peer.ID, msg []byte, msgID string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	t, ok := g.topics[msgID]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	seen := make(map[peer.ID]time.Time)
+	for _, el := range t.topic.ListPeers() {
+		seen[el] = now
+		if since, ok := t.seen[el]; ok {
+			seen[el] = since
+		}
+	}
+	// the peers that left the topic are seen afresh when they join it again
+	t.seen = seen
+	settled := now.Add(-gossipSettleTime)
+	if t.joined.After(settled) {
+		return false
+	}
+	var recipients []peer.ID
+	for _, el := range peers {
+		if el == g.self {
+			continue
+		}
+		seen, ok := t.seen[el]
+		if !ok || seen.After(settled) {
+			g.logger.Debug().Msgf("peer(%s) is not settled on the gossip topic of msg id(%s)", el, msgID)
+			return false
+		}
+		recipients = append(recipients, el)
+	}
+	// a single recipient gains nothing from the relays
+	if len(recipients) < 2 {
+		return false
+	}
+	buf, err := json.Marshal(gossipMessage{
+		Recipients: recipients,
+		Payload:    msg,
+	})
+	if err != nil {
+		g.logger.Error().Err(err).Msg("fail to marshal the gossip message")
+		return false
+	}
+	if err := t.topic.Publish(g.ctx, buf); err != nil {
+		g.logger.Error().Err(err).Msgf("fail to publish to the gossip topic of msg id(%s)", msgID)
+		return false
+	}
+	g.logger.Debug().Msgf("published the message to %d peers on the gossip topic of msg id(%s)", len(recipients), msgID)
+	return true
+}
+
+// read route the messages published on the topic of the msg id to us till the subscription is cancelled
+func (g *gossip) read(msgID string, subscription *pubsub.Subscription) {
+	defer g.readers.Done()
+	for {
+		msg, err := subscription.Next(g.ctx)
+		if err != nil {
+			return
+		}
+		author := msg.GetFrom()
+		if author == g.self {
+			continue
+		}
+		var gossipMsg gossipMessage
+		if err := json.Unmarshal(msg.Data, &gossipMsg); err != nil {
+			g.logger.Error().Err(err).Msgf("fail to unmarshal the gossip message from peer(%s)", author)
+			continue
+		}
+		for _, el := range gossipMsg.Recipients {
+			if el == g.self {
+				g.route(author, msgID, gossipMsg.Payload)
+				break
+			}
+		}
+	}
+}
+
+// stop leave all the topics and shut down the gossip sub
+func (g *gossip) stop() {
+	g.cancel()
+	g.lock.Lock()
+	for msgID, t := range g.topics {
+		t.subscription.Cancel()
+		delete(g.topics, msgID)
+	}
+	g.lock.Unlock()
+	g.readers.Wait()
+}
@@ -0,0 +1,127 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	maddr "github.com/multiformats/go-multiaddr"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+type GossipTestSuite struct{}
+
+var _ = Suite(&GossipTestSuite{})
+
+func (s *GossipTestSuite) TestBroadcastMode(c *C) {
+	var mode BroadcastMode
+	c.Assert(mode.Set(""), IsNil)
+	c.Assert(mode, Equals, BroadcastUnicast)
+	c.Assert(mode.Set("gossip"), IsNil)
+	c.Assert(mode, Equals, BroadcastGossip)
+	c.Assert(mode.Set("flood"), NotNil)
+	c.Assert(mode.String(), Equals, "gossip")
+
+	comm, err := NewCommunication("commTest", nil, 2240, "")
+	c.Assert(err, IsNil)
+	c.Assert(comm.SetBroadcastMode("flood"), NotNil)
+	c.Assert(comm.broadcastMode, Equals, BroadcastUnicast)
+}
+
+func waitForTopicPeers(c *C, comm *Communication, msgID string, n int) {
+	for i := 0; i < 100; i++ {
+		comm.gossip.lock.Lock()
+		t, ok := comm.gossip.topics[msgID]
+		comm.gossip.lock.Unlock()
+		if ok && len(t.topic.ListPeers()) >= n {
+			return
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	c.Fatalf("the peers do not join the gossip topic of %s", msgID)
+}
+
+func (s *GossipTestSuite) TestGossipBroadcast(c *C) {
+	var comms []*Communication
+	var peers []peer.ID
+	for i := 0; i < 3; i++ {
+		sk, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		c.Assert(err, IsNil)
+		skRaw, err := sk.Raw()
+		c.Assert(err, IsNil)
+		id, err := peer.IDFromPrivateKey(sk)
+		c.Assert(err, IsNil)
+		var bootstrapPeers []maddr.Multiaddr
+		if i > 0 {
+			bootstrapPeer, err := maddr.NewMultiaddr("/ip4/127.0.0.1/tcp/2241/p2p/" + peers[0].String())
+			c.Assert(err, IsNil)
+			bootstrapPeers = append(bootstrapPeers, bootstrapPeer)
+		}
+		comm, err := NewCommunication("commTest", bootstrapPeers, 2241+i, "")
+		c.Assert(err, IsNil)
+		c.Assert(comm.SetBroadcastMode(BroadcastGossip), IsNil)
+		c.Assert(comm.Start(skRaw), IsNil)
+		defer comm.Stop()
+		comms = append(comms, comm)
+		peers = append(peers, id)
+	}
+	c.Assert(comms[2].host.Connect(context.Background(), peer.AddrInfo{ID: peers[1], Addrs: comms[1].host.Addrs()}), IsNil)
+
+	var channels []chan *Message
+	for _, el := range comms {
+		channel := make(chan *Message, 10)
+		el.SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+		el.SetSubscribe(messages.TSSKeyGenVerMsg, "msg1", channel)
+		channels = append(channels, channel)
+	}
+	for _, el := range comms {
+		waitForTopicPeers(c, el, "msg1", 2)
+	}
+	// the peers just seen on the topic may not be in its mesh yet
+	c.Assert(comms[0].gossip.publish(peers, wrapTestMessage(c, "msg1", "a"), "msg1"), Equals, false)
+	time.Sleep(gossipSettleTime)
+
+	// the messages to all the parties are published once, and relayed in the name of their author
+	comms[0].GetBroadcastChannel() <- &messages.BroadcastMsgChan{
+		WrappedMessage: messages.WrappedMessage{MessageType: messages.TSSKeyGenMsg, MsgID: "msg1", Payload: []byte("a")},
+		PeersID:        peers,
+	}
+	for _, channel := range channels[1:] {
+		select {
+		case msg := <-channel:
+			c.Assert(msg.PeerID, Equals, peers[0])
+			var wrappedMsg messages.WrappedMessage
+			c.Assert(json.Unmarshal(msg.Payload, &wrappedMsg), IsNil)
+			c.Assert(string(wrappedMsg.Payload), Equals, "a")
+		case <-time.After(time.Second * 5):
+			c.Fatal("the gossip message is not delivered")
+		}
+	}
+	c.Assert(comms[0].getPeerStream(peers[1]).opened, Equals, 0)
+	c.Assert(comms[0].getPeerStream(peers[2]).opened, Equals, 0)
+	c.Assert(channels[0], HasLen, 0)
+
+	// the parties that are not on the topic, and the single recipients, get the messages on the streams
+	c.Assert(comms[0].gossip.publish(peers, wrapTestMessage(c, "msg2", "b"), "msg2"), Equals, false)
+	c.Assert(comms[0].gossip.publish(peers[:2], wrapTestMessage(c, "msg1", "b"), "msg1"), Equals, false)
+	sk, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	c.Assert(err, IsNil)
+	unknown, err := peer.IDFromPrivateKey(sk)
+	c.Assert(err, IsNil)
+	c.Assert(comms[0].gossip.publish(append(peers, unknown), wrapTestMessage(c, "msg1", "b"), "msg1"), Equals, false)
+
+	// the topic is left once all the subscriptions of the msg id are cancelled
+	comms[2].CancelSubscribe(messages.TSSKeyGenMsg, "msg1")
+	comms[2].gossip.lock.Lock()
+	c.Assert(comms[2].gossip.topics, HasLen, 1)
+	comms[2].gossip.lock.Unlock()
+	comms[2].CancelSubscribe(messages.TSSKeyGenVerMsg, "msg1")
+	comms[2].gossip.lock.Lock()
+	c.Assert(comms[2].gossip.topics, HasLen, 0)
+	comms[2].gossip.lock.Unlock()
+}
@@ -0,0 +1,590 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	maddr "github.com/multiformats/go-multiaddr"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/monitor"
+)
+
+var (
+	// ErrMemoryPeerNotFound is returned when the peer has not joined the memory network
+	ErrMemoryPeerNotFound = errors.New("peer not found in the memory network")
+	// ErrMemoryStreamReset is returned by the in-memory streams after they are reset
+	ErrMemoryStreamReset = errors.New("stream reset")
+	// ErrMemoryStreamTimeout is returned by the reads of the in-memory streams that pass the read deadline
+	ErrMemoryStreamTimeout = errors.New("i/o deadline reached")
+)
+
+// MemoryNetwork connect the memory transports of the parties running in the same process, every party gets the
+// messages in the order they are sent
+type MemoryNetwork struct {
+	lock        *sync.RWMutex
+	peers       map[peer.ID]*MemoryTransport
+	streamCount int64
+}
+
+// NewMemoryNetwork create an empty memory network
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		lock:  &sync.RWMutex{},
+		peers: make(map[peer.ID]*MemoryTransport),
+	}
+}
+
+// NewTransport create a transport of the memory network, the party joins the network when the transport starts
+func (n *MemoryNetwork) NewTransport() *MemoryTransport {
+	logger := log.With().Str("module", "memory_transport").Logger()
+	return &MemoryTransport{
+		network:          n,
+		logger:           logger,
+		subscribers:      newTopicSubscribers(logger),
+		BroadcastMsgChan: make(chan *messages.BroadcastMsgChan, 1024),
+		handlerLock:      &sync.RWMutex{},
+		handlers:         make(map[protocol.ID]network.StreamHandler),
+		queueLock:        &sync.Mutex{},
+		queueSignal:      make(chan struct{}, 1),
+		stopChan:         make(chan struct{}),
+		wg:               &sync.WaitGroup{},
+		addressBook:      NewAddressBook(),
+		gater:            newPeerGater(logger),
+		limiter:          newRateLimiter(DefaultRateLimits, logger),
+	}
+}
+
+// Peers return the peers joined the memory network
+func (n *MemoryNetwork) Peers() []peer.ID {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	peers := make([]peer.ID, 0, len(n.peers))
+	for pID := range n.peers {
+		peers = append(peers, pID)
+	}
+	return peers
+}
+
+func (n *MemoryNetwork) join(t *MemoryTransport) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, ok := n.peers[t.id]; ok {
+		return fmt.Errorf("peer(%s) already joined the memory network", t.id)
+	}
+	n.peers[t.id] = t
+	return nil
+}
+
+func (n *MemoryNetwork) leave(pID peer.ID) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.peers, pID)
+}
+
+func (n *MemoryNetwork) getPeer(pID peer.ID) (*MemoryTransport, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	t, ok := n.peers[pID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMemoryPeerNotFound, pID)
+	}
+	return t, nil
+}
+
+// memoryMessage is a tss message queued for a party of the memory network
+type memoryMessage struct {
+	from    peer.ID
+	payload []byte
+}
+
+// MemoryTransport is the Transport of one party of a MemoryNetwork, the tss messages are handed over in memory
+// and the streams of the party coordinator and the signature notifier are in-memory pipes
+type MemoryTransport struct {
+	network          *MemoryNetwork
+	logger           zerolog.Logger
+	id               peer.ID
+	subscribers      *topicSubscribers
+	BroadcastMsgChan chan *messages.BroadcastMsgChan
+	handlerLock      *sync.RWMutex
+	handlers         map[protocol.ID]network.StreamHandler
+	queueLock        *sync.Mutex
+	queue            []memoryMessage
+	queueSignal      chan struct{}
+	stopChan         chan struct{}
+	wg               *sync.WaitGroup
+	addressBook      *AddressBook
+	gater            *peerGater
+	limiter          *rateLimiter
+}
+
+// Start join the memory network with the peer id of the given private key
+func (t *MemoryTransport) Start(priKeyBytes []byte) error {
+	p2pPriKey, err := crypto.UnmarshalSecp256k1PrivateKey(priKeyBytes)
+	if err != nil {
+		return fmt.Errorf("fail to unmarshal the private key: %w", err)
+	}
+	t.id, err = peer.IDFromPrivateKey(p2pPriKey)
+	if err != nil {
+		return fmt.Errorf("fail to get the peer id: %w", err)
+	}
+	t.logger = t.logger.With().Str("peer", t.id.String()).Logger()
+	if err := t.network.join(t); err != nil {
+		return err
+	}
+	t.wg.Add(2)
+	go t.processQueue()
+	go t.processBroadcast()
+	return nil
+}
+
+// Stop leave the memory network
+func (t *MemoryTransport) Stop() error {
+	t.network.leave(t.id)
+	close(t.stopChan)
+	t.wg.Wait()
+	return nil
+}
+
+// GetLocalPeerID return the peer id of the party
+func (t *MemoryTransport) GetLocalPeerID() string {
+	return t.id.String()
+}
+
+// GetStreamHost return the transport itself, it opens the streams to the other parties of the memory network
+func (t *MemoryTransport) GetStreamHost() StreamHost {
+	return t
+}
+
+// GetBroadcastChannel return the channel the tss messages to broadcast are queued in
+func (t *MemoryTransport) GetBroadcastChannel() chan *messages.BroadcastMsgChan {
+	return t.BroadcastMsgChan
+}
+
+// Broadcast queue the message for the given peers in the order of the peers, the peers not in the memory network
+// are skipped
+func (t *MemoryTransport) Broadcast(peers []peer.ID, msg []byte, msgID string) {
+	for _, el := range peers {
+		// don't send to ourselves
+		if el == t.id {
+			continue
+		}
+		remote, err := t.network.getPeer(el)
+		if err != nil {
+			t.logger.Error().Err(err).Msgf("fail to send the message(%s)", msgID)
+			continue
+		}
+		if !t.admit(remote) {
+			continue
+		}
+		remote.enqueue(memoryMessage{from: t.id, payload: msg})
+	}
+}
+
+// SetSubscribe deliver the messages of the given type and msg id to the channel
+func (t *MemoryTransport) SetSubscribe(topic messages.THORChainTSSMessageType, msgID string, channel chan *Message) {
+	t.subscribers.subscribe(topic, msgID, channel)
+}
+
+// CancelSubscribe stop delivering the messages of the given type and msg id
+func (t *MemoryTransport) CancelSubscribe(topic messages.THORChainTSSMessageType, msgID string) {
+	t.subscribers.unsubscribe(topic, msgID)
+}
+
+// ReleaseStream forget the rate limits of the msg id, the tss messages are not sent over streams
+func (t *MemoryTransport) ReleaseStream(msgID string) {
+	t.limiter.release(msgID)
+}
+
+// SetRateLimitHandler report the peers that keep passing the inbound rate limits of the msg id to the handler
+func (t *MemoryTransport) SetRateLimitHandler(msgID string, handler func(peer.ID)) {
+	t.limiter.setHandler(msgID, handler)
+}
+
+// ExportAddressBook return an empty address book, the parties of the memory network have no address
+func (t *MemoryTransport) ExportAddressBook() *AddressBook {
+	return t.addressBook
+}
+
+// SetAllowedPeers admit only the given peers to send messages and open streams to the party, an empty list admits
+// every peer
+func (t *MemoryTransport) SetAllowedPeers(peers []peer.ID) {
+	t.gater.setAllowed(peers)
+}
+
+// admit check both ends of a message or a stream against their allowlists
+func (t *MemoryTransport) admit(remote *MemoryTransport) bool {
+	return t.gater.admit(remote.id, monitor.RejectedOutbound) && remote.gater.admit(t.id, monitor.RejectedInbound)
+}
+
+func (t *MemoryTransport) enqueue(msg memoryMessage) {
+	t.queueLock.Lock()
+	t.queue = append(t.queue, msg)
+	t.queueLock.Unlock()
+	select {
+	case t.queueSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (t *MemoryTransport) dequeue() []memoryMessage {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+	queue := t.queue
+	t.queue = nil
+	return queue
+}
+
+// processQueue hand the queued messages to the subscribers one by one
+func (t *MemoryTransport) processQueue() {
+	defer t.wg.Done()
+	for {
+		select {
+		case <-t.stopChan:
+			return
+		case <-t.queueSignal:
+			for _, el := range t.dequeue() {
+				if !t.deliver(el) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver hand the message to its subscriber, it returns false when the transport stops before the message is
+// taken
+func (t *MemoryTransport) deliver(msg memoryMessage) bool {
+	var wrappedMsg messages.WrappedMessage
+	if err := json.Unmarshal(msg.payload, &wrappedMsg); nil != err {
+		t.logger.Error().Err(err).Msg("fail to unmarshal wrapped message bytes")
+		return true
+	}
+	if !t.limiter.allow(msg.from, wrappedMsg.MsgID, len(msg.payload)) {
+		return true
+	}
+	channel := t.subscribers.get(wrappedMsg.MessageType, wrappedMsg.MsgID)
+	if nil == channel {
+		t.logger.Debug().Msgf("no MsgID %s found for this message", wrappedMsg.MsgID)
+		return true
+	}
+	select {
+	case channel <- &Message{PeerID: msg.from, Payload: msg.payload}:
+		return true
+	case <-t.stopChan:
+		return false
+	}
+}
+
+func (t *MemoryTransport) processBroadcast() {
+	defer t.wg.Done()
+	for {
+		select {
+		case msg := <-t.BroadcastMsgChan:
+			wrappedMsgBytes, err := json.Marshal(msg.WrappedMessage)
+			if err != nil {
+				t.logger.Error().Err(err).Msg("fail to marshal a wrapped message to json bytes")
+				continue
+			}
+			t.Broadcast(msg.PeersID, wrappedMsgBytes, msg.WrappedMessage.MsgID)
+		case <-t.stopChan:
+			return
+		}
+	}
+}
+
+// ID return the peer id of the party
+func (t *MemoryTransport) ID() peer.ID {
+	return t.id
+}
+
+// NewStream open an in-memory stream to the handler of the first given protocol the peer serves
+func (t *MemoryTransport) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	remote, err := t.network.getPeer(p)
+	if err != nil {
+		return nil, err
+	}
+	if !t.admit(remote) {
+		return nil, fmt.Errorf("peer(%s) is not admitted", p)
+	}
+	for _, pid := range pids {
+		handler := remote.getStreamHandler(pid)
+		if handler == nil {
+			continue
+		}
+		local, inbound := newMemoryStreamPair(t.network, t.id, p, pid)
+		go handler(inbound)
+		return local, nil
+	}
+	return nil, fmt.Errorf("peer(%s) does not support the protocols %v", p, pids)
+}
+
+// SetStreamHandler serve the streams of the given protocol with the handler
+func (t *MemoryTransport) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	t.handlerLock.Lock()
+	defer t.handlerLock.Unlock()
+	t.handlers[pid] = handler
+}
+
+// RemoveStreamHandler stop serving the streams of the given protocol
+func (t *MemoryTransport) RemoveStreamHandler(pid protocol.ID) {
+	t.handlerLock.Lock()
+	defer t.handlerLock.Unlock()
+	delete(t.handlers, pid)
+}
+
+func (t *MemoryTransport) getStreamHandler(pid protocol.ID) network.StreamHandler {
+	t.handlerLock.RLock()
+	defer t.handlerLock.RUnlock()
+	return t.handlers[pid]
+}
+
+// memoryPipe carry the bytes of one direction of an in-memory stream, the writes never block like the writes to
+// the buffered libp2p streams
+type memoryPipe struct {
+	lock   *sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+	reset  bool
+	signal chan struct{}
+}
+
+func newMemoryPipe() *memoryPipe {
+	return &memoryPipe{
+		lock:   &sync.Mutex{},
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func (p *memoryPipe) notify() {
+	select {
+	case p.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (p *memoryPipe) read(b []byte, deadline time.Time) (int, error) {
+	for {
+		p.lock.Lock()
+		switch {
+		case p.reset:
+			p.lock.Unlock()
+			return 0, ErrMemoryStreamReset
+		case p.buf.Len() > 0:
+			n, err := p.buf.Read(b)
+			p.lock.Unlock()
+			return n, err
+		case p.closed:
+			p.lock.Unlock()
+			return 0, io.EOF
+		}
+		p.lock.Unlock()
+		if deadline.IsZero() {
+			<-p.signal
+			continue
+		}
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return 0, ErrMemoryStreamTimeout
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case <-p.signal:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *memoryPipe) write(b []byte) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.reset {
+		return 0, ErrMemoryStreamReset
+	}
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := p.buf.Write(b)
+	p.notify()
+	return n, err
+}
+
+func (p *memoryPipe) close(reset bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	p.reset = p.reset || reset
+	p.notify()
+}
+
+// memoryStream is one end of an in-memory stream between two parties of the memory network
+type memoryStream struct {
+	in           *memoryPipe
+	out          *memoryPipe
+	lock         *sync.Mutex
+	readDeadline time.Time
+	id           string
+	protocol     protocol.ID
+	stat         network.Stat
+	conn         *memoryConn
+}
+
+func newMemoryStreamPair(n *MemoryNetwork, local, remote peer.ID, pid protocol.ID) (*memoryStream, *memoryStream) {
+	id := strconv.FormatInt(atomic.AddInt64(&n.streamCount, 1), 10)
+	opened := time.Now()
+	toRemote, toLocal := newMemoryPipe(), newMemoryPipe()
+	outbound := &memoryStream{
+		in:       toLocal,
+		out:      toRemote,
+		lock:     &sync.Mutex{},
+		id:       id,
+		protocol: pid,
+		stat:     network.Stat{Direction: network.DirOutbound, Opened: opened},
+	}
+	outbound.conn = &memoryConn{id: id, local: local, remote: remote, stream: outbound}
+	inbound := &memoryStream{
+		in:       toRemote,
+		out:      toLocal,
+		lock:     &sync.Mutex{},
+		id:       id,
+		protocol: pid,
+		stat:     network.Stat{Direction: network.DirInbound, Opened: opened},
+	}
+	inbound.conn = &memoryConn{id: id, local: remote, remote: local, stream: inbound}
+	return outbound, inbound
+}
+
+func (s *memoryStream) Read(b []byte) (int, error) {
+	s.lock.Lock()
+	deadline := s.readDeadline
+	s.lock.Unlock()
+	return s.in.read(b, deadline)
+}
+
+func (s *memoryStream) Write(b []byte) (int, error) {
+	return s.out.write(b)
+}
+
+// Close close the stream for writing, the remote side can still write to it
+func (s *memoryStream) Close() error {
+	s.out.close(false)
+	return nil
+}
+
+// Reset close both directions of the stream
+func (s *memoryStream) Reset() error {
+	s.in.close(true)
+	s.out.close(true)
+	return nil
+}
+
+func (s *memoryStream) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+func (s *memoryStream) SetReadDeadline(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline does nothing, the writes never block
+func (s *memoryStream) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (s *memoryStream) ID() string {
+	return s.id
+}
+
+func (s *memoryStream) Protocol() protocol.ID {
+	return s.protocol
+}
+
+func (s *memoryStream) SetProtocol(id protocol.ID) {
+	s.protocol = id
+}
+
+func (s *memoryStream) Stat() network.Stat {
+	return s.stat
+}
+
+func (s *memoryStream) Conn() network.Conn {
+	return s.conn
+}
+
+// memoryConn is the connection of a single in-memory stream
+type memoryConn struct {
+	id     string
+	local  peer.ID
+	remote peer.ID
+	stream *memoryStream
+}
+
+func (c *memoryConn) Close() error {
+	return c.stream.Reset()
+}
+
+func (c *memoryConn) LocalPeer() peer.ID {
+	return c.local
+}
+
+// LocalPrivateKey return nil, the memory network does not hold the keys of the parties
+func (c *memoryConn) LocalPrivateKey() crypto.PrivKey {
+	return nil
+}
+
+func (c *memoryConn) RemotePeer() peer.ID {
+	return c.remote
+}
+
+func (c *memoryConn) RemotePublicKey() crypto.PubKey {
+	pubKey, err := c.remote.ExtractPublicKey()
+	if err != nil {
+		return nil
+	}
+	return pubKey
+}
+
+func (c *memoryConn) LocalMultiaddr() maddr.Multiaddr {
+	return nil
+}
+
+func (c *memoryConn) RemoteMultiaddr() maddr.Multiaddr {
+	return nil
+}
+
+func (c *memoryConn) ID() string {
+	return c.id
+}
+
+func (c *memoryConn) NewStream() (network.Stream, error) {
+	return nil, errors.New("the memory connection carries a single stream")
+}
+
+func (c *memoryConn) GetStreams() []network.Stream {
+	return []network.Stream{c.stream}
+}
+
+func (c *memoryConn) Stat() network.Stat {
+	return c.stream.stat
+}
@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+const testMemoryProtocol protocol.ID = "/p2p/memory-test"
+
+type MemoryTransportTestSuite struct{}
+
+var _ = Suite(&MemoryTransportTestSuite{})
+
+func startMemoryTransports(c *C, n int) (*MemoryNetwork, []*MemoryTransport, [][]byte) {
+	network := NewMemoryNetwork()
+	var transports []*MemoryTransport
+	var keys [][]byte
+	for i := 0; i < n; i++ {
+		priKey, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		c.Assert(err, IsNil)
+		keyBytes, err := priKey.Raw()
+		c.Assert(err, IsNil)
+		transport := network.NewTransport()
+		c.Assert(transport.Start(keyBytes), IsNil)
+		transports = append(transports, transport)
+		keys = append(keys, keyBytes)
+	}
+	return network, transports, keys
+}
+
+func wrapTestMessage(c *C, msgID string, payload string) []byte {
+	buf, err := json.Marshal(messages.WrappedMessage{
+		MessageType: messages.TSSKeyGenMsg,
+		MsgID:       msgID,
+		Payload:     []byte(payload),
+	})
+	c.Assert(err, IsNil)
+	return buf
+}
+
+func (s *MemoryTransportTestSuite) TestBroadcast(c *C) {
+	network, transports, keys := startMemoryTransports(c, 3)
+	defer func() {
+		for _, el := range transports[:2] {
+			c.Assert(el.Stop(), IsNil)
+		}
+	}()
+	c.Assert(network.Peers(), HasLen, 3)
+	c.Assert(network.NewTransport().Start(keys[0]), NotNil)
+
+	var peers []peer.ID
+	var channels []chan *Message
+	for _, el := range transports {
+		peers = append(peers, el.ID())
+		channel := make(chan *Message, 10)
+		el.SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+		channels = append(channels, channel)
+	}
+	// the messages reach every other party in the order they are sent, the ones nobody subscribed are dropped
+	transports[0].Broadcast(peers, wrapTestMessage(c, "msg2", "dropped"), "msg2")
+	for i := 0; i < 3; i++ {
+		transports[0].Broadcast(peers, wrapTestMessage(c, "msg1", string(rune('a'+i))), "msg1")
+	}
+	transports[0].GetBroadcastChannel() <- &messages.BroadcastMsgChan{
+		WrappedMessage: messages.WrappedMessage{MessageType: messages.TSSKeyGenMsg, MsgID: "msg1", Payload: []byte("d")},
+		PeersID:        peers,
+	}
+	for _, channel := range channels[1:] {
+		for _, expected := range []string{"a", "b", "c", "d"} {
+			var msg *Message
+			select {
+			case msg = <-channel:
+			case <-time.After(time.Second * 2):
+				c.Fatalf("message %s is not delivered", expected)
+			}
+			c.Assert(msg.PeerID, Equals, transports[0].ID())
+			var wrappedMsg messages.WrappedMessage
+			c.Assert(json.Unmarshal(msg.Payload, &wrappedMsg), IsNil)
+			c.Assert(string(wrappedMsg.Payload), Equals, expected)
+		}
+	}
+	c.Assert(channels[0], HasLen, 0)
+
+	// a stopped party leaves the network and gets nothing
+	c.Assert(transports[2].Stop(), IsNil)
+	c.Assert(network.Peers(), HasLen, 2)
+	transports[1].CancelSubscribe(messages.TSSKeyGenMsg, "msg1")
+	transports[0].Broadcast(peers, wrapTestMessage(c, "msg1", "e"), "msg1")
+	time.Sleep(time.Millisecond * 100)
+	c.Assert(channels[1], HasLen, 0)
+	c.Assert(channels[2], HasLen, 0)
+}
+
+func (s *MemoryTransportTestSuite) TestStream(c *C) {
+	_, transports, _ := startMemoryTransports(c, 2)
+	defer func() {
+		for _, el := range transports {
+			c.Assert(el.Stop(), IsNil)
+		}
+	}()
+	received := make(chan []byte, 1)
+	transports[1].SetStreamHandler(testMemoryProtocol, func(stream network.Stream) {
+		c.Check(stream.Conn().RemotePeer(), Equals, transports[0].ID())
+		buf, err := ReadStreamWithBuffer(stream)
+		c.Check(err, IsNil)
+		received <- buf
+		c.Check(WriteStreamWithBuffer([]byte("done"), stream), IsNil)
+	})
+
+	stream, err := transports[0].NewStream(context.Background(), transports[1].ID(), "/p2p/unknown", testMemoryProtocol)
+	c.Assert(err, IsNil)
+	c.Assert(stream.Protocol(), Equals, testMemoryProtocol)
+	c.Assert(stream.Conn().RemotePeer(), Equals, transports[1].ID())
+	c.Assert(WriteStreamWithBuffer([]byte("hello"), stream), IsNil)
+	// closing the stream for writing still reads the reply
+	c.Assert(stream.Close(), IsNil)
+	c.Assert(WriteStreamWithBuffer([]byte("hello"), stream), NotNil)
+	c.Assert(<-received, DeepEquals, []byte("hello"))
+	reply, err := ReadStreamWithBuffer(stream)
+	c.Assert(err, IsNil)
+	c.Assert(reply, DeepEquals, []byte("done"))
+
+	transports[1].RemoveStreamHandler(testMemoryProtocol)
+	_, err = transports[0].NewStream(context.Background(), transports[1].ID(), testMemoryProtocol)
+	c.Assert(err, NotNil)
+
+	// the reads time out at the deadline and fail once the stream is reset
+	transports[1].SetStreamHandler(testMemoryProtocol, func(stream network.Stream) {})
+	stream, err = transports[0].NewStream(context.Background(), transports[1].ID(), testMemoryProtocol)
+	c.Assert(err, IsNil)
+	c.Assert(stream.SetReadDeadline(time.Now().Add(time.Millisecond*50)), IsNil)
+	_, err = stream.Read(make([]byte, 8))
+	c.Assert(err, Equals, ErrMemoryStreamTimeout)
+	c.Assert(stream.Reset(), IsNil)
+	_, err = stream.Read(make([]byte, 8))
+	c.Assert(err, Equals, ErrMemoryStreamReset)
+}
+
+func (s *MemoryTransportTestSuite) TestPartyCoordinator(c *C) {
+	_, transports, _ := startMemoryTransports(c, 4)
+	var pcs []*PartyCoordinator
+	var peers []string
+	for _, el := range transports {
+		pcs = append(pcs, NewPartyCoordinator(el.GetStreamHost(), time.Second*4))
+		peers = append(peers, el.GetLocalPeerID())
+	}
+	defer func() {
+		for i, el := range pcs {
+			el.Stop()
+			c.Assert(transports[i].Stop(), IsNil)
+		}
+	}()
+
+	msgID := conversion.RandStringBytesMask(64)
+	wg := sync.WaitGroup{}
+	for _, el := range pcs {
+		wg.Add(1)
+		go func(coordinator *PartyCoordinator) {
+			defer wg.Done()
+			onlinePeers, _, err := coordinator.JoinPartyWithLeader(msgID, 10, peers, 3, make(chan string))
+			c.Check(err, IsNil)
+			c.Check(onlinePeers, HasLen, 4)
+		}(el)
+	}
+	wg.Wait()
+}
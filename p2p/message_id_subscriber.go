@@ -1,6 +1,12 @@
 package p2p
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
 
 // MessageIDSubscriber
 type MessageIDSubscriber struct {
@@ -47,3 +53,59 @@ func (ms *MessageIDSubscriber) IsEmpty() bool {
 	defer ms.lock.Unlock()
 	return len(ms.subscribers) == 0
 }
+
+// topicSubscribers keep the subscribers of each message type
+type topicSubscribers struct {
+	lock        *sync.Mutex
+	subscribers map[messages.THORChainTSSMessageType]*MessageIDSubscriber
+	logger      zerolog.Logger
+}
+
+func newTopicSubscribers(logger zerolog.Logger) *topicSubscribers {
+	return &topicSubscribers{
+		lock:        &sync.Mutex{},
+		subscribers: make(map[messages.THORChainTSSMessageType]*MessageIDSubscriber),
+		logger:      logger,
+	}
+}
+
+func (ts *topicSubscribers) subscribe(topic messages.THORChainTSSMessageType, msgID string, channel chan *Message) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	messageIDSubscribers, ok := ts.subscribers[topic]
+	if !ok {
+		messageIDSubscribers = NewMessageIDSubscriber()
+		ts.subscribers[topic] = messageIDSubscribers
+	}
+	messageIDSubscribers.Subscribe(msgID, channel)
+}
+
+func (ts *topicSubscribers) get(topic messages.THORChainTSSMessageType, msgID string) chan *Message {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	messageIDSubscribers, ok := ts.subscribers[topic]
+	if !ok {
+		ts.logger.Debug().Msgf("fail to find subscribers for %s", topic)
+		return nil
+	}
+	return messageIDSubscribers.GetSubscriber(msgID)
+}
+
+func (ts *topicSubscribers) unsubscribe(topic messages.THORChainTSSMessageType, msgID string) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	messageIDSubscribers, ok := ts.subscribers[topic]
+	if !ok {
+		ts.logger.Debug().Msgf("cannot find the given channels %s", topic.String())
+		return
+	}
+	if nil == messageIDSubscribers {
+		return
+	}
+	messageIDSubscribers.UnSubscribe(msgID)
+	if messageIDSubscribers.IsEmpty() {
+		delete(ts.subscribers, topic)
+	}
+}
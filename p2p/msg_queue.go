@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"sync"
+)
+
+// maxQueuedMessages is the number of the inbound messages of a msg id waiting for their subscribers, the stream of the
+// peer is not read any further till there is room for its message
+const maxQueuedMessages = 4096
+
+type queuedMessage struct {
+	channel chan *Message
+	msg     *Message
+}
+
+// msgQueue hold the inbound messages of a msg id till they are handed to their subscribers
+type msgQueue struct {
+	msgs     []queuedMessage
+	released bool
+	stopChan chan struct{}
+}
+
+// msgQueues deliver the inbound messages of every msg id to their subscribers one at a time in the order they
+// arrive, so a slow subscriber holds up neither the stream nor the messages of the other msg ids till its queue is
+// full. A protocol message can not be sent again, so the messages are never dropped for a full queue, the peer
+// waits till the subscriber takes the ones queued before. The queue of a msg id is dropped once it is empty, so is
+// the goroutine delivering it
+type msgQueues struct {
+	lock     *sync.Mutex
+	room     *sync.Cond
+	queues   map[string]*msgQueue
+	stopped  bool
+	stopChan chan struct{}
+}
+
+func newMsgQueues(stopChan chan struct{}) *msgQueues {
+	lock := &sync.Mutex{}
+	mq := &msgQueues{
+		lock:     lock,
+		room:     sync.NewCond(lock),
+		queues:   make(map[string]*msgQueue),
+		stopChan: stopChan,
+	}
+	go func() {
+		<-stopChan
+		mq.lock.Lock()
+		defer mq.lock.Unlock()
+		mq.stopped = true
+		mq.room.Broadcast()
+	}()
+	return mq
+}
+
+// push queue the message of the msg id for the given subscriber, it waits while the queue of the msg id is full. It
+// returns false when the msg id is released or the node stops before there is room, the message is dropped then
+func (mq *msgQueues) push(msgID string, channel chan *Message, msg *Message) bool {
+	mq.lock.Lock()
+	defer mq.lock.Unlock()
+	queue, ok := mq.queues[msgID]
+	if !ok {
+		queue = &msgQueue{
+			stopChan: make(chan struct{}),
+		}
+		mq.queues[msgID] = queue
+		go mq.deliver(msgID, queue)
+	}
+	for len(queue.msgs) >= maxQueuedMessages && !queue.released && !mq.stopped {
+		mq.room.Wait()
+	}
+	if queue.released || mq.stopped {
+		return false
+	}
+	queue.msgs = append(queue.msgs, queuedMessage{channel: channel, msg: msg})
+	return true
+}
+
+func (mq *msgQueues) deliver(msgID string, queue *msgQueue) {
+	for {
+		mq.lock.Lock()
+		if len(queue.msgs) == 0 {
+			// the queue is dropped under the lock, so no message is pushed to it afterwards
+			if mq.queues[msgID] == queue {
+				delete(mq.queues, msgID)
+			}
+			mq.lock.Unlock()
+			return
+		}
+		el := queue.msgs[0]
+		queue.msgs[0] = queuedMessage{}
+		queue.msgs = queue.msgs[1:]
+		if len(queue.msgs) == maxQueuedMessages-1 {
+			mq.room.Broadcast()
+		}
+		mq.lock.Unlock()
+		select {
+		case el.channel <- el.msg:
+		case <-queue.stopChan:
+			return
+		case <-mq.stopChan:
+			return
+		}
+	}
+}
+
+// release drop the messages of the msg id that are still queued, the messages waiting for room are dropped as well
+func (mq *msgQueues) release(msgID string) {
+	mq.lock.Lock()
+	defer mq.lock.Unlock()
+	queue, ok := mq.queues[msgID]
+	if !ok {
+		return
+	}
+	queue.released = true
+	close(queue.stopChan)
+	delete(mq.queues, msgID)
+	mq.room.Broadcast()
+}
@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type MsgQueueTestSuite struct{}
+
+var _ = Suite(&MsgQueueTestSuite{})
+
+func (s *MsgQueueTestSuite) TestMsgQueues(c *C) {
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	queues := newMsgQueues(stopChan)
+	p := newTestPeerID(c)
+	slow := make(chan *Message)
+	fast := make(chan *Message, 10)
+
+	// the first message is taken out of the queue while it waits for the slow subscriber
+	c.Assert(queues.push("msg1", slow, &Message{PeerID: p, Payload: []byte("first")}), Equals, true)
+	for queued := 1; queued > 0; {
+		time.Sleep(10 * time.Millisecond)
+		queues.lock.Lock()
+		queued = len(queues.queues["msg1"].msgs)
+		queues.lock.Unlock()
+	}
+	for i := 0; i < maxQueuedMessages; i++ {
+		c.Assert(queues.push("msg1", slow, &Message{PeerID: p, Payload: []byte(strconv.Itoa(i))}), Equals, true)
+	}
+	// the message beyond the full queue waits for room rather than is dropped
+	pushed := make(chan bool)
+	go func() {
+		pushed <- queues.push("msg1", slow, &Message{PeerID: p, Payload: []byte("last")})
+	}()
+	select {
+	case <-pushed:
+		c.Fatal("the message is queued beyond the full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the slow subscriber does not hold up the other msg ids
+	for i := 0; i < 10; i++ {
+		c.Assert(queues.push("msg2", fast, &Message{PeerID: p, Payload: []byte(strconv.Itoa(i))}), Equals, true)
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case msg := <-fast:
+			c.Assert(string(msg.Payload), Equals, strconv.Itoa(i))
+		case <-time.After(time.Second):
+			c.Fatal("the message is not delivered")
+		}
+	}
+
+	// the messages are delivered in the order they arrive, none of them is dropped
+	c.Assert(string((<-slow).Payload), Equals, "first")
+	c.Assert(<-pushed, Equals, true)
+	for i := 0; i < maxQueuedMessages; i++ {
+		c.Assert(string((<-slow).Payload), Equals, strconv.Itoa(i))
+	}
+	c.Assert(string((<-slow).Payload), Equals, "last")
+
+	// the messages still queued are dropped on release, only the one waiting for the subscriber may be delivered,
+	// and the message waiting for room fails
+	c.Assert(queues.push("msg3", slow, &Message{PeerID: p, Payload: []byte("first")}), Equals, true)
+	for queued := 1; queued > 0; {
+		time.Sleep(10 * time.Millisecond)
+		queues.lock.Lock()
+		queued = len(queues.queues["msg3"].msgs)
+		queues.lock.Unlock()
+	}
+	for i := 0; i < maxQueuedMessages; i++ {
+		c.Assert(queues.push("msg3", slow, &Message{PeerID: p, Payload: []byte(strconv.Itoa(i))}), Equals, true)
+	}
+	go func() {
+		pushed <- queues.push("msg3", slow, &Message{PeerID: p, Payload: []byte("dropped")})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	queues.release("msg3")
+	select {
+	case ok := <-pushed:
+		c.Assert(ok, Equals, false)
+	case <-time.After(time.Second):
+		c.Fatal("the message waiting for room is not dropped on release")
+	}
+	select {
+	case <-slow:
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case msg := <-slow:
+		c.Fatalf("message(%s) is delivered after the release", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// the empty queues are dropped
+	time.Sleep(100 * time.Millisecond)
+	queues.lock.Lock()
+	c.Assert(queues.queues, HasLen, 0)
+	queues.lock.Unlock()
+}
+
+func (s *MsgQueueTestSuite) TestMsgQueuesStop(c *C) {
+	stopChan := make(chan struct{})
+	queues := newMsgQueues(stopChan)
+	p := newTestPeerID(c)
+	slow := make(chan *Message)
+	for i := 0; i <= maxQueuedMessages; i++ {
+		c.Assert(queues.push("msg1", slow, &Message{PeerID: p, Payload: []byte(strconv.Itoa(i))}), Equals, true)
+	}
+	pushed := make(chan bool)
+	go func() {
+		pushed <- queues.push("msg1", slow, &Message{PeerID: p, Payload: []byte("dropped")})
+	}()
+	close(stopChan)
+	select {
+	case ok := <-pushed:
+		c.Assert(ok, Equals, false)
+	case <-time.After(time.Second):
+		c.Fatal("the message waiting for room is not dropped when the node stops")
+	}
+}
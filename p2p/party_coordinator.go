@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
@@ -29,7 +28,7 @@ var (
 
 type PartyCoordinator struct {
 	logger             zerolog.Logger
-	host               host.Host
+	host               StreamHost
 	stopChan           chan struct{}
 	timeout            time.Duration
 	peersGroup         map[string]*PeerStatus
@@ -38,7 +37,7 @@ type PartyCoordinator struct {
 }
 
 // NewPartyCoordinator create a new instance of PartyCoordinator
-func NewPartyCoordinator(host host.Host, timeout time.Duration) *PartyCoordinator {
+func NewPartyCoordinator(host StreamHost, timeout time.Duration) *PartyCoordinator {
 	// if no timeout is given, default to 10 seconds
 	if timeout.Nanoseconds() == 0 {
 		timeout = 10 * time.Second
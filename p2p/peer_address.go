@@ -1,17 +1,48 @@
 package p2p
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-peerstore/addr"
 )
 
-func (c *Communication) ExportPeerAddress() map[peer.ID]addr.AddrList {
+// SetAddressBook set the address book the bootstrap dialing is ordered by, it must be called before Start
+func (c *Communication) SetAddressBook(book *AddressBook) {
+	c.addressBook = book
+}
+
+// ExportAddressBook record the connected peers as seen in the address book and return it
+func (c *Communication) ExportAddressBook() *AddressBook {
+	now := time.Now()
 	peerStore := c.host.Peerstore()
-	peers := peerStore.Peers()
-	addressBook := make(map[peer.ID]addr.AddrList)
-	for _, el := range peers {
-		addrs := peerStore.Addrs(el)
-		addressBook[el] = addrs
+	for _, el := range c.host.Network().Peers() {
+		c.addressBook.Seen(el, peerStore.Addrs(el), now)
+	}
+	return c.addressBook
+}
+
+// getBootstrapPeers return the given bootstrap peers and the peers in the address book ordered by their score,
+// the best first
+func (c *Communication) getBootstrapPeers() ([]peer.AddrInfo, error) {
+	infos, err := peer.AddrInfosFromP2pAddrs(c.bootstrapPeers...)
+	if err != nil {
+		return nil, fmt.Errorf("fail to add peer: %w", err)
+	}
+	now := time.Now()
+	for _, el := range c.addressBook.AddrInfos(now) {
+		merged := false
+		for i := range infos {
+			if infos[i].ID == el.ID {
+				infos[i].Addrs = append(infos[i].Addrs, el.Addrs...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			infos = append(infos, el)
+		}
 	}
-	return addressBook
+	c.addressBook.SortByScore(infos, now)
+	return infos, nil
 }
@@ -0,0 +1,213 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/rs/zerolog/log"
+
+	"gitlab.com/thorchain/tss/go-tss/monitor"
+)
+
+// TSSMuxProtocolID is the protocol of the long-lived stream a node sends all its tss messages to a peer over, the
+// frames on it are routed by their msg id. The nodes that only serve TSSProtocolID get a stream per message
+var TSSMuxProtocolID protocol.ID = "/p2p/tss-mux/1.0.0"
+
+const (
+	// msgIDLengthHeader is how many bytes the length of the msg id takes in a frame
+	msgIDLengthHeader = 2
+	// maxStreamAttempts is how many streams a message is tried on before it is given up
+	maxStreamAttempts = 2
+)
+
+// peerStream hold the long-lived stream to one peer, the frames written to it are serialized
+type peerStream struct {
+	lock   *sync.Mutex
+	stream network.Stream
+	// opened count the streams opened to the peer, the streams after the first are reconnections
+	opened int
+}
+
+func newPeerStream() *peerStream {
+	return &peerStream{
+		lock: &sync.Mutex{},
+	}
+}
+
+// writeFrame write a length-prefixed frame carrying the msg id and the message
+func writeFrame(stream network.Stream, msgID string, msg []byte) error {
+	if len(msgID) > math.MaxUint16 {
+		return fmt.Errorf("msg id length:%d exceed max length:%d", len(msgID), math.MaxUint16)
+	}
+	frame := make([]byte, msgIDLengthHeader+len(msgID)+len(msg))
+	binary.LittleEndian.PutUint16(frame, uint16(len(msgID)))
+	copy(frame[msgIDLengthHeader:], msgID)
+	copy(frame[msgIDLengthHeader+len(msgID):], msg)
+	return WriteStreamWithBuffer(frame, stream)
+}
+
+// readFrame read a frame written by writeFrame, the stream idles between the messages, so only the body of a
+// frame is read under the deadline
+func readFrame(stream network.Stream, reader *bufio.Reader, check func(length int) bool) (string, []byte, error) {
+	if ApplyDeadline {
+		if err := stream.SetReadDeadline(time.Time{}); err != nil {
+			return "", nil, err
+		}
+	}
+	lengthBytes := make([]byte, LengthHeader)
+	if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+		return "", nil, err
+	}
+	length := binary.LittleEndian.Uint32(lengthBytes)
+	if length > MaxPayload {
+		return "", nil, fmt.Errorf("payload length:%d exceed max payload length:%d", length, MaxPayload)
+	}
+	if length < msgIDLengthHeader {
+		return "", nil, errors.New("frame is too short to carry the msg id")
+	}
+	if ApplyDeadline {
+		if err := stream.SetReadDeadline(time.Now().Add(TimeoutReadPayload)); err != nil {
+			return "", nil, err
+		}
+	}
+	// the frame of a dropped message is skipped without being held in memory, so the stream stays in use
+	if check != nil && !check(int(length)) {
+		if _, err := io.CopyN(ioutil.Discard, reader, int64(length)); err != nil {
+			return "", nil, fmt.Errorf("short read of the frame: %w", err)
+		}
+		return "", nil, errMessageDropped
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return "", nil, fmt.Errorf("short read of the frame: %w", err)
+	}
+	msgIDLength := int(binary.LittleEndian.Uint16(frame))
+	if msgIDLength > len(frame)-msgIDLengthHeader {
+		return "", nil, fmt.Errorf("msg id length:%d exceed the frame length:%d", msgIDLength, len(frame))
+	}
+	msgID := string(frame[msgIDLengthHeader : msgIDLengthHeader+msgIDLength])
+	return msgID, frame[msgIDLengthHeader+msgIDLength:], nil
+}
+
+func (c *Communication) getPeerStream(pID peer.ID) *peerStream {
+	c.peerStreamsLock.Lock()
+	defer c.peerStreamsLock.Unlock()
+	ps, ok := c.peerStreams[pID]
+	if !ok {
+		ps = newPeerStream()
+		c.peerStreams[pID] = ps
+	}
+	return ps
+}
+
+// writeToPeerStream send the message over the long-lived stream to the peer, the stream is opened when there is
+// none, and opened again when the write to it fails
+func (c *Communication) writeToPeerStream(pID peer.ID, msg []byte, msgID string) error {
+	ps := c.getPeerStream(pID)
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	var err error
+	for attempt := 0; attempt < maxStreamAttempts; attempt++ {
+		if ps.stream != nil {
+			c.metrics.UpdateP2PStream(monitor.StreamReused)
+		} else {
+			stream, errOpen := c.connectToOnePeer(pID)
+			if errOpen != nil {
+				c.metrics.UpdateP2PStream(monitor.StreamFailed)
+				return fmt.Errorf("fail to open stream to peer(%s): %w", pID, errOpen)
+			}
+			if stream.Protocol() != TSSMuxProtocolID {
+				// the peer only serves a stream per message
+				defer c.streamMgr.AddStream(msgID, stream)
+				return WriteStreamWithBuffer(msg, stream)
+			}
+			if ps.opened > 0 {
+				c.metrics.UpdateP2PStream(monitor.StreamReconnected)
+			} else {
+				c.metrics.UpdateP2PStream(monitor.StreamOpened)
+			}
+			ps.opened++
+			ps.stream = stream
+			c.wg.Add(1)
+			go c.watchPeerStream(ps, stream)
+		}
+		err = writeFrame(ps.stream, msgID, msg)
+		if err == nil {
+			return nil
+		}
+		c.logger.Warn().Err(err).Msgf("fail to write to the stream of peer(%s), reconnect", pID)
+		ps.drop(ps.stream)
+	}
+	return err
+}
+
+// drop reset the given stream and forget it if it is still the stream to the peer
+func (ps *peerStream) drop(stream network.Stream) {
+	if err := stream.Reset(); err != nil {
+		log.Debug().Err(err).Msg("fail to reset the stream")
+	}
+	if ps.stream == stream {
+		ps.stream = nil
+	}
+}
+
+// watchPeerStream drop the stream to the peer once the peer closes or resets it, so the next message opens a new
+// one, nothing is read from the stream otherwise
+func (c *Communication) watchPeerStream(ps *peerStream, stream network.Stream) {
+	defer c.wg.Done()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_, err := stream.Read(make([]byte, 1))
+		c.logger.Debug().Err(err).Msgf("the stream to peer(%s) is closed", stream.Conn().RemotePeer())
+	}()
+	select {
+	case <-closed:
+	case <-c.stopChan:
+	}
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	ps.drop(stream)
+}
+
+// handleMuxStream read the frames from the long-lived stream of the peer till the stream is closed
+func (c *Communication) handleMuxStream(stream network.Stream) {
+	remotePeer := stream.Conn().RemotePeer()
+	c.logger.Debug().Msgf("handle the long-lived stream from peer: %s", remotePeer)
+	defer func() {
+		if err := stream.Reset(); err != nil {
+			c.logger.Debug().Err(err).Msg("fail to reset the stream")
+		}
+	}()
+	reader := bufio.NewReader(stream)
+	for {
+		msgID, payload, err := readFrame(stream, reader, func(length int) bool {
+			return c.limiter.allowPeer(remotePeer, length)
+		})
+		if errors.Is(err, errMessageDropped) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.logger.Error().Err(err).Msgf("fail to read from the stream of peer: %s", remotePeer)
+			}
+			return
+		}
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+		c.routeMessage(remotePeer, msgID, payload)
+	}
+}
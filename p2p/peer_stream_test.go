@@ -0,0 +1,136 @@
+package p2p
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	maddr "github.com/multiformats/go-multiaddr"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+type PeerStreamTestSuite struct{}
+
+var _ = Suite(&PeerStreamTestSuite{})
+
+func (s *PeerStreamTestSuite) TestFrame(c *C) {
+	local, remote := newMemoryStreamPair(NewMemoryNetwork(), "local", "remote", TSSMuxProtocolID)
+	c.Assert(writeFrame(local, "msg1", []byte("hello")), IsNil)
+	c.Assert(writeFrame(local, "msg2", []byte("world")), IsNil)
+	c.Assert(writeFrame(local, "", nil), IsNil)
+	// the frames queued on the stream are read one after the other
+	reader := bufio.NewReader(remote)
+	msgID, payload, err := readFrame(remote, reader, nil)
+	c.Assert(err, IsNil)
+	c.Assert(msgID, Equals, "msg1")
+	c.Assert(payload, DeepEquals, []byte("hello"))
+	msgID, payload, err = readFrame(remote, reader, nil)
+	c.Assert(err, IsNil)
+	c.Assert(msgID, Equals, "msg2")
+	c.Assert(payload, DeepEquals, []byte("world"))
+	msgID, payload, err = readFrame(remote, reader, nil)
+	c.Assert(err, IsNil)
+	c.Assert(msgID, Equals, "")
+	c.Assert(payload, HasLen, 0)
+
+	// the frame of a dropped message is skipped, the next frame is read as usual
+	c.Assert(writeFrame(local, "msg3", []byte("dropped")), IsNil)
+	c.Assert(writeFrame(local, "msg4", []byte("kept")), IsNil)
+	var lengths []int
+	check := func(length int) bool {
+		lengths = append(lengths, length)
+		return len(lengths) > 1
+	}
+	_, _, err = readFrame(remote, reader, check)
+	c.Assert(err, Equals, errMessageDropped)
+	msgID, payload, err = readFrame(remote, reader, check)
+	c.Assert(err, IsNil)
+	c.Assert(msgID, Equals, "msg4")
+	c.Assert(payload, DeepEquals, []byte("kept"))
+
+	// a msg id longer than the frame is rejected
+	c.Assert(WriteStreamWithBuffer([]byte{0xff, 0x00, 'a'}, local), IsNil)
+	_, _, err = readFrame(remote, reader, nil)
+	c.Assert(err, NotNil)
+}
+
+func receiveTestMessage(c *C, channel chan *Message, expected string) {
+	select {
+	case msg := <-channel:
+		var wrappedMsg messages.WrappedMessage
+		c.Assert(json.Unmarshal(msg.Payload, &wrappedMsg), IsNil)
+		c.Assert(string(wrappedMsg.Payload), Equals, expected)
+	case <-time.After(time.Second * 5):
+		c.Fatalf("message %s is not received", expected)
+	}
+}
+
+func (s *PeerStreamTestSuite) TestLongLivedStream(c *C) {
+	sk1, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	c.Assert(err, IsNil)
+	sk1raw, err := sk1.Raw()
+	c.Assert(err, IsNil)
+	id1, err := peer.IDFromPrivateKey(sk1)
+	c.Assert(err, IsNil)
+	sk2, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	c.Assert(err, IsNil)
+	sk2raw, err := sk2.Raw()
+	c.Assert(err, IsNil)
+
+	comm1, err := NewCommunication("commTest", nil, 2230, "")
+	c.Assert(err, IsNil)
+	c.Assert(comm1.Start(sk1raw), IsNil)
+	defer comm1.Stop()
+	bootstrapPeer, err := maddr.NewMultiaddr("/ip4/127.0.0.1/tcp/2230/p2p/" + id1.String())
+	c.Assert(err, IsNil)
+	comm2, err := NewCommunication("commTest", []maddr.Multiaddr{bootstrapPeer}, 2231, "")
+	c.Assert(err, IsNil)
+	c.Assert(comm2.Start(sk2raw), IsNil)
+	defer comm2.Stop()
+
+	channel := make(chan *Message, 10)
+	comm1.SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+	comm1.SetSubscribe(messages.TSSKeyGenMsg, "msg2", channel)
+	// the messages of all the msg ids go over the same stream
+	for i, el := range []string{"msg1", "msg2", "msg1"} {
+		payload := string(rune('a' + i))
+		c.Assert(comm2.writeToStream(id1, wrapTestMessage(c, el, payload), el), IsNil)
+		receiveTestMessage(c, channel, payload)
+	}
+	ps := comm2.getPeerStream(id1)
+	c.Assert(ps.opened, Equals, 1)
+
+	// a broken stream is opened again
+	ps.lock.Lock()
+	c.Assert(ps.stream.Reset(), IsNil)
+	ps.lock.Unlock()
+	c.Assert(comm2.writeToStream(id1, wrapTestMessage(c, "msg1", "d"), "msg1"), IsNil)
+	receiveTestMessage(c, channel, "d")
+	c.Assert(ps.opened, Equals, 2)
+
+	// the peer that does not serve the long-lived stream gets a stream per message
+	sk3, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	c.Assert(err, IsNil)
+	sk3raw, err := sk3.Raw()
+	c.Assert(err, IsNil)
+	comm3, err := NewCommunication("commTest", nil, 2232, "")
+	c.Assert(err, IsNil)
+	c.Assert(comm3.Start(sk3raw), IsNil)
+	defer comm3.Stop()
+	comm3.host.RemoveStreamHandler(TSSMuxProtocolID)
+	comm3.SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+	comm2.host.Peerstore().AddAddrs(comm3.host.ID(), comm3.host.Addrs(), peerstore.PermanentAddrTTL)
+	for _, el := range []string{"e", "f"} {
+		c.Assert(comm2.writeToStream(comm3.host.ID(), wrapTestMessage(c, "msg1", el), "msg1"), IsNil)
+		receiveTestMessage(c, channel, el)
+	}
+	ps = comm2.getPeerStream(comm3.host.ID())
+	c.Assert(ps.opened, Equals, 0)
+	c.Assert(ps.stream, IsNil)
+}
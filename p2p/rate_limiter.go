@@ -0,0 +1,234 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/rs/zerolog"
+
+	"gitlab.com/thorchain/tss/go-tss/monitor"
+)
+
+// RateLimit is the token bucket of the inbound messages and bytes, the buckets refill at the given rate per second
+// up to their burst. A rate of zero leaves the messages or the bytes unlimited
+type RateLimit struct {
+	Messages     float64
+	MessageBurst float64
+	Bytes        float64
+	ByteBurst    float64
+}
+
+// RateLimits are the limits of the inbound messages of every peer, and of every peer in every msg id
+type RateLimits struct {
+	Peer  RateLimit
+	MsgID RateLimit
+	// Violations is the number of the messages of a peer dropped in a msg id before the peer is reported
+	Violations int
+}
+
+// DefaultRateLimits leave room for the biggest message and the burst of a batch keysign, they only stop the peers
+// that flood the node
+var DefaultRateLimits = RateLimits{
+	Peer: RateLimit{
+		Messages:     1000,
+		MessageBurst: 10000,
+		Bytes:        MaxPayload,
+		ByteBurst:    5 * MaxPayload,
+	},
+	MsgID: RateLimit{
+		Messages:     500,
+		MessageBurst: 5000,
+		Bytes:        MaxPayload / 2,
+		ByteBurst:    2 * MaxPayload,
+	},
+	Violations: 10,
+}
+
+// tokenBucket hold the tokens left at the last time it is taken from
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill add the tokens earned since the last take, it returns false when the bucket is full, as a full bucket is
+// the same as a new one
+func (b *tokenBucket) refill(rate, burst float64, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += rate * now.Sub(b.last).Seconds()
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	return b.tokens < burst
+}
+
+// rateBuckets are the buckets of the messages and the bytes of a peer, or of a peer in a msg id
+type rateBuckets struct {
+	messages tokenBucket
+	bytes    tokenBucket
+}
+
+// fits check the message fits both buckets
+func (rb *rateBuckets) fits(limit RateLimit, size int, now time.Time) bool {
+	rb.messages.refill(limit.Messages, limit.MessageBurst, now)
+	rb.bytes.refill(limit.Bytes, limit.ByteBurst, now)
+	if limit.Messages > 0 && rb.messages.tokens < 1 {
+		return false
+	}
+	return limit.Bytes <= 0 || rb.bytes.tokens >= float64(size)
+}
+
+func (rb *rateBuckets) take(limit RateLimit, size int) {
+	if limit.Messages > 0 {
+		rb.messages.tokens--
+	}
+	if limit.Bytes > 0 {
+		rb.bytes.tokens -= float64(size)
+	}
+}
+
+// full return true when both buckets are full at the given time
+func (rb *rateBuckets) full(limit RateLimit, now time.Time) bool {
+	messages, bytes := rb.messages, rb.bytes
+	return !messages.refill(limit.Messages, limit.MessageBurst, now) && !bytes.refill(limit.Bytes, limit.ByteBurst, now)
+}
+
+type peerMsgID struct {
+	peer  peer.ID
+	msgID string
+}
+
+// rateLimiter drop the inbound messages over the limits of their peer, or of their peer in their msg id. The peers
+// whose messages are dropped again and again in a msg id are reported to the handler of the msg id
+type rateLimiter struct {
+	lock       *sync.Mutex
+	limits     RateLimits
+	peers      map[peer.ID]*rateBuckets
+	msgIDs     map[peerMsgID]*rateBuckets
+	violations map[peerMsgID]int
+	handlers   map[string]func(peer.ID)
+	logger     zerolog.Logger
+	metrics    *monitor.Metric
+}
+
+func newRateLimiter(limits RateLimits, logger zerolog.Logger) *rateLimiter {
+	return &rateLimiter{
+		lock:       &sync.Mutex{},
+		limits:     limits,
+		peers:      make(map[peer.ID]*rateBuckets),
+		msgIDs:     make(map[peerMsgID]*rateBuckets),
+		violations: make(map[peerMsgID]int),
+		handlers:   make(map[string]func(peer.ID)),
+		logger:     logger,
+		metrics:    monitor.NewMetric(),
+	}
+}
+
+// setHandler report the peers that keep passing the limits of the msg id to the handler
+func (rl *rateLimiter) setHandler(msgID string, handler func(peer.ID)) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.handlers[msgID] = handler
+}
+
+// allow take the message of the given size from the buckets of the peer and of the peer in the msg id, it returns
+// false when the message is over either limit and must be dropped
+func (rl *rateLimiter) allow(p peer.ID, msgID string, size int) bool {
+	return rl.check(p, msgID, size, true, true)
+}
+
+// allowPeer take the message of the given size from the buckets of the peer only, so the message is dropped before
+// it is read when its msg id is not known yet
+func (rl *rateLimiter) allowPeer(p peer.ID, size int) bool {
+	return rl.check(p, "", size, true, false)
+}
+
+// allowMsgID take the message of the given size from the buckets of the peer in the msg id only, the message has
+// passed allowPeer already
+func (rl *rateLimiter) allowMsgID(p peer.ID, msgID string, size int) bool {
+	return rl.check(p, msgID, size, false, true)
+}
+
+func (rl *rateLimiter) check(p peer.ID, msgID string, size int, checkPeer, checkMsgID bool) bool {
+	now := time.Now()
+	key := peerMsgID{peer: p, msgID: msgID}
+	rl.lock.Lock()
+	var peerBuckets, msgIDBuckets *rateBuckets
+	if checkPeer {
+		peerBuckets = rl.peers[p]
+		if peerBuckets == nil {
+			peerBuckets = &rateBuckets{}
+			rl.peers[p] = peerBuckets
+		}
+	}
+	if checkMsgID {
+		msgIDBuckets = rl.msgIDs[key]
+		if msgIDBuckets == nil {
+			msgIDBuckets = &rateBuckets{}
+			rl.msgIDs[key] = msgIDBuckets
+		}
+	}
+	limit := ""
+	if peerBuckets != nil && !peerBuckets.fits(rl.limits.Peer, size, now) {
+		limit = monitor.DroppedPeerLimit
+	} else if msgIDBuckets != nil && !msgIDBuckets.fits(rl.limits.MsgID, size, now) {
+		limit = monitor.DroppedMsgIDLimit
+	}
+	if limit == "" {
+		if peerBuckets != nil {
+			peerBuckets.take(rl.limits.Peer, size)
+		}
+		if msgIDBuckets != nil {
+			msgIDBuckets.take(rl.limits.MsgID, size)
+		}
+		rl.lock.Unlock()
+		return true
+	}
+	// the peer is only reported in the msg id of the messages it sends
+	violations := 0
+	if checkMsgID {
+		rl.violations[key]++
+		violations = rl.violations[key]
+	}
+	handler := rl.handlers[msgID]
+	rl.lock.Unlock()
+
+	rl.metrics.UpdateP2PDropped(limit)
+	rl.logger.Debug().Msgf("drop the message(%s) of peer(%s) over the %s limit", msgID, p, limit)
+	if checkMsgID && violations == rl.limits.Violations {
+		rl.logger.Warn().Msgf("peer(%s) keeps passing the rate limit of the message(%s)", p, msgID)
+		if handler != nil {
+			handler(p)
+		}
+	}
+	return false
+}
+
+// release forget the buckets, the violations and the handler of the msg id, and the buckets of the peers that are
+// full again
+func (rl *rateLimiter) release(msgID string) {
+	now := time.Now()
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	delete(rl.handlers, msgID)
+	// the violations of the msg ids nobody listens to are only counted till the next release
+	for key := range rl.violations {
+		if key.msgID == msgID || rl.handlers[key.msgID] == nil {
+			delete(rl.violations, key)
+		}
+	}
+	for key, el := range rl.msgIDs {
+		if key.msgID == msgID || el.full(rl.limits.MsgID, now) {
+			delete(rl.msgIDs, key)
+		}
+	}
+	for key, el := range rl.peers {
+		if el.full(rl.limits.Peer, now) {
+			delete(rl.peers, key)
+		}
+	}
+}
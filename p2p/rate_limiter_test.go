@@ -0,0 +1,155 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/rs/zerolog/log"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+type RateLimiterTestSuite struct{}
+
+var _ = Suite(&RateLimiterTestSuite{})
+
+func newTestPeerID(c *C) peer.ID {
+	sk, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	c.Assert(err, IsNil)
+	id, err := peer.IDFromPrivateKey(sk)
+	c.Assert(err, IsNil)
+	return id
+}
+
+func (s *RateLimiterTestSuite) TestRateLimiter(c *C) {
+	limits := RateLimits{
+		Peer:       RateLimit{Messages: 1, MessageBurst: 5},
+		MsgID:      RateLimit{Messages: 1, MessageBurst: 3, Bytes: 1, ByteBurst: 100},
+		Violations: 2,
+	}
+	limiter := newRateLimiter(limits, log.Logger)
+	var reported []peer.ID
+	limiter.setHandler("msg1", func(p peer.ID) {
+		reported = append(reported, p)
+	})
+	p1 := newTestPeerID(c)
+	p2 := newTestPeerID(c)
+
+	// the messages of a peer in a msg id are limited apart from the other msg ids and peers
+	for i := 0; i < 3; i++ {
+		c.Assert(limiter.allow(p1, "msg1", 10), Equals, true)
+	}
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, false)
+	c.Assert(limiter.allow(p2, "msg1", 10), Equals, true)
+	c.Assert(limiter.allow(p1, "msg2", 10), Equals, true)
+	c.Assert(reported, HasLen, 0)
+
+	// the peer is reported once it passes the limits as many times as the violations allow
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, false)
+	c.Assert(reported, DeepEquals, []peer.ID{p1})
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, false)
+	c.Assert(reported, HasLen, 1)
+
+	// the messages of the peer are limited over all the msg ids
+	c.Assert(limiter.allow(p1, "msg3", 10), Equals, true)
+	c.Assert(limiter.allow(p1, "msg4", 10), Equals, false)
+
+	// the bytes are limited as well as the messages
+	c.Assert(limiter.allow(p2, "msg2", 101), Equals, false)
+	c.Assert(limiter.allow(p2, "msg2", 100), Equals, true)
+	c.Assert(limiter.allow(p2, "msg2", 1), Equals, false)
+
+	// the released msg id starts over, the buckets still in use are kept
+	limiter.release("msg1")
+	limiter.lock.Lock()
+	c.Assert(limiter.handlers, HasLen, 0)
+	c.Assert(limiter.violations, HasLen, 0)
+	for key := range limiter.msgIDs {
+		c.Assert(key.msgID, Not(Equals), "msg1")
+	}
+	c.Assert(limiter.peers, HasLen, 2)
+	limiter.lock.Unlock()
+
+	// the peer is checked before the msg id of its message is known, it is only reported in the msg id
+	limiter = newRateLimiter(limits, log.Logger)
+	limiter.setHandler("msg1", func(p peer.ID) {
+		reported = append(reported, p)
+	})
+	reported = nil
+	for i := 0; i < 5; i++ {
+		c.Assert(limiter.allowPeer(p1, 10), Equals, true)
+	}
+	for i := 0; i < limits.Violations; i++ {
+		c.Assert(limiter.allowPeer(p1, 10), Equals, false)
+	}
+	c.Assert(reported, HasLen, 0)
+	for i := 0; i < 3; i++ {
+		c.Assert(limiter.allowMsgID(p1, "msg1", 10), Equals, true)
+	}
+	c.Assert(limiter.allowMsgID(p1, "msg1", 10), Equals, false)
+	c.Assert(limiter.allowMsgID(p1, "msg1", 10), Equals, false)
+	c.Assert(reported, DeepEquals, []peer.ID{p1})
+
+	// the buckets refill over time
+	limiter = newRateLimiter(RateLimits{Peer: RateLimit{Messages: 100, MessageBurst: 1}}, log.Logger)
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, true)
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, false)
+	time.Sleep(time.Millisecond * 20)
+	c.Assert(limiter.allow(p1, "msg1", 10), Equals, true)
+	time.Sleep(time.Millisecond * 20)
+	limiter.release("msg1")
+	limiter.lock.Lock()
+	c.Assert(limiter.peers, HasLen, 0)
+	c.Assert(limiter.msgIDs, HasLen, 0)
+	limiter.lock.Unlock()
+}
+
+func (s *RateLimiterTestSuite) TestCommunicationRateLimit(c *C) {
+	var comms []*Communication
+	for i := 0; i < 2; i++ {
+		sk, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		c.Assert(err, IsNil)
+		skRaw, err := sk.Raw()
+		c.Assert(err, IsNil)
+		comm, err := NewCommunication("commTest", nil, 2260+i, "")
+		c.Assert(err, IsNil)
+		comm.SetRateLimits(RateLimits{
+			MsgID:      RateLimit{Messages: 0.1, MessageBurst: 2},
+			Violations: 3,
+		})
+		c.Assert(comm.Start(skRaw), IsNil)
+		defer comm.Stop()
+		comms = append(comms, comm)
+	}
+	sender := comms[0].host.ID()
+	c.Assert(comms[0].host.Connect(context.Background(), peer.AddrInfo{ID: comms[1].host.ID(), Addrs: comms[1].host.Addrs()}), IsNil)
+	channel := make(chan *Message, 10)
+	comms[1].SetSubscribe(messages.TSSKeyGenMsg, "msg1", channel)
+	reported := make(chan peer.ID, 1)
+	comms[1].SetRateLimitHandler("msg1", func(p peer.ID) {
+		reported <- p
+	})
+
+	// the messages over the limit are dropped, and the flooding peer is reported
+	for i := 0; i < 5; i++ {
+		comms[0].Broadcast([]peer.ID{comms[1].host.ID()}, wrapTestMessage(c, "msg1", "a"), "msg1")
+	}
+	select {
+	case p := <-reported:
+		c.Assert(p, Equals, sender)
+	case <-time.After(time.Second * 5):
+		c.Fatal("the flooding peer is not reported")
+	}
+	time.Sleep(time.Millisecond * 100)
+	c.Assert(channel, HasLen, 2)
+
+	// the limits of the released msg id start over
+	comms[1].ReleaseStream("msg1")
+	comms[0].Broadcast([]peer.ID{comms[1].host.ID()}, wrapTestMessage(c, "msg1", "b"), "msg1")
+	time.Sleep(time.Millisecond * 100)
+	c.Assert(channel, HasLen, 3)
+}
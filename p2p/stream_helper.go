@@ -3,6 +3,7 @@ package p2p
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -20,6 +21,9 @@ const (
 	MaxPayload          = 20000000 // 20M
 )
 
+// errMessageDropped is returned when the message is dropped before its payload is read
+var errMessageDropped = errors.New("message is dropped")
+
 // applyDeadline will be true , and only disable it when we are doing test
 // the reason being the p2p network , mocknet, mock stream doesn't support SetReadDeadline ,SetWriteDeadline feature
 var ApplyDeadline = true
@@ -75,6 +79,12 @@ func (sm *StreamMgr) AddStream(msgID string, stream network.Stream) {
 
 // ReadStreamWithBuffer read data from the given stream
 func ReadStreamWithBuffer(stream network.Stream) ([]byte, error) {
+	return readStreamWithCheck(stream, nil)
+}
+
+// readStreamWithCheck read the message from the stream like ReadStreamWithBuffer, the payload is only read when the
+// check passes its length, errMessageDropped is returned otherwise
+func readStreamWithCheck(stream network.Stream, check func(length int) bool) ([]byte, error) {
 	if ApplyDeadline {
 		if err := stream.SetReadDeadline(time.Now().Add(TimeoutReadPayload)); nil != err {
 			if errReset := stream.Reset(); errReset != nil {
@@ -93,6 +103,9 @@ func ReadStreamWithBuffer(stream network.Stream) ([]byte, error) {
 	if length > MaxPayload {
 		return nil, fmt.Errorf("payload length:%d exceed max payload length:%d", length, MaxPayload)
 	}
+	if check != nil && !check(int(length)) {
+		return nil, errMessageDropped
+	}
 	dataBuf := make([]byte, length)
 	n, err = io.ReadFull(streamReader, dataBuf)
 	if uint32(n) != length || err != nil {
@@ -0,0 +1,47 @@
+package p2p
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+// StreamHost is the part of the libp2p host the party coordinator and the signature notifier need, they open and
+// serve their own streams with it
+type StreamHost interface {
+	ID() peer.ID
+	NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error)
+	SetStreamHandler(pid protocol.ID, handler network.StreamHandler)
+	RemoveStreamHandler(pid protocol.ID)
+}
+
+// Transport carry the tss messages between the parties, Communication is the libp2p implementation and
+// MemoryTransport the in-process one
+type Transport interface {
+	// Start join the network with the given secp256k1 private key, which is the identity of the party
+	Start(priKeyBytes []byte) error
+	Stop() error
+	// GetLocalPeerID return the peer id of the local party
+	GetLocalPeerID() string
+	// GetStreamHost return the host the party coordinator and the signature notifier run on
+	GetStreamHost() StreamHost
+	// GetBroadcastChannel return the channel the tss messages to send to the other parties are queued in
+	GetBroadcastChannel() chan *messages.BroadcastMsgChan
+	// Broadcast send the wrapped message to the given peers
+	Broadcast(peers []peer.ID, msg []byte, msgID string)
+	// SetSubscribe deliver the messages of the given type and msg id to the channel
+	SetSubscribe(topic messages.THORChainTSSMessageType, msgID string, channel chan *Message)
+	CancelSubscribe(topic messages.THORChainTSSMessageType, msgID string)
+	// ReleaseStream release what is held to deliver the messages of the given msg id
+	ReleaseStream(msgID string)
+	// SetRateLimitHandler report the peers that keep passing the inbound rate limits of the msg id to the handler
+	SetRateLimitHandler(msgID string, handler func(peer.ID))
+	// ExportAddressBook return the address book of the peers seen
+	ExportAddressBook() *AddressBook
+	// SetAllowedPeers admit only the given peers to talk to the party, an empty list admits every peer
+	SetAllowedPeers(peers []peer.ID)
+}
@@ -9,12 +9,18 @@ import (
 // A new type we need for writing a custom flag parser
 type addrList []maddr.Multiaddr
 
+// pubKeyList is the flag parser of a list of node pub keys
+type pubKeyList []string
+
 // Config is configuration for P2P
 type Config struct {
 	RendezvousString string
 	Port             int
 	BootstrapPeers   addrList
 	ExternalIP       string
+	BroadcastMode    BroadcastMode
+	GatePeers        bool
+	AllowedPubKeys   pubKeyList
 }
 
 // String implement fmt.Stringer
@@ -35,3 +41,14 @@ func (al *addrList) Set(value string) error {
 	*al = append(*al, addr)
 	return nil
 }
+
+// String implement fmt.Stringer
+func (pl *pubKeyList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+// Set add the given value to pubKeyList
+func (pl *pubKeyList) Set(value string) error {
+	*pl = append(*pl, value)
+	return nil
+}
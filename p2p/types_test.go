@@ -13,3 +13,10 @@ func (AddrListTestSuite) TestAddressList(c *C) {
 	c.Assert(al.Set("/ip4/127.0.0.1/tcp/6668/p2p/16Uiu2HAm1PcCAcUZd6N4RZWnbmBHjb14Hm5iE98BY6xi7R4otHCP"), IsNil)
 	c.Assert(al.String(), Equals, "/ip4/127.0.0.1/tcp/6668/p2p/16Uiu2HAm1PcCAcUZd6N4RZWnbmBHjb14Hm5iE98BY6xi7R4otHCP")
 }
+
+func (AddrListTestSuite) TestPubKeyList(c *C) {
+	pl := pubKeyList{}
+	c.Assert(pl.Set("thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"), IsNil)
+	c.Assert(pl.Set("thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09"), IsNil)
+	c.Assert(pl.String(), Equals, "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3,thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09")
+}
@@ -0,0 +1,46 @@
+package resharing
+
+// Request request to move the shares of a pool key from the old committee to the new committee
+type Request struct {
+	PoolPubKey   string   `json:"pool_pub_key"`
+	OldPartyKeys []string `json:"old_party_keys"`
+	NewPartyKeys []string `json:"new_party_keys"`
+	BlockHeight  int64    `json:"block_height"`
+	Version      string   `json:"tss_version"`
+	Threshold    int      `json:"threshold,omitempty"` // number of new parties needed to sign, default to 2/3 of them
+	// OldThreshold is the number of old parties needed to sign, it is the threshold of the pool, default to 2/3 of
+	// the old committee. The nodes of the new committee do not hold the pool state to read it from, so every node has
+	// to give the same, the nodes of the old committee check it against their share
+	OldThreshold int `json:"old_threshold,omitempty"`
+	// KeyHeight is the block height the pool key was last reshared or refreshed at, 0 if it never was. The parties of
+	// the old committee are identified at this height, so every node has to give the same, the nodes of the old
+	// committee check it against their share
+	KeyHeight int64 `json:"key_height,omitempty"`
+}
+
+// NewRequest create a new instance of resharing.Request
+func NewRequest(poolPubKey string, oldPartyKeys, newPartyKeys []string, blockHeight int64, version string) Request {
+	return Request{
+		PoolPubKey:   poolPubKey,
+		OldPartyKeys: oldPartyKeys,
+		NewPartyKeys: newPartyKeys,
+		BlockHeight:  blockHeight,
+		Version:      version,
+	}
+}
+
+// RefreshRequest request to re-randomise the shares of a pool key among its participants
+type RefreshRequest struct {
+	PoolPubKey  string `json:"pool_pub_key"`
+	BlockHeight int64  `json:"block_height"`
+	Version     string `json:"tss_version"`
+}
+
+// NewRefreshRequest create a new instance of resharing.RefreshRequest
+func NewRefreshRequest(poolPubKey string, blockHeight int64, version string) RefreshRequest {
+	return RefreshRequest{
+		PoolPubKey:  poolPubKey,
+		BlockHeight: blockHeight,
+		Version:     version,
+	}
+}
@@ -0,0 +1,582 @@
+package resharing
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
+	btsskeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	maddr "github.com/multiformats/go-multiaddr"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/secp256k1"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+const testPoolPubKey = "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+
+var (
+	testPubKeys = []string{
+		"thorpub1addwnpepq2ryyje5zr09lq7gqptjwnxqsy2vcdngvwd6z7yt5yjcnyj8c8cn559xe69",
+		"thorpub1addwnpepqfjcw5l4ay5t00c32mmlky7qrppepxzdlkcwfs2fd5u73qrwna0vzag3y4j",
+		"thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3",
+		"thorpub1addwnpepqtspqyy6gk22u37ztra4hq3hdakc0w0k60sfy849mlml2vrpfr0wvm6uz09",
+	}
+	testPriKeyArr = []string{
+		"6LABmWB4iXqkqOJ9H0YFEA2CSSx6bA7XAKGyI/TDtas=",
+		"528pkgjuCWfHx1JihEjiIXS7jfTS/viEdAbjqVvSifQ=",
+		"JFB2LIJZtK+KasK00NcNil4PRJS4c4liOnK0nDalhqc=",
+		"vLMGhVXMOXQVnAE3BUU8fwNj/q0ZbndKkwmxfS5EN9Y=",
+	}
+
+	testNodePrivkey = []string{
+		"ZThiMDAxOTk2MDc4ODk3YWE0YThlMjdkMWY0NjA1MTAwZDgyNDkyYzdhNmMwZWQ3MDBhMWIyMjNmNGMzYjVhYg==",
+		"ZTc2ZjI5OTIwOGVlMDk2N2M3Yzc1MjYyODQ0OGUyMjE3NGJiOGRmNGQyZmVmODg0NzQwNmUzYTk1YmQyODlmNA==",
+		"MjQ1MDc2MmM4MjU5YjRhZjhhNmFjMmI0ZDBkNzBkOGE1ZTBmNDQ5NGI4NzM4OTYyM2E3MmI0OWMzNmE1ODZhNw==",
+		"YmNiMzA2ODU1NWNjMzk3NDE1OWMwMTM3MDU0NTNjN2YwMzYzZmVhZDE5NmU3NzRhOTMwOWIxN2QyZTQ0MzdkNg==",
+	}
+)
+
+func TestPackage(t *testing.T) { TestingT(t) }
+
+// MockLocalStateManager loads the shares from the test data and keeps the saved shares in memory
+type MockLocalStateManager struct {
+	file  string
+	lock  *sync.Mutex
+	saved *storage.KeygenLocalState
+}
+
+func (m *MockLocalStateManager) SaveLocalState(state storage.KeygenLocalState) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.saved = &state
+	return nil
+}
+
+func (m *MockLocalStateManager) GetLocalState(pubKey string) (storage.KeygenLocalState, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.saved != nil {
+		return *m.saved, nil
+	}
+	buf, err := ioutil.ReadFile(m.file)
+	if err != nil {
+		return storage.KeygenLocalState{}, err
+	}
+	var state storage.KeygenLocalState
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return storage.KeygenLocalState{}, err
+	}
+	return state, nil
+}
+
+func (m *MockLocalStateManager) SaveAddressBook(addressBook *p2p.AddressBook) error {
+	return nil
+}
+
+func (m *MockLocalStateManager) GetAddressBook() (*p2p.AddressBook, error) {
+	return p2p.NewAddressBook(), nil
+}
+
+func (m *MockLocalStateManager) SavePreSignatures(pubKey string, preSignatures []storage.PreSignature) error {
+	return nil
+}
+
+func (m *MockLocalStateManager) TakePreSignatures(pubKey string, signers []string, ids []string) ([]storage.PreSignature, error) {
+	return nil, nil
+}
+
+func (m *MockLocalStateManager) ListLocalStates() ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockLocalStateManager) SavePreParams(preParams []*btsskeygen.LocalPreParams) error {
+	return nil
+}
+
+func (m *MockLocalStateManager) GetPreParams() ([]*btsskeygen.LocalPreParams, error) {
+	return nil, nil
+}
+
+func (m *MockLocalStateManager) GetLocalStateHistory(pubKey string) ([]storage.LocalStateVersion, error) {
+	return nil, nil
+}
+
+func (m *MockLocalStateManager) PinLocalState(pubKey string, generation int64) error {
+	return nil
+}
+
+func (m *MockLocalStateManager) RetireLocalState(pubKey string) error {
+	return nil
+}
+
+type TssReSharingTestSuite struct {
+	comms        []*p2p.Communication
+	preParams    []*btsskeygen.LocalPreParams
+	partyNum     int
+	stateMgrs    []*MockLocalStateManager
+	nodePrivKeys []tcrypto.PrivKey
+}
+
+var _ = Suite(&TssReSharingTestSuite{})
+
+func (s *TssReSharingTestSuite) SetUpSuite(c *C) {
+	common.InitLog("info", true, "resharing_test")
+	conversion.SetupBech32Prefix()
+	for _, el := range testNodePrivkey {
+		priHexBytes, err := base64.StdEncoding.DecodeString(el)
+		c.Assert(err, IsNil)
+		rawBytes, err := hex.DecodeString(string(priHexBytes))
+		c.Assert(err, IsNil)
+		var priKey secp256k1.PrivKey
+		priKey = rawBytes[:32]
+		s.nodePrivKeys = append(s.nodePrivKeys, priKey)
+	}
+}
+
+func (s *TssReSharingTestSuite) SetUpTest(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	ports := []int{
+		19666, 19667, 19668, 19669,
+	}
+	s.partyNum = 4
+	s.comms = make([]*p2p.Communication, s.partyNum)
+	s.stateMgrs = make([]*MockLocalStateManager, s.partyNum)
+	bootstrapPeer := "/ip4/127.0.0.1/tcp/19666/p2p/16Uiu2HAm4TmEzUqy3q3Dv7HvdoSboHk5sFj2FH3npiN5vDbJC6gh"
+	multiAddr, err := maddr.NewMultiaddr(bootstrapPeer)
+	c.Assert(err, IsNil)
+	s.preParams = getPreparams(c)
+	for i := 0; i < s.partyNum; i++ {
+		buf, err := base64.StdEncoding.DecodeString(testPriKeyArr[i])
+		c.Assert(err, IsNil)
+		if i == 0 {
+			comm, err := p2p.NewCommunication("asgard", nil, ports[i], "")
+			c.Assert(err, IsNil)
+			c.Assert(comm.Start(buf), IsNil)
+			s.comms[i] = comm
+			continue
+		}
+		comm, err := p2p.NewCommunication("asgard", []maddr.Multiaddr{multiAddr}, ports[i], "")
+		c.Assert(err, IsNil)
+		c.Assert(comm.Start(buf), IsNil)
+		s.comms[i] = comm
+	}
+
+	// the nodes do not join a party before resharing in this test, so make sure they know each other
+	for _, comm := range s.comms {
+		for _, other := range s.comms {
+			if comm == other {
+				continue
+			}
+			comm.GetHost().Peerstore().AddAddrs(other.GetHost().ID(), other.GetHost().Addrs(), peerstore.PermanentAddrTTL)
+		}
+	}
+
+	for i := 0; i < s.partyNum; i++ {
+		s.stateMgrs[i] = &MockLocalStateManager{
+			file: fmt.Sprintf("../test_data/keysign_data/%d.json", i),
+			lock: &sync.Mutex{},
+		}
+	}
+}
+
+func (s *TssReSharingTestSuite) TearDownTest(c *C) {
+	if testing.Short() {
+		return
+	}
+	time.Sleep(time.Second)
+	for _, item := range s.comms {
+		c.Assert(item.Stop(), IsNil)
+	}
+}
+
+func getPreparams(c *C) []*btsskeygen.LocalPreParams {
+	const (
+		testFileLocation = "../test_data"
+		preParamTestFile = "preParam_test.data"
+	)
+	var preParamArray []*btsskeygen.LocalPreParams
+	buf, err := ioutil.ReadFile(path.Join(testFileLocation, preParamTestFile))
+	c.Assert(err, IsNil)
+	preParamsStr := strings.Split(string(buf), "\n")
+	for _, item := range preParamsStr {
+		var preParam btsskeygen.LocalPreParams
+		val, err := hex.DecodeString(item)
+		c.Assert(err, IsNil)
+		c.Assert(json.Unmarshal(val, &preParam), IsNil)
+		preParamArray = append(preParamArray, &preParam)
+	}
+	return preParamArray
+}
+
+func (s *TssReSharingTestSuite) TestReShare(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	// node 0 leaves, node 3 joins, node 1 and node 2 are in both committees
+	req := NewRequest(testPoolPubKey, testPubKeys[:3], testPubKeys[1:], 100, "")
+	req.OldThreshold = 3
+	messageID, err := common.MsgToHashString([]byte("resharing" + strings.Join(testPubKeys, "")))
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   120 * time.Second,
+		KeySignTimeout:  120 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	reSharingResult := make(map[int]*bcrypto.ECPoint)
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			stopChan := make(chan struct{})
+			reSharingInstance := NewTssReSharing(
+				comm.GetLocalPeerID(),
+				conf,
+				testPubKeys[idx],
+				comm.BroadcastMsgChan,
+				stopChan,
+				s.preParams[idx],
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			c.Assert(reSharingInstance, NotNil)
+			reSharingMsgChannel := reSharingInstance.GetTssReSharingChannels()
+			comm.SetSubscribe(messages.TSSReSharingMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSReSharingVerMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, reSharingMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSReSharingMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSReSharingVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			resp, err := reSharingInstance.ReShare(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			reSharingResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	for _, el := range reSharingResult {
+		pk, _, err := conversion.GetTssPubKey(el)
+		c.Assert(err, IsNil)
+		c.Assert(pk, Equals, testPoolPubKey)
+	}
+	c.Assert(s.stateMgrs[0].saved, IsNil)
+	for i := 1; i < s.partyNum; i++ {
+		saved := s.stateMgrs[i].saved
+		c.Assert(saved, NotNil)
+		c.Assert(saved.PubKey, Equals, testPoolPubKey)
+		c.Assert(saved.ParticipantKeys, DeepEquals, testPubKeys[1:])
+		c.Assert(saved.Threshold, Equals, 2)
+		c.Assert(conversion.GetPartyKeyHeight(saved.LocalData.Ks[0]), Equals, int64(100))
+	}
+
+	// the new committee can sign with the reshared key
+	msg := []byte("helloworld-test")
+	signMsgID, err := common.MsgToHashString(append([]byte("reshared"), msg...))
+	c.Assert(err, IsNil)
+	signResult := make(map[int]*tsslibcommon.ECSignature)
+	for i := 1; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			keysignIns := keysign.NewTssKeySign(comm.GetLocalPeerID(),
+				conf,
+				comm.BroadcastMsgChan,
+				make(chan struct{}), signMsgID,
+				s.nodePrivKeys[idx], s.comms[idx], s.stateMgrs[idx], 1)
+			keysignMsgChannel := keysignIns.GetTssKeySignChannels()
+			comm.SetSubscribe(messages.TSSKeySignMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSKeySignVerMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, signMsgID, keysignMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSKeySignMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSKeySignVerMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, signMsgID)
+			localState, err := s.stateMgrs[idx].GetLocalState(testPoolPubKey)
+			c.Assert(err, IsNil)
+			sigs, err := keysignIns.SignMessage([][]byte{msg}, localState, testPubKeys[1:], common.HashRaw)
+			c.Assert(err, IsNil)
+			c.Assert(sigs, HasLen, 1)
+			lock.Lock()
+			defer lock.Unlock()
+			signResult[idx] = sigs[0]
+		}(i)
+	}
+	wg.Wait()
+	poolKey := s.stateMgrs[1].saved.LocalData.ECDSAPub
+	pk := ecdsa.PublicKey{
+		Curve: btcec.S256(),
+		X:     poolKey.X(),
+		Y:     poolKey.Y(),
+	}
+	for _, sig := range signResult {
+		r := new(big.Int).SetBytes(sig.R)
+		sv := new(big.Int).SetBytes(sig.S)
+		c.Assert(ecdsa.Verify(&pk, msg, r, sv), Equals, true)
+	}
+}
+
+func (s *TssReSharingTestSuite) TestReShareOldThreshold(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	comm := s.comms[0]
+	reSharingInstance := NewTssReSharing(
+		comm.GetLocalPeerID(),
+		common.TssConfig{KeyGenTimeout: time.Second},
+		testPubKeys[0],
+		comm.BroadcastMsgChan,
+		make(chan struct{}),
+		s.preParams[0],
+		"whatever",
+		s.stateMgrs[0], s.nodePrivKeys[0], s.comms[0])
+	// the pool of the 4 parties needs 3 signers, not the default of the 3 old parties
+	req := NewRequest(testPoolPubKey, testPubKeys[:3], testPubKeys[1:], 100, "")
+	_, err := reSharingInstance.ReShare(req)
+	c.Assert(err, ErrorMatches, "the pool needs 3 signers, not 2")
+	req.OldThreshold = 4
+	_, err = reSharingInstance.ReShare(req)
+	c.Assert(err, ErrorMatches, "invalid threshold 4 of 3 parties")
+}
+
+// reShare run the resharing of the request on the given nodes, and return the pool key each of them gets
+func (s *TssReSharingTestSuite) reShare(c *C, conf common.TssConfig, req Request, nodes []int) map[int]*bcrypto.ECPoint {
+	messageID, err := common.MsgToHashString([]byte("resharing" + strconv.FormatInt(req.BlockHeight, 10)))
+	c.Assert(err, IsNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	reSharingResult := make(map[int]*bcrypto.ECPoint)
+	for _, i := range nodes {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			reSharingInstance := NewTssReSharing(
+				comm.GetLocalPeerID(),
+				conf,
+				testPubKeys[idx],
+				comm.BroadcastMsgChan,
+				make(chan struct{}),
+				s.preParams[idx],
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			reSharingMsgChannel := reSharingInstance.GetTssReSharingChannels()
+			comm.SetSubscribe(messages.TSSReSharingMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSReSharingVerMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, reSharingMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSReSharingMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSReSharingVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			resp, err := reSharingInstance.ReShare(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			reSharingResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	return reSharingResult
+}
+
+func (s *TssReSharingTestSuite) TestReShareTwice(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	conf := common.TssConfig{
+		KeyGenTimeout:   120 * time.Second,
+		KeySignTimeout:  120 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	// node 0 leaves and node 3 joins, then node 3 leaves and node 0 joins back without the share of the pool
+	first := NewRequest(testPoolPubKey, testPubKeys[:3], testPubKeys[1:], 100, "")
+	first.OldThreshold = 3
+	for _, el := range s.reShare(c, conf, first, []int{0, 1, 2, 3}) {
+		pk, _, err := conversion.GetTssPubKey(el)
+		c.Assert(err, IsNil)
+		c.Assert(pk, Equals, testPoolPubKey)
+	}
+	s.stateMgrs[0].saved = &storage.KeygenLocalState{}
+
+	second := NewRequest(testPoolPubKey, testPubKeys[1:], testPubKeys[:3], 200, "")
+	second.KeyHeight = 100
+	for _, el := range s.reShare(c, conf, second, []int{0, 1, 2, 3}) {
+		pk, _, err := conversion.GetTssPubKey(el)
+		c.Assert(err, IsNil)
+		c.Assert(pk, Equals, testPoolPubKey)
+	}
+	for i := 0; i < 3; i++ {
+		saved := s.stateMgrs[i].saved
+		c.Assert(saved.PubKey, Equals, testPoolPubKey)
+		c.Assert(saved.ParticipantKeys, DeepEquals, testPubKeys[:3])
+		c.Assert(conversion.GetPartyKeyHeight(saved.LocalData.Ks[0]), Equals, int64(200))
+	}
+
+	// the new committee can sign with the key reshared twice
+	msg := []byte("helloworld-reshared-twice")
+	poolKey := s.stateMgrs[0].saved.LocalData.ECDSAPub
+	pk := ecdsa.PublicKey{
+		Curve: btcec.S256(),
+		X:     poolKey.X(),
+		Y:     poolKey.Y(),
+	}
+	for _, sig := range s.signMessage(c, conf, msg, []int{0, 1, 2}, testPubKeys[:3]) {
+		r := new(big.Int).SetBytes(sig.R)
+		sv := new(big.Int).SetBytes(sig.S)
+		c.Assert(ecdsa.Verify(&pk, msg, r, sv), Equals, true)
+	}
+}
+
+func (s *TssReSharingTestSuite) signMessage(c *C, conf common.TssConfig, msg []byte, nodes []int, signers []string) []*tsslibcommon.ECSignature {
+	signMsgID, err := common.MsgToHashString(append([]byte(strings.Join(signers, "")), msg...))
+	c.Assert(err, IsNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	var signResult []*tsslibcommon.ECSignature
+	for _, i := range nodes {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			keysignIns := keysign.NewTssKeySign(comm.GetLocalPeerID(),
+				conf,
+				comm.BroadcastMsgChan,
+				make(chan struct{}), signMsgID,
+				s.nodePrivKeys[idx], s.comms[idx], s.stateMgrs[idx], 1)
+			keysignMsgChannel := keysignIns.GetTssKeySignChannels()
+			comm.SetSubscribe(messages.TSSKeySignMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSKeySignVerMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, signMsgID, keysignMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, signMsgID, keysignMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSKeySignMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSKeySignVerMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, signMsgID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, signMsgID)
+			localState, err := s.stateMgrs[idx].GetLocalState(testPoolPubKey)
+			c.Assert(err, IsNil)
+			sigs, err := keysignIns.SignMessage([][]byte{msg}, localState, signers, common.HashRaw)
+			c.Assert(err, IsNil)
+			c.Assert(sigs, HasLen, 1)
+			lock.Lock()
+			defer lock.Unlock()
+			signResult = append(signResult, sigs[0])
+		}(i)
+	}
+	wg.Wait()
+	return signResult
+}
+
+func (s *TssReSharingTestSuite) TestRefresh(c *C) {
+	if testing.Short() {
+		c.Skip("skip the test")
+		return
+	}
+	messageID, err := common.MsgToHashString([]byte("refresh" + strings.Join(testPubKeys, "")))
+	c.Assert(err, IsNil)
+	conf := common.TssConfig{
+		KeyGenTimeout:   120 * time.Second,
+		KeySignTimeout:  120 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	refreshResult := make(map[int]*bcrypto.ECPoint)
+	oldStates := make(map[int]storage.KeygenLocalState)
+	for i := 0; i < s.partyNum; i++ {
+		localState, err := s.stateMgrs[i].GetLocalState(testPoolPubKey)
+		c.Assert(err, IsNil)
+		oldStates[i] = localState
+	}
+	for i := 0; i < s.partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			comm := s.comms[idx]
+			reSharingInstance := NewTssReSharing(
+				comm.GetLocalPeerID(),
+				conf,
+				testPubKeys[idx],
+				comm.BroadcastMsgChan,
+				make(chan struct{}),
+				s.preParams[idx],
+				messageID,
+				s.stateMgrs[idx], s.nodePrivKeys[idx], s.comms[idx])
+			reSharingMsgChannel := reSharingInstance.GetTssReSharingChannels()
+			comm.SetSubscribe(messages.TSSReSharingMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSReSharingVerMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSControlMsg, messageID, reSharingMsgChannel)
+			comm.SetSubscribe(messages.TSSTaskDone, messageID, reSharingMsgChannel)
+			defer comm.CancelSubscribe(messages.TSSReSharingMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSReSharingVerMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSControlMsg, messageID)
+			defer comm.CancelSubscribe(messages.TSSTaskDone, messageID)
+			keys := oldStates[idx].ParticipantKeys
+			req := NewRequest(testPoolPubKey, keys, keys, 100, "")
+			resp, err := reSharingInstance.Refresh(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			refreshResult[idx] = resp
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < s.partyNum; i++ {
+		pk, _, err := conversion.GetTssPubKey(refreshResult[i])
+		c.Assert(err, IsNil)
+		c.Assert(pk, Equals, testPoolPubKey)
+		saved := s.stateMgrs[i].saved
+		c.Assert(saved, NotNil)
+		c.Assert(saved.PubKey, Equals, testPoolPubKey)
+		c.Assert(saved.ParticipantKeys, DeepEquals, oldStates[i].ParticipantKeys)
+		c.Assert(saved.LocalPartyKey, Equals, oldStates[i].LocalPartyKey)
+		c.Assert(saved.LocalData.Xi.Cmp(oldStates[i].LocalData.Xi), Not(Equals), 0)
+		c.Assert(conversion.GetPartyKeyHeight(saved.LocalData.Ks[0]), Equals, int64(100))
+	}
+
+	// the refreshed shares sign with the same pool key
+	msg := []byte("helloworld-refreshed")
+	poolKey := oldStates[0].LocalData.ECDSAPub
+	pk := ecdsa.PublicKey{
+		Curve: btcec.S256(),
+		X:     poolKey.X(),
+		Y:     poolKey.Y(),
+	}
+	for _, sig := range s.signMessage(c, conf, msg, []int{0, 1, 2}, testPubKeys[:3]) {
+		r := new(big.Int).SetBytes(sig.R)
+		sv := new(big.Int).SetBytes(sig.S)
+		c.Assert(ecdsa.Verify(&pk, msg, r, sv), Equals, true)
+	}
+}
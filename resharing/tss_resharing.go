@@ -0,0 +1,414 @@
+package resharing
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
+	bkg "github.com/binance-chain/tss-lib/ecdsa/keygen"
+	bresharing "github.com/binance-chain/tss-lib/ecdsa/resharing"
+	btss "github.com/binance-chain/tss-lib/tss"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+	"go.uber.org/atomic"
+
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+type TssReSharing struct {
+	logger          zerolog.Logger
+	localNodePubKey string
+	preParams       *bkg.LocalPreParams
+	tssCommonStruct *common.TssCommon
+	stopChan        chan struct{} // channel to indicate whether we should stop
+	stateManager    storage.LocalStateManager
+	commStopChan    chan struct{}
+	p2pComm         p2p.Transport
+	refresh         bool
+}
+
+// committee is the parties of one side of the resharing
+type committee struct {
+	partiesID    []*btss.PartyID
+	localPartyID *btss.PartyID
+}
+
+func (c committee) partyIDMap() map[string]*btss.PartyID {
+	return conversion.SetupPartyIDMap(c.partiesID)
+}
+
+func NewTssReSharing(localP2PID string,
+	conf common.TssConfig,
+	localNodePubKey string,
+	broadcastChan chan *messages.BroadcastMsgChan,
+	stopChan chan struct{},
+	preParam *bkg.LocalPreParams,
+	msgID string,
+	stateManager storage.LocalStateManager,
+	privateKey tcrypto.PrivKey,
+	p2pComm p2p.Transport) *TssReSharing {
+	return &TssReSharing{
+		logger: log.With().
+			Str("module", "resharing").
+			Str("msgID", msgID).Logger(),
+		localNodePubKey: localNodePubKey,
+		preParams:       preParam,
+		tssCommonStruct: common.NewTssCommon(localP2PID, broadcastChan, conf, msgID, privateKey, 1),
+		stopChan:        stopChan,
+		stateManager:    stateManager,
+		commStopChan:    make(chan struct{}),
+		p2pComm:         p2pComm,
+	}
+}
+
+func (tReSharing *TssReSharing) GetTssReSharingChannels() chan *p2p.Message {
+	return tReSharing.tssCommonStruct.TssMsg
+}
+
+func (tReSharing *TssReSharing) GetTssCommonStruct() *common.TssCommon {
+	return tReSharing.tssCommonStruct
+}
+
+// Refresh re-randomises the shares of the pool key among its participants, both committees of the resharing are
+// the participants of the pool, so the pool key and the participants stay the same. The refreshed share can not
+// sign together with the current shares, so it only replaces the stored share after all the parties confirm that
+// they are done. It returns the pool public key.
+func (tReSharing *TssReSharing) Refresh(req Request) (*bcrypto.ECPoint, error) {
+	if len(req.OldPartyKeys) != len(req.NewPartyKeys) {
+		return nil, errors.New("the committees of a refresh must be the same")
+	}
+	for _, el := range req.NewPartyKeys {
+		if !containsKey(req.OldPartyKeys, el) {
+			return nil, errors.New("the committees of a refresh must be the same")
+		}
+	}
+	tReSharing.refresh = true
+	return tReSharing.ReShare(req)
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, el := range keys {
+		if el == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ReShare moves the shares of the pool key from the old committee to the new committee, a node can be in both
+// committees, in which case it runs a local party for each of them. It returns the pool public key.
+func (tReSharing *TssReSharing) ReShare(req Request) (*bcrypto.ECPoint, error) {
+	if req.BlockHeight <= 0 {
+		return nil, errors.New("invalid block height")
+	}
+	oldKeys := append([]string{}, req.OldPartyKeys...)
+	newKeys := append([]string{}, req.NewPartyKeys...)
+	isOld := containsKey(oldKeys, tReSharing.localNodePubKey)
+	isNew := containsKey(newKeys, tReSharing.localNodePubKey)
+	if !isOld && !isNew {
+		return nil, errors.New("local party is not in the resharing committees")
+	}
+
+	// the new parties do not hold the pool state, so all the parties take the old threshold from the request
+	oldThreshold, err := conversion.GetThresholdWithSigners(len(oldKeys), req.OldThreshold)
+	if err != nil {
+		return nil, err
+	}
+	newThreshold, err := conversion.GetThresholdWithSigners(len(newKeys), req.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	// the parties of the old committee are identified by the keys the shares are generated with, all the nodes
+	// are given their height, while the new parties use keys created at the block height of this resharing
+	keyHeight := req.KeyHeight
+	if keyHeight < 0 || keyHeight >= req.BlockHeight {
+		return nil, fmt.Errorf("the key height %d is not below the block height %d", keyHeight, req.BlockHeight)
+	}
+	var localState storage.KeygenLocalState
+	if isOld {
+		localState, err = tReSharing.stateManager.GetLocalState(req.PoolPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get local keygen state: %w", err)
+		}
+		if localState.GetAlgo() != common.ECDSA {
+			return nil, fmt.Errorf("resharing is not supported for %s keys", localState.GetAlgo())
+		}
+		for _, el := range oldKeys {
+			if !containsKey(localState.ParticipantKeys, el) {
+				return nil, fmt.Errorf("old party %s does not hold a share of the pool", el)
+			}
+		}
+		poolThreshold, err := localState.GetThreshold()
+		if err != nil {
+			return nil, err
+		}
+		if poolThreshold != oldThreshold {
+			return nil, fmt.Errorf("the pool needs %d signers, not %d", poolThreshold+1, oldThreshold+1)
+		}
+		if sharedHeight := localState.GetInfo().KeyHeight; sharedHeight != keyHeight {
+			return nil, fmt.Errorf("the key was reshared at block height %d, not %d", sharedHeight, keyHeight)
+		}
+	}
+
+	oldPartiesID, oldLocalPartyID, err := conversion.GetPartiesAtHeight(oldKeys, tReSharing.localNodePubKey, keyHeight, 0)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get old parties: %w", err)
+	}
+	newPartiesID, newLocalPartyID, err := conversion.GetPartiesAtHeight(newKeys, tReSharing.localNodePubKey, req.BlockHeight, len(oldKeys))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get new parties: %w", err)
+	}
+	oldCommittee := committee{partiesID: oldPartiesID, localPartyID: oldLocalPartyID}
+	newCommittee := committee{partiesID: newPartiesID, localPartyID: newLocalPartyID}
+
+	oldCtx := btss.NewPeerContext(oldPartiesID)
+	newCtx := btss.NewPeerContext(newPartiesID)
+	outCh := make(chan btss.Message, 2*(len(oldPartiesID)+len(newPartiesID)))
+	// only the end channels of the committees we are in are created, a nil channel is never selected
+	var oldEndCh, newEndCh chan bkg.LocalPartySaveData
+	errChan := make(chan struct{})
+
+	// tss-lib works on a process wide curve, hold it till the local parties are done
+	if err := tReSharing.tssCommonStruct.AcquireCurve(common.ECDSA, 0); err != nil {
+		return nil, err
+	}
+	defer tReSharing.tssCommonStruct.ReleaseCurve()
+	reSharingPartyMap := new(sync.Map)
+	if isOld {
+		oldEndCh = make(chan bkg.LocalPartySaveData, 1)
+		params := btss.NewReSharingParameters(oldCtx, newCtx, oldLocalPartyID, len(oldPartiesID), oldThreshold, len(newPartiesID), newThreshold)
+		reSharingPartyMap.Store(oldLocalPartyID.Id, bresharing.NewLocalParty(params, localState.LocalData, outCh, oldEndCh))
+	}
+	if isNew {
+		if tReSharing.preParams == nil {
+			tReSharing.logger.Error().Msg("error, empty pre-parameters")
+			return nil, errors.New("error, empty pre-parameters")
+		}
+		newEndCh = make(chan bkg.LocalPartySaveData, 1)
+		params := btss.NewReSharingParameters(oldCtx, newCtx, newLocalPartyID, len(oldPartiesID), oldThreshold, len(newPartiesID), newThreshold)
+		save := bkg.NewLocalPartySaveData(len(newPartiesID))
+		save.LocalPreParams = *tReSharing.preParams
+		reSharingPartyMap.Store(newLocalPartyID.Id, bresharing.NewLocalParty(params, save, outCh, newEndCh))
+	}
+
+	blameMgr := tReSharing.tssCommonStruct.GetBlameMgr()
+	partyIDMap := conversion.SetupPartyIDMap(append(append([]*btss.PartyID{}, oldPartiesID...), newPartiesID...))
+	err1 := conversion.SetupIDMaps(partyIDMap, tReSharing.tssCommonStruct.PartyIDtoP2PID)
+	err2 := conversion.SetupIDMaps(partyIDMap, blameMgr.PartyIDtoP2PID)
+	if err1 != nil || err2 != nil {
+		tReSharing.logger.Error().Msgf("error in creating mapping between partyID and P2P ID")
+		return nil, errors.New("fail to create mapping between partyID and P2P ID")
+	}
+	partyInfo := &common.PartyInfo{
+		PartyMap:   reSharingPartyMap,
+		PartyIDMap: partyIDMap,
+		Algo:       common.ECDSA,
+	}
+	tReSharing.tssCommonStruct.SetPartyInfo(partyInfo)
+	blameMgr.SetPartyInfo(reSharingPartyMap, partyIDMap)
+	tReSharing.tssCommonStruct.P2PPeersLock.Lock()
+	tReSharing.tssCommonStruct.P2PPeers = conversion.GetPeersID(tReSharing.tssCommonStruct.PartyIDtoP2PID, tReSharing.tssCommonStruct.GetLocalPeerID())
+	tReSharing.tssCommonStruct.P2PPeersLock.Unlock()
+
+	var reSharingWg sync.WaitGroup
+	reSharingWg.Add(2)
+	// start the local parties
+	go func() {
+		defer reSharingWg.Done()
+		if !tReSharing.startParties(reSharingPartyMap) {
+			close(errChan)
+		}
+	}()
+	go tReSharing.tssCommonStruct.ProcessInboundMessages(tReSharing.commStopChan, &reSharingWg)
+
+	var newLocalState *storage.KeygenLocalState
+	if isNew {
+		newLocalState = &storage.KeygenLocalState{
+			ParticipantKeys: newKeys,
+			LocalPartyKey:   tReSharing.localNodePubKey,
+			Algo:            common.ECDSA,
+			Threshold:       newThreshold + 1,
+			BlockHeight:     req.BlockHeight,
+			MsgID:           tReSharing.tssCommonStruct.GetMsgID(),
+		}
+	}
+	r, err := tReSharing.processReSharing(req, errChan, outCh, oldEndCh, newEndCh, oldCommittee, newCommittee, newLocalState)
+	if err != nil {
+		close(tReSharing.commStopChan)
+		return nil, fmt.Errorf("fail to process resharing: %w", err)
+	}
+	confirmTimeout := time.Second * 5
+	if tReSharing.refresh {
+		confirmTimeout = tReSharing.tssCommonStruct.GetConf().KeyGenTimeout
+	}
+	confirmed := false
+	select {
+	case <-time.After(confirmTimeout):
+		close(tReSharing.commStopChan)
+
+	case <-tReSharing.tssCommonStruct.GetTaskDone():
+		confirmed = true
+		close(tReSharing.commStopChan)
+	}
+	reSharingWg.Wait()
+	if tReSharing.refresh {
+		if !confirmed {
+			return nil, errors.New("not all the parties confirm the refresh, keep the current share")
+		}
+		if err := tReSharing.stateManager.SaveLocalState(*newLocalState); err != nil {
+			return nil, fmt.Errorf("fail to save refresh result to storage: %w", err)
+		}
+	}
+	if r == nil {
+		// we are only in the old committee, the pool key stays the same
+		r = localState.LocalData.ECDSAPub
+	}
+	return r, nil
+}
+
+func (tReSharing *TssReSharing) startParties(partyMap *sync.Map) bool {
+	var wg sync.WaitGroup
+	ret := atomic.NewBool(true)
+	partyMap.Range(func(key, value interface{}) bool {
+		eachParty := value.(btss.Party)
+		wg.Add(1)
+		go func(eachParty btss.Party) {
+			defer wg.Done()
+			if err := eachParty.Start(); err != nil {
+				tReSharing.logger.Error().Err(err).Msg("fail to start resharing party")
+				ret.Store(false)
+			}
+			tReSharing.logger.Info().Msgf("local party(%s) is ready", eachParty.PartyID().Id)
+		}(eachParty)
+		return true
+	})
+	wg.Wait()
+	return ret.Load()
+}
+
+func (tReSharing *TssReSharing) processReSharing(req Request,
+	errChan chan struct{},
+	outCh <-chan btss.Message,
+	oldEndCh, newEndCh <-chan bkg.LocalPartySaveData,
+	oldCommittee, newCommittee committee,
+	newLocalState *storage.KeygenLocalState) (*bcrypto.ECPoint, error) {
+	defer tReSharing.logger.Debug().Msg("finished resharing process")
+	tReSharing.logger.Debug().Msg("start to read messages from local parties")
+	tssConf := tReSharing.tssCommonStruct.GetConf()
+	blameMgr := tReSharing.tssCommonStruct.GetBlameMgr()
+	oldDone := oldEndCh == nil
+	newDone := newEndCh == nil
+	var poolPubKey *bcrypto.ECPoint
+	for {
+		select {
+		case <-errChan: // when the resharing party return
+			tReSharing.logger.Error().Msg("resharing failed")
+			return nil, errors.New("error channel closed fail to start local party")
+
+		case <-tReSharing.stopChan: // when TSS processor receive signal to quit
+			return nil, errors.New("received exit signal")
+
+		case <-time.After(tssConf.KeyGenTimeout):
+			// resharing is as heavy as keygen, so it shares the keygen timeout
+			tReSharing.logger.Error().Msgf("fail to reshare the key with %s", tssConf.KeyGenTimeout.String())
+			lastMsg := blameMgr.GetLastMsg()
+			failReason := blameMgr.GetBlame().FailReason
+			if failReason == "" {
+				failReason = blame.TssTimeout
+			}
+			if lastMsg == nil {
+				tReSharing.logger.Error().Msg("fail to start the resharing, the last produced message of this node is none")
+				return nil, errors.New("timeout before shared message is generated")
+			}
+			tReSharing.tssCommonStruct.P2PPeersLock.RLock()
+			threshold, err := conversion.GetThreshold(len(tReSharing.tssCommonStruct.P2PPeers) + 1)
+			tReSharing.tssCommonStruct.P2PPeersLock.RUnlock()
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("error in get the threshold to generate blame")
+			}
+			blameNodesUnicast, err := blameMgr.GetUnicastBlame(messages.RESHARING3aUnicast)
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("error in get unicast blame")
+			}
+			if len(blameNodesUnicast) > 0 && len(blameNodesUnicast) <= threshold {
+				blameMgr.GetBlame().SetBlame(failReason, blameNodesUnicast, true)
+			}
+			// the last message is sent by the committee our party is in, so are the messages we are waiting for
+			senders := newCommittee.partyIDMap()
+			if _, ok := oldCommittee.partyIDMap()[lastMsg.GetFrom().Id]; ok {
+				senders = oldCommittee.partyIDMap()
+			}
+			for _, el := range []*btss.PartyID{oldCommittee.localPartyID, newCommittee.localPartyID} {
+				if el != nil {
+					delete(senders, el.Id)
+				}
+			}
+			blameNodesBroadcast, err := blameMgr.GetCommitteeBroadcastBlame(lastMsg.Type(), senders)
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("error in get broadcast blame")
+			}
+			blameMgr.GetBlame().AddBlameNodes(blameNodesBroadcast...)
+
+			// the nodes flooding us are blamed, their messages may have been dropped
+			blameNodesRateLimit, err := blameMgr.GetRateLimitBlame()
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("error in get rate limit blame")
+			}
+			if len(blameNodesRateLimit) > 0 && len(blameNodesRateLimit) <= threshold {
+				blameMgr.GetBlame().AddBlameNodes(blameNodesRateLimit...)
+			}
+			return nil, blame.ErrTssTimeOut
+
+		case msg := <-outCh:
+			tReSharing.logger.Debug().Msgf(">>>>>>>>>>msg: %s", msg.String())
+			blameMgr.SetLastMsg(msg)
+			err := tReSharing.tssCommonStruct.ProcessOutCh(msg, messages.TSSReSharingMsg)
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("fail to process the message")
+				return nil, err
+			}
+
+		case <-oldEndCh:
+			tReSharing.logger.Debug().Msg("the old party finished resharing")
+			oldDone = true
+
+		case msg := <-newEndCh:
+			tReSharing.logger.Debug().Msgf("resharing finished successfully: %s", msg.ECDSAPub.Y().String())
+			pubKey, _, err := conversion.GetTssPubKey(msg.ECDSAPub)
+			if err != nil {
+				return nil, fmt.Errorf("fail to get thorchain pubkey: %w", err)
+			}
+			if pubKey != req.PoolPubKey {
+				return nil, fmt.Errorf("the reshared pool key %s does not match %s", pubKey, req.PoolPubKey)
+			}
+			newLocalState.LocalData = msg
+			newLocalState.PubKey = pubKey
+			// the refreshed share is saved once all the parties confirm they are done
+			if !tReSharing.refresh {
+				if err := tReSharing.stateManager.SaveLocalState(*newLocalState); err != nil {
+					return nil, fmt.Errorf("fail to save resharing result to storage: %w", err)
+				}
+			}
+			poolPubKey = msg.ECDSAPub
+			newDone = true
+		}
+		if oldDone && newDone {
+			err := tReSharing.tssCommonStruct.NotifyTaskDone()
+			if err != nil {
+				tReSharing.logger.Error().Err(err).Msg("fail to broadcast the resharing done")
+			}
+			if err := tReSharing.stateManager.SaveAddressBook(tReSharing.p2pComm.ExportAddressBook()); err != nil {
+				tReSharing.logger.Error().Err(err).Msg("fail to save the peer addresses")
+			}
+			return poolPubKey, nil
+		}
+	}
+}
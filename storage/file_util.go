@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// envelopeMagic marks the local state files that carry the checksum of their content
+var envelopeMagic = []byte("chk1")
+
+// ErrCorruptedFile is returned when the checksum of a file does not match its content
+var ErrCorruptedFile = errors.New("file is corrupted")
+
+// sealEnvelope put the content in an envelope with its sha256 checksum
+func sealEnvelope(content []byte) []byte {
+	sum := sha256.Sum256(content)
+	buf := make([]byte, 0, len(envelopeMagic)+len(sum)+len(content))
+	buf = append(buf, envelopeMagic...)
+	buf = append(buf, sum[:]...)
+	return append(buf, content...)
+}
+
+// openEnvelope return the content of the envelope once its checksum is verified, the files written before the
+// envelope is used are returned as they are
+func openEnvelope(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return data, nil
+	}
+	headerLen := len(envelopeMagic) + sha256.Size
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("%w: the envelope is truncated", ErrCorruptedFile)
+	}
+	content := data[headerLen:]
+	sum := sha256.Sum256(content)
+	if !bytes.Equal(sum[:], data[len(envelopeMagic):headerLen]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptedFile)
+	}
+	return content, nil
+}
+
+// writeFileAtomic write the data to a temp file in the same folder, sync it to disk and rename it over the file,
+// so the file has either the old or the new content after a crash, the file is only accessible by the owner
+func writeFileAtomic(filePathName string, data []byte) (err error) {
+	folder := filepath.Dir(filePathName)
+	f, err := ioutil.TempFile(folder, filepath.Base(filePathName)+".tmp")
+	if err != nil {
+		return fmt.Errorf("fail to create the temp file: %w", err)
+	}
+	tempFilePathName := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tempFilePathName)
+		}
+	}()
+	if err := f.Chmod(0o600); err != nil {
+		return fmt.Errorf("fail to set the permission of the temp file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("fail to write the temp file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("fail to sync the temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("fail to close the temp file: %w", err)
+	}
+	if err := os.Rename(tempFilePathName, filePathName); err != nil {
+		return fmt.Errorf("fail to replace file(%s): %w", filePathName, err)
+	}
+	// the rename is only durable once the folder is synced
+	dir, err := os.Open(folder)
+	if err != nil {
+		return fmt.Errorf("fail to open the folder: %w", err)
+	}
+	defer func() {
+		_ = dir.Close()
+	}()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("fail to sync the folder: %w", err)
+	}
+	return nil
+}
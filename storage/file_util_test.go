@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type FileUtilTestSuite struct{}
+
+var _ = Suite(&FileUtilTestSuite{})
+
+func (s *FileUtilTestSuite) TestEnvelope(c *C) {
+	content := []byte("whatever")
+	sealed := sealEnvelope(content)
+	opened, err := openEnvelope(sealed)
+	c.Assert(err, IsNil)
+	c.Assert(opened, DeepEquals, content)
+
+	// the content written before the envelope is used
+	opened, err = openEnvelope(content)
+	c.Assert(err, IsNil)
+	c.Assert(opened, DeepEquals, content)
+
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = openEnvelope(sealed)
+	c.Assert(err, NotNil)
+	_, err = openEnvelope(sealed[:10])
+	c.Assert(err, NotNil)
+}
+
+func (s *FileUtilTestSuite) TestWriteFileAtomic(c *C) {
+	f, err := ioutil.TempDir("", "atomic")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	filePathName := filepath.Join(f, "test.json")
+	c.Assert(writeFileAtomic(filePathName, []byte("hello")), IsNil)
+	c.Assert(writeFileAtomic(filePathName, []byte("world")), IsNil)
+	buf, err := ioutil.ReadFile(filePathName)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "world")
+	info, err := os.Stat(filePathName)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0o600))
+	// no temp file is left behind
+	files, err := ioutil.ReadDir(f)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+
+	c.Assert(writeFileAtomic(filepath.Join(f, "nonexist", "test.json"), []byte("hello")), NotNil)
+}
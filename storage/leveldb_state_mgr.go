@@ -0,0 +1,669 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+)
+
+const (
+	// BackendFile keeps every record in its own file, this is the default backend
+	BackendFile = "file"
+	// BackendLevelDB keeps all the records in an embedded LevelDB database
+	BackendLevelDB = "leveldb"
+
+	levelDBFolder    = "localstate.db"
+	localStatePrefix = "localstate/"
+	preSignPrefix    = "presign/"
+	retiredPrefix    = "retired/"
+	historyPrefix    = "history/"
+	addressBookDBKey = "address_book"
+	preParamsDBKey   = "preparams"
+)
+
+// NewLocalStateManager create the LocalStateManager of the given backend in the folder, backups is the number of
+// the older generations kept in the history of the local states, 0 uses the default
+func NewLocalStateManager(backend, folder string, sk []byte, backups int) (LocalStateManager, error) {
+	switch backend {
+	case "", BackendFile:
+		fsm, err := NewFileStateMgr(folder, sk)
+		if err != nil {
+			return nil, err
+		}
+		if backups != 0 {
+			fsm.SetBackups(backups)
+		}
+		return fsm, nil
+	case BackendLevelDB:
+		ldm, err := NewLevelDBStateMgr(folder, sk)
+		if err != nil {
+			return nil, err
+		}
+		if backups != 0 {
+			ldm.SetBackups(backups)
+		}
+		return ldm, nil
+	default:
+		return nil, fmt.Errorf("unknown state backend(%s)", backend)
+	}
+}
+
+// LevelDBStateMgr save the local state to an embedded LevelDB database, every write is synced to disk, and the
+// records written together are updated atomically
+type LevelDBStateMgr struct {
+	db        *leveldb.DB
+	sk        []byte
+	writeLock *sync.Mutex
+	backups   int
+}
+
+// NewLevelDBStateMgr create a new instance of the LevelDBStateMgr which implements LocalStateManager, the
+// database is kept in the localstate.db folder of the given folder
+func NewLevelDBStateMgr(folder string, sk []byte) (*LevelDBStateMgr, error) {
+	if len(folder) < 1 {
+		return nil, errors.New("base file path is invalid")
+	}
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := leveldb.OpenFile(filepath.Join(folder, levelDBFolder), nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open the local state database: %w", err)
+	}
+	return &LevelDBStateMgr{
+		db:        db,
+		sk:        sk,
+		writeLock: &sync.Mutex{},
+		backups:   DefaultLocalStateBackups,
+	}, nil
+}
+
+// SetBackups set the number of the older generations kept in the history of a pool besides the pinned one, 0 keeps
+// only the pinned one
+func (ldm *LevelDBStateMgr) SetBackups(num int) {
+	if num < 0 {
+		num = 0
+	}
+	ldm.backups = num
+}
+
+// Close close the database
+func (ldm *LevelDBStateMgr) Close() error {
+	return ldm.db.Close()
+}
+
+func getPoolDBKey(prefix, pubKey string) ([]byte, error) {
+	ret, err := conversion.CheckKeyOnCurve(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if !ret {
+		return nil, errors.New("invalid pubkey for the database key")
+	}
+	return []byte(prefix + pubKey), nil
+}
+
+func (ldm *LevelDBStateMgr) encodeLocalState(state KeygenLocalState) ([]byte, []byte, error) {
+	key, err := getPoolDBKey(localStatePrefix, state.PubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to marshal KeygenLocalState to json: %w", err)
+	}
+	encryptedData, err := common.AESEncrypt(buf, ldm.sk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, encryptedData, nil
+}
+
+func (ldm *LevelDBStateMgr) write(batch *leveldb.Batch) error {
+	if err := ldm.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("fail to write to the local state database: %w", err)
+	}
+	return nil
+}
+
+// SaveLocalState save the local state to the database as a new generation of the local state of the pool, and
+// pin it
+func (ldm *LevelDBStateMgr) SaveLocalState(state KeygenLocalState) error {
+	return ldm.SaveLocalStates(state)
+}
+
+// SaveLocalStates save all the given local states in one atomic update, either all of them or none are saved. Only
+// the newest generations of the pools are kept in the history, as many as the backups besides the pinned one
+func (ldm *LevelDBStateMgr) SaveLocalStates(states ...KeygenLocalState) error {
+	ldm.writeLock.Lock()
+	defer ldm.writeLock.Unlock()
+	batch := new(leveldb.Batch)
+	// the generations of the pools in the database and in the batch, the oldest first
+	poolGenerations := make(map[string][]int64)
+	for _, el := range states {
+		key, value, err := ldm.encodeLocalState(el)
+		if err != nil {
+			return err
+		}
+		generations, ok := poolGenerations[el.PubKey]
+		if !ok {
+			generations, err = ldm.startGenerations(key, el.PubKey, batch)
+			if err != nil {
+				return err
+			}
+		}
+		generation := int64(1)
+		if len(generations) > 0 {
+			generation = generations[len(generations)-1] + 1
+		}
+		historyKey, err := getGenerationDBKey(el.PubKey, generation)
+		if err != nil {
+			return err
+		}
+		batch.Put(historyKey, value)
+		batch.Put(key, value)
+		poolGenerations[el.PubKey] = append(generations, generation)
+	}
+	// the generation saved last is pinned, it is the newest
+	for pubKey, generations := range poolGenerations {
+		for i := 0; i < len(generations)-ldm.backups-1; i++ {
+			historyKey, err := getGenerationDBKey(pubKey, generations[i])
+			if err != nil {
+				return err
+			}
+			batch.Delete(historyKey)
+		}
+	}
+	return ldm.write(batch)
+}
+
+func getHistoryDBPrefix(pubKey string) ([]byte, error) {
+	prefix, err := getPoolDBKey(historyPrefix, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, '/'), nil
+}
+
+// getGenerationDBKey return the key of the generation of the local state, the generation is padded so that the
+// keys are sorted by the generation
+func getGenerationDBKey(pubKey string, generation int64) ([]byte, error) {
+	prefix, err := getHistoryDBPrefix(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, []byte(fmt.Sprintf("%020d", generation))...), nil
+}
+
+// listGenerations return the generations of the local state of the pool in the history, the oldest first
+func (ldm *LevelDBStateMgr) listGenerations(pubKey string) ([]int64, error) {
+	prefix, err := getHistoryDBPrefix(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	iter := ldm.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	var generations []int64
+	for iter.Next() {
+		generation, err := strconv.ParseInt(string(iter.Key()[len(prefix):]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation key of the local state of %s: %w", pubKey, err)
+		}
+		generations = append(generations, generation)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("fail to read the local state history: %w", err)
+	}
+	return generations, nil
+}
+
+// startGenerations return the generations of the local state of the pool in the history, the share saved before the
+// history is kept is added to the batch as the first generation
+func (ldm *LevelDBStateMgr) startGenerations(key []byte, pubKey string, batch *leveldb.Batch) ([]int64, error) {
+	generations, err := ldm.listGenerations(pubKey)
+	if err != nil || len(generations) > 0 {
+		return generations, err
+	}
+	currentData, err := ldm.db.Get(key, nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read the local state of %s: %w", pubKey, err)
+	}
+	historyKey, err := getGenerationDBKey(pubKey, 1)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put(historyKey, currentData)
+	return []int64{1}, nil
+}
+
+// GetLocalState read the local state from the database
+func (ldm *LevelDBStateMgr) GetLocalState(pubKey string) (KeygenLocalState, error) {
+	if len(pubKey) == 0 {
+		return KeygenLocalState{}, errors.New("pub key is empty")
+	}
+	key, err := getPoolDBKey(localStatePrefix, pubKey)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	loadedData, err := ldm.db.Get(key, nil)
+	if err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to read the local state of %s: %w", pubKey, err)
+	}
+	plainText, err := ldm.decrypt(loadedData)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	return unmarshalLocalState(plainText)
+}
+
+func (ldm *LevelDBStateMgr) SaveAddressBook(addressBook *p2p.AddressBook) error {
+	buf, err := json.Marshal(addressBook)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the address book: %w", err)
+	}
+	batch := new(leveldb.Batch)
+	batch.Put([]byte(addressBookDBKey), buf)
+	return ldm.write(batch)
+}
+
+func (ldm *LevelDBStateMgr) GetAddressBook() (*p2p.AddressBook, error) {
+	input, err := ldm.db.Get([]byte(addressBookDBKey), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return p2p.NewAddressBook(), nil
+		}
+		return nil, fmt.Errorf("fail to read the address book: %w", err)
+	}
+	// the address book saved before the peers are scored is one multiaddr per line
+	if !bytes.HasPrefix(input, []byte("{")) {
+		return decodeLegacyAddressBook(input, time.Now())
+	}
+	addressBook := p2p.NewAddressBook()
+	if err := json.Unmarshal(input, addressBook); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the address book: %w", err)
+	}
+	return addressBook, nil
+}
+
+func (ldm *LevelDBStateMgr) readPreSignatures(key []byte) ([]PreSignature, error) {
+	loadedData, err := ldm.db.Get(key, nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read the presignatures: %w", err)
+	}
+	plainText, err := common.AESDecrypt(loadedData, ldm.sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt the presignatures: %w", err)
+	}
+	var preSignatures []PreSignature
+	if err := json.Unmarshal(plainText, &preSignatures); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the presignatures: %w", err)
+	}
+	return preSignatures, nil
+}
+
+func (ldm *LevelDBStateMgr) encodePreSignatures(preSignatures []PreSignature) ([]byte, error) {
+	buf, err := json.Marshal(preSignatures)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal the presignatures to json: %w", err)
+	}
+	return common.AESEncrypt(buf, ldm.sk)
+}
+
+func (ldm *LevelDBStateMgr) writePreSignatures(key []byte, preSignatures []PreSignature) error {
+	batch := new(leveldb.Batch)
+	if len(preSignatures) == 0 {
+		batch.Delete(key)
+		return ldm.write(batch)
+	}
+	encryptedData, err := ldm.encodePreSignatures(preSignatures)
+	if err != nil {
+		return err
+	}
+	batch.Put(key, encryptedData)
+	return ldm.write(batch)
+}
+
+// SavePreSignatures add the presignatures to the encrypted presignatures of the pool
+func (ldm *LevelDBStateMgr) SavePreSignatures(pubKey string, preSignatures []PreSignature) error {
+	key, err := getPoolDBKey(preSignPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	ldm.writeLock.Lock()
+	defer ldm.writeLock.Unlock()
+	saved, err := ldm.readPreSignatures(key)
+	if err != nil {
+		return err
+	}
+	return ldm.writePreSignatures(key, append(saved, preSignatures...))
+}
+
+// TakePreSignatures remove the presignatures of the given ids and signers from the presignatures of the pool and
+// return them, the presignatures are removed before they are used as they can only be used once
+func (ldm *LevelDBStateMgr) TakePreSignatures(pubKey string, signers []string, ids []string) ([]PreSignature, error) {
+	key, err := getPoolDBKey(preSignPrefix, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	ldm.writeLock.Lock()
+	defer ldm.writeLock.Unlock()
+	saved, err := ldm.readPreSignatures(key)
+	if err != nil {
+		return nil, err
+	}
+	taken, left := PickPreSignatures(saved, signers, ids)
+	if len(taken) == 0 {
+		return nil, nil
+	}
+	if err := ldm.writePreSignatures(key, left); err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
+
+// ListLocalStates return the pub keys of the pools that have a local state in the database
+func (ldm *LevelDBStateMgr) ListLocalStates() ([]string, error) {
+	iter := ldm.db.NewIterator(util.BytesPrefix([]byte(localStatePrefix)), nil)
+	defer iter.Release()
+	var pubKeys []string
+	for iter.Next() {
+		pubKeys = append(pubKeys, strings.TrimPrefix(string(iter.Key()), localStatePrefix))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("fail to list the local states: %w", err)
+	}
+	return pubKeys, nil
+}
+
+// RetireLocalState remove the local state and the presignatures of the pool in one atomic update, the encrypted
+// local state is kept under the retired/<unix time>/<pubkey> key, and its history under the
+// retired/<unix time>/history/<pubkey>/<generation> keys
+func (ldm *LevelDBStateMgr) RetireLocalState(pubKey string) error {
+	key, err := getPoolDBKey(localStatePrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	preSignKey, err := getPoolDBKey(preSignPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	historyPrefixKey, err := getHistoryDBPrefix(pubKey)
+	if err != nil {
+		return err
+	}
+	ldm.writeLock.Lock()
+	defer ldm.writeLock.Unlock()
+	loadedData, err := ldm.db.Get(key, nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrLocalStateNotFound, pubKey)
+		}
+		return fmt.Errorf("fail to read the local state of %s: %w", pubKey, err)
+	}
+	retiredPrefixAt := fmt.Sprintf("%s%d/", retiredPrefix, time.Now().Unix())
+	batch := new(leveldb.Batch)
+	batch.Put([]byte(retiredPrefixAt+pubKey), loadedData)
+	batch.Delete(key)
+	batch.Delete(preSignKey)
+	// the history goes with the tombstone, so the generations of the retired pool are no longer found
+	iter := ldm.db.NewIterator(util.BytesPrefix(historyPrefixKey), nil)
+	for iter.Next() {
+		batch.Put(append([]byte(retiredPrefixAt), iter.Key()...), append([]byte{}, iter.Value()...))
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("fail to read the local state history: %w", err)
+	}
+	return ldm.write(batch)
+}
+
+// GetLocalStateHistory return the generations of the local state of the pool kept in the database
+func (ldm *LevelDBStateMgr) GetLocalStateHistory(pubKey string) ([]LocalStateVersion, error) {
+	key, err := getPoolDBKey(localStatePrefix, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := getHistoryDBPrefix(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	// the snapshot keeps the local state and its history consistent with each other
+	snapshot, err := ldm.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("fail to get the database snapshot: %w", err)
+	}
+	defer snapshot.Release()
+	currentData, err := snapshot.Get(key, nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("fail to read the local state of %s: %w", pubKey, err)
+	}
+	var currentPlainText []byte
+	if err == nil {
+		if currentPlainText, err = ldm.decrypt(currentData); err != nil {
+			return nil, err
+		}
+	}
+	iter := snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	var generations []int64
+	var plainTexts [][]byte
+	for iter.Next() {
+		generation, err := strconv.ParseInt(string(iter.Key()[len(prefix):]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation key of the local state of %s: %w", pubKey, err)
+		}
+		plainText, err := ldm.decrypt(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, generation)
+		plainTexts = append(plainTexts, plainText)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("fail to read the local state history: %w", err)
+	}
+	return buildLocalStateHistory(pubKey, currentPlainText, generations, plainTexts)
+}
+
+func (ldm *LevelDBStateMgr) decrypt(loadedData []byte) ([]byte, error) {
+	plainText, err := common.AESDecrypt(loadedData, ldm.sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt the keygen data with %v", err)
+	}
+	return plainText, nil
+}
+
+// PinLocalState copy the given generation over the local state of the pool and remove the presignatures of the
+// pool in one atomic update
+func (ldm *LevelDBStateMgr) PinLocalState(pubKey string, generation int64) error {
+	key, err := getPoolDBKey(localStatePrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	preSignKey, err := getPoolDBKey(preSignPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	historyKey, err := getGenerationDBKey(pubKey, generation)
+	if err != nil {
+		return err
+	}
+	ldm.writeLock.Lock()
+	defer ldm.writeLock.Unlock()
+	loadedData, err := ldm.db.Get(historyKey, nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return fmt.Errorf("%w: generation %d of %s", ErrLocalStateNotFound, generation, pubKey)
+		}
+		return fmt.Errorf("fail to read the generation %d of %s: %w", generation, pubKey, err)
+	}
+	batch := new(leveldb.Batch)
+	batch.Put(key, loadedData)
+	batch.Delete(preSignKey)
+	return ldm.write(batch)
+}
+
+// migrateFileStateHistory add the local state of the pool and its history to the batch, the pinned generation
+// stays pinned
+func migrateFileStateHistory(fsm *FileStateMgr, ldm *LevelDBStateMgr, pubKey string, batch *leveldb.Batch) error {
+	state, err := fsm.GetLocalState(pubKey)
+	if err != nil {
+		return fmt.Errorf("fail to read the local state of %s: %w", pubKey, err)
+	}
+	key, value, err := ldm.encodeLocalState(state)
+	if err != nil {
+		return err
+	}
+	versions, err := fsm.GetLocalStateHistory(pubKey)
+	if err != nil {
+		return err
+	}
+	for _, el := range versions {
+		if el.Generation == 0 {
+			continue
+		}
+		filePathName, err := fsm.getGenerationFilePathName(pubKey, el.Generation)
+		if err != nil {
+			return err
+		}
+		loadedData, err := ioutil.ReadFile(filePathName)
+		if err != nil {
+			return fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+		}
+		plainText, err := fsm.openLocalStateFile(loadedData)
+		if err != nil {
+			return err
+		}
+		encryptedData, err := common.AESEncrypt(plainText, ldm.sk)
+		if err != nil {
+			return err
+		}
+		historyKey, err := getGenerationDBKey(pubKey, el.Generation)
+		if err != nil {
+			return err
+		}
+		batch.Put(historyKey, encryptedData)
+		if el.Pinned {
+			value = encryptedData
+		}
+	}
+	batch.Put(key, value)
+	return nil
+}
+
+// SavePreParams replace the encrypted pre-parameters in the database
+func (ldm *LevelDBStateMgr) SavePreParams(preParams []*keygen.LocalPreParams) error {
+	batch := new(leveldb.Batch)
+	if len(preParams) == 0 {
+		batch.Delete([]byte(preParamsDBKey))
+		return ldm.write(batch)
+	}
+	encryptedData, err := encodePreParams(preParams, ldm.sk)
+	if err != nil {
+		return err
+	}
+	batch.Put([]byte(preParamsDBKey), encryptedData)
+	return ldm.write(batch)
+}
+
+// GetPreParams read the pre-parameters from the database
+func (ldm *LevelDBStateMgr) GetPreParams() ([]*keygen.LocalPreParams, error) {
+	loadedData, err := ldm.db.Get([]byte(preParamsDBKey), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read the pre-parameters: %w", err)
+	}
+	return decodePreParams(loadedData, ldm.sk)
+}
+
+// MigrateFileState import the local states with their history, the presignatures, the pre-parameters and the
+// address book the FileStateMgr saved in the folder into the database in one atomic update, the files are left as
+// they are. It returns the number of the local states imported
+func MigrateFileState(folder string, sk []byte, ldm *LevelDBStateMgr) (int, error) {
+	fsm, err := NewFileStateMgr(folder, sk)
+	if err != nil {
+		return 0, fmt.Errorf("fail to create file state manager: %w", err)
+	}
+	pubKeys, err := fsm.ListLocalStates()
+	if err != nil {
+		return 0, err
+	}
+	batch := new(leveldb.Batch)
+	for _, pubKey := range pubKeys {
+		if err := migrateFileStateHistory(fsm, ldm, pubKey, batch); err != nil {
+			return 0, err
+		}
+		preSignFile, err := fsm.getPoolFilePathName("presign", pubKey)
+		if err != nil {
+			return 0, err
+		}
+		preSignatures, err := fsm.readPreSignatures(preSignFile)
+		if err != nil {
+			return 0, err
+		}
+		if len(preSignatures) == 0 {
+			continue
+		}
+		encryptedData, err := ldm.encodePreSignatures(preSignatures)
+		if err != nil {
+			return 0, err
+		}
+		preSignKey, err := getPoolDBKey(preSignPrefix, pubKey)
+		if err != nil {
+			return 0, err
+		}
+		batch.Put(preSignKey, encryptedData)
+	}
+	preParams, err := fsm.GetPreParams()
+	if err != nil {
+		return 0, err
+	}
+	if len(preParams) > 0 {
+		encryptedData, err := encodePreParams(preParams, ldm.sk)
+		if err != nil {
+			return 0, err
+		}
+		batch.Put([]byte(preParamsDBKey), encryptedData)
+	}
+	addressBook, err := fsm.GetAddressBook()
+	if err != nil {
+		return 0, err
+	}
+	if addressBook.Len() > 0 {
+		buf, err := json.Marshal(addressBook)
+		if err != nil {
+			return 0, fmt.Errorf("fail to marshal the address book: %w", err)
+		}
+		batch.Put([]byte(addressBookDBKey), buf)
+	}
+	if err := ldm.write(batch); err != nil {
+		return 0, err
+	}
+	return len(pubKeys), nil
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	tnet "github.com/libp2p/go-libp2p-testing/net"
+	maddr "github.com/multiformats/go-multiaddr"
+	"golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+)
+
+type LevelDBStateMgrTestSuite struct{}
+
+var _ = Suite(&LevelDBStateMgrTestSuite{})
+
+func (s *LevelDBStateMgrTestSuite) SetUpTest(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func getTestStateKey() []byte {
+	h := sha3.New256()
+	h.Write([]byte("my password!"))
+	return h.Sum(nil)
+}
+
+func getTestLocalState(pubKey string) KeygenLocalState {
+	return KeygenLocalState{
+		PubKey:          pubKey,
+		LocalData:       keygen.NewLocalPartySaveData(5),
+		ParticipantKeys: []string{"A", "B", "C"},
+		LocalPartyKey:   "A",
+	}
+}
+
+func (s *LevelDBStateMgrTestSuite) TestLevelDBStateMgr(c *C) {
+	sk := getTestStateKey()
+	f, err := ioutil.TempDir("", "leveldb")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	_, err = NewLevelDBStateMgr("", sk)
+	c.Assert(err, NotNil)
+	ldm, err := NewLevelDBStateMgr(f, sk)
+	c.Assert(err, IsNil)
+
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	_, err = ldm.GetLocalState(pubKey1)
+	c.Assert(err, NotNil)
+	// nothing is saved when any of the states is invalid
+	c.Assert(ldm.SaveLocalStates(getTestLocalState(pubKey1), getTestLocalState("whatever")), NotNil)
+	_, err = ldm.GetLocalState(pubKey1)
+	c.Assert(err, NotNil)
+	c.Assert(ldm.SaveLocalStates(getTestLocalState(pubKey1), getTestLocalState(pubKey2)), IsNil)
+	item, err := ldm.GetLocalState(pubKey2)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(getTestLocalState(pubKey2), item), Equals, true)
+
+	var t *testing.T
+	mockAddr, err := maddr.NewMultiaddr("/ip4/192.168.3.5/tcp/6668")
+	c.Assert(err, IsNil)
+	addressBook := p2p.NewAddressBook()
+	now := time.Now().UTC().Round(0)
+	addressBook.Seen(tnet.RandIdentityOrFatal(t).ID(), []maddr.Multiaddr{mockAddr}, now)
+	addressBook.Seen(tnet.RandIdentityOrFatal(t).ID(), []maddr.Multiaddr{mockAddr}, now)
+	saved, err := ldm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(saved.Len(), Equals, 0)
+	c.Assert(ldm.SaveAddressBook(addressBook), IsNil)
+
+	c.Assert(ldm.SavePreSignatures(pubKey1, []PreSignature{
+		{ID: "2", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{2}}},
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	taken, err := ldm.TakePreSignatures(pubKey1, []string{"B", "A"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	c.Assert(taken[0].ID, Equals, "1")
+
+	// the records are kept after the database is opened again
+	c.Assert(ldm.Close(), IsNil)
+	ldm, err = NewLevelDBStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	item, err = ldm.GetLocalState(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(getTestLocalState(pubKey1), item), Equals, true)
+	saved, err = ldm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(saved.Records(), DeepEquals, addressBook.Records())
+	taken, err = ldm.TakePreSignatures(pubKey1, []string{"A", "B"}, []string{"1", "2"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+	taken, err = ldm.TakePreSignatures(pubKey1, []string{"A", "B"}, []string{"2"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	c.Assert(taken[0].ID, Equals, "2")
+	taken, err = ldm.TakePreSignatures(pubKey1, []string{"A", "B"}, []string{"2"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+
+	pubKeys, err := ldm.ListLocalStates()
+	c.Assert(err, IsNil)
+	c.Assert(pubKeys, HasLen, 2)
+	c.Assert(ldm.SavePreSignatures(pubKey2, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	c.Assert(ldm.RetireLocalState(pubKey2), IsNil)
+	err = ldm.RetireLocalState(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	_, err = ldm.GetLocalState(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	taken, err = ldm.TakePreSignatures(pubKey2, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+	// the history of the retired pool is gone along with it
+	_, err = ldm.GetLocalStateHistory(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	_, err = ldm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+	pubKeys, err = ldm.ListLocalStates()
+	c.Assert(err, IsNil)
+	c.Assert(pubKeys, DeepEquals, []string{pubKey1})
+}
+
+func (s *LevelDBStateMgrTestSuite) TestLocalStateHistory(c *C) {
+	f, err := ioutil.TempDir("", "leveldb")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	ldm, err := NewLevelDBStateMgr(f, getTestStateKey())
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	checkLocalStateHistory(c, ldm)
+}
+
+func (s *LevelDBStateMgrTestSuite) TestLocalStateBackups(c *C) {
+	ldm, err := NewLevelDBStateMgr(c.MkDir(), getTestStateKey())
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	ldm.SetBackups(2)
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	for i := 1; i <= 4; i++ {
+		state := getTestLocalState(pubKey1)
+		state.MsgID = fmt.Sprintf("msg%d", i)
+		c.Assert(ldm.SaveLocalState(state), IsNil)
+	}
+	// the history keeps the pinned generation and as many older ones as the backups
+	generations, err := ldm.listGenerations(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(generations, DeepEquals, []int64{2, 3, 4})
+
+	// the generations saved in one batch are pruned as well
+	c.Assert(ldm.SaveLocalStates(getTestLocalState(pubKey1), getTestLocalState(pubKey2), getTestLocalState(pubKey1)), IsNil)
+	generations, err = ldm.listGenerations(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(generations, DeepEquals, []int64{4, 5, 6})
+	generations, err = ldm.listGenerations(pubKey2)
+	c.Assert(err, IsNil)
+	c.Assert(generations, DeepEquals, []int64{1})
+
+	ldm.SetBackups(0)
+	c.Assert(ldm.SaveLocalState(getTestLocalState(pubKey1)), IsNil)
+	versions, err := ldm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []LocalStateVersion{{Generation: 7, Pinned: true}})
+}
+
+func (s *LevelDBStateMgrTestSuite) TestPreParams(c *C) {
+	ldm, err := NewLevelDBStateMgr(c.MkDir(), getTestStateKey())
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	checkPreParams(c, ldm)
+}
+
+func (s *LevelDBStateMgrTestSuite) TestMigrateFileState(c *C) {
+	sk := getTestStateKey()
+	f, err := ioutil.TempDir("", "migrate")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	fsm, err := NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey1)), IsNil)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey2)), IsNil)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey2)), IsNil)
+	c.Assert(fsm.PinLocalState(pubKey2, 1), IsNil)
+	c.Assert(fsm.SavePreSignatures(pubKey2, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(f, "address_book.seed"),
+		[]byte("/ip4/192.168.3.5/tcp/6668/p2p/16Uiu2HAm4TmEzUqy3q3Dv7HvdoSboHk5sFj2FH3npiN5vDbJC6gh\n"), 0o600), IsNil)
+
+	ldm, err := NewLevelDBStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	// the key the files are encrypted with is needed
+	_, err = MigrateFileState(f, []byte("wrong key"), ldm)
+	c.Assert(err, NotNil)
+	_, err = ldm.GetLocalState(pubKey1)
+	c.Assert(err, NotNil)
+
+	num, err := MigrateFileState(f, sk, ldm)
+	c.Assert(err, IsNil)
+	c.Assert(num, Equals, 2)
+	for _, el := range []string{pubKey1, pubKey2} {
+		item, err := ldm.GetLocalState(el)
+		c.Assert(err, IsNil)
+		c.Assert(reflect.DeepEqual(getTestLocalState(el), item), Equals, true)
+	}
+	taken, err := ldm.TakePreSignatures(pubKey2, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	// the history is imported with the pinned generation
+	versions, err := ldm.GetLocalStateHistory(pubKey2)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 2)
+	c.Assert(versions[0].Pinned, Equals, true)
+	addressBook, err := ldm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(addressBook.Len(), Equals, 1)
+}
@@ -2,29 +2,206 @@ package storage
 
 import (
 	"bytes"
+	"crypto/elliptic"
 	"encoding/json"
 	"errors"
 	"fmt"
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
 	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	eddsakeygen "github.com/binance-chain/tss-lib/eddsa/keygen"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-peerstore/addr"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	tcrypto "github.com/tendermint/tendermint/crypto"
 	"gitlab.com/thorchain/tss/go-tss/common"
+	"golang.org/x/crypto/sha3"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
 )
 
+// ErrLocalStateNotFound is returned when the node does not hold a share of the pool
+var ErrLocalStateNotFound = errors.New("local state not found")
+
+// IsLocalStateNotFound tell whether the error is caused by the local state of the pool being absent
+func IsLocalStateNotFound(err error) bool {
+	return errors.Is(err, ErrLocalStateNotFound) || errors.Is(err, os.ErrNotExist) || errors.Is(err, leveldb.ErrNotFound)
+}
+
 // KeygenLocalState is a structure used to represent the data we saved locally for different keygen
 type KeygenLocalState struct {
 	PubKey          string                    `json:"pub_key"`
 	LocalData       keygen.LocalPartySaveData `json:"local_data"`
 	ParticipantKeys []string                  `json:"participant_keys"` // the paticipant of last key gen
 	LocalPartyKey   string                    `json:"local_party_key"`
+	// Algo is empty for the pools created before EdDSA is supported, which are all ECDSA pools
+	Algo           common.Algo                     `json:"algo,omitempty"`
+	EdDSALocalData *eddsakeygen.LocalPartySaveData `json:"eddsa_local_data,omitempty"`
+	// Threshold is the number of parties needed to sign, it is 0 for the pools created before the threshold is
+	// configurable, which use the default threshold
+	Threshold int `json:"threshold,omitempty"`
+	// BlockHeight is the block height of the keygen, resharing or refresh that created this share
+	BlockHeight int64 `json:"block_height,omitempty"`
+	// MsgID is the message id of the keygen, resharing or refresh that created this share
+	MsgID string `json:"msg_id,omitempty"`
+}
+
+// LocalStateVersion is the metadata of one generation of the local state of a pool, every save of the local state
+// creates a new generation
+type LocalStateVersion struct {
+	// Generation is 0 for the share saved before the history is kept, and grows from 1 with every save
+	Generation  int64  `json:"generation"`
+	BlockHeight int64  `json:"block_height"`
+	MsgID       string `json:"msg_id"`
+	// Pinned tells whether this generation is the one GetLocalState returns, which keysign uses
+	Pinned bool `json:"pinned"`
+}
+
+// getLocalStateVersion read the metadata of the given generation from the json of the local state
+func getLocalStateVersion(generation int64, plainText []byte) (LocalStateVersion, error) {
+	var header struct {
+		BlockHeight int64  `json:"block_height"`
+		MsgID       string `json:"msg_id"`
+	}
+	if err := json.Unmarshal(plainText, &header); err != nil {
+		return LocalStateVersion{}, fmt.Errorf("fail to unmarshal the generation %d of the local state: %w", generation, err)
+	}
+	return LocalStateVersion{
+		Generation:  generation,
+		BlockHeight: header.BlockHeight,
+		MsgID:       header.MsgID,
+	}, nil
+}
+
+// buildLocalStateHistory return the versions of the generations of the local state of the pool from their json,
+// the generation that is the same as the current local state is pinned, the current local state saved before the
+// history is kept is listed as the generation 0
+func buildLocalStateHistory(pubKey string, current []byte, generations []int64, plainTexts [][]byte) ([]LocalStateVersion, error) {
+	versions := make([]LocalStateVersion, 0, len(generations)+1)
+	pinned := false
+	for i, generation := range generations {
+		version, err := getLocalStateVersion(generation, plainTexts[i])
+		if err != nil {
+			return nil, err
+		}
+		version.Pinned = current != nil && bytes.Equal(plainTexts[i], current)
+		pinned = pinned || version.Pinned
+		versions = append(versions, version)
+	}
+	if !pinned && current != nil {
+		version, err := getLocalStateVersion(0, current)
+		if err != nil {
+			return nil, err
+		}
+		version.Pinned = true
+		versions = append([]LocalStateVersion{version}, versions...)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLocalStateNotFound, pubKey)
+	}
+	return versions, nil
+}
+
+// GetAlgo return the algorithm of this pool
+func (s KeygenLocalState) GetAlgo() common.Algo {
+	algo, _ := common.GetAlgo(s.Algo)
+	return algo
+}
+
+// GetThreshold return the threshold tss-lib signs with for this pool
+func (s KeygenLocalState) GetThreshold() (int, error) {
+	return conversion.GetThresholdWithSigners(len(s.ParticipantKeys), s.Threshold)
+}
+
+// LocalStateInfo is the metadata of a local state, it does not carry any secret of the share
+type LocalStateInfo struct {
+	PubKey          string      `json:"pub_key"`
+	Algo            common.Algo `json:"algo"`
+	ParticipantKeys []string    `json:"participant_keys"`
+	LocalPartyKey   string      `json:"local_party_key"`
+	// Threshold is the number of parties needed to sign
+	Threshold int `json:"threshold"`
+	// KeyHeight is the block height the share was last reshared or refreshed at, 0 if it never was
+	KeyHeight int64 `json:"key_height"`
+}
+
+// GetInfo return the metadata of the local state
+func (s KeygenLocalState) GetInfo() LocalStateInfo {
+	info := LocalStateInfo{
+		PubKey:          s.PubKey,
+		Algo:            s.GetAlgo(),
+		ParticipantKeys: s.ParticipantKeys,
+		LocalPartyKey:   s.LocalPartyKey,
+	}
+	if threshold, err := s.GetThreshold(); err == nil {
+		info.Threshold = threshold + 1
+	}
+	ks := s.LocalData.Ks
+	if info.Algo == common.EdDSA && s.EdDSALocalData != nil {
+		ks = s.EdDSALocalData.Ks
+	}
+	if len(ks) > 0 && ks[0] != nil {
+		info.KeyHeight = conversion.GetPartyKeyHeight(ks[0])
+	}
+	return info
+}
+
+// PreSignature is the message independent part of an ECDSA signature that a signer set generates ahead of time,
+// a presignature must never be used more than once
+type PreSignature struct {
+	// ID is derived from the R of the signature, it is the same on all the signers
+	ID      string                              `json:"id"`
+	Signers []string                            `json:"signers"`
+	Data    *signing.SignatureData_OneRoundData `json:"data"`
+}
+
+// PickPreSignatures pick the presignatures of the given ids generated by the given signers out of preSignatures,
+// in the order of the ids, it picks nothing when any of them is missing. The presignatures left are returned as well
+func PickPreSignatures(preSignatures []PreSignature, signers []string, ids []string) ([]PreSignature, []PreSignature) {
+	joinSorted := func(keys []string) string {
+		sorted := append([]string{}, keys...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, ",")
+	}
+	target := joinSorted(signers)
+	wanted := make(map[string]int, len(ids))
+	for idx, el := range ids {
+		wanted[el] = idx
+	}
+	if len(ids) == 0 || len(wanted) != len(ids) {
+		return nil, preSignatures
+	}
+	picked := make([]PreSignature, len(ids))
+	isPicked := make([]bool, len(ids))
+	found := 0
+	var left []PreSignature
+	for _, el := range preSignatures {
+		idx, ok := wanted[el.ID]
+		if ok && !isPicked[idx] && joinSorted(el.Signers) == target {
+			picked[idx] = el
+			isPicked[idx] = true
+			found++
+			continue
+		}
+		left = append(left, el)
+	}
+	if found != len(ids) {
+		return nil, preSignatures
+	}
+	return picked, left
 }
 
 // LocalStateManager provide necessary methods to manage the local state, save it , and read it back
@@ -32,8 +209,36 @@ type KeygenLocalState struct {
 type LocalStateManager interface {
 	SaveLocalState(state KeygenLocalState) error
 	GetLocalState(pubKey string) (KeygenLocalState, error)
-	SaveAddressBook(addressBook map[peer.ID]addr.AddrList) error
-	RetrieveP2PAddresses() (addr.AddrList, error)
+	// SaveAddressBook replace the saved address book with the given one
+	SaveAddressBook(addressBook *p2p.AddressBook) error
+	// GetAddressBook return the saved address book, it is empty if none is saved
+	GetAddressBook() (*p2p.AddressBook, error)
+	// SavePreSignatures add the given presignatures to the ones of the pool
+	SavePreSignatures(pubKey string, preSignatures []PreSignature) error
+	// TakePreSignatures remove the presignatures of the given ids generated by the given signers from the pool and
+	// return them in the order of the ids, it removes nothing and returns nil if the pool misses any of them
+	TakePreSignatures(pubKey string, signers []string, ids []string) ([]PreSignature, error)
+	// ListLocalStates return the pub keys of all the pools the node holds a share of
+	ListLocalStates() ([]string, error)
+	// SavePreParams replace the saved Paillier pre-parameters with the given ones
+	SavePreParams(preParams []*keygen.LocalPreParams) error
+	// GetPreParams return the saved Paillier pre-parameters
+	GetPreParams() ([]*keygen.LocalPreParams, error)
+	// GetLocalStateHistory return the generations of the local state of the pool, the oldest first
+	GetLocalStateHistory(pubKey string) ([]LocalStateVersion, error)
+	// PinLocalState make the given generation the local state of the pool GetLocalState returns and remove the
+	// presignatures of the pool, the latest saved generation is pinned
+	PinLocalState(pubKey string, generation int64) error
+	// RetireLocalState remove the local state and the presignatures of the pool, an encrypted copy of the local
+	// state is kept as a tombstone
+	RetireLocalState(pubKey string) error
+}
+
+// BatchLocalStateManager is the LocalStateManager that saves several local states at once
+type BatchLocalStateManager interface {
+	LocalStateManager
+	// SaveLocalStates save all the given local states, the node never keeps part of them, even after a crash
+	SaveLocalStates(states ...KeygenLocalState) error
 }
 
 // FileStateMgr save the local state to file
@@ -41,6 +246,30 @@ type FileStateMgr struct {
 	folder    string
 	sk        []byte
 	writeLock *sync.RWMutex
+	backups   int
+	logger    zerolog.Logger
+}
+
+const (
+	// preParamsFileName is the file the FileStateMgr saves the Paillier pre-parameters in
+	preParamsFileName = "preparams.dat"
+	// addressBookFileName is the file the FileStateMgr saves the address book in
+	addressBookFileName = "address_book.json"
+	// legacyAddressBookFileName is the file the address book was saved in before the peers are scored
+	legacyAddressBookFileName = "address_book.seed"
+	// batchFileName is the file the FileStateMgr records the local states of a batch in before it saves them
+	batchFileName = "localstate-batch.dat"
+)
+
+// DefaultLocalStateBackups is the number of the generations older than the pinned one the local state managers keep
+// in the history of a pool
+const DefaultLocalStateBackups = 3
+
+// GetStateKey return the key the local states of the node are encrypted with, it is derived from the node key
+func GetStateKey(priKey tcrypto.PrivKey) []byte {
+	h := sha3.New256()
+	h.Write(priKey.Bytes())
+	return h.Sum(nil)
 }
 
 // NewFileStateMgr create a new instance of the FileStateMgr which implements LocalStateManager
@@ -53,14 +282,33 @@ func NewFileStateMgr(folder string, sk []byte) (*FileStateMgr, error) {
 			}
 		}
 	}
-	return &FileStateMgr{
+	fsm := &FileStateMgr{
 		folder:    folder,
 		writeLock: &sync.RWMutex{},
 		sk:        sk,
-	}, nil
+		backups:   DefaultLocalStateBackups,
+		logger:    log.With().Str("module", "storage").Logger(),
+	}
+	if err := fsm.completeBatch(); err != nil {
+		return nil, err
+	}
+	return fsm, nil
+}
+
+// SetBackups set the number of the older generations kept in the history of a pool besides the pinned one, 0 keeps
+// only the pinned one. The generations kept are the backups GetLocalState falls back to
+func (fsm *FileStateMgr) SetBackups(num int) {
+	if num < 0 {
+		num = 0
+	}
+	fsm.backups = num
 }
 
 func (fsm *FileStateMgr) getFilePathName(pubKey string) (string, error) {
+	return fsm.getPoolFilePathName("localstate", pubKey)
+}
+
+func (fsm *FileStateMgr) getPoolFilePathName(prefix, pubKey string) (string, error) {
 	ret, err := conversion.CheckKeyOnCurve(pubKey)
 	if err != nil {
 		return "", err
@@ -69,32 +317,307 @@ func (fsm *FileStateMgr) getFilePathName(pubKey string) (string, error) {
 		return "", errors.New("invalid pubkey for file name")
 	}
 
-	localFileName := fmt.Sprintf("localstate-%s.json", pubKey)
+	localFileName := fmt.Sprintf("%s-%s.json", prefix, pubKey)
 	if len(fsm.folder) > 0 {
 		return filepath.Join(fsm.folder, localFileName), nil
 	}
 	return localFileName, nil
 }
 
-// SaveLocalState save the local state to file
+// SaveLocalState save the local state to file as a new generation of the local state of the pool, and pin it
 func (fsm *FileStateMgr) SaveLocalState(state KeygenLocalState) error {
 	buf, err := json.Marshal(state)
 	if err != nil {
 		return fmt.Errorf("fail to marshal KeygenLocalState to json: %w", err)
 	}
-	filePathName, err := fsm.getFilePathName(state.PubKey)
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	return fsm.writeLocalState(state.PubKey, buf)
+}
+
+// writeLocalState write the json of the local state of the pool as its new generation, and pin it
+func (fsm *FileStateMgr) writeLocalState(pubKey string, buf []byte) error {
+	filePathName, err := fsm.getFilePathName(pubKey)
+	if err != nil {
+		return err
+	}
+	encryptedData, err := common.AESEncrypt(buf, fsm.sk)
+	if err != nil {
+		return err
+	}
+	encryptedData = sealEnvelope(append([]byte("enc"), encryptedData...))
+	generations, err := fsm.listGenerations(pubKey)
 	if err != nil {
 		return err
 	}
+	generation := int64(1)
+	if len(generations) > 0 {
+		generation = generations[len(generations)-1] + 1
+	} else {
+		// the share saved before the history is kept becomes the first generation
+		currentData, err := ioutil.ReadFile(filePathName)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+		}
+		if err == nil {
+			if err := fsm.writeGeneration(pubKey, generation, currentData); err != nil {
+				return err
+			}
+			generation++
+		}
+	}
+	if err := fsm.writeGeneration(pubKey, generation, encryptedData); err != nil {
+		return err
+	}
+	// the local state is saved once the generation is pinned, GetLocalState uses the pinned generation when the node
+	// stops before the local state file is written
+	if err := fsm.writePinnedGeneration(pubKey, generation); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filePathName, encryptedData); err != nil {
+		return err
+	}
+	if err := fsm.pruneGenerations(pubKey, generation); err != nil {
+		fsm.logger.Warn().Err(err).Msgf("fail to prune the local state history of %s", pubKey)
+	}
+	return nil
+}
+
+// SaveLocalStates save the local states one after another, they are recorded in the batch file first. Once the
+// batch is recorded it is completed, the local states the node fails to save, or does not get to save before it
+// stops, are saved when the FileStateMgr is created again
+func (fsm *FileStateMgr) SaveLocalStates(states ...KeygenLocalState) error {
+	batch := make([]json.RawMessage, len(states))
+	for i, el := range states {
+		if _, err := fsm.getFilePathName(el.PubKey); err != nil {
+			return err
+		}
+		buf, err := json.Marshal(el)
+		if err != nil {
+			return fmt.Errorf("fail to marshal KeygenLocalState to json: %w", err)
+		}
+		batch[i] = buf
+	}
+	buf, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the batch to json: %w", err)
+	}
 	encryptedData, err := common.AESEncrypt(buf, fsm.sk)
 	if err != nil {
 		return err
 	}
-	encryptedData = append([]byte("enc"), encryptedData...)
-	return ioutil.WriteFile(filePathName, encryptedData, 0o655)
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	batchFilePathName := filepath.Join(fsm.folder, batchFileName)
+	if err := writeFileAtomic(batchFilePathName, sealEnvelope(append([]byte("enc"), encryptedData...))); err != nil {
+		return fmt.Errorf("fail to record the batch: %w", err)
+	}
+	if err := fsm.writeBatch(batch); err != nil {
+		return fmt.Errorf("fail to save the batch, it is completed when the local states are loaded again: %w", err)
+	}
+	if err := os.Remove(batchFilePathName); err != nil {
+		return fmt.Errorf("fail to remove the batch file: %w", err)
+	}
+	return nil
+}
+
+// writeBatch write the local states of the batch, the ones already saved are skipped
+func (fsm *FileStateMgr) writeBatch(batch []json.RawMessage) error {
+	for _, el := range batch {
+		var header struct {
+			PubKey string `json:"pub_key"`
+		}
+		if err := json.Unmarshal(el, &header); err != nil {
+			return fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+		}
+		filePathName, err := fsm.getFilePathName(header.PubKey)
+		if err != nil {
+			return err
+		}
+		currentData, err := ioutil.ReadFile(filePathName)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+		}
+		if err == nil {
+			if plainText, err := fsm.openLocalStateFile(currentData); err == nil && bytes.Equal(plainText, el) {
+				continue
+			}
+		}
+		if err := fsm.writeLocalState(header.PubKey, el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeBatch save the local states of the batch recorded in the batch file that are not saved yet
+func (fsm *FileStateMgr) completeBatch() error {
+	batchFilePathName := filepath.Join(fsm.folder, batchFileName)
+	loadedData, err := ioutil.ReadFile(batchFilePathName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("fail to read from file(%s): %w", batchFilePathName, err)
+	}
+	plainText, err := fsm.openLocalStateFile(loadedData)
+	if err != nil {
+		return fmt.Errorf("fail to open the batch file: %w", err)
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(plainText, &batch); err != nil {
+		return fmt.Errorf("fail to unmarshal the batch: %w", err)
+	}
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	if err := fsm.writeBatch(batch); err != nil {
+		return fmt.Errorf("fail to complete the batch: %w", err)
+	}
+	fsm.logger.Info().Msgf("the batch of %d local states is completed", len(batch))
+	if err := os.Remove(batchFilePathName); err != nil {
+		return fmt.Errorf("fail to remove the batch file: %w", err)
+	}
+	return nil
+}
+
+// sealLocalStateFile return the content of a local state file encrypted and in the checksummed envelope, the local
+// states saved before they are encrypted are encrypted
+func (fsm *FileStateMgr) sealLocalStateFile(loadedData []byte) ([]byte, error) {
+	content, err := openEnvelope(loadedData)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(content, []byte("enc")) {
+		encryptedData, err := common.AESEncrypt(content, fsm.sk)
+		if err != nil {
+			return nil, err
+		}
+		content = append([]byte("enc"), encryptedData...)
+	}
+	return sealEnvelope(content), nil
+}
+
+// openLocalStateFile return the json of the local state in the content of a local state file
+func (fsm *FileStateMgr) openLocalStateFile(loadedData []byte) ([]byte, error) {
+	content, err := openEnvelope(loadedData)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(content, []byte("enc")) {
+		return content, nil
+	}
+	plainText, err := common.AESDecrypt(content[3:], fsm.sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt the keygen data with %v", err)
+	}
+	return plainText, nil
+}
+
+func (fsm *FileStateMgr) getGenerationFilePathName(pubKey string, generation int64) (string, error) {
+	return fsm.getPoolFilePathName(fmt.Sprintf("history-%d", generation), pubKey)
+}
+
+// listGenerations return the generations of the local state of the pool that have a history file, the oldest first
+func (fsm *FileStateMgr) listGenerations(pubKey string) ([]int64, error) {
+	suffix := "-" + pubKey + ".json"
+	files, err := filepath.Glob(filepath.Join(fsm.folder, "history-*"+suffix))
+	if err != nil {
+		return nil, fmt.Errorf("fail to list the local state history files: %w", err)
+	}
+	generations := make([]int64, 0, len(files))
+	for _, el := range files {
+		value := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(el), "history-"), suffix)
+		generation, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, generation)
+	}
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i] < generations[j]
+	})
+	return generations, nil
+}
+
+func (fsm *FileStateMgr) writeGeneration(pubKey string, generation int64, loadedData []byte) error {
+	filePathName, err := fsm.getGenerationFilePathName(pubKey, generation)
+	if err != nil {
+		return err
+	}
+	sealedData, err := fsm.sealLocalStateFile(loadedData)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filePathName, sealedData); err != nil {
+		return fmt.Errorf("fail to write the generation %d of the local state: %w", generation, err)
+	}
+	return nil
+}
+
+// pruneGenerations remove the history files of the pool but the pinned generation and the newest backups+1
+// generations
+func (fsm *FileStateMgr) pruneGenerations(pubKey string, pinned int64) error {
+	generations, err := fsm.listGenerations(pubKey)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(generations)-fsm.backups-1; i++ {
+		if generations[i] == pinned {
+			continue
+		}
+		generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generations[i])
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(generationFilePathName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to remove the generation %d of the local state: %w", generations[i], err)
+		}
+	}
+	return nil
+}
+
+func (fsm *FileStateMgr) getPinnedFilePathName(pubKey string) (string, error) {
+	return fsm.getPoolFilePathName("pinned", pubKey)
+}
+
+// writePinnedGeneration record the generation the local state file of the pool is a copy of, it is written before
+// the local state file, so the generation can be recovered from the history when the local state file is corrupted
+func (fsm *FileStateMgr) writePinnedGeneration(pubKey string, generation int64) error {
+	filePathName, err := fsm.getPinnedFilePathName(pubKey)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filePathName, sealEnvelope([]byte(strconv.FormatInt(generation, 10)))); err != nil {
+		return fmt.Errorf("fail to write the pinned generation of the local state: %w", err)
+	}
+	return nil
+}
+
+func (fsm *FileStateMgr) readPinnedGeneration(pubKey string) (int64, error) {
+	filePathName, err := fsm.getPinnedFilePathName(pubKey)
+	if err != nil {
+		return 0, err
+	}
+	loadedData, err := ioutil.ReadFile(filePathName)
+	if err != nil {
+		return 0, fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	content, err := openEnvelope(loadedData)
+	if err != nil {
+		return 0, err
+	}
+	generation, err := strconv.ParseInt(string(content), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("fail to parse the pinned generation of the local state: %w", err)
+	}
+	return generation, nil
 }
 
-// GetLocalState read the local state from file system
+// GetLocalState read the local state from file system. The pinned generation is used when the local state file does
+// not hold it, which happens when the node stops after the generation is pinned and before the local state file is
+// written. When the local state file is corrupted, the generations kept in the history are its backups, they are
+// tried from the pinned one back to the oldest, the generations newer than the pinned one are rolled back from and
+// never used. The files are read under the lock and decoded after it is released, so decoding never holds up a save
 func (fsm *FileStateMgr) GetLocalState(pubKey string) (KeygenLocalState, error) {
 	if len(pubKey) == 0 {
 		return KeygenLocalState{}, errors.New("pub key is empty")
@@ -106,70 +629,298 @@ func (fsm *FileStateMgr) GetLocalState(pubKey string) (KeygenLocalState, error)
 	if _, err := os.Stat(filePathName); os.IsNotExist(err) {
 		return KeygenLocalState{}, err
 	}
+	loadedData, err := fsm.readFile(filePathName)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	plainText, err := fsm.openLocalStateFile(loadedData)
+	if pinned, pinnedData := fsm.readPinnedGenerationFile(pubKey); pinnedData != nil {
+		pinnedPlainText, pinnedErr := fsm.openLocalStateFile(pinnedData)
+		if pinnedErr == nil && err == nil && !bytes.Equal(plainText, pinnedPlainText) {
+			fsm.logger.Warn().Msgf("the local state file of %s does not hold the pinned generation %d, use the pinned one", pubKey, pinned)
+			plainText = pinnedPlainText
+		}
+	}
+	var state KeygenLocalState
+	if err == nil {
+		state, err = unmarshalLocalState(plainText)
+	}
+	if err == nil {
+		return state, nil
+	}
+	generations, history, historyErr := fsm.readBackupGenerations(pubKey)
+	if historyErr != nil {
+		fsm.logger.Error().Err(historyErr).Msgf("the local state of %s is corrupted and its history can not be read", pubKey)
+		return KeygenLocalState{}, err
+	}
+	for i, generation := range generations {
+		backup, backupErr := fsm.decodeLocalStateFile(history[i])
+		if backupErr != nil {
+			fsm.logger.Error().Err(backupErr).Msgf("the generation %d of the local state of %s is corrupted", generation, pubKey)
+			continue
+		}
+		fsm.logger.Warn().Err(err).Msgf("the local state of %s is corrupted, use the generation %d in the history", pubKey, generation)
+		return backup, nil
+	}
+	fsm.logger.Error().Msgf("the local state of %s and all the %d generations kept are corrupted", pubKey, len(generations))
+	return KeygenLocalState{}, err
+}
 
+// readFile read the file under the lock
+func (fsm *FileStateMgr) readFile(filePathName string) ([]byte, error) {
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
 	loadedData, err := ioutil.ReadFile(filePathName)
 	if err != nil {
-		return KeygenLocalState{}, fmt.Errorf("file to read from file(%s): %w", filePathName, err)
+		return nil, fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	return loadedData, nil
+}
+
+// readPinnedGenerationFile read the history file of the pinned generation of the pool under the lock, it returns nil
+// when the pool has no pinned generation
+func (fsm *FileStateMgr) readPinnedGenerationFile(pubKey string) (int64, []byte) {
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
+	pinned, err := fsm.readPinnedGeneration(pubKey)
+	if err != nil {
+		return 0, nil
+	}
+	generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, pinned)
+	if err != nil {
+		return 0, nil
+	}
+	loadedData, err := ioutil.ReadFile(generationFilePathName)
+	if err != nil {
+		fsm.logger.Error().Err(err).Msgf("fail to read the pinned generation %d of the local state of %s", pinned, pubKey)
+		return 0, nil
+	}
+	return pinned, loadedData
+}
+
+// readBackupGenerations read the history files of the pool under the lock, from the pinned generation back to the
+// oldest, all the generations are read from the newest when the pinned one is unknown
+func (fsm *FileStateMgr) readBackupGenerations(pubKey string) ([]int64, [][]byte, error) {
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
+	generations, err := fsm.listGenerations(pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	pinned, err := fsm.readPinnedGeneration(pubKey)
+	if err != nil {
+		fsm.logger.Warn().Err(err).Msgf("the pinned generation of %s is unknown, try from the newest generation", pubKey)
+		pinned = math.MaxInt64
 	}
-	var plainText []byte
-	if bytes.Equal([]byte("enc"), loadedData[:3]) {
-		plainText, err = common.AESDecrypt(loadedData[3:], fsm.sk)
+	var backups []int64
+	var history [][]byte
+	for i := len(generations) - 1; i >= 0; i-- {
+		if generations[i] > pinned {
+			continue
+		}
+		generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generations[i])
 		if err != nil {
-			return KeygenLocalState{}, fmt.Errorf("fail to decrypt the keygen data with %v", err)
+			return nil, nil, err
 		}
-	} else {
-		plainText = loadedData
+		loadedData, err := ioutil.ReadFile(generationFilePathName)
+		if err != nil {
+			fsm.logger.Error().Err(err).Msgf("fail to read the generation %d of the local state of %s", generations[i], pubKey)
+			continue
+		}
+		backups = append(backups, generations[i])
+		history = append(history, loadedData)
 	}
+	return backups, history, nil
+}
 
+// decodeLocalStateFile decode the content of a local state file
+func (fsm *FileStateMgr) decodeLocalStateFile(loadedData []byte) (KeygenLocalState, error) {
+	plainText, err := fsm.openLocalStateFile(loadedData)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	return unmarshalLocalState(plainText)
+}
+
+// ReadLocalStateFile read a local state file the FileStateMgr wrote, sk is the key the local states in the folder
+// of the file are encrypted with, it is used by the tools that read the files of other nodes
+func ReadLocalStateFile(filePathName string, sk []byte) (KeygenLocalState, error) {
+	fsm := &FileStateMgr{sk: sk, writeLock: &sync.RWMutex{}}
+	loadedData, err := fsm.readFile(filePathName)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	return fsm.decodeLocalStateFile(loadedData)
+}
+
+// jsonPoint is the json of a crypto.ECPoint
+type jsonPoint struct {
+	Coords [2]*big.Int
+}
+
+// takePoints remove the BigXj and the given pub key field from the json of the local data of a local state, and
+// return the points in them
+func takePoints(localData json.RawMessage, pubKeyField string) (json.RawMessage, []*jsonPoint, *jsonPoint, error) {
+	if len(localData) == 0 || bytes.Equal(localData, []byte("null")) {
+		return localData, nil, nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(localData, &fields); err != nil {
+		return nil, nil, nil, err
+	}
+	var bigXj []*jsonPoint
+	var pubKey *jsonPoint
+	if err := json.Unmarshal(orNull(fields["BigXj"]), &bigXj); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := json.Unmarshal(orNull(fields[pubKeyField]), &pubKey); err != nil {
+		return nil, nil, nil, err
+	}
+	delete(fields, "BigXj")
+	delete(fields, pubKeyField)
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return buf, bigXj, pubKey, nil
+}
+
+func orNull(value json.RawMessage) json.RawMessage {
+	if len(value) == 0 {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+// toECPoint return the point on the given curve, the same as crypto.ECPoint would decode it on that curve
+func (p *jsonPoint) toECPoint(curve elliptic.Curve) (*bcrypto.ECPoint, error) {
+	if p == nil {
+		return nil, nil
+	}
+	x, y := p.Coords[0], p.Coords[1]
+	if x == nil || y == nil || !curve.IsOnCurve(x, y) {
+		return nil, errors.New("the point is not on the elliptic curve")
+	}
+	return bcrypto.NewECPointNoCurveCheck(curve, x, y), nil
+}
+
+func toECPoints(curve elliptic.Curve, points []*jsonPoint) ([]*bcrypto.ECPoint, error) {
+	if points == nil {
+		return nil, nil
+	}
+	ecPoints := make([]*bcrypto.ECPoint, len(points))
+	for i, el := range points {
+		ecPoint, err := el.toECPoint(curve)
+		if err != nil {
+			return nil, err
+		}
+		ecPoints[i] = ecPoint
+	}
+	return ecPoints, nil
+}
+
+// unmarshalLocalState decode the json of a local state. crypto.ECPoint decodes a point on the process wide curve of
+// tss-lib, which belongs to the ceremonies running, so the points are decoded on the curve of the algorithm here, and
+// the local state can be read while a ceremony of any algorithm runs
+func unmarshalLocalState(plainText []byte) (KeygenLocalState, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(plainText, &fields); nil != err {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	}
+	var header struct {
+		Algo common.Algo `json:"algo"`
+	}
+	if err := json.Unmarshal(plainText, &header); nil != err {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	}
+	if _, ok := common.GetAlgo(header.Algo); !ok {
+		return KeygenLocalState{}, fmt.Errorf("unknown algorithm(%s) in KeygenLocalState", header.Algo)
+	}
+	var err error
+	var bigXj, eddsaBigXj []*jsonPoint
+	var ecdsaPub, eddsaPub *jsonPoint
+	if fields["local_data"], bigXj, ecdsaPub, err = takePoints(fields["local_data"], "ECDSAPub"); err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal the local data of KeygenLocalState: %w", err)
+	}
+	if fields["eddsa_local_data"], eddsaBigXj, eddsaPub, err = takePoints(fields["eddsa_local_data"], "EDDSAPub"); err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal the EdDSA local data of KeygenLocalState: %w", err)
+	}
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	}
 	var localState KeygenLocalState
-	if err := json.Unmarshal(plainText, &localState); nil != err {
+	if err := json.Unmarshal(buf, &localState); nil != err {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	}
+	ecdsaCurve := common.ECDSA.Curve()
+	if localState.LocalData.BigXj, err = toECPoints(ecdsaCurve, bigXj); err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+	}
+	if localState.LocalData.ECDSAPub, err = ecdsaPub.toECPoint(ecdsaCurve); err != nil {
 		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
 	}
+	if localState.EdDSALocalData != nil {
+		eddsaCurve := common.EdDSA.Curve()
+		if localState.EdDSALocalData.BigXj, err = toECPoints(eddsaCurve, eddsaBigXj); err != nil {
+			return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+		}
+		if localState.EdDSALocalData.EDDSAPub, err = eddsaPub.toECPoint(eddsaCurve); err != nil {
+			return KeygenLocalState{}, fmt.Errorf("fail to unmarshal KeygenLocalState: %w", err)
+		}
+	}
 	return localState, nil
 }
 
-func (fsm *FileStateMgr) SaveAddressBook(address map[peer.ID]addr.AddrList) error {
+func (fsm *FileStateMgr) SaveAddressBook(addressBook *p2p.AddressBook) error {
 	if len(fsm.folder) < 1 {
 		return errors.New("base file path is invalid")
 	}
-	filePathName := filepath.Join(fsm.folder, "address_book.seed")
-	var buf bytes.Buffer
-
-	for peer, addrs := range address {
-		for _, addr := range addrs {
-			// we do not save the loopback addr
-			if strings.Contains(addr.String(), "127.0.0.1") {
-				continue
-			}
-			record := addr.String() + "/p2p/" + peer.String() + "\n"
-			_, err := buf.WriteString(record)
-			if err != nil {
-				return errors.New("fail to write the record to buffer")
-			}
-		}
+	buf, err := json.Marshal(addressBook)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the address book: %w", err)
 	}
 	fsm.writeLock.Lock()
 	defer fsm.writeLock.Unlock()
-	return ioutil.WriteFile(filePathName, buf.Bytes(), 0o655)
+	return writeFileAtomic(filepath.Join(fsm.folder, addressBookFileName), buf)
 }
 
-func (fsm *FileStateMgr) RetrieveP2PAddresses() (addr.AddrList, error) {
+func (fsm *FileStateMgr) GetAddressBook() (*p2p.AddressBook, error) {
 	if len(fsm.folder) < 1 {
 		return nil, errors.New("base file path is invalid")
 	}
-	filePathName := filepath.Join(fsm.folder, "address_book.seed")
-
-	_, err := os.Stat(filePathName)
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
+	input, err := ioutil.ReadFile(filepath.Join(fsm.folder, addressBookFileName))
+	if err == nil {
+		addressBook := p2p.NewAddressBook()
+		if err := json.Unmarshal(input, addressBook); err != nil {
+			return nil, fmt.Errorf("fail to unmarshal the address book: %w", err)
+		}
+		return addressBook, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("fail to read the address book: %w", err)
+	}
+	// the address book saved before the peers are scored is imported as seen when the file is written
+	legacyFilePathName := filepath.Join(fsm.folder, legacyAddressBookFileName)
+	fi, err := os.Stat(legacyFilePathName)
 	if err != nil {
-		return nil, err
+		if os.IsNotExist(err) {
+			return p2p.NewAddressBook(), nil
+		}
+		return nil, fmt.Errorf("fail to read the address book: %w", err)
 	}
-	fsm.writeLock.RLock()
-	input, err := ioutil.ReadFile(filePathName)
+	input, err = ioutil.ReadFile(legacyFilePathName)
 	if err != nil {
-		fsm.writeLock.RUnlock()
-		return nil, err
+		return nil, fmt.Errorf("fail to read the address book: %w", err)
 	}
-	fsm.writeLock.RUnlock()
+	return decodeLegacyAddressBook(input, fi.ModTime())
+}
+
+// decodeLegacyAddressBook parse the address book saved as one multiaddr per line before the peers are scored,
+// all the peers are recorded as seen at the given time
+func decodeLegacyAddressBook(input []byte, seen time.Time) (*p2p.AddressBook, error) {
 	data := strings.Split(string(input), "\n")
 	var peerAddresses []ma.Multiaddr
 	for _, el := range data {
@@ -183,5 +934,312 @@ func (fsm *FileStateMgr) RetrieveP2PAddresses() (addr.AddrList, error) {
 		}
 		peerAddresses = append(peerAddresses, addr)
 	}
-	return peerAddresses, nil
+	infos, err := peer.AddrInfosFromP2pAddrs(peerAddresses...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address in address book %w", err)
+	}
+	addressBook := p2p.NewAddressBook()
+	for _, el := range infos {
+		addressBook.Seen(el.ID, el.Addrs, seen)
+	}
+	return addressBook, nil
+}
+
+func (fsm *FileStateMgr) readPreSignatures(filePathName string) ([]PreSignature, error) {
+	loadedData, err := ioutil.ReadFile(filePathName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	plainText, err := common.AESDecrypt(loadedData, fsm.sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt the presignatures: %w", err)
+	}
+	var preSignatures []PreSignature
+	if err := json.Unmarshal(plainText, &preSignatures); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the presignatures: %w", err)
+	}
+	return preSignatures, nil
+}
+
+func (fsm *FileStateMgr) writePreSignatures(filePathName string, preSignatures []PreSignature) error {
+	if len(preSignatures) == 0 {
+		if err := os.Remove(filePathName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to remove the presignatures file: %w", err)
+		}
+		return nil
+	}
+	buf, err := json.Marshal(preSignatures)
+	if err != nil {
+		return fmt.Errorf("fail to marshal the presignatures to json: %w", err)
+	}
+	encryptedData, err := common.AESEncrypt(buf, fsm.sk)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePathName, encryptedData)
+}
+
+// SavePreSignatures add the presignatures to the encrypted presignature file of the pool
+func (fsm *FileStateMgr) SavePreSignatures(pubKey string, preSignatures []PreSignature) error {
+	filePathName, err := fsm.getPoolFilePathName("presign", pubKey)
+	if err != nil {
+		return err
+	}
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	saved, err := fsm.readPreSignatures(filePathName)
+	if err != nil {
+		return err
+	}
+	return fsm.writePreSignatures(filePathName, append(saved, preSignatures...))
+}
+
+// TakePreSignatures remove the presignatures of the given ids and signers from the presignature file of the pool
+// and return them, the presignatures are removed before they are used as they can only be used once
+func (fsm *FileStateMgr) TakePreSignatures(pubKey string, signers []string, ids []string) ([]PreSignature, error) {
+	filePathName, err := fsm.getPoolFilePathName("presign", pubKey)
+	if err != nil {
+		return nil, err
+	}
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	saved, err := fsm.readPreSignatures(filePathName)
+	if err != nil {
+		return nil, err
+	}
+	taken, left := PickPreSignatures(saved, signers, ids)
+	if len(taken) == 0 {
+		return nil, nil
+	}
+	if err := fsm.writePreSignatures(filePathName, left); err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
+
+// ListLocalStates return the pub keys of the pools that have a local state file in the folder
+func (fsm *FileStateMgr) ListLocalStates() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(fsm.folder, "localstate-*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to list the local state files: %w", err)
+	}
+	pubKeys := make([]string, len(files))
+	for i, el := range files {
+		pubKeys[i] = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(el), "localstate-"), ".json")
+	}
+	return pubKeys, nil
+}
+
+// RetireLocalState remove the local state file and the presignature file of the pool, the encrypted local state
+// is kept in the retired-<unix time>-<pubkey>.json file, and its history files are renamed to
+// retired-<unix time>-history-<generation>-<pubkey>.json
+func (fsm *FileStateMgr) RetireLocalState(pubKey string) error {
+	filePathName, err := fsm.getFilePathName(pubKey)
+	if err != nil {
+		return err
+	}
+	retiredAt := time.Now().Unix()
+	tombstoneFilePathName, err := fsm.getPoolFilePathName(fmt.Sprintf("retired-%d", retiredAt), pubKey)
+	if err != nil {
+		return err
+	}
+	preSignFilePathName, err := fsm.getPoolFilePathName("presign", pubKey)
+	if err != nil {
+		return err
+	}
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	loadedData, err := ioutil.ReadFile(filePathName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrLocalStateNotFound, pubKey)
+		}
+		return fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	sealedData, err := fsm.sealLocalStateFile(loadedData)
+	if err != nil {
+		if !errors.Is(err, ErrCorruptedFile) {
+			return err
+		}
+		// the corrupted file only has the encrypted content, it is kept as it is
+		sealedData = loadedData
+	}
+	if err := writeFileAtomic(tombstoneFilePathName, sealedData); err != nil {
+		return fmt.Errorf("fail to write the retired local state: %w", err)
+	}
+	// the history goes with the tombstone, so the generations of the retired pool are no longer found
+	generations, err := fsm.listGenerations(pubKey)
+	if err != nil {
+		return err
+	}
+	for _, generation := range generations {
+		generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generation)
+		if err != nil {
+			return err
+		}
+		retiredFilePathName, err := fsm.getPoolFilePathName(fmt.Sprintf("retired-%d-history-%d", retiredAt, generation), pubKey)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(generationFilePathName, retiredFilePathName); err != nil {
+			return fmt.Errorf("fail to retire the generation %d of the local state: %w", generation, err)
+		}
+	}
+	if err := os.Remove(filePathName); err != nil {
+		return fmt.Errorf("fail to remove the local state file: %w", err)
+	}
+	pinnedFilePathName, err := fsm.getPinnedFilePathName(pubKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(pinnedFilePathName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fail to remove the pinned generation file: %w", err)
+	}
+	if err := os.Remove(preSignFilePathName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fail to remove the presignatures file: %w", err)
+	}
+	return nil
+}
+
+// GetLocalStateHistory return the generations of the local state of the pool kept in the history files
+func (fsm *FileStateMgr) GetLocalStateHistory(pubKey string) ([]LocalStateVersion, error) {
+	filePathName, err := fsm.getFilePathName(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
+	currentData, err := ioutil.ReadFile(filePathName)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	generations, err := fsm.listGenerations(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	var currentPlainText []byte
+	if currentData != nil {
+		if currentPlainText, err = fsm.openLocalStateFile(currentData); err != nil {
+			return nil, err
+		}
+	}
+	plainTexts := make([][]byte, len(generations))
+	for i, generation := range generations {
+		generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generation)
+		if err != nil {
+			return nil, err
+		}
+		loadedData, err := ioutil.ReadFile(generationFilePathName)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read from file(%s): %w", generationFilePathName, err)
+		}
+		if plainTexts[i], err = fsm.openLocalStateFile(loadedData); err != nil {
+			return nil, err
+		}
+	}
+	return buildLocalStateHistory(pubKey, currentPlainText, generations, plainTexts)
+}
+
+// PinLocalState copy the history file of the given generation over the local state file of the pool, the
+// presignatures of the pool are removed as they are generated with the share that is replaced
+func (fsm *FileStateMgr) PinLocalState(pubKey string, generation int64) error {
+	filePathName, err := fsm.getFilePathName(pubKey)
+	if err != nil {
+		return err
+	}
+	preSignFilePathName, err := fsm.getPoolFilePathName("presign", pubKey)
+	if err != nil {
+		return err
+	}
+	generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generation)
+	if err != nil {
+		return err
+	}
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	loadedData, err := ioutil.ReadFile(generationFilePathName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: generation %d of %s", ErrLocalStateNotFound, generation, pubKey)
+		}
+		return fmt.Errorf("fail to read from file(%s): %w", generationFilePathName, err)
+	}
+	if err := os.Remove(preSignFilePathName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fail to remove the presignatures file: %w", err)
+	}
+	sealedData, err := fsm.sealLocalStateFile(loadedData)
+	if err != nil {
+		return err
+	}
+	if err := fsm.writePinnedGeneration(pubKey, generation); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePathName, sealedData)
+}
+
+// encodePreParams return the encrypted json of the pre-parameters
+func encodePreParams(preParams []*keygen.LocalPreParams, sk []byte) ([]byte, error) {
+	buf, err := json.Marshal(preParams)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal the pre-parameters to json: %w", err)
+	}
+	encryptedData, err := common.AESEncrypt(buf, sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to encrypt the pre-parameters: %w", err)
+	}
+	return encryptedData, nil
+}
+
+// decodePreParams decrypt the pre-parameters encoded by encodePreParams
+func decodePreParams(loadedData, sk []byte) ([]*keygen.LocalPreParams, error) {
+	plainText, err := common.AESDecrypt(loadedData, sk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt the pre-parameters: %w", err)
+	}
+	var preParams []*keygen.LocalPreParams
+	if err := json.Unmarshal(plainText, &preParams); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the pre-parameters: %w", err)
+	}
+	return preParams, nil
+}
+
+func (fsm *FileStateMgr) getPreParamsFilePathName() string {
+	return filepath.Join(fsm.folder, preParamsFileName)
+}
+
+// SavePreParams save the encrypted pre-parameters to the preparams.dat file, the file is removed when there is
+// no pre-parameters
+func (fsm *FileStateMgr) SavePreParams(preParams []*keygen.LocalPreParams) error {
+	filePathName := fsm.getPreParamsFilePathName()
+	fsm.writeLock.Lock()
+	defer fsm.writeLock.Unlock()
+	if len(preParams) == 0 {
+		if err := os.Remove(filePathName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to remove the pre-parameters file: %w", err)
+		}
+		return nil
+	}
+	encryptedData, err := encodePreParams(preParams, fsm.sk)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePathName, encryptedData)
+}
+
+// GetPreParams read the pre-parameters from the preparams.dat file
+func (fsm *FileStateMgr) GetPreParams() ([]*keygen.LocalPreParams, error) {
+	filePathName := fsm.getPreParamsFilePathName()
+	fsm.writeLock.RLock()
+	defer fsm.writeLock.RUnlock()
+	loadedData, err := ioutil.ReadFile(filePathName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read from file(%s): %w", filePathName, err)
+	}
+	return decodePreParams(loadedData, fsm.sk)
 }
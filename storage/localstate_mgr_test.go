@@ -1,20 +1,30 @@
 package storage
 
 import (
+	"encoding/json"
+	"fmt"
 	"golang.org/x/crypto/sha3"
+	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
 	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	eddsakeygen "github.com/binance-chain/tss-lib/eddsa/keygen"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-peerstore/addr"
 	tnet "github.com/libp2p/go-libp2p-testing/net"
 	maddr "github.com/multiformats/go-multiaddr"
 	. "gopkg.in/check.v1"
 
+	"gitlab.com/thorchain/tss/go-tss/common"
 	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
 )
 
 type FileStateMgrTestSuite struct{}
@@ -85,7 +95,7 @@ func (s *FileStateMgrTestSuite) TestSaveLocalState(c *C) {
 }
 
 func (s *FileStateMgrTestSuite) TestSaveAddressBook(c *C) {
-	testAddresses := make(map[peer.ID]addr.AddrList)
+	addressBook := p2p.NewAddressBook()
 	password := "my password!"
 	h := sha3.New256()
 	h.Write([]byte(password))
@@ -98,9 +108,12 @@ func (s *FileStateMgrTestSuite) TestSaveAddressBook(c *C) {
 	mockAddr, err := maddr.NewMultiaddr("/ip4/192.168.3.5/tcp/6668")
 	c.Assert(err, IsNil)
 	peers := []peer.ID{id1.ID(), id2.ID(), id3.ID()}
+	now := time.Now().UTC().Round(0)
 	for _, each := range peers {
-		testAddresses[each] = []maddr.Multiaddr{mockAddr}
+		addressBook.Seen(each, []maddr.Multiaddr{mockAddr}, now)
 	}
+	addressBook.RecordDial(id1.ID(), true, now)
+	addressBook.RecordDial(id2.ID(), false, now)
 	folder := os.TempDir()
 	f := filepath.Join(folder, "test")
 	defer func() {
@@ -110,11 +123,464 @@ func (s *FileStateMgrTestSuite) TestSaveAddressBook(c *C) {
 	fsm, err := NewFileStateMgr(f, sk)
 	c.Assert(err, IsNil)
 	c.Assert(fsm, NotNil)
-	c.Assert(fsm.SaveAddressBook(testAddresses), IsNil)
-	filePathName := filepath.Join(f, "address_book.seed")
+	item, err := fsm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(item.Len(), Equals, 0)
+
+	// the address book saved before the peers are scored is imported
+	legacyFilePathName := filepath.Join(f, "address_book.seed")
+	c.Assert(ioutil.WriteFile(legacyFilePathName, []byte(mockAddr.String()+"/p2p/"+id1.ID().String()+"\n"), 0o600), IsNil)
+	item, err = fsm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(item.Len(), Equals, 1)
+	record, ok := item.Get(id1.ID())
+	c.Assert(ok, Equals, true)
+	c.Assert(record.Addrs, DeepEquals, []string{mockAddr.String()})
+
+	c.Assert(fsm.SaveAddressBook(addressBook), IsNil)
+	filePathName := filepath.Join(f, "address_book.json")
+	_, err = os.Stat(filePathName)
+	c.Assert(err, IsNil)
+	item, err = fsm.GetAddressBook()
+	c.Assert(err, IsNil)
+	c.Assert(item.Len(), Equals, 3)
+	record, ok = item.Get(id1.ID())
+	c.Assert(ok, Equals, true)
+	c.Assert(record.Successes, Equals, 1)
+	record, ok = item.Get(id2.ID())
+	c.Assert(ok, Equals, true)
+	c.Assert(record.Failures, Equals, 1)
+}
+
+func (s *FileStateMgrTestSuite) TestPreSignatures(c *C) {
+	password := "my password!"
+	h := sha3.New256()
+	h.Write([]byte(password))
+	sk := h.Sum(nil)
+	folder := os.TempDir()
+	f := filepath.Join(folder, "test", "presign")
+	defer func() {
+		err := os.RemoveAll(f)
+		c.Assert(err, IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	pubKey := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	taken, err := fsm.TakePreSignatures(pubKey, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+
+	preSignatures := []PreSignature{
+		{ID: "3", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{3}}},
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}
+	c.Assert(fsm.SavePreSignatures(pubKey, preSignatures), IsNil)
+	c.Assert(fsm.SavePreSignatures(pubKey, []PreSignature{
+		{ID: "2", Signers: []string{"A", "C"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{2}}},
+	}), IsNil)
+	filePathName := filepath.Join(f, "presign-"+pubKey+".json")
 	_, err = os.Stat(filePathName)
 	c.Assert(err, IsNil)
-	item, err := fsm.RetrieveP2PAddresses()
+
+	// the presignature 2 is generated by other signers
+	taken, err = fsm.TakePreSignatures(pubKey, []string{"B", "A"}, []string{"1", "2", "3"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+
+	taken, err = fsm.TakePreSignatures(pubKey, []string{"B", "A"}, []string{"1", "3"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 2)
+	c.Assert(taken[0].ID, Equals, "1")
+	c.Assert(taken[0].Data.KI, DeepEquals, []byte{1})
+	c.Assert(taken[1].ID, Equals, "3")
+	// the presignatures can only be taken once
+	taken, err = fsm.TakePreSignatures(pubKey, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+
+	taken, err = fsm.TakePreSignatures(pubKey, []string{"A", "C"}, []string{"2"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	c.Assert(taken[0].ID, Equals, "2")
+	_, err = os.Stat(filePathName)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FileStateMgrTestSuite) TestRetireLocalState(c *C) {
+	sk := getTestStateKey()
+	f, err := ioutil.TempDir("", "retire")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	pubKeys, err := fsm.ListLocalStates()
 	c.Assert(err, IsNil)
-	c.Assert(item, HasLen, 3)
+	c.Assert(pubKeys, HasLen, 0)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey1)), IsNil)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey2)), IsNil)
+	c.Assert(fsm.SavePreSignatures(pubKey2, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	pubKeys, err = fsm.ListLocalStates()
+	c.Assert(err, IsNil)
+	c.Assert(pubKeys, DeepEquals, []string{pubKey1, pubKey2})
+
+	info := getTestLocalState(pubKey2).GetInfo()
+	c.Assert(info.PubKey, Equals, pubKey2)
+	c.Assert(info.Threshold, Equals, 2)
+
+	c.Assert(fsm.RetireLocalState("whatever"), NotNil)
+	c.Assert(fsm.RetireLocalState(pubKey2), IsNil)
+	err = fsm.RetireLocalState(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	_, err = fsm.GetLocalState(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	taken, err := fsm.TakePreSignatures(pubKey2, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+	pubKeys, err = fsm.ListLocalStates()
+	c.Assert(err, IsNil)
+	c.Assert(pubKeys, DeepEquals, []string{pubKey1})
+	// the history of the retired pool is gone along with it
+	_, err = fsm.GetLocalStateHistory(pubKey2)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	c.Assert(IsLocalStateNotFound(fsm.PinLocalState(pubKey2, 1)), Equals, true)
+	_, err = fsm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+
+	// the tombstone is the encrypted local state, the history files are kept next to it
+	files, err := filepath.Glob(filepath.Join(f, "retired-*-"+pubKey2+".json"))
+	c.Assert(err, IsNil)
+	var tombstones, retiredHistory []string
+	for _, el := range files {
+		if strings.Contains(filepath.Base(el), "-history-") {
+			retiredHistory = append(retiredHistory, el)
+			continue
+		}
+		tombstones = append(tombstones, el)
+	}
+	c.Assert(tombstones, HasLen, 1)
+	c.Assert(retiredHistory, HasLen, 1)
+	buf, err := ioutil.ReadFile(tombstones[0])
+	c.Assert(err, IsNil)
+	plainText, err := fsm.openLocalStateFile(buf)
+	c.Assert(err, IsNil)
+	item, err := unmarshalLocalState(plainText)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(getTestLocalState(pubKey2), item), Equals, true)
+}
+
+// checkLocalStateHistory check the history of the local state kept by the given manager
+func checkLocalStateHistory(c *C, mgr LocalStateManager) {
+	pubKey := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	_, err := mgr.GetLocalStateHistory(pubKey)
+	c.Assert(IsLocalStateNotFound(err), Equals, true)
+	for i := 1; i <= 3; i++ {
+		state := getTestLocalState(pubKey)
+		state.BlockHeight = int64(i * 10)
+		state.MsgID = fmt.Sprintf("msg%d", i)
+		c.Assert(mgr.SaveLocalState(state), IsNil)
+	}
+	versions, err := mgr.GetLocalStateHistory(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []LocalStateVersion{
+		{Generation: 1, BlockHeight: 10, MsgID: "msg1"},
+		{Generation: 2, BlockHeight: 20, MsgID: "msg2"},
+		{Generation: 3, BlockHeight: 30, MsgID: "msg3", Pinned: true},
+	})
+
+	c.Assert(mgr.SavePreSignatures(pubKey, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	c.Assert(IsLocalStateNotFound(mgr.PinLocalState(pubKey, 4)), Equals, true)
+	c.Assert(mgr.PinLocalState(pubKey, 2), IsNil)
+	item, err := mgr.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg2")
+	// the presignatures of the replaced share are removed
+	taken, err := mgr.TakePreSignatures(pubKey, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, IsNil)
+	versions, err = mgr.GetLocalStateHistory(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(versions[1].Pinned, Equals, true)
+	c.Assert(versions[2].Pinned, Equals, false)
+
+	// a new share is always saved as the latest generation
+	state := getTestLocalState(pubKey)
+	state.MsgID = "msg4"
+	c.Assert(mgr.SaveLocalState(state), IsNil)
+	versions, err = mgr.GetLocalStateHistory(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 4)
+	c.Assert(versions[3].Generation, Equals, int64(4))
+	c.Assert(versions[3].Pinned, Equals, true)
+}
+
+func (s *FileStateMgrTestSuite) TestLocalStateHistory(c *C) {
+	sk := getTestStateKey()
+	f, err := ioutil.TempDir("", "history")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	checkLocalStateHistory(c, fsm)
+
+	// the share saved before the history is kept becomes the first generation
+	pubKey := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	buf, err := json.Marshal(getTestLocalState(pubKey))
+	c.Assert(err, IsNil)
+	filePathName, err := fsm.getFilePathName(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filePathName, buf, 0o600), IsNil)
+	versions, err := fsm.GetLocalStateHistory(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []LocalStateVersion{{Generation: 0, Pinned: true}})
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey)), IsNil)
+	versions, err = fsm.GetLocalStateHistory(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 2)
+	c.Assert(versions[1].Pinned, Equals, true)
+	c.Assert(fsm.PinLocalState(pubKey, 1), IsNil)
+	item, err := fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(getTestLocalState(pubKey), item), Equals, true)
+}
+
+func (s *FileStateMgrTestSuite) TestSaveLocalStates(c *C) {
+	sk := getTestStateKey()
+	f, err := ioutil.TempDir("", "batch")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	var _ BatchLocalStateManager = fsm
+	var _ BatchLocalStateManager = &LevelDBStateMgr{}
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	c.Assert(fsm.SaveLocalStates(getTestLocalState(pubKey1), getTestLocalState(pubKey2)), IsNil)
+	_, err = os.Stat(filepath.Join(f, batchFileName))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	for _, el := range []string{pubKey1, pubKey2} {
+		item, err := fsm.GetLocalState(el)
+		c.Assert(err, IsNil)
+		c.Assert(reflect.DeepEqual(getTestLocalState(el), item), Equals, true)
+	}
+	c.Assert(fsm.SaveLocalStates(KeygenLocalState{PubKey: "../invalid"}), NotNil)
+
+	// the batch recorded before the node stops is completed when the FileStateMgr is created again
+	pubKey3 := "thorpub1addwnpepq2jgpsw2lalzuk7sgtmyakj7l6890f5cfpwjyfp8k4y4t7cw2vk8vcglsjy"
+	state3 := getTestLocalState(pubKey3)
+	state3.LocalPartyKey = "B"
+	buf1, err := json.Marshal(getTestLocalState(pubKey1))
+	c.Assert(err, IsNil)
+	buf3, err := json.Marshal(state3)
+	c.Assert(err, IsNil)
+	buf, err := json.Marshal([]json.RawMessage{buf1, buf3})
+	c.Assert(err, IsNil)
+	encryptedData, err := common.AESEncrypt(buf, sk)
+	c.Assert(err, IsNil)
+	c.Assert(writeFileAtomic(filepath.Join(f, batchFileName), sealEnvelope(append([]byte("enc"), encryptedData...))), IsNil)
+	fsm, err = NewFileStateMgr(f, sk)
+	c.Assert(err, IsNil)
+	_, err = os.Stat(filepath.Join(f, batchFileName))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	item, err := fsm.GetLocalState(pubKey3)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(state3, item), Equals, true)
+	// the local state already saved is not saved again
+	versions, err := fsm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 1)
+}
+
+func (s *FileStateMgrTestSuite) TestLocalStateBackups(c *C) {
+	f, err := ioutil.TempDir("", "backups")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, getTestStateKey())
+	c.Assert(err, IsNil)
+	fsm.SetBackups(2)
+	pubKey := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	filePathName, err := fsm.getFilePathName(pubKey)
+	c.Assert(err, IsNil)
+	for i := 1; i <= 4; i++ {
+		state := getTestLocalState(pubKey)
+		state.MsgID = fmt.Sprintf("msg%d", i)
+		c.Assert(fsm.SaveLocalState(state), IsNil)
+	}
+	info, err := os.Stat(filePathName)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0o600))
+
+	// the history keeps the pinned generation and as many older ones as the backups
+	generations, err := fsm.listGenerations(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(generations, DeepEquals, []int64{2, 3, 4})
+
+	// the pinned generation is used when the local state file is corrupted
+	corrupt := func(filePathName string) {
+		buf, err := ioutil.ReadFile(filePathName)
+		c.Assert(err, IsNil)
+		buf[len(buf)-1] ^= 0xff
+		c.Assert(ioutil.WriteFile(filePathName, buf, 0o600), IsNil)
+	}
+	corrupt(filePathName)
+	item, err := fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg4")
+
+	c.Assert(fsm.PinLocalState(pubKey, 3), IsNil)
+	corrupt(filePathName)
+	item, err = fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg3")
+
+	// the older generations are used when the pinned one is corrupted as well, never the newer ones
+	generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, 3)
+	c.Assert(err, IsNil)
+	corrupt(generationFilePathName)
+	item, err = fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg2")
+
+	// no local state is returned when all the generations from the pinned one back are corrupted
+	generationFilePathName, err = fsm.getGenerationFilePathName(pubKey, 2)
+	c.Assert(err, IsNil)
+	corrupt(generationFilePathName)
+	_, err = fsm.GetLocalState(pubKey)
+	c.Assert(err, NotNil)
+
+	// the generations are tried from the newest when the pinned generation is unknown
+	c.Assert(fsm.PinLocalState(pubKey, 4), IsNil)
+	corrupt(filePathName)
+	pinnedFilePathName, err := fsm.getPinnedFilePathName(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(os.Remove(pinnedFilePathName), IsNil)
+	item, err = fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg4")
+
+	// the pinned generation is removed with the retired local state
+	c.Assert(fsm.PinLocalState(pubKey, 4), IsNil)
+	c.Assert(fsm.RetireLocalState(pubKey), IsNil)
+	_, err = os.Stat(pinnedFilePathName)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+// checkPreParams check the pre-parameters saved by the given manager
+func checkPreParams(c *C, mgr LocalStateManager) {
+	preParams, err := mgr.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(preParams, HasLen, 0)
+	saved := []*keygen.LocalPreParams{
+		{NTildei: big.NewInt(1), H1i: big.NewInt(2), H2i: big.NewInt(3)},
+		{NTildei: big.NewInt(4), H1i: big.NewInt(5), H2i: big.NewInt(6)},
+	}
+	c.Assert(mgr.SavePreParams(saved), IsNil)
+	preParams, err = mgr.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(preParams, HasLen, 2)
+	c.Assert(preParams[1].H2i.Int64(), Equals, int64(6))
+	c.Assert(mgr.SavePreParams(nil), IsNil)
+	preParams, err = mgr.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(preParams, HasLen, 0)
+}
+
+func (s *FileStateMgrTestSuite) TestInterruptedLocalStateWrite(c *C) {
+	f, err := ioutil.TempDir("", "interrupted")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	fsm, err := NewFileStateMgr(f, getTestStateKey())
+	c.Assert(err, IsNil)
+	pubKey := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	filePathName, err := fsm.getFilePathName(pubKey)
+	c.Assert(err, IsNil)
+	for i := 1; i <= 2; i++ {
+		state := getTestLocalState(pubKey)
+		state.MsgID = fmt.Sprintf("msg%d", i)
+		c.Assert(fsm.SaveLocalState(state), IsNil)
+	}
+	// the node stops after the generation is pinned and before the local state file is written
+	writeGenerationFile := func(generation int64) {
+		generationFilePathName, err := fsm.getGenerationFilePathName(pubKey, generation)
+		c.Assert(err, IsNil)
+		buf, err := ioutil.ReadFile(generationFilePathName)
+		c.Assert(err, IsNil)
+		c.Assert(ioutil.WriteFile(filePathName, buf, 0o600), IsNil)
+	}
+	writeGenerationFile(1)
+	item, err := fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg2")
+
+	c.Assert(fsm.PinLocalState(pubKey, 1), IsNil)
+	writeGenerationFile(2)
+	item, err = fsm.GetLocalState(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(item.MsgID, Equals, "msg1")
+}
+
+func (s *FileStateMgrTestSuite) TestPreParams(c *C) {
+	fsm, err := NewFileStateMgr(c.MkDir(), getTestStateKey())
+	c.Assert(err, IsNil)
+	checkPreParams(c, fsm)
+}
+
+func (s *FileStateMgrTestSuite) TestUnmarshalLocalStateWithOtherCurve(c *C) {
+	ecdsaData, err := ioutil.ReadFile("../test_data/keysign_data/0.json")
+	c.Assert(err, IsNil)
+	eddsaLocalData := eddsakeygen.NewLocalPartySaveData(1)
+	eddsaLocalData.Ks[0] = big.NewInt(1)
+	eddsaLocalData.BigXj[0] = bcrypto.ScalarBaseMult(common.EdDSA.Curve(), big.NewInt(7))
+	eddsaLocalData.EDDSAPub = bcrypto.ScalarBaseMult(common.EdDSA.Curve(), big.NewInt(11))
+	eddsaData, err := json.Marshal(KeygenLocalState{
+		PubKey:         "whatever",
+		Algo:           common.EdDSA,
+		EdDSALocalData: &eddsaLocalData,
+	})
+	c.Assert(err, IsNil)
+
+	// the local states are decoded on the curve of their algorithm, whichever curve the ceremonies hold
+	for _, algo := range []common.Algo{common.ECDSA, common.EdDSA} {
+		common.AcquireCurve(algo)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			state, err := unmarshalLocalState(ecdsaData)
+			c.Check(err, IsNil)
+			c.Check(state.Verify().Valid, Equals, true)
+			state, err = unmarshalLocalState(eddsaData)
+			c.Check(err, IsNil)
+			c.Check(state.EdDSALocalData.BigXj[0].Equals(eddsaLocalData.BigXj[0]), Equals, true)
+			c.Check(state.EdDSALocalData.EDDSAPub.Equals(eddsaLocalData.EDDSAPub), Equals, true)
+			c.Check(state.LocalData.ECDSAPub, IsNil)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			c.Fatalf("the local state is not decoded while the %s curve is held", algo)
+		}
+		common.ReleaseCurve()
+	}
+
+	// a point off the curve of the algorithm is rejected
+	eddsaLocalData.EDDSAPub = bcrypto.ScalarBaseMult(common.ECDSA.Curve(), big.NewInt(11))
+	eddsaData, err = json.Marshal(KeygenLocalState{PubKey: "whatever", Algo: common.EdDSA, EdDSALocalData: &eddsaLocalData})
+	c.Assert(err, IsNil)
+	_, err = unmarshalLocalState(eddsaData)
+	c.Assert(err, NotNil)
 }
@@ -1,8 +1,9 @@
 package storage
 
 import (
-	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-peerstore/addr"
+	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+
+	"gitlab.com/thorchain/tss/go-tss/p2p"
 )
 
 // MockLocalStateManager is a mock use for test purpose
@@ -17,10 +18,42 @@ func (s *MockLocalStateManager) GetLocalState(pubKey string) (KeygenLocalState,
 	return KeygenLocalState{}, nil
 }
 
-func (s *MockLocalStateManager) SaveAddressBook(address map[peer.ID]addr.AddrList) error {
+func (s *MockLocalStateManager) SaveAddressBook(addressBook *p2p.AddressBook) error {
+	return nil
+}
+
+func (s *MockLocalStateManager) GetAddressBook() (*p2p.AddressBook, error) {
+	return p2p.NewAddressBook(), nil
+}
+
+func (s *MockLocalStateManager) SavePreSignatures(pubKey string, preSignatures []PreSignature) error {
+	return nil
+}
+
+func (s *MockLocalStateManager) TakePreSignatures(pubKey string, signers []string, ids []string) ([]PreSignature, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) ListLocalStates() ([]string, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) SavePreParams(preParams []*keygen.LocalPreParams) error {
 	return nil
 }
 
-func (s *MockLocalStateManager) RetrieveP2PAddresses() (addr.AddrList, error) {
+func (s *MockLocalStateManager) GetPreParams() ([]*keygen.LocalPreParams, error) {
+	return nil, nil
+}
+
+func (s *MockLocalStateManager) GetLocalStateHistory(pubKey string) ([]LocalStateVersion, error) {
 	return nil, nil
 }
+
+func (s *MockLocalStateManager) PinLocalState(pubKey string, generation int64) error {
+	return nil
+}
+
+func (s *MockLocalStateManager) RetireLocalState(pubKey string) error {
+	return nil
+}
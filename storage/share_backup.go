@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+const (
+	// ShareBackupType marks the files that carry the backup of one share
+	ShareBackupType = "go-tss-share-backup"
+	// ShareBackupVersion is the version of the share backups created by NewShareBackup
+	ShareBackupVersion = 1
+)
+
+// ShareBackup is the portable backup of the share of one node in one pool, the share is encrypted with the key
+// derived from the backup passphrase, the rest is only there to tell the backups apart
+type ShareBackup struct {
+	Type          string         `json:"type"`
+	Version       int            `json:"version"`
+	PubKey        string         `json:"pub_key"`
+	LocalPartyKey string         `json:"local_party_key"`
+	Algo          common.Algo    `json:"algo"`
+	KeyHeader     StateKeyHeader `json:"key_header"`
+	Data          []byte         `json:"data"`
+}
+
+// NewShareBackup create the backup of the given share encrypted with the passphrase, only a valid share is
+// backed up
+func NewShareBackup(localState KeygenLocalState, passphrase string) ([]byte, error) {
+	if result := localState.Verify(); !result.Valid {
+		return nil, fmt.Errorf("the share of %s is invalid: %s", localState.PubKey, strings.Join(result.Errors, "; "))
+	}
+	header, err := NewStateKeyHeader()
+	if err != nil {
+		return nil, err
+	}
+	key, err := header.DeriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := json.Marshal(localState)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal the local state: %w", err)
+	}
+	data, err := common.AESEncrypt(plainText, key)
+	if err != nil {
+		return nil, fmt.Errorf("fail to encrypt the local state: %w", err)
+	}
+	buf, err := json.MarshalIndent(ShareBackup{
+		Type:          ShareBackupType,
+		Version:       ShareBackupVersion,
+		PubKey:        localState.PubKey,
+		LocalPartyKey: localState.LocalPartyKey,
+		Algo:          localState.GetAlgo(),
+		KeyHeader:     header,
+		Data:          data,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal the share backup: %w", err)
+	}
+	return buf, nil
+}
+
+// OpenShareBackup decrypt the share in the backup with the passphrase, the share is only returned when it is
+// valid for its pool
+func OpenShareBackup(buf []byte, passphrase string) (KeygenLocalState, error) {
+	var backup ShareBackup
+	if err := json.Unmarshal(buf, &backup); err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to unmarshal the share backup: %w", err)
+	}
+	if backup.Type != ShareBackupType {
+		return KeygenLocalState{}, errors.New("not a share backup")
+	}
+	if backup.Version != ShareBackupVersion {
+		return KeygenLocalState{}, fmt.Errorf("unsupported share backup version(%d)", backup.Version)
+	}
+	key, err := backup.KeyHeader.DeriveKey(passphrase)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	plainText, err := common.AESDecrypt(backup.Data, key)
+	if err != nil {
+		return KeygenLocalState{}, fmt.Errorf("fail to decrypt the share backup: %w", err)
+	}
+	localState, err := unmarshalLocalState(plainText)
+	if err != nil {
+		return KeygenLocalState{}, err
+	}
+	if localState.PubKey != backup.PubKey || localState.LocalPartyKey != backup.LocalPartyKey {
+		return KeygenLocalState{}, errors.New("the share does not match the header of the backup")
+	}
+	if result := localState.Verify(); !result.Valid {
+		return KeygenLocalState{}, fmt.Errorf("the share of %s is invalid: %s", localState.PubKey, strings.Join(result.Errors, "; "))
+	}
+	return localState, nil
+}
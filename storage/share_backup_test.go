@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"math/big"
+
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+type ShareBackupTestSuite struct{}
+
+var _ = Suite(&ShareBackupTestSuite{})
+
+func (s *ShareBackupTestSuite) SetUpSuite(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func (s *ShareBackupTestSuite) TestShareBackup(c *C) {
+	localState := loadTestLocalState(c, 0)
+	buf, err := NewShareBackup(localState, "the passphrase")
+	c.Assert(err, IsNil)
+
+	var backup ShareBackup
+	c.Assert(json.Unmarshal(buf, &backup), IsNil)
+	c.Assert(backup.Type, Equals, ShareBackupType)
+	c.Assert(backup.Version, Equals, ShareBackupVersion)
+	c.Assert(backup.PubKey, Equals, localState.PubKey)
+	c.Assert(backup.LocalPartyKey, Equals, localState.LocalPartyKey)
+	c.Assert(backup.Algo, Equals, common.ECDSA)
+
+	restored, err := OpenShareBackup(buf, "the passphrase")
+	c.Assert(err, IsNil)
+	c.Assert(restored.PubKey, Equals, localState.PubKey)
+	c.Assert(restored.LocalData.Xi, DeepEquals, localState.LocalData.Xi)
+
+	_, err = OpenShareBackup(buf, "another passphrase")
+	c.Assert(err, ErrorMatches, "wrong passphrase")
+	_, err = OpenShareBackup(buf, "")
+	c.Assert(err, NotNil)
+
+	// the header can not be changed to restore the share as another pool
+	backup.PubKey = "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu3"
+	changed, err := json.Marshal(backup)
+	c.Assert(err, IsNil)
+	_, err = OpenShareBackup(changed, "the passphrase")
+	c.Assert(err, ErrorMatches, "the share does not match the header of the backup")
+
+	backup.Version = ShareBackupVersion + 1
+	changed, err = json.Marshal(backup)
+	c.Assert(err, IsNil)
+	_, err = OpenShareBackup(changed, "the passphrase")
+	c.Assert(err, ErrorMatches, "unsupported share backup version.*")
+
+	_, err = OpenShareBackup([]byte(`{"type":"whatever"}`), "the passphrase")
+	c.Assert(err, ErrorMatches, "not a share backup")
+
+	// an invalid share is not backed up
+	localState.LocalData.Xi = new(big.Int).Add(localState.LocalData.Xi, big.NewInt(1))
+	_, err = NewShareBackup(localState, "the passphrase")
+	c.Assert(err, ErrorMatches, "the share of .* is invalid: .*")
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+
+	tsslibcommon "github.com/binance-chain/tss-lib/common"
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+// ShareVerification is the result of the self verification of the share of a pool
+type ShareVerification struct {
+	PubKey string      `json:"pub_key"`
+	Algo   common.Algo `json:"algo"`
+	Valid  bool        `json:"valid"`
+	// Errors is the mismatches found in the share, it is empty when the share is valid
+	Errors []string `json:"errors,omitempty"`
+}
+
+// shareData is the part of the local data of either algorithm the verification works on
+type shareData struct {
+	xi, shareID *big.Int
+	ks          []*big.Int
+	bigXj       []*bcrypto.ECPoint
+	pub         *bcrypto.ECPoint
+}
+
+func (s KeygenLocalState) getShareData() (shareData, error) {
+	if s.GetAlgo() == common.EdDSA {
+		if s.EdDSALocalData == nil {
+			return shareData{}, errors.New("no EdDSA local data")
+		}
+		data := s.EdDSALocalData
+		return shareData{xi: data.Xi, shareID: data.ShareID, ks: data.Ks, bigXj: data.BigXj, pub: data.EDDSAPub}, nil
+	}
+	data := s.LocalData
+	return shareData{xi: data.Xi, shareID: data.ShareID, ks: data.Ks, bigXj: data.BigXj, pub: data.ECDSAPub}, nil
+}
+
+// Verify check the share is still valid for the pool without running a keysign, that is Xi*G of this party
+// matches its entry in BigXj, all of BigXj interpolate to the pool public key, and the pool public key derives
+// PubKey. All the mismatches found are reported
+func (s KeygenLocalState) Verify() ShareVerification {
+	result := ShareVerification{
+		PubKey: s.PubKey,
+		Algo:   s.GetAlgo(),
+	}
+	for _, err := range s.verify() {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+func (s KeygenLocalState) verify() []error {
+	data, err := s.getShareData()
+	if err != nil {
+		return []error{err}
+	}
+	curve := s.GetAlgo().Curve()
+	var errs []error
+	if err := verifySharePoints(data, len(s.ParticipantKeys)); err != nil {
+		return append(errs, err)
+	}
+	if err := verifyOwnShare(curve, data); err != nil {
+		errs = append(errs, err)
+	}
+	threshold, err := s.GetThreshold()
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := verifyInterpolation(curve, data, threshold); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.verifyPubKey(data.pub); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// verifySharePoints check the indexes and the public shares are complete, the other checks rely on them
+func verifySharePoints(data shareData, parties int) error {
+	if len(data.ks) != parties || len(data.bigXj) != parties {
+		return fmt.Errorf("%d parties with %d indexes and %d public shares", parties, len(data.ks), len(data.bigXj))
+	}
+	seen := make(map[string]bool, len(data.ks))
+	for i, k := range data.ks {
+		if k == nil || k.Sign() == 0 {
+			return fmt.Errorf("the index of party %d is empty", i)
+		}
+		if seen[k.String()] {
+			return fmt.Errorf("the index of party %d is duplicated", i)
+		}
+		seen[k.String()] = true
+		if data.bigXj[i] == nil || !data.bigXj[i].ValidateBasic() {
+			return fmt.Errorf("the public share of party %d is invalid", i)
+		}
+	}
+	if data.pub == nil || !data.pub.ValidateBasic() {
+		return errors.New("the pool public key is invalid")
+	}
+	return nil
+}
+
+// verifyOwnShare check Xi*G matches the entry of this party in BigXj
+func verifyOwnShare(curve elliptic.Curve, data shareData) error {
+	if data.xi == nil || data.shareID == nil {
+		return errors.New("the secret share is empty")
+	}
+	for i, k := range data.ks {
+		if k.Cmp(data.shareID) != 0 {
+			continue
+		}
+		if !bcrypto.ScalarBaseMult(curve, data.xi).Equals(data.bigXj[i]) {
+			return fmt.Errorf("Xi*G does not match the public share of party %d", i)
+		}
+		return nil
+	}
+	return errors.New("the share id is not one of the party indexes")
+}
+
+// verifyInterpolation check the public shares lie on one polynomial of the threshold degree whose value at 0 is
+// the pool public key, the polynomial is interpolated from the first threshold+1 public shares
+func verifyInterpolation(curve elliptic.Curve, data shareData, threshold int) error {
+	if threshold+1 > len(data.ks) {
+		return fmt.Errorf("threshold %d needs more than %d parties", threshold, len(data.ks))
+	}
+	ks := data.ks[:threshold+1]
+	points := data.bigXj[:threshold+1]
+	pub, err := interpolatePoint(curve, ks, points, big.NewInt(0))
+	if err != nil {
+		return err
+	}
+	if !pub.Equals(data.pub) {
+		return errors.New("the public shares do not interpolate to the pool public key")
+	}
+	for i := threshold + 1; i < len(data.ks); i++ {
+		point, err := interpolatePoint(curve, ks, points, data.ks[i])
+		if err != nil {
+			return err
+		}
+		if !point.Equals(data.bigXj[i]) {
+			return fmt.Errorf("the public share of party %d is not consistent with the others", i)
+		}
+	}
+	return nil
+}
+
+// interpolatePoint evaluate at x the polynomial in the exponent that goes through the points at the indexes ks
+func interpolatePoint(curve elliptic.Curve, ks []*big.Int, points []*bcrypto.ECPoint, x *big.Int) (*bcrypto.ECPoint, error) {
+	modN := tsslibcommon.ModInt(curve.Params().N)
+	var result *bcrypto.ECPoint
+	for j, kj := range ks {
+		coefficient := big.NewInt(1)
+		for m, km := range ks {
+			if m == j {
+				continue
+			}
+			coefficient = modN.Mul(coefficient, modN.Sub(x, km))
+			coefficient = modN.Mul(coefficient, modN.Inverse(modN.Sub(kj, km)))
+		}
+		term := points[j].ScalarMult(coefficient)
+		if result == nil {
+			result = term
+			continue
+		}
+		sum, err := result.Add(term)
+		if err != nil {
+			return nil, fmt.Errorf("fail to add the public shares: %w", err)
+		}
+		result = sum
+	}
+	return result, nil
+}
+
+// verifyPubKey check the pool public key derives the pub key the share is saved under
+func (s KeygenLocalState) verifyPubKey(pub *bcrypto.ECPoint) error {
+	var pubKey string
+	var err error
+	if s.GetAlgo() == common.EdDSA {
+		pubKey, _, err = conversion.GetTssPubKeyEDDSA(pub)
+	} else {
+		pubKey, _, err = conversion.GetTssPubKey(pub)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to derive the pub key: %w", err)
+	}
+	if pubKey != s.PubKey {
+		return fmt.Errorf("the pool public key derives %s", pubKey)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+type ShareVerifyTestSuite struct{}
+
+var _ = Suite(&ShareVerifyTestSuite{})
+
+func (s *ShareVerifyTestSuite) SetUpSuite(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func loadTestLocalState(c *C, idx int) KeygenLocalState {
+	buf, err := ioutil.ReadFile(fmt.Sprintf("../test_data/keysign_data/%d.json", idx))
+	c.Assert(err, IsNil)
+	state, err := unmarshalLocalState(buf)
+	c.Assert(err, IsNil)
+	return state
+}
+
+func (s *ShareVerifyTestSuite) TestVerify(c *C) {
+	for i := 0; i < 4; i++ {
+		result := loadTestLocalState(c, i).Verify()
+		c.Assert(result.Valid, Equals, true, Commentf("%v", result.Errors))
+		c.Assert(result.Errors, HasLen, 0)
+	}
+
+	// the secret share no longer matches the public share of the party
+	state := loadTestLocalState(c, 0)
+	state.LocalData.Xi = new(big.Int).Add(state.LocalData.Xi, big.NewInt(1))
+	result := state.Verify()
+	c.Assert(result.Valid, Equals, false)
+	c.Assert(result.Errors, HasLen, 1)
+	c.Assert(result.Errors[0], Matches, "Xi\\*G does not match .*")
+
+	// the public share of another party is replaced
+	state = loadTestLocalState(c, 0)
+	other := loadTestLocalState(c, 1)
+	idx := 0
+	for i, k := range state.LocalData.Ks {
+		if k.Cmp(state.LocalData.ShareID) != 0 {
+			idx = i
+			break
+		}
+	}
+	state.LocalData.BigXj[idx] = other.LocalData.ECDSAPub
+	result = state.Verify()
+	c.Assert(result.Valid, Equals, false)
+	c.Assert(result.Errors, HasLen, 1)
+	c.Assert(result.Errors[0], Matches, "the public share.*")
+
+	// the share is saved under the pub key of another pool
+	state = loadTestLocalState(c, 0)
+	state.PubKey = "thorpub1addwnpepqtdklw8tf3anjz7nn5fly3uvq2e67w2apn560s4smmrt9e3x52nt2svmmu9"
+	result = state.Verify()
+	c.Assert(result.Valid, Equals, false)
+	c.Assert(result.Errors, HasLen, 1)
+	c.Assert(result.Errors[0], Matches, "the pool public key derives .*")
+
+	// the public shares are incomplete
+	state = loadTestLocalState(c, 0)
+	state.LocalData.BigXj = state.LocalData.BigXj[1:]
+	result = state.Verify()
+	c.Assert(result.Valid, Equals, false)
+	c.Assert(result.Errors, HasLen, 1)
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+)
+
+const (
+	// StateKeyHeaderFile is the file in the home folder that keeps the parameters the passphrase based key of the
+	// local states is derived with
+	StateKeyHeaderFile = "localstate.key"
+	// StateKeyVersion is the version of the header the new passphrase based keys are created with
+	StateKeyVersion = 1
+	// KDFScrypt derives the key with scrypt
+	KDFScrypt = "scrypt"
+
+	stateKeyLength   = 32
+	stateKeySaltSize = 32
+	stateKeyCheckTag = "go-tss state key check"
+)
+
+// StateKeyHeader is the versioned parameters the key the local states are encrypted with is derived from the
+// operator passphrase with
+type StateKeyHeader struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	// KeyCheck tells whether a passphrase derives the right key, it does not reveal the key
+	KeyCheck []byte `json:"key_check"`
+}
+
+// NewStateKeyHeader create the header of a new passphrase based key with a random salt and the default scrypt
+// parameters, the key check is set by the first DeriveKey
+func NewStateKeyHeader() (StateKeyHeader, error) {
+	salt := make([]byte, stateKeySaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return StateKeyHeader{}, fmt.Errorf("fail to generate the salt: %w", err)
+	}
+	return StateKeyHeader{
+		Version: StateKeyVersion,
+		KDF:     KDFScrypt,
+		Salt:    salt,
+		N:       1 << 15,
+		R:       8,
+		P:       1,
+	}, nil
+}
+
+func getStateKeyCheck(key []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(stateKeyCheckTag))
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// DeriveKey derive the key the local states are encrypted with from the passphrase, it fails when the passphrase
+// is not the one the key check is created with
+func (h *StateKeyHeader) DeriveKey(passphrase string) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	if h.Version != StateKeyVersion {
+		return nil, fmt.Errorf("unsupported state key version(%d)", h.Version)
+	}
+	if h.KDF != KDFScrypt {
+		return nil, fmt.Errorf("unsupported key derivation function(%s)", h.KDF)
+	}
+	key, err := scrypt.Key([]byte(passphrase), h.Salt, h.N, h.R, h.P, stateKeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("fail to derive the state key: %w", err)
+	}
+	keyCheck := getStateKeyCheck(key)
+	if len(h.KeyCheck) == 0 {
+		h.KeyCheck = keyCheck
+		return key, nil
+	}
+	if !bytes.Equal(h.KeyCheck, keyCheck) {
+		return nil, errors.New("wrong passphrase")
+	}
+	return key, nil
+}
+
+// LoadStateKeyHeader read the state key header in the folder, it returns nil if the local states are encrypted
+// with the key derived from the node key
+func LoadStateKeyHeader(folder string) (*StateKeyHeader, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(folder, StateKeyHeaderFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fail to read the state key header: %w", err)
+	}
+	var header StateKeyHeader
+	if err := json.Unmarshal(buf, &header); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the state key header: %w", err)
+	}
+	return &header, nil
+}
+
+// SaveStateKeyHeader write the state key header to the folder
+func SaveStateKeyHeader(folder string, header StateKeyHeader) error {
+	if len(header.KeyCheck) == 0 {
+		return errors.New("the state key header has no key check")
+	}
+	buf, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return fmt.Errorf("fail to marshal the state key header: %w", err)
+	}
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(folder, StateKeyHeaderFile), buf)
+}
+
+// GetLocalStateKey return the key the local states in the folder are encrypted with, it is derived from the
+// passphrase when the folder has a state key header, and from the node key otherwise
+func GetLocalStateKey(folder string, priKey tcrypto.PrivKey, passphrase string) ([]byte, error) {
+	header, err := LoadStateKeyHeader(folder)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		if len(passphrase) != 0 {
+			return nil, errors.New("no state key header, re-encrypt the local states with the passphrase first")
+		}
+		return GetStateKey(priKey), nil
+	}
+	return header.DeriveKey(passphrase)
+}
+
+// reEncryptData return the data encrypted with the old key encrypted with the new key, it returns false when the
+// data is already encrypted with the new key
+func reEncryptData(data, oldKey, newKey []byte) ([]byte, bool, error) {
+	if _, err := common.AESDecrypt(data, newKey); err == nil {
+		return nil, false, nil
+	}
+	plainText, err := common.AESDecrypt(data, oldKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("fail to decrypt with the old key: %w", err)
+	}
+	encryptedData, err := common.AESEncrypt(plainText, newKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return encryptedData, true, nil
+}
+
+// ReEncryptLocalStates encrypt the local states, their history and tombstones, the batch, the presignatures and the
+// pre-parameters in the folder with the new key, both the files and the records of the LevelDB database in the
+// localstate.db folder. The files and records already encrypted with the new key are left as they are so it can be
+// run again after it is interrupted. It returns the number of the files and records re-encrypted
+func ReEncryptLocalStates(folder string, oldKey, newKey []byte) (int, error) {
+	num := 0
+	patterns := []string{"localstate-*.json", "history-*.json", "retired-*.json", "presign-*.json", preParamsFileName, batchFileName}
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(filepath.Join(folder, pattern))
+		if err != nil {
+			return num, fmt.Errorf("fail to list the files: %w", err)
+		}
+		for _, el := range files {
+			loadedData, err := ioutil.ReadFile(el)
+			if err != nil {
+				return num, fmt.Errorf("fail to read from file(%s): %w", el, err)
+			}
+			var encryptedData []byte
+			changed := true
+			// the presignatures and the pre-parameters are encrypted without the envelope
+			if pattern == "presign-*.json" || pattern == preParamsFileName {
+				encryptedData, changed, err = reEncryptData(loadedData, oldKey, newKey)
+			} else {
+				var content []byte
+				content, err = openEnvelope(loadedData)
+				switch {
+				case err != nil:
+				case bytes.HasPrefix(content, []byte("enc")):
+					encryptedData, changed, err = reEncryptData(content[3:], oldKey, newKey)
+					encryptedData = sealEnvelope(append([]byte("enc"), encryptedData...))
+				default:
+					// the local states saved before they are encrypted
+					encryptedData, err = common.AESEncrypt(content, newKey)
+					encryptedData = sealEnvelope(append([]byte("enc"), encryptedData...))
+				}
+			}
+			if err != nil {
+				return num, fmt.Errorf("fail to re-encrypt file(%s): %w", el, err)
+			}
+			if !changed {
+				continue
+			}
+			if err := writeFileAtomic(el, encryptedData); err != nil {
+				return num, err
+			}
+			num++
+		}
+	}
+	dbNum, err := reEncryptLevelDB(folder, oldKey, newKey)
+	if err != nil {
+		return num, err
+	}
+	return num + dbNum, nil
+}
+
+// reEncryptLevelDB encrypt the records of the LevelDB database in the folder with the new key in one atomic update,
+// the address book is not encrypted. It returns the number of the records re-encrypted
+func reEncryptLevelDB(folder string, oldKey, newKey []byte) (int, error) {
+	dbFolder := filepath.Join(folder, levelDBFolder)
+	if _, err := os.Stat(dbFolder); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("fail to read the local state database: %w", err)
+	}
+	db, err := leveldb.OpenFile(dbFolder, &opt.Options{ErrorIfMissing: true})
+	if err != nil {
+		return 0, fmt.Errorf("fail to open the local state database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Error().Err(err).Msg("fail to close the local state database")
+		}
+	}()
+	batch := new(leveldb.Batch)
+	for _, prefix := range []string{localStatePrefix, historyPrefix, preSignPrefix, retiredPrefix, preParamsDBKey} {
+		iter := db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			encryptedData, changed, err := reEncryptData(iter.Value(), oldKey, newKey)
+			if err != nil {
+				iter.Release()
+				return 0, fmt.Errorf("fail to re-encrypt record(%s): %w", iter.Key(), err)
+			}
+			if changed {
+				batch.Put(append([]byte{}, iter.Key()...), encryptedData)
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return 0, fmt.Errorf("fail to read the local state database: %w", err)
+		}
+	}
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+	if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return 0, fmt.Errorf("fail to write to the local state database: %w", err)
+	}
+	return batch.Len(), nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/binance-chain/tss-lib/ecdsa/keygen"
+	"github.com/binance-chain/tss-lib/ecdsa/signing"
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+)
+
+type StateKeyTestSuite struct{}
+
+var _ = Suite(&StateKeyTestSuite{})
+
+func (s *StateKeyTestSuite) SetUpTest(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func (s *StateKeyTestSuite) TestStateKeyHeader(c *C) {
+	f, err := ioutil.TempDir("", "statekey")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	header, err := LoadStateKeyHeader(f)
+	c.Assert(err, IsNil)
+	c.Assert(header, IsNil)
+
+	newHeader, err := NewStateKeyHeader()
+	c.Assert(err, IsNil)
+	// the parameters are kept low so the test runs fast
+	newHeader.N = 1 << 10
+	c.Assert(SaveStateKeyHeader(f, newHeader), NotNil)
+	_, err = newHeader.DeriveKey("")
+	c.Assert(err, NotNil)
+	key, err := newHeader.DeriveKey("my passphrase")
+	c.Assert(err, IsNil)
+	c.Assert(key, HasLen, 32)
+	c.Assert(SaveStateKeyHeader(f, newHeader), IsNil)
+
+	header, err = LoadStateKeyHeader(f)
+	c.Assert(err, IsNil)
+	c.Assert(header, NotNil)
+	_, err = header.DeriveKey("wrong passphrase")
+	c.Assert(err, NotNil)
+	derived, err := GetLocalStateKey(f, nil, "my passphrase")
+	c.Assert(err, IsNil)
+	c.Assert(derived, DeepEquals, key)
+	header.Version = 2
+	_, err = header.DeriveKey("my passphrase")
+	c.Assert(err, NotNil)
+
+	// the passphrase is only used with a header
+	c.Assert(os.Remove(filepath.Join(f, StateKeyHeaderFile)), IsNil)
+	_, err = GetLocalStateKey(f, nil, "my passphrase")
+	c.Assert(err, NotNil)
+}
+
+func (s *StateKeyTestSuite) TestReEncryptLocalStates(c *C) {
+	oldKey := getTestStateKey()
+	f, err := ioutil.TempDir("", "reencrypt")
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(os.RemoveAll(f), IsNil)
+	}()
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	fsm, err := NewFileStateMgr(f, oldKey)
+	c.Assert(err, IsNil)
+	c.Assert(fsm.SaveLocalState(getTestLocalState(pubKey1)), IsNil)
+	c.Assert(fsm.SavePreSignatures(pubKey1, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	preParams := []*keygen.LocalPreParams{
+		{NTildei: big.NewInt(1), H1i: big.NewInt(2), H2i: big.NewInt(3)},
+	}
+	c.Assert(fsm.SavePreParams(preParams), IsNil)
+	// the local state saved before the local states are encrypted
+	buf, err := json.Marshal(getTestLocalState(pubKey2))
+	c.Assert(err, IsNil)
+	filePathName, err := fsm.getFilePathName(pubKey2)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filePathName, buf, 0o600), IsNil)
+
+	header, err := NewStateKeyHeader()
+	c.Assert(err, IsNil)
+	header.N = 1 << 10
+	newKey, err := header.DeriveKey("my passphrase")
+	c.Assert(err, IsNil)
+	_, err = ReEncryptLocalStates(f, []byte("wrong key"), newKey)
+	c.Assert(err, NotNil)
+	num, err := ReEncryptLocalStates(f, oldKey, newKey)
+	c.Assert(err, IsNil)
+	// the history and the local state of pubKey1, its presignatures, the local state of pubKey2 and the
+	// pre-parameters
+	c.Assert(num, Equals, 5)
+	// the files already re-encrypted are left as they are
+	num, err = ReEncryptLocalStates(f, oldKey, newKey)
+	c.Assert(err, IsNil)
+	c.Assert(num, Equals, 0)
+
+	_, err = fsm.GetLocalState(pubKey1)
+	c.Assert(err, NotNil)
+	fsm, err = NewFileStateMgr(f, newKey)
+	c.Assert(err, IsNil)
+	for _, el := range []string{pubKey1, pubKey2} {
+		item, err := fsm.GetLocalState(el)
+		c.Assert(err, IsNil)
+		c.Assert(reflect.DeepEqual(getTestLocalState(el), item), Equals, true)
+	}
+	versions, err := fsm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 1)
+	c.Assert(versions[0].Pinned, Equals, true)
+	taken, err := fsm.TakePreSignatures(pubKey1, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	saved, err := fsm.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(saved, DeepEquals, preParams)
+}
+
+func (s *StateKeyTestSuite) TestReEncryptLevelDB(c *C) {
+	oldKey := getTestStateKey()
+	f := c.MkDir()
+	pubKey1 := "thorpub1addwnpepqf90u7n3nr2jwsw4t2gzhzqfdlply8dlzv3mdj4dr22uvhe04azq5gac3gq"
+	pubKey2 := "thorpub1addwnpepqv6xp3fmm47dfuzglywqvpv8fdjv55zxte4a26tslcezns5czv586u2fw33"
+	ldm, err := NewLevelDBStateMgr(f, oldKey)
+	c.Assert(err, IsNil)
+	c.Assert(ldm.SaveLocalStates(getTestLocalState(pubKey1), getTestLocalState(pubKey2)), IsNil)
+	c.Assert(ldm.RetireLocalState(pubKey2), IsNil)
+	c.Assert(ldm.SavePreSignatures(pubKey1, []PreSignature{
+		{ID: "1", Signers: []string{"A", "B"}, Data: &signing.SignatureData_OneRoundData{T: 1, KI: []byte{1}}},
+	}), IsNil)
+	preParams := []*keygen.LocalPreParams{
+		{NTildei: big.NewInt(1), H1i: big.NewInt(2), H2i: big.NewInt(3)},
+	}
+	c.Assert(ldm.SavePreParams(preParams), IsNil)
+	c.Assert(ldm.SaveAddressBook(p2p.NewAddressBook()), IsNil)
+	c.Assert(ldm.Close(), IsNil)
+
+	header, err := NewStateKeyHeader()
+	c.Assert(err, IsNil)
+	header.N = 1 << 10
+	newKey, err := header.DeriveKey("my passphrase")
+	c.Assert(err, IsNil)
+	_, err = ReEncryptLocalStates(f, []byte("wrong key"), newKey)
+	c.Assert(err, NotNil)
+	num, err := ReEncryptLocalStates(f, oldKey, newKey)
+	c.Assert(err, IsNil)
+	// the local state of pubKey1 and its history, its presignatures, the pre-parameters, and the tombstone of
+	// pubKey2 and its history
+	c.Assert(num, Equals, 6)
+	num, err = ReEncryptLocalStates(f, oldKey, newKey)
+	c.Assert(err, IsNil)
+	c.Assert(num, Equals, 0)
+
+	ldm, err = NewLevelDBStateMgr(f, newKey)
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Assert(ldm.Close(), IsNil)
+	}()
+	item, err := ldm.GetLocalState(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(reflect.DeepEqual(getTestLocalState(pubKey1), item), Equals, true)
+	versions, err := ldm.GetLocalStateHistory(pubKey1)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 1)
+	taken, err := ldm.TakePreSignatures(pubKey1, []string{"A", "B"}, []string{"1"})
+	c.Assert(err, IsNil)
+	c.Assert(taken, HasLen, 1)
+	saved, err := ldm.GetPreParams()
+	c.Assert(err, IsNil)
+	c.Assert(saved, DeepEquals, preParams)
+	_, err = ldm.GetAddressBook()
+	c.Assert(err, IsNil)
+}
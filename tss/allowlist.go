@@ -0,0 +1,102 @@
+package tss
+
+import (
+	"fmt"
+	"sync"
+
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+)
+
+// allowlist hold the node pub keys admitted to connect to the node, when gating is enabled the participants of the
+// pools the node holds a share of, and the participants of the keygens and resharings in progress, are admitted as
+// well
+type allowlist struct {
+	lock    *sync.Mutex
+	enabled bool
+	pubKeys []string
+	// ceremonies hold the participants of the keygens and resharings in progress by their msg ids
+	ceremonies map[string][]string
+}
+
+// SetAllowedPeers enable the connection gating of the p2p layer, only the nodes of the given pub keys and the
+// participants of the pools the node holds a share of are admitted. The participants of a keygen or resharing are
+// admitted while it is in progress, so the nodes joining a pool need not be allowed beforehand, and the participants
+// of the pools are refreshed once it is done
+func (t *TssServer) SetAllowedPeers(pubKeys []string) error {
+	if _, err := conversion.GetPeerIDsFromPubKeys(pubKeys); err != nil {
+		return fmt.Errorf("fail to convert the pub keys to peer ids: %w", err)
+	}
+	t.allowlist.lock.Lock()
+	t.allowlist.enabled = true
+	t.allowlist.pubKeys = append([]string{}, pubKeys...)
+	t.allowlist.lock.Unlock()
+	return t.refreshAllowedPeers()
+}
+
+// refreshAllowedPeers pass the allowed pub keys and the participants of the known pools to the p2p layer, it does
+// nothing till the gating is enabled
+func (t *TssServer) refreshAllowedPeers() error {
+	t.allowlist.lock.Lock()
+	defer t.allowlist.lock.Unlock()
+	if !t.allowlist.enabled {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var pubKeys []string
+	addPubKeys := func(keys []string) {
+		for _, el := range keys {
+			if !seen[el] {
+				seen[el] = true
+				pubKeys = append(pubKeys, el)
+			}
+		}
+	}
+	addPubKeys(append([]string{t.localNodePubKey}, t.allowlist.pubKeys...))
+	for _, el := range t.allowlist.ceremonies {
+		addPubKeys(el)
+	}
+	pools, err := t.stateManager.ListLocalStates()
+	if err != nil {
+		return fmt.Errorf("fail to list the local states: %w", err)
+	}
+	for _, el := range pools {
+		localState, err := t.stateManager.GetLocalState(el)
+		if err != nil {
+			return fmt.Errorf("fail to get the local state of %s: %w", el, err)
+		}
+		addPubKeys(localState.ParticipantKeys)
+	}
+	peerIDs, err := conversion.GetPeerIDsFromPubKeys(pubKeys)
+	if err != nil {
+		return fmt.Errorf("fail to convert the pub keys to peer ids: %w", err)
+	}
+	t.p2pCommunication.SetAllowedPeers(peerIDs)
+	t.logger.Info().Msgf("%d peers are allowed to connect", len(peerIDs))
+	return nil
+}
+
+// admitParticipants admit the participants of the keygen or resharing of the msg id till releaseParticipants is
+// called, the ones not in any pool of the node yet included
+func (t *TssServer) admitParticipants(msgID string, pubKeys []string) error {
+	if _, err := conversion.GetPeerIDsFromPubKeys(pubKeys); err != nil {
+		return fmt.Errorf("fail to convert the pub keys to peer ids: %w", err)
+	}
+	t.allowlist.lock.Lock()
+	if t.allowlist.ceremonies == nil {
+		t.allowlist.ceremonies = make(map[string][]string)
+	}
+	t.allowlist.ceremonies[msgID] = append([]string{}, pubKeys...)
+	t.allowlist.lock.Unlock()
+	return t.refreshAllowedPeers()
+}
+
+// releaseParticipants stop admitting the participants of the keygen or resharing of the msg id, the ones in the pools
+// of the node stay admitted
+func (t *TssServer) releaseParticipants(msgID string) {
+	t.allowlist.lock.Lock()
+	delete(t.allowlist.ceremonies, msgID)
+	t.allowlist.lock.Unlock()
+	if err := t.refreshAllowedPeers(); err != nil {
+		t.logger.Error().Err(err).Msg("fail to refresh the allowed peers")
+	}
+}
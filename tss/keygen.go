@@ -3,6 +3,8 @@ package tss
 import (
 	"time"
 
+	bkeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
+
 	"gitlab.com/thorchain/tss/go-tss/blame"
 	"gitlab.com/thorchain/tss/go-tss/common"
 	"gitlab.com/thorchain/tss/go-tss/conversion"
@@ -18,14 +20,49 @@ func (t *TssServer) Keygen(req keygen.Request) (keygen.Response, error) {
 	if err != nil {
 		return keygen.Response{}, err
 	}
+	if _, err := conversion.GetThresholdWithSigners(len(req.Keys), req.Threshold); err != nil {
+		return keygen.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	if err := req.ValidateKeyCount(); err != nil {
+		return keygen.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	keyNum := req.GetKeyCount()
+	// every ECDSA key uses a fresh set of pre-parameters, EdDSA does not need them. They are taken before the party
+	// forms, so no peer waits for them, and they go back to the pool unless the keygen starts
+	var preParams []*bkeygen.LocalPreParams
+	keygenStarted := false
+	defer func() {
+		if !keygenStarted {
+			t.returnPreParams(preParams)
+		}
+	}()
+	if req.Algo != common.EdDSA {
+		for i := 0; i < keyNum; i++ {
+			preParam, err := t.getPreParams()
+			if err != nil {
+				return keygen.Response{
+					Status: common.Fail,
+					Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+				}, err
+			}
+			preParams = append(preParams, preParam)
+		}
+	}
 
-	keygenInstance := keygen.NewTssKeyGen(
+	keygenInstance := keygen.NewTssBatchKeyGen(
 		t.p2pCommunication.GetLocalPeerID(),
 		t.conf,
 		t.localNodePubKey,
-		t.p2pCommunication.BroadcastMsgChan,
+		t.p2pCommunication.GetBroadcastChannel(),
 		t.stopChan,
-		t.preParams,
+		preParams,
+		keyNum,
 		msgID,
 		t.stateManager,
 		t.privateKey,
@@ -48,6 +85,29 @@ func (t *TssServer) Keygen(req keygen.Request) (keygen.Response, error) {
 	}()
 	sigChan := make(chan string)
 	blameMgr := keygenInstance.GetTssCommonStruct().GetBlameMgr()
+	// the peers that keep flooding the ceremony are evidence for the blame
+	t.p2pCommunication.SetRateLimitHandler(msgID, blameMgr.AddRateLimitEvidence)
+	// the participants are admitted by the connection gating before the party forms, once the keygen is done the
+	// participants of the new pool stay admitted
+	if err := t.admitParticipants(msgID, req.Keys); err != nil {
+		return keygen.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer t.releaseParticipants(msgID)
+	// the curve is held from before the party forms, so a node busy with the ceremonies of the other algorithm fails
+	// here instead of holding up the party, the keygen releases it once its local parties are done
+	tssCommon := keygenInstance.GetTssCommonStruct()
+	algo, _ := common.GetAlgo(req.Algo)
+	if err := tssCommon.AcquireCurve(algo, t.conf.PartyTimeout); err != nil {
+		t.logger.Error().Err(err).Msg("fail to acquire the curve for the keygen")
+		return keygen.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer tssCommon.ReleaseCurve()
 	joinPartyStartTime := time.Now()
 	onlinePeers, leader, errJoinParty := t.joinParty(msgID, req.Version, req.BlockHeight, req.Keys, len(req.Keys)-1, sigChan)
 	joinPartyTime := time.Since(joinPartyStartTime)
@@ -109,8 +169,11 @@ func (t *TssServer) Keygen(req keygen.Request) (keygen.Response, error) {
 	// following http response aborts, it still counted as a successful keygen
 	// as the Tss model runs successfully.
 	beforeKeygen := time.Now()
-	k, err := keygenInstance.GenerateNewKey(req)
+	keygenStarted = true
+	keys, err := keygenInstance.GenerateNewKeys(req)
 	keygenTime := time.Since(beforeKeygen)
+	// the local parties are done, the curve is not held while the local states are read for the allowed peers
+	tssCommon.ReleaseCurve()
 	if err != nil {
 		t.tssMetrics.UpdateKeyGen(keygenTime, false)
 		t.logger.Error().Err(err).Msg("err in keygen")
@@ -120,17 +183,29 @@ func (t *TssServer) Keygen(req keygen.Request) (keygen.Response, error) {
 		t.tssMetrics.UpdateKeyGen(keygenTime, true)
 	}
 
-	newPubKey, addr, err := conversion.GetTssPubKey(k)
-	if err != nil {
-		t.logger.Error().Err(err).Msg("fail to generate the new Tss key")
-		status = common.Fail
+	getTssPubKey := conversion.GetTssPubKey
+	if req.Algo == common.EdDSA {
+		getTssPubKey = conversion.GetTssPubKeyEDDSA
+	}
+	var newPubKeys, addrs []string
+	for _, k := range keys {
+		newPubKey, addr, err := getTssPubKey(k)
+		if err != nil {
+			t.logger.Error().Err(err).Msg("fail to generate the new Tss key")
+			status = common.Fail
+		}
+		newPubKeys = append(newPubKeys, newPubKey)
+		addrs = append(addrs, addr.String())
 	}
 
 	blameNodes := *blameMgr.GetBlame()
-	return keygen.NewResponse(
-		newPubKey,
-		addr.String(),
+	resp := keygen.NewResponse(
+		newPubKeys[0],
+		addrs[0],
 		status,
 		blameNodes,
-	), nil
+	)
+	resp.PubKeys = newPubKeys
+	resp.PoolAddresses = addrs
+	return resp, nil
 }
@@ -2,8 +2,10 @@ package tss
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 	"sort"
 	"strings"
 	"sync"
@@ -21,9 +23,9 @@ import (
 	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
-func (t *TssServer) waitForSignatures(msgID, poolPubKey string, msgsToSign [][]byte, sigChan chan string) (keysign.Response, error) {
+func (t *TssServer) waitForSignatures(msgID, poolPubKey string, msgsToSign [][]byte, algo common.Algo, hashMode common.HashMode, sigChan chan string) (keysign.Response, error) {
 	// TSS keysign include both form party and keysign itself, thus we wait twice of the timeout
-	data, err := t.signatureNotifier.WaitForSignature(msgID, msgsToSign, poolPubKey, t.conf.KeySignTimeout, sigChan)
+	data, err := t.signatureNotifier.WaitForSignature(msgID, msgsToSign, poolPubKey, hashMode, t.conf.KeySignTimeout, sigChan)
 	if err != nil {
 		return keysign.Response{}, err
 	}
@@ -32,7 +34,7 @@ func (t *TssServer) waitForSignatures(msgID, poolPubKey string, msgsToSign [][]b
 		return keysign.Response{}, errors.New("keysign failed")
 	}
 
-	return t.batchSignatures(data, msgsToSign), nil
+	return t.batchSignatures(data, msgsToSign, algo), nil
 }
 
 func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req keysign.Request, threshold int, allParticipants []string, localStateItem storage.KeygenLocalState, blameMgr *blame.Manager, keysignInstance *keysign.TssKeySign, sigChan chan string) (keysign.Response, error) {
@@ -55,9 +57,9 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 	// we use the old join party
 	if oldJoinParty {
 		allParticipants = req.SignerPubKeys
-		myPk, err := conversion.GetPubKeyFromPeerID(t.p2pCommunication.GetHost().ID().String())
+		myPk, err := conversion.GetPubKeyFromPeerID(t.p2pCommunication.GetLocalPeerID())
 		if err != nil {
-			t.logger.Info().Msgf("fail to convert the p2p id(%s) to pubkey, turn to wait for signature", t.p2pCommunication.GetHost().ID().String())
+			t.logger.Info().Msgf("fail to convert the p2p id(%s) to pubkey, turn to wait for signature", t.p2pCommunication.GetLocalPeerID())
 			return keysign.Response{}, p2p.ErrNotActiveSigner
 		}
 		isSignMember := false
@@ -68,12 +70,23 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 			}
 		}
 		if !isSignMember {
-			t.logger.Info().Msgf("we(%s) are not the active signer", t.p2pCommunication.GetHost().ID().String())
+			t.logger.Info().Msgf("we(%s) are not the active signer", t.p2pCommunication.GetLocalPeerID())
 			return keysign.Response{}, p2p.ErrNotActiveSigner
 		}
 
 	}
 
+	// the curve is held from before the party forms, so a node busy with the ceremonies of the other algorithm fails
+	// here instead of holding up the party, it may still get the signature from the other signers
+	tssCommon := keysignInstance.GetTssCommonStruct()
+	if err := tssCommon.AcquireCurve(localStateItem.GetAlgo(), t.conf.PartyTimeout); err != nil {
+		t.logger.Error().Err(err).Msg("fail to acquire the curve for the keysign")
+		return keysign.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer tssCommon.ReleaseCurve()
 	joinPartyStartTime := time.Now()
 	onlinePeers, leader, errJoinParty := t.joinParty(msgID, req.Version, req.BlockHeight, allParticipants, threshold, sigChan)
 	joinPartyTime := time.Since(joinPartyStartTime)
@@ -128,13 +141,13 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 	t.tssMetrics.KeysignJoinParty(joinPartyTime, true)
 	isKeySignMember := false
 	for _, el := range onlinePeers {
-		if el == t.p2pCommunication.GetHost().ID() {
+		if el == t.p2pCommunication.GetStreamHost().ID() {
 			isKeySignMember = true
 		}
 	}
 	if !isKeySignMember {
 		// we are not the keysign member so we quit keysign and waiting for signature
-		t.logger.Info().Msgf("we(%s) are not the active signer", t.p2pCommunication.GetHost().ID().String())
+		t.logger.Info().Msgf("we(%s) are not the active signer", t.p2pCommunication.GetLocalPeerID())
 		return keysign.Response{}, p2p.ErrNotActiveSigner
 	}
 	parsedPeers := make([]string, len(onlinePeers))
@@ -150,7 +163,7 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 			Blame:  blame.Blame{},
 		}, nil
 	}
-	signatureData, err := keysignInstance.SignMessage(msgsToSign, localStateItem, signers)
+	signatureData, err := keysignInstance.SignMessage(msgsToSign, localStateItem, signers, req.HashMode)
 	// the statistic of keygen only care about Tss it self, even if the following http response aborts,
 	// it still counted as a successful keygen as the Tss model runs successfully.
 	if err != nil {
@@ -158,6 +171,13 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 		sigChan <- "signature generated"
 		t.broadcastKeysignFailure(msgID, allPeersID)
 		blameNodes := *blameMgr.GetBlame()
+		// the caller retries without the presignatures when they are not found
+		if errors.Is(err, keysign.ErrPreSignaturesNotFound) {
+			return keysign.Response{
+				Status: common.Fail,
+				Blame:  blameNodes,
+			}, err
+		}
 		return keysign.Response{
 			Status: common.Fail,
 			Blame:  blameNodes,
@@ -170,7 +190,7 @@ func (t *TssServer) generateSignature(msgID string, msgsToSign [][]byte, req key
 		return keysign.Response{}, fmt.Errorf("fail to broadcast signature:%w", err)
 	}
 
-	return t.batchSignatures(signatureData, msgsToSign), nil
+	return t.batchSignatures(signatureData, msgsToSign, localStateItem.GetAlgo()), nil
 }
 
 func (t *TssServer) updateKeySignResult(result keysign.Response, timeSpent time.Duration) {
@@ -188,6 +208,17 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 		Str("msg", strings.Join(req.Messages, ",")).
 		Msg("received keysign request")
 	emptyResp := keysign.Response{}
+	// the local state manager returns the pinned generation of the share
+	localStateItem, err := t.stateManager.GetLocalState(req.PoolPubKey)
+	if err != nil {
+		return emptyResp, fmt.Errorf("fail to get local keygen state: %w", err)
+	}
+	algo := localStateItem.GetAlgo()
+	if req.Algo != "" && req.Algo != algo {
+		return emptyResp, fmt.Errorf("pool(%s) is an %s key, not %s", req.PoolPubKey, algo, req.Algo)
+	}
+	// the algorithm is part of the msg id, the signers that leave it empty meet the ones that name it
+	req.Algo = algo
 	msgID, err := t.requestToMsgId(req)
 	if err != nil {
 		return emptyResp, err
@@ -196,7 +227,7 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 	keysignInstance := keysign.NewTssKeySign(
 		t.p2pCommunication.GetLocalPeerID(),
 		t.conf,
-		t.p2pCommunication.BroadcastMsgChan,
+		t.p2pCommunication.GetBroadcastChannel(),
 		t.stopChan,
 		msgID,
 		t.privateKey,
@@ -210,21 +241,63 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 	t.p2pCommunication.SetSubscribe(messages.TSSKeySignVerMsg, msgID, keySignChannels)
 	t.p2pCommunication.SetSubscribe(messages.TSSControlMsg, msgID, keySignChannels)
 	t.p2pCommunication.SetSubscribe(messages.TSSTaskDone, msgID, keySignChannels)
+	t.p2pCommunication.SetSubscribe(messages.TSSOnlineSignMsg, msgID, keySignChannels)
 
 	defer func() {
 		t.p2pCommunication.CancelSubscribe(messages.TSSKeySignMsg, msgID)
 		t.p2pCommunication.CancelSubscribe(messages.TSSKeySignVerMsg, msgID)
 		t.p2pCommunication.CancelSubscribe(messages.TSSControlMsg, msgID)
 		t.p2pCommunication.CancelSubscribe(messages.TSSTaskDone, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSOnlineSignMsg, msgID)
 
 		t.p2pCommunication.ReleaseStream(msgID)
 		t.signatureNotifier.ReleaseStream(msgID)
 		t.partyCoordinator.ReleaseStream(msgID)
 	}()
 
-	localStateItem, err := t.stateManager.GetLocalState(req.PoolPubKey)
-	if err != nil {
-		return emptyResp, fmt.Errorf("fail to get local keygen state: %w", err)
+	hashMode, ok := common.GetHashMode(req.HashMode)
+	if !ok {
+		return emptyResp, fmt.Errorf("unsupported hash mode: %s", req.HashMode)
+	}
+	if algo == common.EdDSA && hashMode != common.HashRaw {
+		return emptyResp, fmt.Errorf("hash mode %s is not supported for %s keys", hashMode, algo)
+	}
+	if len(req.Formats) > 0 {
+		if algo != common.ECDSA {
+			return emptyResp, fmt.Errorf("signature formats are not supported for %s keys", algo)
+		}
+		if err := keysign.ValidateFormats(req.Formats); err != nil {
+			return emptyResp, err
+		}
+	}
+	// the signatures of a child key are verified with the child pub key
+	signingPubKey := req.PoolPubKey
+	if len(req.DerivationPath) > 0 {
+		if algo != common.ECDSA {
+			return emptyResp, fmt.Errorf("child key derivation is not supported for %s keys", algo)
+		}
+		chainCode, err := hex.DecodeString(req.ChainCode)
+		if err != nil {
+			return emptyResp, fmt.Errorf("fail to decode the chain code: %w", err)
+		}
+		derivationPath, err := conversion.ParseDerivationPath(req.DerivationPath)
+		if err != nil {
+			return emptyResp, fmt.Errorf("fail to parse the derivation path: %w", err)
+		}
+		signingPubKey, _, err = conversion.GetDerivedPubKey(req.PoolPubKey, chainCode, req.DerivationPath)
+		if err != nil {
+			return emptyResp, fmt.Errorf("fail to derive the child pub key: %w", err)
+		}
+		keysignInstance.SetDerivation(chainCode, derivationPath)
+	}
+	if len(req.PreSignIDs) > 0 {
+		if algo != common.ECDSA || len(req.DerivationPath) > 0 {
+			return emptyResp, errors.New("presignatures can only sign with the ecdsa pool key")
+		}
+		if len(req.PreSignIDs) != len(req.Messages) {
+			return emptyResp, fmt.Errorf("%d presignatures are given to sign %d messages", len(req.PreSignIDs), len(req.Messages))
+		}
+		keysignInstance.SetPreSignatures(req.PreSignIDs)
 	}
 
 	var msgsToSign [][]byte
@@ -233,15 +306,23 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 		if err != nil {
 			return keysign.Response{}, fmt.Errorf("fail to decode message(%s): %w", strings.Join(req.Messages, ","), err)
 		}
+		if _, err := common.HashMessage(msgToSign, hashMode); err != nil {
+			return keysign.Response{}, fmt.Errorf("fail to hash message(%s): %w", val, err)
+		}
+		// a raw message, asked for or by default, must be the digest itself
+		if hashMode == common.HashRaw && algo == common.ECDSA && len(msgToSign) != 32 {
+			return keysign.Response{}, fmt.Errorf("raw message(%s) is not a 32 bytes digest", val)
+		}
 		msgsToSign = append(msgsToSign, msgToSign)
 	}
 
+	// the messages are in the order of the digests the signatures carry
 	sort.SliceStable(msgsToSign, func(i, j int) bool {
-		ma, err := common.MsgToHashInt(msgsToSign[i])
+		ma, err := msgToSignInt(msgsToSign[i], algo, hashMode)
 		if err != nil {
 			t.logger.Error().Err(err).Msgf("fail to convert the hash value")
 		}
-		mb, err := common.MsgToHashInt(msgsToSign[j])
+		mb, err := msgToSignInt(msgsToSign[j], algo, hashMode)
 		if err != nil {
 			t.logger.Error().Err(err).Msgf("fail to convert the hash value")
 		}
@@ -263,7 +344,7 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 		return emptyResp, errors.New("empty signer pub keys")
 	}
 
-	threshold, err := conversion.GetThreshold(len(localStateItem.ParticipantKeys))
+	threshold, err := localStateItem.GetThreshold()
 	if err != nil {
 		t.logger.Error().Err(err).Msg("fail to get the threshold")
 		return emptyResp, errors.New("fail to get threshold")
@@ -274,6 +355,8 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 	}
 
 	blameMgr := keysignInstance.GetTssCommonStruct().GetBlameMgr()
+	// the peers that keep flooding the ceremony are evidence for the blame
+	t.p2pCommunication.SetRateLimitHandler(msgID, blameMgr.AddRateLimitEvidence)
 
 	var receivedSig, generatedSig keysign.Response
 	var errWait, errGen error
@@ -284,7 +367,7 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 	// we wait for signatures
 	go func() {
 		defer wg.Done()
-		receivedSig, errWait = t.waitForSignatures(msgID, req.PoolPubKey, msgsToSign, sigChan)
+		receivedSig, errWait = t.waitForSignatures(msgID, signingPubKey, msgsToSign, algo, hashMode, sigChan)
 		// we received an valid signature indeed
 		if errWait == nil {
 			sigChan <- "signature received"
@@ -305,16 +388,52 @@ func (t *TssServer) KeySign(req keysign.Request) (keysign.Response, error) {
 	// we received the generated verified signature, so we return
 	if errWait == nil {
 		t.updateKeySignResult(receivedSig, keysignTime)
-		return receivedSig, nil
+		return t.addSignatureFormats(receivedSig, req, signingPubKey)
 	}
 	// for this round, we are not the active signer
 	if errors.Is(errGen, p2p.ErrSignReceived) || errors.Is(errGen, p2p.ErrNotActiveSigner) {
 		t.updateKeySignResult(receivedSig, keysignTime)
-		return receivedSig, nil
+		return t.addSignatureFormats(receivedSig, req, signingPubKey)
 	}
 	// we get the signature from our tss keysign
 	t.updateKeySignResult(generatedSig, keysignTime)
-	return generatedSig, errGen
+	if errGen != nil {
+		return generatedSig, errGen
+	}
+	return t.addSignatureFormats(generatedSig, req, signingPubKey)
+}
+
+// addSignatureFormats set the chain specific forms the request asks for to the signatures, the forms are verified
+// against the pub key that signs
+func (t *TssServer) addSignatureFormats(resp keysign.Response, req keysign.Request, pubKey string) (keysign.Response, error) {
+	if len(req.Formats) == 0 || resp.Status != common.Success {
+		return resp, nil
+	}
+	signingPubKey, err := conversion.GetECDSAPubKey(pubKey)
+	if err != nil {
+		return keysign.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, fmt.Errorf("fail to get the pub key: %w", err)
+	}
+	for i := range resp.Signatures {
+		if err := resp.Signatures[i].AddFormats(signingPubKey, req.Formats, req.ChainID, req.HashMode); err != nil {
+			return keysign.Response{
+				Status: common.Fail,
+				Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+			}, fmt.Errorf("fail to encode the signature: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// msgToSignInt return the integer tss-lib signs for the message hashed with the given hash mode
+func msgToSignInt(msg []byte, algo common.Algo, hashMode common.HashMode) (*big.Int, error) {
+	digest, err := common.HashMessage(msg, hashMode)
+	if err != nil {
+		return nil, err
+	}
+	return common.MsgToSignInt(digest, algo)
 }
 
 func (t *TssServer) broadcastKeysignFailure(messageID string, peers []peer.ID) {
@@ -332,12 +451,18 @@ func (t *TssServer) isPartOfKeysignParty(parties []string) bool {
 	return false
 }
 
-func (t *TssServer) batchSignatures(sigs []*tsslibcommon.ECSignature, msgsToSign [][]byte) keysign.Response {
+func (t *TssServer) batchSignatures(sigs []*tsslibcommon.ECSignature, msgsToSign [][]byte, algo common.Algo) keysign.Response {
 	var signatures []keysign.Signature
 	for i, sig := range sigs {
 		msg := base64.StdEncoding.EncodeToString(msgsToSign[i])
 		r := base64.StdEncoding.EncodeToString(sig.R)
 		s := base64.StdEncoding.EncodeToString(sig.S)
+		if algo == common.EdDSA && len(sig.Signature) == 64 {
+			// tss-lib gives the standard R||S encoding of ed25519 signature in Signature, while R is big endian
+			// and S is little endian
+			r = base64.StdEncoding.EncodeToString(sig.Signature[:32])
+			s = base64.StdEncoding.EncodeToString(sig.Signature[32:])
+		}
 		recovery := base64.StdEncoding.EncodeToString(sig.SignatureRecovery)
 
 		signature := keysign.NewSignature(msg, r, s, recovery)
@@ -0,0 +1,154 @@
+package tss
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+// ListLocalStates return the metadata of the shares of all the pools the node holds
+func (t *TssServer) ListLocalStates() ([]storage.LocalStateInfo, error) {
+	pubKeys, err := t.stateManager.ListLocalStates()
+	if err != nil {
+		return nil, fmt.Errorf("fail to list the local states: %w", err)
+	}
+	infos := make([]storage.LocalStateInfo, 0, len(pubKeys))
+	for _, el := range pubKeys {
+		info, err := t.GetLocalStateInfo(el)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// GetLocalStateInfo return the metadata of the share of the given pool, the secret of the share is not returned
+func (t *TssServer) GetLocalStateInfo(pubKey string) (storage.LocalStateInfo, error) {
+	localState, err := t.stateManager.GetLocalState(pubKey)
+	if err != nil {
+		return storage.LocalStateInfo{}, fmt.Errorf("fail to get the local state of %s: %w", pubKey, err)
+	}
+	return localState.GetInfo(), nil
+}
+
+// GetLocalStateHistory return the generations of the share of the given pool, the oldest first
+func (t *TssServer) GetLocalStateHistory(pubKey string) ([]storage.LocalStateVersion, error) {
+	versions, err := t.stateManager.GetLocalStateHistory(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get the local state history of %s: %w", pubKey, err)
+	}
+	return versions, nil
+}
+
+// PinLocalState make keysign use the given generation of the share of the pool
+func (t *TssServer) PinLocalState(pubKey string, generation int64) error {
+	// the share is not pinned while a keygen or resharing updates it
+	t.tssKeyGenLocker.Lock()
+	defer t.tssKeyGenLocker.Unlock()
+	if err := t.stateManager.PinLocalState(pubKey, generation); err != nil {
+		return fmt.Errorf("fail to pin the generation %d of the local state of %s: %w", generation, pubKey, err)
+	}
+	t.logger.Info().Msgf("the generation %d of the local state of %s is pinned", generation, pubKey)
+	return nil
+}
+
+// RollbackLocalState pin the generation of the share of the pool before the pinned one, it is used when the
+// resharing or refresh that created the pinned generation fails to complete on the other parties
+func (t *TssServer) RollbackLocalState(pubKey string) (storage.LocalStateVersion, error) {
+	t.tssKeyGenLocker.Lock()
+	defer t.tssKeyGenLocker.Unlock()
+	versions, err := t.stateManager.GetLocalStateHistory(pubKey)
+	if err != nil {
+		return storage.LocalStateVersion{}, fmt.Errorf("fail to get the local state history of %s: %w", pubKey, err)
+	}
+	for i, el := range versions {
+		if !el.Pinned {
+			continue
+		}
+		if i == 0 || versions[i-1].Generation == 0 {
+			return storage.LocalStateVersion{}, fmt.Errorf("no generation of the local state of %s before %d", pubKey, el.Generation)
+		}
+		previous := versions[i-1]
+		if err := t.stateManager.PinLocalState(pubKey, previous.Generation); err != nil {
+			return storage.LocalStateVersion{}, fmt.Errorf("fail to pin the generation %d of the local state of %s: %w", previous.Generation, pubKey, err)
+		}
+		t.logger.Info().Msgf("the local state of %s is rolled back to the generation %d", pubKey, previous.Generation)
+		previous.Pinned = true
+		return previous, nil
+	}
+	return storage.LocalStateVersion{}, fmt.Errorf("no generation of the local state of %s is pinned", pubKey)
+}
+
+// RetireLocalState remove the share of the given pool, the share is kept encrypted as a tombstone
+func (t *TssServer) RetireLocalState(pubKey string) error {
+	// the share is not retired while a keygen or resharing updates it
+	t.tssKeyGenLocker.Lock()
+	defer t.tssKeyGenLocker.Unlock()
+	if err := t.stateManager.RetireLocalState(pubKey); err != nil {
+		return fmt.Errorf("fail to retire the local state of %s: %w", pubKey, err)
+	}
+	t.logger.Info().Msgf("the local state of %s is retired", pubKey)
+	return nil
+}
+
+// VerifyLocalState check the share of the given pool is still valid without running a keysign
+func (t *TssServer) VerifyLocalState(pubKey string) (storage.ShareVerification, error) {
+	localState, err := t.stateManager.GetLocalState(pubKey)
+	if err != nil {
+		return storage.ShareVerification{}, fmt.Errorf("fail to get the local state of %s: %w", pubKey, err)
+	}
+	result := localState.Verify()
+	if !result.Valid {
+		t.logger.Error().Strs("errors", result.Errors).Msgf("the local state of %s is invalid", pubKey)
+	}
+	return result, nil
+}
+
+// VerifyLocalStates check the shares of all the pools the node holds, a share that can not be loaded is reported
+// as invalid
+func (t *TssServer) VerifyLocalStates() ([]storage.ShareVerification, error) {
+	pubKeys, err := t.stateManager.ListLocalStates()
+	if err != nil {
+		return nil, fmt.Errorf("fail to list the local states: %w", err)
+	}
+	results := make([]storage.ShareVerification, 0, len(pubKeys))
+	for _, el := range pubKeys {
+		result, err := t.VerifyLocalState(el)
+		if err != nil {
+			t.logger.Error().Err(err).Msgf("fail to verify the local state of %s", el)
+			result = storage.ShareVerification{
+				PubKey: el,
+				Errors: []string{err.Error()},
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// verifyLocalStatesPeriodically verify the shares of all the pools every interval till the server stops
+func (t *TssServer) verifyLocalStatesPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopChan:
+			return
+		case <-ticker.C:
+			results, err := t.VerifyLocalStates()
+			if err != nil {
+				t.logger.Error().Err(err).Msg("fail to verify the local states")
+				continue
+			}
+			invalid := 0
+			for _, el := range results {
+				if !el.Valid {
+					invalid++
+				}
+			}
+			t.logger.Info().Msgf("%d local states are verified, %d are invalid", len(results), invalid)
+		}
+	}
+}
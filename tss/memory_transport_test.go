@@ -0,0 +1,207 @@
+package tss
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/keygen"
+	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/p2p"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+)
+
+type MemoryTransportTestSuite struct {
+	servers []*TssServer
+}
+
+var _ = Suite(&MemoryTransportTestSuite{})
+
+// setup four nodes talking over the memory network
+func (s *MemoryTransportTestSuite) SetUpTest(c *C) {
+	common.InitLog("info", true, "memory_transport_test")
+	conversion.SetupBech32Prefix()
+	preParams := getPreparams(c)
+	conf := common.TssConfig{
+		KeyGenTimeout:   60 * time.Second,
+		KeySignTimeout:  60 * time.Second,
+		PreParamTimeout: 5 * time.Second,
+	}
+	network := p2p.NewMemoryNetwork()
+	s.servers = make([]*TssServer, partyNum)
+	for i := 0; i < partyNum; i++ {
+		priKey, err := conversion.GetPriKey(testPriKeyArr[i])
+		c.Assert(err, IsNil)
+		s.servers[i], err = NewTssWithTransport(network.NewTransport(), priKey, c.MkDir(), conf, preParams[i])
+		c.Assert(err, IsNil)
+		c.Assert(s.servers[i].Start(), IsNil)
+	}
+	c.Assert(network.Peers(), HasLen, partyNum)
+}
+
+func (s *MemoryTransportTestSuite) TearDownTest(c *C) {
+	for _, el := range s.servers {
+		el.Stop()
+	}
+}
+
+func (s *MemoryTransportTestSuite) TestKeygenAndKeySign(c *C) {
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int]keygen.Response)
+	for i := 0; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keygen.NewRequest(append([]string{}, testPubKeys...), 10, "0.14.0")
+			res, err := s.servers[idx].Keygen(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	poolPubKey := keygenResult[0].PubKey
+	for _, item := range keygenResult {
+		c.Assert(item.PubKey, Equals, poolPubKey)
+	}
+
+	msgs := []string{base64.StdEncoding.EncodeToString(hash([]byte("helloworld"))), base64.StdEncoding.EncodeToString(hash([]byte("helloworld2")))}
+	keysignResult := make(map[int]keysign.Response)
+	for i := 0; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keysign.NewRequest(poolPubKey, msgs, 10, append([]string{}, testPubKeys...), "0.14.0")
+			res, err := s.servers[idx].KeySign(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	checkSignResult(c, keysignResult)
+}
+
+func (s *MemoryTransportTestSuite) TestAllowedPeers(c *C) {
+	// the parties only admit each other, and once they share a pool the participants of the pool are admitted
+	// without any allowed pub key
+	for _, el := range s.servers {
+		c.Assert(el.SetAllowedPeers(append([]string{}, testPubKeys...)), IsNil)
+	}
+	c.Assert(s.servers[0].SetAllowedPeers([]string{"whatever"}), NotNil)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int]keygen.Response)
+	for i := 0; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keygen.NewRequest(append([]string{}, testPubKeys...), 10, "0.14.0")
+			res, err := s.servers[idx].Keygen(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	poolPubKey := keygenResult[0].PubKey
+	for _, el := range s.servers {
+		c.Assert(el.SetAllowedPeers(nil), IsNil)
+	}
+
+	msgs := []string{base64.StdEncoding.EncodeToString(hash([]byte("helloworld"))), base64.StdEncoding.EncodeToString(hash([]byte("helloworld2")))}
+	keysignResult := make(map[int]keysign.Response)
+	for i := 0; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keysign.NewRequest(poolPubKey, msgs, 10, append([]string{}, testPubKeys...), "0.14.0")
+			res, err := s.servers[idx].KeySign(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	checkSignResult(c, keysignResult)
+}
+
+func (s *MemoryTransportTestSuite) TestGatedReShare(c *C) {
+	// no node is allowed beforehand, the participants of the keygen and the resharing are admitted while they are in
+	// progress, node 3 joins the pool by the resharing without being in any pool before
+	for _, el := range s.servers {
+		c.Assert(el.SetAllowedPeers(nil), IsNil)
+	}
+	oldKeys := append([]string{}, testPubKeys[:3]...)
+	newKeys := append([]string{}, testPubKeys[1:]...)
+	wg := sync.WaitGroup{}
+	lock := &sync.Mutex{}
+	keygenResult := make(map[int]keygen.Response)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keygen.NewRequest(append([]string{}, oldKeys...), 10, "0.14.0")
+			res, err := s.servers[idx].Keygen(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keygenResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	poolPubKey := keygenResult[0].PubKey
+	for _, item := range keygenResult {
+		c.Assert(item.Status, Equals, common.Success)
+		c.Assert(item.PubKey, Equals, poolPubKey)
+	}
+
+	reShareResult := make(map[int]resharing.Response)
+	for i := 0; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := resharing.NewRequest(poolPubKey, append([]string{}, oldKeys...), append([]string{}, newKeys...), 20, "0.14.0")
+			res, err := s.servers[idx].Reshare(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			reShareResult[idx] = res
+		}(i)
+	}
+	wg.Wait()
+	for _, item := range reShareResult {
+		c.Assert(item.Status, Equals, common.Success)
+		c.Assert(item.PubKey, Equals, poolPubKey)
+	}
+	for _, el := range s.servers {
+		c.Assert(el.allowlist.ceremonies, HasLen, 0)
+	}
+
+	// the new committee is admitted as the participants of the pool once the resharing is done
+	msgs := []string{base64.StdEncoding.EncodeToString(hash([]byte("helloworld"))), base64.StdEncoding.EncodeToString(hash([]byte("helloworld2")))}
+	keysignResult := make(map[int]keysign.Response)
+	for i := 1; i < partyNum; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			req := keysign.NewRequest(poolPubKey, msgs, 30, append([]string{}, newKeys...), "0.14.0")
+			res, err := s.servers[idx].KeySign(req)
+			c.Assert(err, IsNil)
+			lock.Lock()
+			defer lock.Unlock()
+			keysignResult[idx-1] = res
+		}(i)
+	}
+	wg.Wait()
+	checkSignResult(c, keysignResult)
+}
@@ -0,0 +1,23 @@
+package tss
+
+import (
+	"fmt"
+
+	tcrypto "github.com/tendermint/tendermint/crypto"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/keygen"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+// GeneratePreParams generate the Paillier pre-parameters into the local state of the node in the base folder till
+// num of them are saved, so the tss can join the keygens as soon as it starts. The tss must not be running
+func GeneratePreParams(baseFolder string, priKey tcrypto.PrivKey, conf common.TssConfig, num int) error {
+	return withLocalStateManager(baseFolder, priKey, conf, func(stateManager storage.LocalStateManager) error {
+		pool, err := keygen.NewPreParamsPool(stateManager, num, conf.PreParamsConcurrency, conf.PreParamTimeout)
+		if err != nil {
+			return fmt.Errorf("fail to create the pre-parameters pool: %w", err)
+		}
+		return pool.Fill(num)
+	})
+}
@@ -0,0 +1,138 @@
+package tss
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+)
+
+// PreSign generate presignatures of the pool with the given signers, all the signers must be online
+func (t *TssServer) PreSign(req keysign.PreSignRequest) (keysign.PreSignResponse, error) {
+	if req.Number <= 0 {
+		return keysign.PreSignResponse{}, errors.New("invalid number of presignatures")
+	}
+	if !t.isPartOfKeysignParty(req.SignerPubKeys) {
+		return keysign.PreSignResponse{}, errors.New("we are not one of the signers")
+	}
+	localStateItem, err := t.stateManager.GetLocalState(req.PoolPubKey)
+	if err != nil {
+		return keysign.PreSignResponse{}, fmt.Errorf("fail to get local keygen state: %w", err)
+	}
+	if localStateItem.GetAlgo() != common.ECDSA {
+		return keysign.PreSignResponse{}, fmt.Errorf("pool(%s) is an %s key, presignature is only supported by ecdsa", req.PoolPubKey, localStateItem.GetAlgo())
+	}
+	msgID, err := t.requestToMsgId(req)
+	if err != nil {
+		return keysign.PreSignResponse{}, err
+	}
+
+	preSignInstance := keysign.NewTssKeySign(
+		t.p2pCommunication.GetLocalPeerID(),
+		t.conf,
+		t.p2pCommunication.GetBroadcastChannel(),
+		t.stopChan,
+		msgID,
+		t.privateKey,
+		t.p2pCommunication,
+		t.stateManager,
+		req.Number,
+	)
+
+	preSignChannels := preSignInstance.GetTssKeySignChannels()
+	t.p2pCommunication.SetSubscribe(messages.TSSKeySignMsg, msgID, preSignChannels)
+	t.p2pCommunication.SetSubscribe(messages.TSSKeySignVerMsg, msgID, preSignChannels)
+	t.p2pCommunication.SetSubscribe(messages.TSSControlMsg, msgID, preSignChannels)
+	t.p2pCommunication.SetSubscribe(messages.TSSTaskDone, msgID, preSignChannels)
+
+	defer func() {
+		t.p2pCommunication.CancelSubscribe(messages.TSSKeySignMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSKeySignVerMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSControlMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSTaskDone, msgID)
+
+		t.p2pCommunication.ReleaseStream(msgID)
+		t.partyCoordinator.ReleaseStream(msgID)
+	}()
+	sigChan := make(chan string)
+	blameMgr := preSignInstance.GetTssCommonStruct().GetBlameMgr()
+	// the peers that keep flooding the ceremony are evidence for the blame
+	t.p2pCommunication.SetRateLimitHandler(msgID, blameMgr.AddRateLimitEvidence)
+	// the curve is held from before the party forms, so a node busy with the ceremonies of the other algorithm fails
+	// here instead of holding up the party
+	tssCommon := preSignInstance.GetTssCommonStruct()
+	if err := tssCommon.AcquireCurve(common.ECDSA, t.conf.PartyTimeout); err != nil {
+		t.logger.Error().Err(err).Msg("fail to acquire the curve for the presign")
+		return keysign.PreSignResponse{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer tssCommon.ReleaseCurve()
+	onlinePeers, leader, errJoinParty := t.joinParty(msgID, req.Version, req.BlockHeight, req.SignerPubKeys, len(req.SignerPubKeys)-1, sigChan)
+	if errJoinParty != nil {
+		// this indicate we are processing the leaderless join party
+		if leader == "NONE" {
+			if onlinePeers == nil {
+				t.logger.Error().Err(errJoinParty).Msg("error before we start join party")
+				return keysign.PreSignResponse{
+					Status: common.Fail,
+					Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+				}, nil
+			}
+			blameNodes, err := blameMgr.NodeSyncBlame(req.SignerPubKeys, onlinePeers)
+			if err != nil {
+				t.logger.Err(errJoinParty).Msg("fail to get peers to blame")
+			}
+			t.logger.Error().Err(errJoinParty).Msgf("fail to form presign party with online:%v", onlinePeers)
+			return keysign.PreSignResponse{
+				Status: common.Fail,
+				Blame:  blameNodes,
+			}, nil
+		}
+
+		var blameLeader blame.Blame
+		var blameNodes blame.Blame
+		blameNodes, err = blameMgr.NodeSyncBlame(req.SignerPubKeys, onlinePeers)
+		if err != nil {
+			t.logger.Err(errJoinParty).Msg("fail to get peers to blame")
+		}
+		leaderPubKey, err := conversion.GetPubKeyFromPeerID(leader)
+		if err != nil {
+			t.logger.Error().Err(errJoinParty).Msgf("fail to convert the peerID to public key with leader %s", leader)
+			blameLeader = blame.NewBlame(blame.TssSyncFail, []blame.Node{})
+		} else {
+			blameLeader = blame.NewBlame(blame.TssSyncFail, []blame.Node{blame.NewNode(leaderPubKey, nil, nil)})
+		}
+		if len(onlinePeers) != 0 {
+			blameNodes.AddBlameNodes(blameLeader.BlameNodes...)
+		} else {
+			blameNodes = blameLeader
+		}
+		t.logger.Error().Err(errJoinParty).Msgf("fail to form presign party with online:%v", onlinePeers)
+
+		return keysign.PreSignResponse{
+			Status: common.Fail,
+			Blame:  blameNodes,
+		}, nil
+	}
+
+	t.logger.Debug().Msg("presign party formed")
+	preSignatures, err := preSignInstance.PreSign(req.Number, localStateItem, req.SignerPubKeys)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("err in presign")
+		blameNodes := *blameMgr.GetBlame()
+		return keysign.NewPreSignResponse(nil, common.Fail, blameNodes), err
+	}
+	ids := make([]string, len(preSignatures))
+	for idx, el := range preSignatures {
+		ids[idx] = el.ID
+	}
+	sort.Strings(ids)
+	return keysign.NewPreSignResponse(ids, common.Success, blame.Blame{}), nil
+}
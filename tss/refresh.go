@@ -0,0 +1,33 @@
+package tss
+
+import (
+	"fmt"
+
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+)
+
+// Refresh re-randomises the shares of the pool among its participants, the pool key and the participants stay
+// the same, every participant of the pool has to join
+func (t *TssServer) Refresh(req resharing.RefreshRequest) (resharing.Response, error) {
+	t.tssKeyGenLocker.Lock()
+	defer t.tssKeyGenLocker.Unlock()
+	msgID, err := t.requestToMsgId(req)
+	if err != nil {
+		return resharing.Response{}, err
+	}
+	localState, err := t.stateManager.GetLocalState(req.PoolPubKey)
+	if err != nil {
+		return resharing.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, fmt.Errorf("fail to get local keygen state: %w", err)
+	}
+	reSharingReq := resharing.NewRequest(req.PoolPubKey, localState.ParticipantKeys, localState.ParticipantKeys, req.BlockHeight, req.Version)
+	info := localState.GetInfo()
+	reSharingReq.Threshold = localState.Threshold
+	reSharingReq.OldThreshold = info.Threshold
+	reSharingReq.KeyHeight = info.KeyHeight
+	return t.reshare(reSharingReq, msgID, true)
+}
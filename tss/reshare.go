@@ -0,0 +1,195 @@
+package tss
+
+import (
+	bcrypto "github.com/binance-chain/tss-lib/crypto"
+	bkeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
+
+	"gitlab.com/thorchain/tss/go-tss/blame"
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/messages"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+)
+
+// getReSharingParticipants return the keys of all the nodes join the resharing, a node can be in both committees
+func getReSharingParticipants(req resharing.Request) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, el := range append(append([]string{}, req.OldPartyKeys...), req.NewPartyKeys...) {
+		if seen[el] {
+			continue
+		}
+		seen[el] = true
+		keys = append(keys, el)
+	}
+	return keys
+}
+
+func (t *TssServer) Reshare(req resharing.Request) (resharing.Response, error) {
+	t.tssKeyGenLocker.Lock()
+	defer t.tssKeyGenLocker.Unlock()
+	msgID, err := t.requestToMsgId(req)
+	if err != nil {
+		return resharing.Response{}, err
+	}
+	return t.reshare(req, msgID, false)
+}
+
+// reshare runs the resharing of the request, a refresh is a resharing whose committees are both the participants
+// of the pool
+func (t *TssServer) reshare(req resharing.Request, msgID string, refresh bool) (resharing.Response, error) {
+	status := common.Success
+	var err error
+	// the nodes of the new committee get their shares with a fresh set of pre-parameters, they go back to the pool
+	// unless the resharing starts
+	var preParams *bkeygen.LocalPreParams
+	reSharingStarted := false
+	defer func() {
+		if !reSharingStarted && preParams != nil {
+			t.returnPreParams([]*bkeygen.LocalPreParams{preParams})
+		}
+	}()
+	for _, el := range req.NewPartyKeys {
+		if el != t.localNodePubKey {
+			continue
+		}
+		preParams, err = t.getPreParams()
+		if err != nil {
+			return resharing.Response{
+				Status: common.Fail,
+				Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+			}, err
+		}
+		break
+	}
+
+	reSharingInstance := resharing.NewTssReSharing(
+		t.p2pCommunication.GetLocalPeerID(),
+		t.conf,
+		t.localNodePubKey,
+		t.p2pCommunication.GetBroadcastChannel(),
+		t.stopChan,
+		preParams,
+		msgID,
+		t.stateManager,
+		t.privateKey,
+		t.p2pCommunication)
+
+	reSharingMsgChannel := reSharingInstance.GetTssReSharingChannels()
+	t.p2pCommunication.SetSubscribe(messages.TSSReSharingMsg, msgID, reSharingMsgChannel)
+	t.p2pCommunication.SetSubscribe(messages.TSSReSharingVerMsg, msgID, reSharingMsgChannel)
+	t.p2pCommunication.SetSubscribe(messages.TSSControlMsg, msgID, reSharingMsgChannel)
+	t.p2pCommunication.SetSubscribe(messages.TSSTaskDone, msgID, reSharingMsgChannel)
+
+	defer func() {
+		t.p2pCommunication.CancelSubscribe(messages.TSSReSharingMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSReSharingVerMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSControlMsg, msgID)
+		t.p2pCommunication.CancelSubscribe(messages.TSSTaskDone, msgID)
+
+		t.p2pCommunication.ReleaseStream(msgID)
+		t.partyCoordinator.ReleaseStream(msgID)
+	}()
+	sigChan := make(chan string)
+	blameMgr := reSharingInstance.GetTssCommonStruct().GetBlameMgr()
+	// the peers that keep flooding the ceremony are evidence for the blame
+	t.p2pCommunication.SetRateLimitHandler(msgID, blameMgr.AddRateLimitEvidence)
+	// the participants are admitted by the connection gating before the party forms, the nodes of the new committee
+	// that are in no pool yet included, once the resharing is done the new committee of the pool stays admitted
+	participants := getReSharingParticipants(req)
+	if err := t.admitParticipants(msgID, participants); err != nil {
+		return resharing.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer t.releaseParticipants(msgID)
+	// the curve is held from before the party forms, so a node busy with the ceremonies of the other algorithm fails
+	// here instead of holding up the party, the resharing releases it once its local parties are done
+	tssCommon := reSharingInstance.GetTssCommonStruct()
+	if err := tssCommon.AcquireCurve(common.ECDSA, t.conf.PartyTimeout); err != nil {
+		t.logger.Error().Err(err).Msg("fail to acquire the curve for the resharing")
+		return resharing.Response{
+			Status: common.Fail,
+			Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+		}, err
+	}
+	defer tssCommon.ReleaseCurve()
+	onlinePeers, leader, errJoinParty := t.joinParty(msgID, req.Version, req.BlockHeight, participants, len(participants)-1, sigChan)
+	if errJoinParty != nil {
+		// this indicate we are processing the leaderless join party
+		if leader == "NONE" {
+			if onlinePeers == nil {
+				t.logger.Error().Err(errJoinParty).Msg("error before we start join party")
+				return resharing.Response{
+					Status: common.Fail,
+					Blame:  blame.NewBlame(blame.InternalError, []blame.Node{}),
+				}, nil
+			}
+			blameNodes, err := blameMgr.NodeSyncBlame(participants, onlinePeers)
+			if err != nil {
+				t.logger.Err(errJoinParty).Msg("fail to get peers to blame")
+			}
+			t.logger.Error().Err(errJoinParty).Msgf("fail to form resharing party with online:%v", onlinePeers)
+			return resharing.Response{
+				Status: common.Fail,
+				Blame:  blameNodes,
+			}, nil
+		}
+
+		var blameLeader blame.Blame
+		var blameNodes blame.Blame
+		blameNodes, err = blameMgr.NodeSyncBlame(participants, onlinePeers)
+		if err != nil {
+			t.logger.Err(errJoinParty).Msg("fail to get peers to blame")
+		}
+		leaderPubKey, err := conversion.GetPubKeyFromPeerID(leader)
+		if err != nil {
+			t.logger.Error().Err(errJoinParty).Msgf("fail to convert the peerID to public key with leader %s", leader)
+			blameLeader = blame.NewBlame(blame.TssSyncFail, []blame.Node{})
+		} else {
+			blameLeader = blame.NewBlame(blame.TssSyncFail, []blame.Node{blame.NewNode(leaderPubKey, nil, nil)})
+		}
+		if len(onlinePeers) != 0 {
+			blameNodes.AddBlameNodes(blameLeader.BlameNodes...)
+		} else {
+			blameNodes = blameLeader
+		}
+		t.logger.Error().Err(errJoinParty).Msgf("fail to form resharing party with online:%v", onlinePeers)
+
+		return resharing.Response{
+			Status: common.Fail,
+			Blame:  blameNodes,
+		}, nil
+	}
+
+	t.logger.Debug().Msg("resharing party formed")
+	var k *bcrypto.ECPoint
+	reSharingStarted = true
+	if refresh {
+		k, err = reSharingInstance.Refresh(req)
+	} else {
+		k, err = reSharingInstance.ReShare(req)
+	}
+	// the local parties are done, the curve is not held while the local states are read for the allowed peers
+	tssCommon.ReleaseCurve()
+	if err != nil {
+		t.logger.Error().Err(err).Msg("err in resharing")
+		blameNodes := *blameMgr.GetBlame()
+		return resharing.NewResponse("", "", common.Fail, blameNodes), err
+	}
+
+	pubKey, addr, err := conversion.GetTssPubKey(k)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to get the reshared Tss key")
+		status = common.Fail
+	}
+
+	blameNodes := *blameMgr.GetBlame()
+	return resharing.NewResponse(
+		pubKey,
+		addr.String(),
+		status,
+		blameNodes,
+	), nil
+}
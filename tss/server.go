@@ -3,6 +3,8 @@ package tss
 import (
 	"gitlab.com/thorchain/tss/go-tss/keygen"
 	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
 // Server define the necessary functionality should be provide by a TSS Server implementation
@@ -12,4 +14,17 @@ type Server interface {
 	GetLocalPeerID() string
 	Keygen(req keygen.Request) (keygen.Response, error)
 	KeySign(req keysign.Request) (keysign.Response, error)
+	Reshare(req resharing.Request) (resharing.Response, error)
+	Refresh(req resharing.RefreshRequest) (resharing.Response, error)
+	PreSign(req keysign.PreSignRequest) (keysign.PreSignResponse, error)
+	GetPreParamsBuffered() int
+	ListLocalStates() ([]storage.LocalStateInfo, error)
+	GetLocalStateInfo(pubKey string) (storage.LocalStateInfo, error)
+	RetireLocalState(pubKey string) error
+	GetLocalStateHistory(pubKey string) ([]storage.LocalStateVersion, error)
+	PinLocalState(pubKey string, generation int64) error
+	RollbackLocalState(pubKey string) (storage.LocalStateVersion, error)
+	VerifyLocalState(pubKey string) (storage.ShareVerification, error)
+	VerifyLocalStates() ([]storage.ShareVerification, error)
+	SetAllowedPeers(pubKeys []string) error
 }
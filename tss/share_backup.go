@@ -0,0 +1,59 @@
+package tss
+
+import (
+	"fmt"
+
+	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	tcrypto "github.com/tendermint/tendermint/crypto"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+// ExportShare create the backup of the share of the node in the given pool encrypted with the passphrase, the
+// backup only carries the share of this node, the private key of the pool is never reconstructed
+func ExportShare(baseFolder string, priKey tcrypto.PrivKey, conf common.TssConfig, pubKey, passphrase string) ([]byte, error) {
+	var buf []byte
+	err := withLocalStateManager(baseFolder, priKey, conf, func(stateManager storage.LocalStateManager) error {
+		localState, err := stateManager.GetLocalState(pubKey)
+		if err != nil {
+			return fmt.Errorf("fail to get the local state of %s: %w", pubKey, err)
+		}
+		buf, err = storage.NewShareBackup(localState, passphrase)
+		if err != nil {
+			return fmt.Errorf("fail to create the backup of the local state of %s: %w", pubKey, err)
+		}
+		return nil
+	})
+	return buf, err
+}
+
+// ImportShare restore the share in the backup into the local state of the node in the base folder, the share
+// must be valid and belong to this node. It returns the pub key of the pool. The tss must not be running
+func ImportShare(baseFolder string, priKey tcrypto.PrivKey, conf common.TssConfig, buf []byte, passphrase string) (string, error) {
+	localState, err := storage.OpenShareBackup(buf, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("fail to open the share backup: %w", err)
+	}
+	pk := coskey.PubKey{
+		Key: priKey.PubKey().Bytes()[:],
+	}
+	localPubKey, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &pk)
+	if err != nil {
+		return "", fmt.Errorf("fail to genearte the key: %w", err)
+	}
+	if localState.LocalPartyKey != localPubKey {
+		return "", fmt.Errorf("the share of %s belongs to %s, not this node", localState.PubKey, localState.LocalPartyKey)
+	}
+	err = withLocalStateManager(baseFolder, priKey, conf, func(stateManager storage.LocalStateManager) error {
+		if err := stateManager.SaveLocalState(localState); err != nil {
+			return fmt.Errorf("fail to save the local state of %s: %w", localState.PubKey, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return localState.PubKey, nil
+}
@@ -0,0 +1,57 @@
+package tss
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/common"
+	"gitlab.com/thorchain/tss/go-tss/conversion"
+	"gitlab.com/thorchain/tss/go-tss/storage"
+)
+
+type ShareBackupTestSuite struct{}
+
+var _ = Suite(&ShareBackupTestSuite{})
+
+func (s *ShareBackupTestSuite) SetUpSuite(c *C) {
+	conversion.SetupBech32Prefix()
+}
+
+func (s *ShareBackupTestSuite) TestExportImportShare(c *C) {
+	buf, err := ioutil.ReadFile(path.Join(testFileLocation, "keysign_data", "0.json"))
+	c.Assert(err, IsNil)
+	var localState storage.KeygenLocalState
+	c.Assert(json.Unmarshal(buf, &localState), IsNil)
+	// the share belongs to the third test node
+	c.Assert(localState.LocalPartyKey, Equals, testPubKeys[2])
+	priKey, err := conversion.GetPriKey(testPriKeyArr[2])
+	c.Assert(err, IsNil)
+	otherPriKey, err := conversion.GetPriKey(testPriKeyArr[0])
+	c.Assert(err, IsNil)
+	backup, err := storage.NewShareBackup(localState, "the passphrase")
+	c.Assert(err, IsNil)
+
+	for _, backend := range []string{storage.BackendFile, storage.BackendLevelDB} {
+		folder := c.MkDir()
+		conf := common.TssConfig{StateBackend: backend}
+		_, err = ExportShare(folder, priKey, conf, localState.PubKey, "the passphrase")
+		c.Assert(err, NotNil)
+
+		_, err = ImportShare(folder, otherPriKey, conf, backup, "the passphrase")
+		c.Assert(err, ErrorMatches, ".* not this node")
+		_, err = ImportShare(folder, priKey, conf, backup, "another passphrase")
+		c.Assert(err, NotNil)
+		pubKey, err := ImportShare(folder, priKey, conf, backup, "the passphrase")
+		c.Assert(err, IsNil)
+		c.Assert(pubKey, Equals, localState.PubKey)
+
+		exported, err := ExportShare(folder, priKey, conf, pubKey, "new passphrase")
+		c.Assert(err, IsNil)
+		restored, err := storage.OpenShareBackup(exported, "new passphrase")
+		c.Assert(err, IsNil)
+		c.Assert(restored.LocalData.Xi, DeepEquals, localState.LocalData.Xi)
+	}
+}
@@ -3,10 +3,12 @@ package tss
 import (
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/sha3"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	bkeygen "github.com/binance-chain/tss-lib/ecdsa/keygen"
 	coskey "github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
@@ -24,6 +26,7 @@ import (
 	"gitlab.com/thorchain/tss/go-tss/messages"
 	"gitlab.com/thorchain/tss/go-tss/monitor"
 	"gitlab.com/thorchain/tss/go-tss/p2p"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
 	"gitlab.com/thorchain/tss/go-tss/storage"
 )
 
@@ -31,9 +34,10 @@ import (
 type TssServer struct {
 	conf              common.TssConfig
 	logger            zerolog.Logger
-	p2pCommunication  *p2p.Communication
+	p2pCommunication  p2p.Transport
 	localNodePubKey   string
 	preParams         *bkeygen.LocalPreParams
+	preParamsPool     *keygen.PreParamsPool
 	tssKeyGenLocker   *sync.Mutex
 	stopChan          chan struct{}
 	partyCoordinator  *p2p.PartyCoordinator
@@ -41,6 +45,7 @@ type TssServer struct {
 	signatureNotifier *keysign.SignatureNotifier
 	privateKey        tcrypto.PrivKey
 	tssMetrics        *monitor.Metric
+	allowlist         allowlist
 }
 
 // NewTss create a new instance of Tss
@@ -53,70 +58,105 @@ func NewTss(
 	conf common.TssConfig,
 	preParams *bkeygen.LocalPreParams,
 	externalIP string,
+	broadcastMode p2p.BroadcastMode,
 ) (*TssServer, error) {
-	pk := coskey.PubKey{
-		Key: priKey.PubKey().Bytes()[:],
+	stateManager, err := newLocalStateManager(baseFolder, priKey, conf)
+	if err != nil {
+		return nil, err
 	}
 
-	pubKey, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &pk)
+	// the peers in the saved address book are dialed together with the bootstrap peers, best scored first
+	addressBook, err := stateManager.GetAddressBook()
 	if err != nil {
-		return nil, fmt.Errorf("fail to genearte the key: %w", err)
+		log.Error().Err(err).Msg("fail to load the address book")
+		addressBook = p2p.NewAddressBook()
 	}
-
-	h := sha3.New256()
-	h.Write(priKey.Bytes())
-	aesKey := h.Sum(nil)
-	stateManager, err := storage.NewFileStateMgr(baseFolder, aesKey)
+	addressBook.Prune(time.Now(), p2p.DefaultAddressBookMaxAge)
+	comm, err := p2p.NewCommunication(rendezvous, cmdBootstrapPeers, p2pPort, externalIP)
 	if err != nil {
-		return nil, fmt.Errorf("fail to create file state manager")
+		return nil, fmt.Errorf("fail to create communication layer: %w", err)
 	}
-
-	var bootstrapPeers addr.AddrList
-	savedPeers, err := stateManager.RetrieveP2PAddresses()
+	comm.SetAddressBook(addressBook)
+	if err := comm.SetBroadcastMode(broadcastMode); err != nil {
+		return nil, err
+	}
+	// the stream events of the p2p layer are counted with the rest of the tss metrics
+	metrics := monitor.NewMetric()
+	comm.SetMetric(metrics)
+	tssServer, err := newTss(comm, stateManager, priKey, conf, preParams, metrics)
 	if err != nil {
-		bootstrapPeers = cmdBootstrapPeers
-	} else {
-		bootstrapPeers = savedPeers
-		bootstrapPeers = append(bootstrapPeers, cmdBootstrapPeers...)
+		return nil, err
+	}
+	if err := stateManager.SaveAddressBook(comm.ExportAddressBook()); err != nil {
+		log.Error().Err(err).Msg("fail to save the address book")
 	}
-	comm, err := p2p.NewCommunication(rendezvous, bootstrapPeers, p2pPort, externalIP)
+	return tssServer, nil
+}
+
+// NewTssWithTransport create a new instance of Tss that talks to the other parties over the given transport, which
+// is started with the node key. A p2p.MemoryNetwork runs several parties in the same process without any socket
+func NewTssWithTransport(
+	transport p2p.Transport,
+	priKey tcrypto.PrivKey,
+	baseFolder string,
+	conf common.TssConfig,
+	preParams *bkeygen.LocalPreParams,
+) (*TssServer, error) {
+	stateManager, err := newLocalStateManager(baseFolder, priKey, conf)
 	if err != nil {
-		return nil, fmt.Errorf("fail to create communication layer: %w", err)
+		return nil, err
 	}
-	// When using the keygen party it is recommended that you pre-compute the
-	// "safe primes" and Paillier secret beforehand because this can take some
-	// time.
-	// This code will generate those parameters using a concurrency limit equal
-	// to the number of available CPU cores.
-	if preParams == nil || !preParams.Validate() {
-		preParams, err = bkeygen.GeneratePreParams(conf.PreParamTimeout)
-		if err != nil {
-			return nil, fmt.Errorf("fail to generate pre parameters: %w", err)
-		}
+	return newTss(transport, stateManager, priKey, conf, preParams, monitor.NewMetric())
+}
+
+func newTss(
+	transport p2p.Transport,
+	stateManager storage.LocalStateManager,
+	priKey tcrypto.PrivKey,
+	conf common.TssConfig,
+	preParams *bkeygen.LocalPreParams,
+	metrics *monitor.Metric,
+) (*TssServer, error) {
+	pk := coskey.PubKey{
+		Key: priKey.PubKey().Bytes()[:],
 	}
-	if !preParams.Validate() {
+
+	pubKey, err := sdk.Bech32ifyPubKey(sdk.Bech32PubKeyTypeAccPub, &pk)
+	if err != nil {
+		return nil, fmt.Errorf("fail to genearte the key: %w", err)
+	}
+
+	// The "safe primes" and Paillier secret take some time to compute, the pool
+	// generates them in the background so that every keygen gets a fresh set.
+	// The given preParams, if any, are used by every keygen instead, which is
+	// only meant for tests.
+	if preParams != nil && !preParams.Validate() {
 		return nil, errors.New("invalid preparams")
 	}
+	preParamsPool, err := keygen.NewPreParamsPool(stateManager, conf.PreParamsPoolSize, conf.PreParamsConcurrency, conf.PreParamTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create the pre-parameters pool: %w", err)
+	}
 
 	priKeyRawBytes, err := conversion.GetPriKeyRawBytes(priKey)
 	if err != nil {
 		return nil, fmt.Errorf("fail to get private key")
 	}
-	if err := comm.Start(priKeyRawBytes); nil != err {
+	if err := transport.Start(priKeyRawBytes); nil != err {
 		return nil, fmt.Errorf("fail to start p2p network: %w", err)
 	}
-	pc := p2p.NewPartyCoordinator(comm.GetHost(), conf.PartyTimeout)
-	sn := keysign.NewSignatureNotifier(comm.GetHost())
-	metrics := monitor.NewMetric()
+	pc := p2p.NewPartyCoordinator(transport.GetStreamHost(), conf.PartyTimeout)
+	sn := keysign.NewSignatureNotifier(transport.GetStreamHost())
 	if conf.EnableMonitor {
 		metrics.Enable()
 	}
 	tssServer := TssServer{
 		conf:              conf,
 		logger:            log.With().Str("module", "tss").Logger(),
-		p2pCommunication:  comm,
+		p2pCommunication:  transport,
 		localNodePubKey:   pubKey,
 		preParams:         preParams,
+		preParamsPool:     preParamsPool,
 		tssKeyGenLocker:   &sync.Mutex{},
 		stopChan:          make(chan struct{}),
 		partyCoordinator:  pc,
@@ -124,39 +164,170 @@ func NewTss(
 		signatureNotifier: sn,
 		privateKey:        priKey,
 		tssMetrics:        metrics,
+		allowlist:         allowlist{lock: &sync.Mutex{}},
 	}
 
 	return &tssServer, nil
 }
 
+// newLocalStateManager create the local state manager of the node in the base folder
+func newLocalStateManager(baseFolder string, priKey tcrypto.PrivKey, conf common.TssConfig) (storage.LocalStateManager, error) {
+	aesKey, err := storage.GetLocalStateKey(baseFolder, priKey, conf.StatePassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get the local state key: %w", err)
+	}
+	stateManager, err := storage.NewLocalStateManager(conf.StateBackend, baseFolder, aesKey, conf.StateBackups)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create the local state manager: %w", err)
+	}
+	return stateManager, nil
+}
+
+// withLocalStateManager run fn with the local state manager of the node in the base folder, it is used by the
+// tools that work on the local state while the tss is not running
+func withLocalStateManager(baseFolder string, priKey tcrypto.PrivKey, conf common.TssConfig, fn func(stateManager storage.LocalStateManager) error) (err error) {
+	stateManager, err := newLocalStateManager(baseFolder, priKey, conf)
+	if err != nil {
+		return err
+	}
+	if closer, ok := stateManager.(io.Closer); ok {
+		defer func() {
+			if closeErr := closer.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("fail to close the local state manager: %w", closeErr)
+			}
+		}()
+	}
+	return fn(stateManager)
+}
+
 // Start Tss server
 func (t *TssServer) Start() error {
 	log.Info().Msg("Starting the TSS servers")
+	if t.preParams == nil {
+		t.preParamsPool.Start()
+	}
+	if t.conf.ShareVerifyInterval > 0 {
+		go t.verifyLocalStatesPeriodically(t.conf.ShareVerifyInterval)
+	}
 	return nil
 }
 
 // Stop Tss server
 func (t *TssServer) Stop() {
 	close(t.stopChan)
+	if t.preParams == nil {
+		t.preParamsPool.Stop()
+	}
 	// stop the p2p and finish the p2p wait group
 	err := t.p2pCommunication.Stop()
 	if err != nil {
 		t.logger.Error().Msgf("error in shutdown the p2p server")
 	}
 	t.partyCoordinator.Stop()
+	// the database backends hold the database open till the server stops
+	if closer, ok := t.stateManager.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			t.logger.Error().Err(err).Msg("fail to close the local state manager")
+		}
+	}
 	log.Info().Msg("The Tss and p2p server has been stopped successfully")
 }
 
+// GetPreParamsBuffered return the number of pre-parameters buffered for the coming keygens
+func (t *TssServer) GetPreParamsBuffered() int {
+	return t.preParamsPool.Size()
+}
+
+// getPreParams return the pre-parameters a keygen should use, a fresh set is taken from the pool unless fixed
+// pre-parameters are given
+func (t *TssServer) getPreParams() (*bkeygen.LocalPreParams, error) {
+	if t.preParams != nil {
+		return t.preParams, nil
+	}
+	return t.preParamsPool.Take()
+}
+
+// returnPreParams put the pre-parameters taken for a keygen that never starts back to the pool
+func (t *TssServer) returnPreParams(preParams []*bkeygen.LocalPreParams) {
+	if t.preParams != nil {
+		return
+	}
+	if err := t.preParamsPool.Return(preParams); err != nil {
+		t.logger.Error().Err(err).Msg("fail to return the pre-parameters to the pool")
+	}
+}
+
+// getExplicitSigners return the given number of signers, or 0 if it is the default of the given number of parties,
+// so the callers that name the default threshold and the ones that leave it out meet in the same ceremony
+func getExplicitSigners(parties, signers int) int {
+	threshold, err := conversion.GetThreshold(parties)
+	if err != nil || signers == threshold+1 {
+		return 0
+	}
+	return signers
+}
+
 func (t *TssServer) requestToMsgId(request interface{}) (string, error) {
 	var dat []byte
 	var keys []string
 	switch value := request.(type) {
 	case keygen.Request:
 		keys = value.Keys
+		// ECDSA leaves the msg id as it was before EdDSA is supported
+		if value.Algo == common.EdDSA {
+			dat = []byte(value.Algo)
+		}
+		// a single key leaves the msg id as it was before the batch keygen is supported
+		if value.GetKeyCount() > 1 {
+			dat = append(dat, []byte("keys"+strconv.Itoa(value.GetKeyCount()))...)
+		}
+		// the parties given different thresholds must not join the same keygen, the default leaves the msg id as it was
+		if signers := getExplicitSigners(len(value.Keys), value.Threshold); signers > 0 {
+			dat = append(dat, []byte("threshold"+strconv.Itoa(signers))...)
+		}
 	case keysign.Request:
 		sort.Strings(value.Messages)
 		dat = []byte(strings.Join(value.Messages, ","))
 		keys = value.SignerPubKeys
+		if value.Algo == common.EdDSA {
+			dat = append(dat, []byte(value.Algo)...)
+		}
+		// the messages hashed in different ways are signed apart, raw leaves the msg id as it was
+		if hashMode, _ := common.GetHashMode(value.HashMode); hashMode != common.HashRaw {
+			dat = append(dat, []byte(hashMode)...)
+		}
+		// the child keys of the pool sign the same messages apart
+		if len(value.DerivationPath) > 0 {
+			dat = append(dat, []byte(value.ChainCode+value.DerivationPath)...)
+		}
+		// the signers agree on the presignatures to sign with through the msg id
+		if len(value.PreSignIDs) > 0 {
+			dat = append(dat, []byte("presign"+strings.Join(value.PreSignIDs, ","))...)
+		}
+	case keysign.PreSignRequest:
+		// the same signers may generate presignatures of the pool many times, the block height tells them apart
+		dat = []byte("presign" + value.PoolPubKey + strconv.FormatInt(value.BlockHeight, 10))
+		keys = append([]string{}, value.SignerPubKeys...)
+	case resharing.Request:
+		// the committees are part of the msg id, as the same node may play in either of them
+		oldKeys := append([]string{}, value.OldPartyKeys...)
+		sort.Strings(oldKeys)
+		dat = []byte("resharing" + value.PoolPubKey + strings.Join(oldKeys, ",") + strconv.FormatInt(value.BlockHeight, 10))
+		if signers := getExplicitSigners(len(value.NewPartyKeys), value.Threshold); signers > 0 {
+			dat = append(dat, []byte("threshold"+strconv.Itoa(signers))...)
+		}
+		if signers := getExplicitSigners(len(value.OldPartyKeys), value.OldThreshold); signers > 0 {
+			dat = append(dat, []byte("oldthreshold"+strconv.Itoa(signers))...)
+		}
+		// the nodes given different key heights identify the old parties apart, a pool never reshared leaves the
+		// msg id as it was
+		if value.KeyHeight > 0 {
+			dat = append(dat, []byte("keyheight"+strconv.FormatInt(value.KeyHeight, 10))...)
+		}
+		keys = append([]string{}, value.NewPartyKeys...)
+	case resharing.RefreshRequest:
+		// all the participants of the pool join the refresh, they are the same on every node
+		dat = []byte("refresh" + value.PoolPubKey + strconv.FormatInt(value.BlockHeight, 10))
 	default:
 		t.logger.Error().Msg("unknown request type")
 		return "", errors.New("unknown request type")
@@ -0,0 +1,73 @@
+package tss
+
+import (
+	. "gopkg.in/check.v1"
+
+	"gitlab.com/thorchain/tss/go-tss/keygen"
+	"gitlab.com/thorchain/tss/go-tss/keysign"
+	"gitlab.com/thorchain/tss/go-tss/resharing"
+)
+
+type MsgIDTestSuite struct{}
+
+var _ = Suite(&MsgIDTestSuite{})
+
+func (s *MsgIDTestSuite) TestRequestToMsgID(c *C) {
+	t := &TssServer{}
+	keygenReq := keygen.NewRequest(append([]string{}, testPubKeys...), 10, "0.14.0")
+	defaultID, err := t.requestToMsgId(keygenReq)
+	c.Assert(err, IsNil)
+	keygenReq.Threshold = 2
+	thresholdID, err := t.requestToMsgId(keygenReq)
+	c.Assert(err, IsNil)
+	c.Assert(thresholdID, Not(Equals), defaultID)
+	keygenReq.Threshold = 4
+	otherThresholdID, err := t.requestToMsgId(keygenReq)
+	c.Assert(err, IsNil)
+	c.Assert(otherThresholdID, Not(Equals), thresholdID)
+	c.Assert(otherThresholdID, Not(Equals), defaultID)
+	// the default threshold named explicitly is the same keygen
+	keygenReq.Threshold = 3
+	explicitDefaultID, err := t.requestToMsgId(keygenReq)
+	c.Assert(err, IsNil)
+	c.Assert(explicitDefaultID, Equals, defaultID)
+
+	// the resharing of the same committees at another block height, with other thresholds or of a key reshared at
+	// another height is another ceremony
+	reshareReq := resharing.NewRequest("pool", append([]string{}, testPubKeys[:3]...), append([]string{}, testPubKeys...), 10, "0.14.0")
+	reshareID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	reshareReq.BlockHeight = 11
+	heightID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	c.Assert(heightID, Not(Equals), reshareID)
+	reshareReq.Threshold = 2
+	reshareThresholdID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	c.Assert(reshareThresholdID, Not(Equals), heightID)
+	reshareReq.OldThreshold = 2
+	explicitOldThresholdID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	c.Assert(explicitOldThresholdID, Equals, reshareThresholdID)
+	reshareReq.OldThreshold = 3
+	oldThresholdID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	c.Assert(oldThresholdID, Not(Equals), reshareThresholdID)
+	reshareReq.KeyHeight = 5
+	keyHeightID, err := t.requestToMsgId(reshareReq)
+	c.Assert(err, IsNil)
+	c.Assert(keyHeightID, Not(Equals), oldThresholdID)
+
+	// the signers only meet when they sign with the same presignatures
+	keysignReq := keysign.NewRequest("pool", []string{"bWVzc2FnZQ=="}, 10, append([]string{}, testPubKeys...), "0.14.0")
+	keysignID, err := t.requestToMsgId(keysignReq)
+	c.Assert(err, IsNil)
+	keysignReq.PreSignIDs = []string{"1"}
+	preSignID, err := t.requestToMsgId(keysignReq)
+	c.Assert(err, IsNil)
+	c.Assert(preSignID, Not(Equals), keysignID)
+	keysignReq.PreSignIDs = []string{"2"}
+	otherPreSignID, err := t.requestToMsgId(keysignReq)
+	c.Assert(err, IsNil)
+	c.Assert(otherPreSignID, Not(Equals), preSignID)
+}